@@ -0,0 +1,101 @@
+package americanexpress
+
+import (
+	"encoding/json"
+	"strconv"
+)
+
+// formatAmount formats an amount as a decimal string with the number of
+// decimal places used by the currency, e.g. "100.10" USD or "1000" JPY.
+// Currencies that are not known use two decimal places.
+func formatAmount(amount float64, currency string) string {
+	return strconv.FormatFloat(amount, 'f', CurrencyExponent(currency), 64)
+}
+
+// MarshalJSON encodes the request, sending the amount, any split amounts,
+// the tax and surcharge breakdown and the settlement amount as decimal
+// strings when the client has DecimalStringAmounts enabled. The descriptor
+// fields are grouped in a descriptor object.
+func (r TransactionRequest) MarshalJSON() ([]byte, error) {
+	type alias TransactionRequest
+	if !r.decimalStringAmounts {
+		return json.Marshal(struct {
+			alias
+			Descriptor *transactionDescriptor `json:"descriptor,omitempty"`
+		}{alias(r), r.descriptor()})
+	}
+	return json.Marshal(struct {
+		alias
+		Amount           string                 `json:"amount"`
+		Splits           []decimalSplit         `json:"splits,omitempty"`
+		BaseAmount       *string                `json:"base_amount,omitempty"`
+		TaxAmount        *string                `json:"tax_amount,omitempty"`
+		SurchargeAmount  *string                `json:"surcharge_amount,omitempty"`
+		SettlementAmount *string                `json:"settlement_amount,omitempty"`
+		Descriptor       *transactionDescriptor `json:"descriptor,omitempty"`
+	}{
+		alias(r), formatAmount(r.Amount, r.Currency), formatSplits(r.Splits, r.Currency),
+		formatOptionalAmount(r.BaseAmount, r.Currency), formatOptionalAmount(r.TaxAmount, r.Currency), formatOptionalAmount(r.SurchargeAmount, r.Currency),
+		formatOptionalAmount(r.SettlementAmount, r.SettlementCurrency), r.descriptor(),
+	})
+}
+
+// MarshalJSON encodes the request, sending the amount, any split amounts
+// and the tax and surcharge breakdown as decimal strings when the client has
+// DecimalStringAmounts enabled
+func (r PaymentRequest) MarshalJSON() ([]byte, error) {
+	type alias PaymentRequest
+	if !r.decimalStringAmounts {
+		return json.Marshal(alias(r))
+	}
+	return json.Marshal(struct {
+		alias
+		Amount          string         `json:"amount"`
+		Splits          []decimalSplit `json:"splits,omitempty"`
+		BaseAmount      *string        `json:"base_amount,omitempty"`
+		TaxAmount       *string        `json:"tax_amount,omitempty"`
+		SurchargeAmount *string        `json:"surcharge_amount,omitempty"`
+	}{
+		alias(r), formatAmount(r.Amount, r.Currency), formatSplits(r.Splits, r.Currency),
+		formatOptionalAmount(r.BaseAmount, r.Currency), formatOptionalAmount(r.TaxAmount, r.Currency), formatOptionalAmount(r.SurchargeAmount, r.Currency),
+	})
+}
+
+// MarshalJSON encodes the request, sending the amount, tip breakdown and
+// line item amounts as decimal strings when the client has
+// DecimalStringAmounts enabled
+func (r CaptureTransactionRequest) MarshalJSON() ([]byte, error) {
+	type alias CaptureTransactionRequest
+	if !r.decimalStringAmounts {
+		return json.Marshal(alias(r))
+	}
+	return json.Marshal(struct {
+		alias
+		Amount        *string           `json:"amount,omitempty"`
+		BaseAmount    *string           `json:"base_amount,omitempty"`
+		TipAdjustment *string           `json:"tip_amount,omitempty"`
+		LineItems     []decimalLineItem `json:"line_items,omitempty"`
+	}{alias(r), formatOptionalAmount(r.Amount, r.Currency), formatOptionalAmount(r.BaseAmount, r.Currency), formatOptionalAmount(r.TipAdjustment, r.Currency), formatLineItems(r.LineItems, r.Currency)})
+}
+
+// formatOptionalAmount formats an amount that may be unset
+func formatOptionalAmount(amount *float64, currency string) *string {
+	if amount == nil {
+		return nil
+	}
+	s := formatAmount(*amount, currency)
+	return &s
+}
+
+// MarshalJSON encodes the request, sending the amount as a decimal string
+// when the client has DecimalStringAmounts enabled
+func (r RefundTransactionRequest) MarshalJSON() ([]byte, error) {
+	type alias RefundTransactionRequest
+	if !r.decimalStringAmounts {
+		return json.Marshal(alias(r))
+	}
+	return json.Marshal(struct {
+		alias
+		Amount string `json:"amount"`
+	}{alias(r), formatAmount(r.Amount, r.Currency)})
+}
@@ -0,0 +1,81 @@
+package americanexpress
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// DefaultAsyncPollInterval is how often ProcessAsync polls the status of a
+// pending transaction
+const DefaultAsyncPollInterval = 2 * time.Second
+
+// AsyncTransaction is a handle on a transaction being processed by
+// ProcessAsync
+type AsyncTransaction struct {
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// Cancel stops processing. Unless it has already fired, the callback is
+// invoked with context.Canceled. An authorization already sent is not
+// voided.
+func (at *AsyncTransaction) Cancel() {
+	at.cancel()
+}
+
+// Done returns a channel that is closed once the callback has returned
+func (at *AsyncTransaction) Done() <-chan struct{} {
+	return at.done
+}
+
+// Wait blocks until the callback has returned
+func (at *AsyncTransaction) Wait() {
+	<-at.done
+}
+
+// ProcessAsync authorizes a transaction in the background, for high-value
+// transactions the gateway processes asynchronously. While the transaction
+// is pending its status is polled every Config.AsyncPollInterval; once it
+// leaves pending, e.g. as authorized, declined or failed, the transaction is
+// fetched and passed to callback.
+//
+// The callback fires exactly once, from another goroutine: with the
+// transaction, or with the error that ended processing, including
+// validation errors and the context error when ctx is done or the handle
+// is canceled. No goroutine outlives the callback.
+func (ts *TransactionService) ProcessAsync(ctx context.Context, req *TransactionRequest, callback func(*TransactionResponse, error)) *AsyncTransaction {
+	ctx, cancel := context.WithCancel(ctx)
+	handle := &AsyncTransaction{cancel: cancel, done: make(chan struct{})}
+
+	go func() {
+		defer close(handle.done)
+		defer cancel()
+		callback(ts.processAsync(ctx, req))
+	}()
+	return handle
+}
+
+// processAsync authorizes a transaction and polls it until it is no longer
+// pending
+func (ts *TransactionService) processAsync(ctx context.Context, req *TransactionRequest) (*TransactionResponse, error) {
+	transaction, err := ts.AuthorizeTransaction(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	for transaction.Status == TransactionStatusPending {
+		if err := wait(ctx, ts.client.asyncPoll); err != nil {
+			return nil, fmt.Errorf("stopped waiting for transaction %s: %w", transaction.ID, err)
+		}
+
+		status, err := ts.GetTransactionStatus(ctx, transaction.ID)
+		if err != nil {
+			return nil, err
+		}
+		if status.Status != TransactionStatusPending {
+			return ts.GetTransaction(ctx, transaction.ID)
+		}
+	}
+	return transaction, nil
+}
@@ -0,0 +1,64 @@
+package americanexpress
+
+import "strconv"
+
+// CardBrand is the card network a card number belongs to, as detected from
+// its leading digits
+type CardBrand string
+
+// Card brands
+const (
+	CardBrandUnknown    CardBrand = ""
+	CardBrandAmex       CardBrand = "amex"
+	CardBrandVisa       CardBrand = "visa"
+	CardBrandMastercard CardBrand = "mastercard"
+	CardBrandDiscover   CardBrand = "discover"
+)
+
+// Amex card number and CID lengths
+const (
+	AmexCardNumberLength = 15
+	AmexCIDLength        = 4
+)
+
+// DetectCardBrand returns the brand of a card number from its issuer
+// identification prefix. Spaces and dashes are ignored; the length and
+// check digit are not verified.
+//
+//	Brand       Prefixes
+//	amex        34, 37
+//	visa        4
+//	mastercard  51-55, 2221-2720
+//	discover    6011, 644-649, 65
+func DetectCardBrand(number string) CardBrand {
+	number = (&CardDetails{Number: number}).Normalize().Number
+	switch {
+	case hasPrefixIn(number, 2, 34, 34), hasPrefixIn(number, 2, 37, 37):
+		return CardBrandAmex
+	case hasPrefixIn(number, 1, 4, 4):
+		return CardBrandVisa
+	case hasPrefixIn(number, 2, 51, 55), hasPrefixIn(number, 4, 2221, 2720):
+		return CardBrandMastercard
+	case hasPrefixIn(number, 4, 6011, 6011), hasPrefixIn(number, 3, 644, 649), hasPrefixIn(number, 2, 65, 65):
+		return CardBrandDiscover
+	default:
+		return CardBrandUnknown
+	}
+}
+
+// IsAmexCard reports whether number is an American Express card number: 15
+// digits starting with 34 or 37. Spaces and dashes are ignored.
+func IsAmexCard(number string) bool {
+	number = (&CardDetails{Number: number}).Normalize().Number
+	return len(number) == AmexCardNumberLength && DetectCardBrand(number) == CardBrandAmex
+}
+
+// hasPrefixIn reports whether the first n digits of number lie between low
+// and high, inclusive
+func hasPrefixIn(number string, n, low, high int) bool {
+	if len(number) < n {
+		return false
+	}
+	prefix, err := strconv.Atoi(number[:n])
+	return err == nil && prefix >= low && prefix <= high
+}
@@ -0,0 +1,75 @@
+package americanexpress
+
+// TransactionBuilder builds a TransactionRequest step by step. Options are
+// collected as they are set and the request is validated by Build.
+type TransactionBuilder struct {
+	req TransactionRequest
+}
+
+// NewTransactionBuilder creates a builder for a transaction of amount in
+// currency for the given merchant
+func NewTransactionBuilder(amount float64, currency, merchantID string) *TransactionBuilder {
+	return &TransactionBuilder{
+		req: TransactionRequest{
+			Amount:     amount,
+			Currency:   currency,
+			MerchantID: merchantID,
+		},
+	}
+}
+
+// WithCard charges the given card details. It cannot be combined with WithToken.
+func (b *TransactionBuilder) WithCard(card *CardDetails) *TransactionBuilder {
+	b.req.CardDetails = card
+	return b
+}
+
+// WithToken charges a previously tokenized card. It cannot be combined with WithCard.
+func (b *TransactionBuilder) WithToken(cardToken string) *TransactionBuilder {
+	b.req.CardToken = cardToken
+	return b
+}
+
+// WithBillingAddress sets the billing address
+func (b *TransactionBuilder) WithBillingAddress(addr *Address) *TransactionBuilder {
+	b.req.BillingAddr = addr
+	return b
+}
+
+// WithMetadata adds a metadata entry, replacing any previous value for key
+func (b *TransactionBuilder) WithMetadata(key, value string) *TransactionBuilder {
+	if b.req.Metadata == nil {
+		b.req.Metadata = make(map[string]string)
+	}
+	b.req.Metadata[key] = value
+	return b
+}
+
+// WithCaptureMode sets the capture mode (CaptureModeManual or CaptureModeAuto)
+func (b *TransactionBuilder) WithCaptureMode(mode string) *TransactionBuilder {
+	b.req.CaptureMode = mode
+	return b
+}
+
+// Build validates the collected options and returns the transaction
+// request. Each call returns a new request, so a builder can be reused as
+// a template.
+func (b *TransactionBuilder) Build() (*TransactionRequest, error) {
+	if b.req.CardToken != "" && b.req.CardDetails != nil {
+		return nil, validationError("card_token", ValidationCodeConflict, "card token cannot be combined with card details")
+	}
+
+	req := b.req
+	if b.req.Metadata != nil {
+		req.Metadata = make(map[string]string, len(b.req.Metadata))
+		for k, v := range b.req.Metadata {
+			req.Metadata[k] = v
+		}
+	}
+
+	if err := ValidateTransactionRequest(&req); err != nil {
+		return nil, err
+	}
+
+	return &req, nil
+}
@@ -0,0 +1,168 @@
+package americanexpress
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Cache is a pluggable key/value store used by the client for optional
+// response caching. Implementations must be safe for concurrent use.
+type Cache interface {
+	// Get returns the value stored under key, if present and not expired
+	Get(key string) (interface{}, bool)
+	// Set stores value under key. A zero ttl means the entry never expires.
+	Set(key string, value interface{}, ttl time.Duration)
+	// Delete removes the value stored under key
+	Delete(key string)
+}
+
+// MemoryCache is an in-memory Cache implementation. Expired entries are
+// removed when read, and swept whenever the cache has doubled in size, so
+// entries that are never read again do not pile up.
+type MemoryCache struct {
+	mu      sync.Mutex
+	entries map[string]memoryCacheEntry
+	clock   Clock
+	sweepAt int // Size at which expired entries are next swept
+}
+
+// minMemoryCacheSweep is the smallest size at which a MemoryCache sweeps
+// expired entries
+const minMemoryCacheSweep = 64
+
+type memoryCacheEntry struct {
+	value     interface{}
+	expiresAt time.Time
+}
+
+// NewMemoryCache creates a new in-memory cache
+func NewMemoryCache() *MemoryCache {
+	return NewMemoryCacheWithClock(SystemClock)
+}
+
+// NewMemoryCacheWithClock creates a new in-memory cache that reads the
+// current time from clock when expiring entries
+func NewMemoryCacheWithClock(clock Clock) *MemoryCache {
+	return &MemoryCache{entries: make(map[string]memoryCacheEntry), clock: clock}
+}
+
+// Get returns the value stored under key, if present and not expired
+func (mc *MemoryCache) Get(key string) (interface{}, bool) {
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+
+	entry, ok := mc.entries[key]
+	if !ok {
+		return nil, false
+	}
+	if !entry.expiresAt.IsZero() && !mc.clock.Now().Before(entry.expiresAt) {
+		delete(mc.entries, key)
+		return nil, false
+	}
+	return entry.value, true
+}
+
+// Set stores value under key. A zero ttl means the entry never expires.
+func (mc *MemoryCache) Set(key string, value interface{}, ttl time.Duration) {
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+
+	entry := memoryCacheEntry{value: value}
+	if ttl > 0 {
+		entry.expiresAt = mc.clock.Now().Add(ttl)
+	}
+	mc.entries[key] = entry
+
+	if len(mc.entries) >= mc.sweepAt {
+		mc.removeExpired()
+		mc.sweepAt = max(2*len(mc.entries), minMemoryCacheSweep)
+	}
+}
+
+// removeExpired deletes every expired entry. The caller must hold mu.
+func (mc *MemoryCache) removeExpired() {
+	now := mc.clock.Now()
+	for key, entry := range mc.entries {
+		if !entry.expiresAt.IsZero() && !now.Before(entry.expiresAt) {
+			delete(mc.entries, key)
+		}
+	}
+}
+
+// Delete removes the value stored under key
+func (mc *MemoryCache) Delete(key string) {
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+
+	delete(mc.entries, key)
+}
+
+// DefaultETagCacheTTL is how long a response cached for conditional GETs is
+// kept when Config.ETagCacheTTL is not set
+const DefaultETagCacheTTL = time.Hour
+
+// ErrUnexpectedNotModified is returned when the gateway, or a proxy in
+// front of it, answers 304 Not Modified to a request the client has no
+// cached response for
+var ErrUnexpectedNotModified = errors.New("unexpected 304 Not Modified without a cached response")
+
+// etagEntry is a cached GET response body along with its validator
+type etagEntry struct {
+	etag   string
+	header http.Header
+	body   []byte
+}
+
+// etagCacheKey returns the cache key used for conditional GETs of a URL.
+// The same URL is answered differently for each Accept header, e.g. a
+// statement as PDF or CSV, and for each API key when clients share a Cache,
+// so both are part of the key. The API key is hashed to keep it out of the
+// cache.
+func etagCacheKey(reqURL, accept, apiKey string) string {
+	sum := sha256.Sum256([]byte(apiKey))
+	return "etag:" + hex.EncodeToString(sum[:8]) + ":" + accept + ":" + reqURL
+}
+
+// response rebuilds a successful HTTP response from the cached entry
+func (e *etagEntry) response(req *http.Request) *http.Response {
+	return &http.Response{
+		Status:        "200 OK",
+		StatusCode:    http.StatusOK,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        e.header.Clone(),
+		Body:          io.NopCloser(bytes.NewReader(e.body)),
+		ContentLength: int64(len(e.body)),
+		Request:       req,
+	}
+}
+
+// storeETag caches the body of a successful GET response that carries an
+// ETag. The response body is replaced so the caller can still read it.
+func (c *Client) storeETag(key string, resp *http.Response) error {
+	etag := resp.Header.Get("ETag")
+	if etag == "" {
+		return nil
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+
+	c.cache.Set(key, &etagEntry{
+		etag:   etag,
+		header: resp.Header.Clone(),
+		body:   body,
+	}, c.etagTTL)
+	return nil
+}
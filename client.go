@@ -5,67 +5,497 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"net"
 	"net/http"
 	"net/url"
+	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
 const (
+	// ProductionBaseURL is the base URL of the production environment in
+	// North America
+	ProductionBaseURL = "https://gateway-na.americanexpress.com/api"
+	// SandboxBaseURL is the base URL of the sandbox environment in North
+	// America
+	SandboxBaseURL = "https://sandbox-gateway-na.americanexpress.com/api"
 	// DefaultBaseURL is the default base URL for American Express APIs
-	DefaultBaseURL = "https://gateway-na.americanexpress.com/api"
+	DefaultBaseURL = ProductionBaseURL
 	// DefaultTimeout is the default timeout for HTTP requests
 	DefaultTimeout = 30 * time.Second
 	// SDKVersion is the current version of this SDK
 	SDKVersion = "1.0.0"
+	// DefaultAPIVersion is the API version this SDK is built and tested against
+	DefaultAPIVersion = "2024-01-01"
+	// APIVersionHeader is the header used to request and report the API version
+	APIVersionHeader = "X-AMEX-API-Version"
 )
 
-// Client represents the American Express API client
+var (
+	// ErrContextCanceled is returned when the caller's context was canceled
+	// before the request completed
+	ErrContextCanceled = errors.New("context canceled")
+	// ErrContextDeadline is returned when the caller's context deadline
+	// expired before the request completed
+	ErrContextDeadline = errors.New("context deadline exceeded")
+	// ErrGatewayTimeout is returned when the gateway did not respond within
+	// the client timeout
+	ErrGatewayTimeout = errors.New("gateway timeout")
+	// ErrRetryBudgetExhausted is returned when another retry would exceed
+	// RetryPolicy.MaxElapsedTime
+	ErrRetryBudgetExhausted = errors.New("retry budget exhausted")
+)
+
+// Environment selects the American Express environment the client talks to
+type Environment string
+
+const (
+	// Production is the live environment. It is the default.
+	Production Environment = "production"
+	// Sandbox is the test environment
+	Sandbox Environment = "sandbox"
+)
+
+// Client represents the American Express API client.
+//
+// A Client is safe for concurrent use by multiple goroutines. Its settings
+// are fixed when it is created, except for the credentials, which can be
+// rotated with SetCredentials; the state shared between requests lives in
+// the Cache, DedupeStore, Logger and Observer, which must be safe for
+// concurrent use as well. The built-in implementations are.
 type Client struct {
 	baseURL    string
 	httpClient *http.Client
+	credsMu    sync.RWMutex // Guards apiKey and secretKey; see SetCredentials
 	apiKey     string
 	secretKey  string
 	userAgent  string
+	language   string
+	tokenSalt  string // Keys EnsureToken idempotency keys; see IdempotencySalt
+	apiVersion string
+	cache      Cache
+	etagCache  bool
+	etagTTL    time.Duration
+	retainRaw  bool
+	strictVoid bool
+	signing    bool
+	capsTTL    time.Duration
+	txnTTL     time.Duration
+	statusTTL  time.Duration
+	asyncPoll  time.Duration
+	maxPages   int
+	pathPrefix string
+	clock      Clock
+	clockSkew  atomic.Int64 // Nanoseconds the gateway clock is ahead; see ClockSkew
+	status     statusCache  // Most recent GetServiceStatus result
+
+	defaultCurrency    string
+	defaultCaptureMode string
+
+	tokenBaseURL     string
+	paymentBaseURL   string
+	reportingBaseURL string
+
+	defaultMetadata       map[string]string
+	metadataLimits        MetadataLimits
+	generateCorrelationID bool
+	idempotencyKey        IdempotencyKeyFunc
+	decimalStringAmounts  bool
+	transformResponse     ResponseTransformer
+
+	retry    RetryPolicy
+	logger   Logger
+	observer Observer
+	handler  Handler
+
+	slowRequestThreshold time.Duration
+	slowRequestCallback  func(method, path string, duration time.Duration)
+
+	refundDedupe       DedupeStore
+	refundDedupeWindow time.Duration
+
+	disabledTokens   disabledTokens   // Tokens seen disabled; see DisableToken
+	tokenGenerations tokenGenerations // Tokens removed per customer; see EnsureToken
 }
 
 // Config holds configuration for the American Express client
 type Config struct {
-	BaseURL    string
-	APIKey     string
-	SecretKey  string
-	Timeout    time.Duration
-	HTTPClient *http.Client
+	// Environment selects the base URL when BaseURL is not set. Defaults to
+	// Production.
+	Environment Environment
+	// Region selects the regional gateway when BaseURL is not set, and the
+	// default currency when DefaultCurrency is not. Defaults to RegionNA,
+	// without a default currency.
+	Region Region
+	// DefaultCurrency is used for transaction and payment requests that do
+	// not set a currency
+	DefaultCurrency string
+	// DefaultCaptureMode is used for transaction requests that do not set a
+	// capture mode, CaptureModeManual or CaptureModeAuto. Defaults to
+	// CaptureModeManual.
+	DefaultCaptureMode string
+	// BaseURL overrides the environment's base URL, e.g. for a proxy
+	BaseURL string
+	// TokenBaseURL, PaymentBaseURL and ReportingBaseURL override the base
+	// URL of the token, payment and merchant (reporting) services, for
+	// regions where Amex serves them from separate hosts. Empty values fall
+	// back to BaseURL. Any service can be pointed elsewhere with SetBaseURL.
+	TokenBaseURL     string
+	PaymentBaseURL   string
+	ReportingBaseURL string
+	APIKey           string
+	SecretKey        string
+	// Timeout caps the whole request, from dialing to reading the response
+	// body. Defaults to DefaultTimeout.
+	Timeout time.Duration
+	// DialTimeout, TLSHandshakeTimeout and ResponseHeaderTimeout limit the
+	// individual phases of a request within Timeout, e.g. to fail fast on
+	// connect while allowing large list responses to download. Zero keeps
+	// the net/http defaults. They are ignored when HTTPClient is set.
+	DialTimeout           time.Duration
+	TLSHandshakeTimeout   time.Duration
+	ResponseHeaderTimeout time.Duration
+	HTTPClient            *http.Client
+	// Clock is the source of the current time. Defaults to SystemClock.
+	Clock Clock
+	// APIVersion pins the API version sent with every request. Defaults to
+	// DefaultAPIVersion.
+	APIVersion string
+	// PathPrefix is prepended to every service path, e.g. "/v2". Individual
+	// services can override it with SetPathPrefix.
+	PathPrefix string
+	// Cache is an optional store used by the client's caching features
+	Cache Cache
+	// EnableETagCache enables conditional GETs using If-None-Match. It
+	// requires Cache to be set.
+	EnableETagCache bool
+	// ETagCacheTTL is how long a response is kept in Cache for conditional
+	// GETs. Every URL, including its query, gets its own entry, so the TTL
+	// keeps paging through lists from growing the cache without bound.
+	// Defaults to DefaultETagCacheTTL.
+	ETagCacheTTL time.Duration
+	// CapabilitiesCacheTTL is how long merchant capabilities are cached in
+	// Cache. Defaults to DefaultCapabilitiesCacheTTL; a negative value
+	// disables caching them.
+	CapabilitiesCacheTTL time.Duration
+	// TransactionCacheTTL enables caching transactions fetched with
+	// GetTransaction in Cache for the given time. Only transactions in a
+	// terminal status are cached. Zero, the default, disables caching them.
+	TransactionCacheTTL time.Duration
+	// AsyncPollInterval is how often ProcessAsync polls a pending
+	// transaction. Defaults to DefaultAsyncPollInterval.
+	AsyncPollInterval time.Duration
+	// StatusCacheTTL is how long GetServiceStatus reuses its last result.
+	// Defaults to DefaultStatusCacheTTL; a negative value disables reusing
+	// it. Unlike the other caches it does not need Cache.
+	StatusCacheTTL time.Duration
+	// MaxPages caps the pages an iterator fetches, guarding against a server
+	// that always reports more items. Defaults to DefaultMaxPages; a
+	// negative value removes the limit.
+	MaxPages int
+	// DefaultMetadata is merged into the metadata of every transaction,
+	// payment, capture and refund request. Keys set on a request take
+	// precedence over the defaults.
+	DefaultMetadata map[string]string
+	// MetadataLimits caps the metadata sent with a request, including
+	// DefaultMetadata. Defaults to DefaultMetadataLimits.
+	MetadataLimits MetadataLimits
+	// RefundDedupeWindow is how long a refund reference is remembered to
+	// refuse duplicate refunds. Defaults to DefaultRefundDedupeWindow; a
+	// negative value disables refund deduplication.
+	RefundDedupeWindow time.Duration
+	// RefundDedupeStore tracks refund references. Defaults to an in-memory
+	// store; provide a shared store to dedupe across processes.
+	RefundDedupeStore DedupeStore
+	// UserAgentSuffix identifies your application to Amex support. It is
+	// appended to the SDK's User-Agent, e.g. "mycheckout/2.3.1".
+	UserAgentSuffix string
+	// Language is sent as the Accept-Language header, e.g. "fr-CA", so the
+	// gateway returns error and decline messages localized for display to
+	// the cardholder. No header is sent when it is empty. WithLanguage
+	// overrides it per request.
+	Language string
+	// GenerateCorrelationID generates a correlation ID for requests whose
+	// context does not carry one set with WithCorrelationID
+	GenerateCorrelationID bool
+	// GenerateIdempotencyKeys sends an Idempotency-Key header with every
+	// POST request that does not already carry one, which also lets such
+	// requests be retried. Keys are generated with IdempotencyKeyFunc.
+	GenerateIdempotencyKeys bool
+	// IdempotencyKeyFunc generates the idempotency keys sent when
+	// GenerateIdempotencyKeys is enabled. Defaults to UUIDIdempotencyKey;
+	// use ContentHashIdempotencyKey to dedupe identical requests.
+	IdempotencyKeyFunc IdempotencyKeyFunc
+	// IdempotencySalt keys the idempotency keys EnsureToken derives from
+	// card numbers, so they do not reveal the card. Defaults to a random
+	// salt per client; set the same secret value in every process that
+	// should share one token per card. Keep it when rotating credentials.
+	IdempotencySalt string
+	// Retry controls automatic retries of failed requests. Retries are
+	// disabled by default.
+	Retry RetryPolicy
+	// Logger receives diagnostic messages such as retry decisions. Nothing
+	// is logged when it is nil.
+	Logger Logger
+	// Observer is notified of retry decisions
+	Observer Observer
+	// Interceptors wrap every request, the first one outermost. Each sees a
+	// call once, with retries happening inside the chain. See Interceptor.
+	Interceptors []Interceptor
+	// SlowRequestThreshold is the duration after which a request, including
+	// its retries, is reported to SlowRequestCallback
+	SlowRequestThreshold time.Duration
+	// SlowRequestCallback is called after a request that took longer than
+	// SlowRequestThreshold, whether it succeeded or not. It may be called
+	// concurrently.
+	SlowRequestCallback func(method, path string, duration time.Duration)
+	// DecimalStringAmounts sends transaction and payment amounts as decimal
+	// strings with the currency's number of decimal places ("100.10")
+	// instead of JSON numbers (100.1)
+	DecimalStringAmounts bool
+	// ResponseTransformer rewrites successful response bodies before they
+	// are decoded, an escape hatch for tenants whose field names differ
+	// from the SDK's, e.g. RenameJSONFields. Bodies are decoded unchanged
+	// when it is nil.
+	ResponseTransformer ResponseTransformer
+	// StrictVoidResponses makes VoidTransaction and VoidPayment fail when a
+	// successful void answers with a bare status string, as they used to,
+	// instead of deriving the status from the body or the HTTP status code
+	StrictVoidResponses bool
+	// RetainRawResponses keeps the raw body of every decoded response in
+	// ResponseMeta.Raw for debugging. It doubles the memory held per
+	// response, so leave it off in production.
+	RetainRawResponses bool
+	// SignRequests signs every request with an HMAC-SHA256 keyed with
+	// SecretKey, sent in the Signature and Signature-Input headers. The
+	// signature covers the method, path, sorted query parameters, body and
+	// creation time of the request; streamed uploads are signed without
+	// their body. NewClientWithError rejects it without a SecretKey.
+	SignRequests bool
+}
+
+// InvalidBaseURLError is returned by NewClientWithError when a configured
+// base URL is not an absolute http or https URL, e.g. "gateway.example.com"
+// without a scheme
+type InvalidBaseURLError struct {
+	Field  string // Config field, e.g. "BaseURL"
+	URL    string
+	Reason string
+}
+
+func (e *InvalidBaseURLError) Error() string {
+	return fmt.Sprintf("invalid config: %s %q: %s", e.Field, e.URL, e.Reason)
+}
+
+// validateBaseURL checks that a configured base URL, if any, parses and has
+// an http or https scheme and a host
+func validateBaseURL(field, baseURL string) error {
+	if baseURL == "" {
+		return nil
+	}
+	u, err := url.Parse(baseURL)
+	if err != nil {
+		return &InvalidBaseURLError{Field: field, URL: baseURL, Reason: "malformed URL"}
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return &InvalidBaseURLError{Field: field, URL: baseURL, Reason: "scheme must be http or https"}
+	}
+	if u.Host == "" {
+		return &InvalidBaseURLError{Field: field, URL: baseURL, Reason: "missing host"}
+	}
+	return nil
+}
+
+// NewClientWithError creates a new American Express API client after
+// checking the configuration, e.g. that the retry policy is sane and the
+// base URLs are valid
+func NewClientWithError(config *Config) (*Client, error) {
+	if config != nil {
+		baseURLs := []struct{ field, url string }{
+			{"BaseURL", config.BaseURL},
+			{"TokenBaseURL", config.TokenBaseURL},
+			{"PaymentBaseURL", config.PaymentBaseURL},
+			{"ReportingBaseURL", config.ReportingBaseURL},
+		}
+		for _, baseURL := range baseURLs {
+			if err := validateBaseURL(baseURL.field, baseURL.url); err != nil {
+				return nil, err
+			}
+		}
+		if err := config.Retry.Validate(); err != nil {
+			return nil, fmt.Errorf("invalid config: %w", nestValidationError("retry", "invalid retry policy", err))
+		}
+		if err := validateRegion(config.Region); err != nil {
+			return nil, err
+		}
+		if mode := config.DefaultCaptureMode; mode != "" && mode != CaptureModeAuto && mode != CaptureModeManual {
+			return nil, fmt.Errorf("invalid config: unknown default capture mode %q", mode)
+		}
+		if config.SignRequests && config.SecretKey == "" {
+			return nil, errors.New("invalid config: SignRequests requires a SecretKey")
+		}
+	}
+	return NewClient(config), nil
 }
 
-// NewClient creates a new American Express API client
+// NewClient creates a new American Express API client. The configuration
+// is not checked; use NewClientWithError to catch misconfigurations early.
 func NewClient(config *Config) *Client {
 	if config == nil {
 		config = &Config{}
 	}
+	// Fill in defaults on a copy, so one Config can be shared by clients
+	// created concurrently
+	defaults := *config
+	config = &defaults
 
 	// Set defaults
+	if config.Environment == "" {
+		config.Environment = Production
+	}
 	if config.BaseURL == "" {
-		config.BaseURL = DefaultBaseURL
+		config.BaseURL = config.Region.baseURL(config.Environment)
+	}
+	if config.DefaultCurrency == "" && config.Region != "" {
+		config.DefaultCurrency = config.Region.config().currency
+	}
+	if config.DefaultCaptureMode == "" {
+		config.DefaultCaptureMode = CaptureModeManual
 	}
 	if config.Timeout == 0 {
 		config.Timeout = DefaultTimeout
 	}
+	if config.APIVersion == "" {
+		config.APIVersion = DefaultAPIVersion
+	}
+	if config.Clock == nil {
+		config.Clock = SystemClock
+	}
+	if config.CapabilitiesCacheTTL == 0 {
+		config.CapabilitiesCacheTTL = DefaultCapabilitiesCacheTTL
+	}
+	if config.RefundDedupeWindow == 0 {
+		config.RefundDedupeWindow = DefaultRefundDedupeWindow
+	}
+	if config.ETagCacheTTL <= 0 {
+		config.ETagCacheTTL = DefaultETagCacheTTL
+	}
+	if config.AsyncPollInterval <= 0 {
+		config.AsyncPollInterval = DefaultAsyncPollInterval
+	}
+	if config.StatusCacheTTL == 0 {
+		config.StatusCacheTTL = DefaultStatusCacheTTL
+	}
+	if config.MaxPages == 0 {
+		config.MaxPages = DefaultMaxPages
+	}
+	if config.RefundDedupeStore == nil && config.RefundDedupeWindow > 0 {
+		config.RefundDedupeStore = NewMemoryDedupeStoreWithClock(config.Clock)
+	}
+	if config.IdempotencyKeyFunc == nil {
+		config.IdempotencyKeyFunc = UUIDIdempotencyKey
+	}
+	if config.IdempotencySalt == "" {
+		config.IdempotencySalt = newUUID()
+	}
 	if config.HTTPClient == nil {
 		config.HTTPClient = &http.Client{
-			Timeout: config.Timeout,
+			Timeout:   config.Timeout,
+			Transport: newTransport(config),
 		}
 	}
 
-	return &Client{
+	client := &Client{
 		baseURL:    strings.TrimSuffix(config.BaseURL, "/"),
 		httpClient: config.HTTPClient,
 		apiKey:     config.APIKey,
 		secretKey:  config.SecretKey,
-		userAgent:  fmt.Sprintf("AmexSDK-Go/%s", SDKVersion),
+		userAgent:  userAgent(config.UserAgentSuffix),
+		language:   strings.TrimSpace(config.Language),
+		tokenSalt:  config.IdempotencySalt,
+		apiVersion: config.APIVersion,
+		cache:      config.Cache,
+		etagCache:  config.EnableETagCache && config.Cache != nil,
+		etagTTL:    config.ETagCacheTTL,
+		retainRaw:  config.RetainRawResponses,
+		strictVoid: config.StrictVoidResponses,
+		signing:    config.SignRequests,
+		capsTTL:    config.CapabilitiesCacheTTL,
+		txnTTL:     config.TransactionCacheTTL,
+		statusTTL:  config.StatusCacheTTL,
+		asyncPoll:  config.AsyncPollInterval,
+		maxPages:   config.MaxPages,
+		pathPrefix: normalizePathPrefix(config.PathPrefix),
+		clock:      config.Clock,
+
+		defaultCurrency:    NormalizeCurrency(config.DefaultCurrency),
+		defaultCaptureMode: config.DefaultCaptureMode,
+
+		tokenBaseURL:     strings.TrimSuffix(config.TokenBaseURL, "/"),
+		paymentBaseURL:   strings.TrimSuffix(config.PaymentBaseURL, "/"),
+		reportingBaseURL: strings.TrimSuffix(config.ReportingBaseURL, "/"),
+
+		defaultMetadata:       mergeMetadata(config.DefaultMetadata, nil),
+		metadataLimits:        config.MetadataLimits.withDefaults(),
+		generateCorrelationID: config.GenerateCorrelationID,
+		decimalStringAmounts:  config.DecimalStringAmounts,
+		transformResponse:     config.ResponseTransformer,
+
+		retry:    config.Retry.withDefaults(),
+		logger:   config.Logger,
+		observer: config.Observer,
+
+		slowRequestThreshold: config.SlowRequestThreshold,
+		slowRequestCallback:  config.SlowRequestCallback,
+	}
+	if config.GenerateIdempotencyKeys {
+		client.idempotencyKey = config.IdempotencyKeyFunc
+	}
+	client.handler = chainInterceptors(client.retryRequest, append([]Interceptor(nil), config.Interceptors...))
+	if config.RefundDedupeWindow > 0 {
+		client.refundDedupe = config.RefundDedupeStore
+		client.refundDedupeWindow = config.RefundDedupeWindow
 	}
+
+	return client
+}
+
+// newTransport returns a transport with the configured phase timeouts, or
+// nil to use http.DefaultTransport when none are set
+func newTransport(config *Config) http.RoundTripper {
+	if config.DialTimeout == 0 && config.TLSHandshakeTimeout == 0 && config.ResponseHeaderTimeout == 0 {
+		return nil
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if config.DialTimeout > 0 {
+		transport.DialContext = (&net.Dialer{
+			Timeout:   config.DialTimeout,
+			KeepAlive: 30 * time.Second,
+		}).DialContext
+	}
+	if config.TLSHandshakeTimeout > 0 {
+		transport.TLSHandshakeTimeout = config.TLSHandshakeTimeout
+	}
+	if config.ResponseHeaderTimeout > 0 {
+		transport.ResponseHeaderTimeout = config.ResponseHeaderTimeout
+	}
+	return transport
+}
+
+// userAgent returns the SDK's User-Agent followed by the sanitized suffix
+func userAgent(suffix string) string {
+	ua := fmt.Sprintf("AmexSDK-Go/%s", SDKVersion)
+	if suffix = sanitizeUserAgent(suffix); suffix != "" {
+		ua += " " + suffix
+	}
+	return ua
 }
 
 // APIError represents an error response from the American Express API
@@ -74,12 +504,130 @@ type APIError struct {
 	Message    string `json:"message"`
 	Code       string `json:"code"`
 	Details    string `json:"details"`
+	// Language is the language of Message, as reported in the
+	// Content-Language header; see Config.Language
+	Language string `json:"-"`
+
+	response *http.Response // Error response, for a RetryPredicate
+	body     []byte
 }
 
 func (e *APIError) Error() string {
 	return fmt.Sprintf("amex api error: %d - %s (%s)", e.StatusCode, e.Message, e.Code)
 }
 
+// DecodeAPIError decodes the error response of a failed request, for
+// callers working with a raw *http.Response. It returns nil when resp is
+// nil or its status code is below 400. Otherwise it reads and closes the
+// body, then replaces it with an in-memory copy, so resp.Body can still be
+// read afterwards. A body that is not a JSON error becomes the Message.
+func DecodeAPIError(resp *http.Response) *APIError {
+	if resp == nil || resp.StatusCode < 400 {
+		return nil
+	}
+
+	apiErr := &APIError{StatusCode: resp.StatusCode, Language: resp.Header.Get("Content-Language"), response: resp}
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	if err != nil {
+		apiErr.Message = "failed to read error response"
+		return apiErr
+	}
+
+	apiErr.body = body
+	if err := json.Unmarshal(body, apiErr); err != nil {
+		apiErr.Message = string(body)
+	}
+	return apiErr
+}
+
+// IsRetryable reports whether the request may succeed if retried:
+//
+//	429 Too Many Requests        retryable
+//	5xx server errors            retryable, except 501 Not Implemented
+//	4xx client errors            not retryable
+//
+// Service methods wrap API errors, so use errors.As to extract the
+// *APIError before calling this method.
+func (e *APIError) IsRetryable() bool {
+	switch {
+	case e.StatusCode == http.StatusTooManyRequests:
+		return true
+	case e.StatusCode == http.StatusNotImplemented:
+		return false
+	case e.StatusCode >= 500:
+		return true
+	default:
+		return false
+	}
+}
+
+// IsAuthError reports whether the request was rejected because of missing
+// or invalid credentials (401) or insufficient permissions (403)
+func (e *APIError) IsAuthError() bool {
+	return e.StatusCode == http.StatusUnauthorized || e.StatusCode == http.StatusForbidden
+}
+
+// ResponseMeta holds transport-level details of an API response
+type ResponseMeta struct {
+	StatusCode int
+	Header     http.Header
+	// APIVersion is the API version the server used to handle the request,
+	// as reported in the X-AMEX-API-Version response header
+	APIVersion string
+	// CorrelationID is the correlation ID sent with the request, if any
+	CorrelationID string
+	// ContentRange is the range of items returned by a partial (206) list
+	// response, or nil for complete responses
+	ContentRange *ContentRange
+	// Language is the language of the messages in the response, as
+	// reported in the Content-Language header; see Config.Language
+	Language string
+	// IdempotencyReplayed reports whether the gateway answered from its
+	// idempotency cache, as indicated by the Idempotency-Replayed header:
+	// the request had already been processed and was not executed again
+	IdempotencyReplayed bool
+	// Raw is the response body exactly as received. It is only set when
+	// Config.RetainRawResponses is enabled.
+	Raw json.RawMessage
+}
+
+// clone returns a copy of the metadata that shares no header, range or body
+// with m, or nil
+func (m *ResponseMeta) clone() *ResponseMeta {
+	if m == nil {
+		return nil
+	}
+	clone := *m
+	clone.Header = m.Header.Clone()
+	clone.ContentRange = clonePtr(m.ContentRange)
+	clone.Raw = append(json.RawMessage(nil), m.Raw...)
+	return &clone
+}
+
+// newResponseMeta extracts the response metadata from an HTTP response
+func newResponseMeta(resp *http.Response) *ResponseMeta {
+	meta := &ResponseMeta{
+		StatusCode: resp.StatusCode,
+		Header:     resp.Header,
+		APIVersion: resp.Header.Get(APIVersionHeader),
+		Language:   resp.Header.Get("Content-Language"),
+	}
+	if replayed, err := strconv.ParseBool(resp.Header.Get(IdempotencyReplayedHeader)); err == nil {
+		meta.IdempotencyReplayed = replayed
+	}
+	if resp.Request != nil {
+		meta.CorrelationID = resp.Request.Header.Get(CorrelationIDHeader)
+	}
+	if resp.StatusCode == http.StatusPartialContent {
+		if r, err := parseContentRange(resp.Header.Get("Content-Range")); err == nil {
+			meta.ContentRange = r
+		}
+	}
+	return meta
+}
+
 // Request represents an HTTP request
 type Request struct {
 	Method  string
@@ -87,21 +635,92 @@ type Request struct {
 	Body    interface{}
 	Headers map[string]string
 	Query   url.Values
+	// BaseURL overrides the client's base URL for this request
+	BaseURL string
 }
 
-// doRequest executes an HTTP request and handles the response
+// doRequest executes an HTTP request through the interceptors, retrying
+// failed attempts according to the retry policy, and handles the response
 func (c *Client) doRequest(ctx context.Context, req *Request) (*http.Response, error) {
+	// Resolve the correlation ID once so every attempt carries the same one
+	if id := c.correlationID(ctx); id != "" {
+		ctx = WithCorrelationID(ctx, id)
+	}
+	// Likewise generate the idempotency key once for all attempts, unless
+	// a request option sets one
+	req, err := withRequestOptions(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	req = c.withIdempotencyKey(req)
+
+	return c.handler(ctx, req)
+}
+
+// retryRequest sends a request, retrying failed attempts according to the
+// retry policy
+func (c *Client) retryRequest(ctx context.Context, req *Request) (*http.Response, error) {
+	start := c.now()
+	if c.slowRequestCallback != nil && c.slowRequestThreshold > 0 {
+		defer c.reportSlowRequest(req, start)
+	}
+
+	for retry := 1; ; retry++ {
+		resp, err := c.sendSynced(ctx, req)
+		if err == nil || retry > c.retry.MaxRetries || !c.shouldRetry(ctx, req, err) {
+			return resp, err
+		}
+
+		event := RetryEvent{
+			Method:  req.Method,
+			Path:    req.Path,
+			Attempt: retry,
+			Err:     err,
+			Delay:   c.retry.backoff(retry),
+		}
+
+		// Stop before the backoff would run past the retry budget. A context
+		// deadline that expires first ends the wait below instead.
+		if elapsed := c.now().Sub(start); c.retry.MaxElapsedTime > 0 && elapsed+event.Delay > c.retry.MaxElapsedTime {
+			return nil, fmt.Errorf("request failed: %w after %s: %w", ErrRetryBudgetExhausted, elapsed, err)
+		}
+
+		var apiErr *APIError
+		if errors.As(err, &apiErr) {
+			event.StatusCode = apiErr.StatusCode
+		}
+		c.notifyRetry(event)
+
+		if waitErr := wait(ctx, event.Delay); waitErr != nil {
+			return nil, normalizeRequestError(ctx, err)
+		}
+	}
+}
+
+// send makes a single attempt at an HTTP request and handles the response
+func (c *Client) send(ctx context.Context, req *Request) (*http.Response, error) {
 	var body io.Reader
-	if req.Body != nil {
+	var payload []byte // Marshalled body, for signing
+	contentType := "application/json"
+	if stream, ok := req.Body.(streamingBody); ok {
+		streamBody, streamType, err := stream.open()
+		if err != nil {
+			return nil, err
+		}
+		body, contentType = streamBody, streamType
+	} else if req.Body != nil {
 		jsonBody, err := json.Marshal(req.Body)
 		if err != nil {
 			return nil, fmt.Errorf("failed to marshal request body: %w", err)
 		}
-		body = bytes.NewReader(jsonBody)
+		body, payload = bytes.NewReader(jsonBody), jsonBody
 	}
 
 	// Build URL
 	reqURL := c.baseURL + req.Path
+	if req.BaseURL != "" {
+		reqURL = req.BaseURL + req.Path
+	}
 	if req.Query != nil && len(req.Query) > 0 {
 		reqURL += "?" + req.Query.Encode()
 	}
@@ -109,53 +728,173 @@ func (c *Client) doRequest(ctx context.Context, req *Request) (*http.Response, e
 	// Create HTTP request
 	httpReq, err := http.NewRequestWithContext(ctx, req.Method, reqURL, body)
 	if err != nil {
+		if closer, ok := body.(io.Closer); ok {
+			closer.Close()
+		}
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
 
 	// Set headers
 	httpReq.Header.Set("User-Agent", c.userAgent)
-	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("Content-Type", contentType)
 	httpReq.Header.Set("Accept", "application/json")
+	httpReq.Header.Set(APIVersionHeader, c.apiVersion)
+	if c.language != "" {
+		httpReq.Header.Set("Accept-Language", c.language)
+	}
 
 	// Add authentication headers
 	c.addAuthHeaders(httpReq)
 
+	// Propagate the caller's correlation ID
+	if id, ok := CorrelationIDFromContext(ctx); ok {
+		httpReq.Header.Set(CorrelationIDHeader, id)
+	}
+
 	// Add custom headers
 	for key, value := range req.Headers {
 		httpReq.Header.Set(key, value)
 	}
 
+	if c.signing {
+		c.signRequest(httpReq, payload, c.gatewayNow())
+	}
+
+	// Send the cached validator on conditional GETs
+	var cacheKey string
+	var cached *etagEntry
+	if c.etagCache && req.Method == http.MethodGet {
+		apiKey, _ := c.credentials()
+		cacheKey = etagCacheKey(reqURL, httpReq.Header.Get("Accept"), apiKey)
+		if value, ok := c.cache.Get(cacheKey); ok {
+			if entry, ok := value.(*etagEntry); ok {
+				cached = entry
+				httpReq.Header.Set("If-None-Match", entry.etag)
+			}
+		}
+	}
+
 	// Execute request
 	resp, err := c.httpClient.Do(httpReq)
 	if err != nil {
-		return nil, fmt.Errorf("request failed: %w", err)
+		return nil, normalizeRequestError(ctx, err)
+	}
+	c.observeServerTime(resp.Header)
+
+	// Serve the cached body when the resource has not changed
+	if resp.StatusCode == http.StatusNotModified {
+		resp.Body.Close()
+		if cached == nil {
+			return nil, fmt.Errorf("request failed: %w", ErrUnexpectedNotModified)
+		}
+		return cached.response(httpReq), nil
 	}
 
 	// Check for API errors
-	if resp.StatusCode >= 400 {
-		defer resp.Body.Close()
-		apiErr := &APIError{StatusCode: resp.StatusCode}
-		
-		respBody, err := io.ReadAll(resp.Body)
-		if err != nil {
-			apiErr.Message = "failed to read error response"
-		} else {
-			// Try to parse error response
-			if err := json.Unmarshal(respBody, apiErr); err != nil {
-				apiErr.Message = string(respBody)
-			}
+	if apiErr := DecodeAPIError(resp); apiErr != nil {
+		// Declines are reported separately from other API errors
+		if resp.StatusCode == http.StatusPaymentRequired {
+			return nil, newDeclineError(apiErr, apiErr.body)
 		}
-		
 		return nil, apiErr
 	}
 
+	if cacheKey != "" && resp.StatusCode == http.StatusOK {
+		if err := c.storeETag(cacheKey, resp); err != nil {
+			return nil, fmt.Errorf("failed to read response: %w", err)
+		}
+	}
+
 	return resp, nil
 }
 
+// now returns the current time according to the configured clock
+func (c *Client) now() time.Time {
+	return c.clock.Now()
+}
+
+// normalizeRequestError classifies a transport error so callers can tell
+// whether their own context gave up or the gateway was slow. The original
+// error stays in the chain.
+func normalizeRequestError(ctx context.Context, err error) error {
+	switch ctx.Err() {
+	case context.Canceled:
+		return fmt.Errorf("request failed: %w: %w", ErrContextCanceled, err)
+	case context.DeadlineExceeded:
+		return fmt.Errorf("request failed: %w: %w", ErrContextDeadline, err)
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return fmt.Errorf("request failed: %w: %w", ErrGatewayTimeout, err)
+	}
+
+	return fmt.Errorf("request failed: %w", err)
+}
+
+// decodeResponse reads and closes the response body, unmarshals it into v
+// and returns the response metadata. A 204 No Content or empty body is a
+// success that leaves v unchanged. A body that is neither JSON nor labelled
+// as JSON is reported as an *UnexpectedContentTypeError.
+func (c *Client) decodeResponse(resp *http.Response, v interface{}) (*ResponseMeta, error) {
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusNoContent && len(bytes.TrimSpace(body)) > 0 {
+		decoded := body
+		if c.transformResponse != nil {
+			if decoded, err = c.transformResponse(resp, body); err != nil {
+				return nil, fmt.Errorf("failed to transform response: %w", err)
+			}
+		}
+		if err := json.Unmarshal(decoded, v); err != nil {
+			// A body that is not labelled as JSON is most likely a page from
+			// a proxy rather than a malformed gateway response. Mislabelled
+			// JSON that parses is still accepted.
+			if contentType := resp.Header.Get("Content-Type"); !isJSONContentType(contentType) {
+				return nil, &UnexpectedContentTypeError{
+					StatusCode:  resp.StatusCode,
+					ContentType: contentType,
+					Snippet:     bodySnippet(body),
+				}
+			}
+			return nil, fmt.Errorf("failed to unmarshal response: %w", err)
+		}
+	}
+
+	meta := newResponseMeta(resp)
+	if c.retainRaw {
+		meta.Raw = body
+	}
+	return meta, nil
+}
+
+// SetCredentials replaces the API key and secret key, e.g. to rotate them
+// without restarting. Both change together: every attempt at a request,
+// including retries of one in flight, uses either the old or the new pair.
+// Idempotency keys already generated are kept, so a retry across the
+// rotation is still deduplicated.
+func (c *Client) SetCredentials(apiKey, secretKey string) {
+	c.credsMu.Lock()
+	defer c.credsMu.Unlock()
+	c.apiKey, c.secretKey = apiKey, secretKey
+}
+
+// credentials returns the current API key and secret key
+func (c *Client) credentials() (apiKey, secretKey string) {
+	c.credsMu.RLock()
+	defer c.credsMu.RUnlock()
+	return c.apiKey, c.secretKey
+}
+
 // addAuthHeaders adds authentication headers to the request
 func (c *Client) addAuthHeaders(req *http.Request) {
-	if c.apiKey != "" {
-		req.Header.Set("X-AMEX-API-KEY", c.apiKey)
+	if apiKey, _ := c.credentials(); apiKey != "" {
+		req.Header.Set("X-AMEX-API-KEY", apiKey)
 	}
 	// Additional authentication logic can be added here
 	// This might include OAuth, JWT, or other authentication methods
@@ -194,4 +933,70 @@ func (c *Client) Delete(ctx context.Context, path string) (*http.Response, error
 		Method: http.MethodDelete,
 		Path:   path,
 	})
-}
\ No newline at end of file
+}
+
+// Do is a lower-level escape hatch for calling endpoints the SDK does not
+// wrap yet. It sends body as JSON to path (relative to the base URL, without
+// any path prefix) through the same authentication and error handling as
+// the service methods, and decodes the response into out. Pass a nil out to
+// discard the response body. API failures are returned as *APIError.
+//
+// Prefer the typed service methods where they exist.
+func (c *Client) Do(ctx context.Context, method, path string, body interface{}, out interface{}) error {
+	resp, err := c.doRequest(ctx, &Request{
+		Method: method,
+		Path:   path,
+		Body:   body,
+	})
+	if err != nil {
+		return err
+	}
+
+	if out == nil || resp.StatusCode == http.StatusNoContent {
+		resp.Body.Close()
+		return nil
+	}
+
+	_, err = c.decodeResponse(resp, out)
+	return err
+}
+
+// RawResponse is a response body returned as received, for endpoints that
+// answer with CSV, XML, PDF or other non-JSON content
+type RawResponse struct {
+	ContentType string
+	Body        []byte
+	Meta        *ResponseMeta
+}
+
+// GetRaw performs a GET request asking for the given content type, e.g.
+// "text/csv", and returns the body without decoding it. API failures are
+// still returned as *APIError.
+func (c *Client) GetRaw(ctx context.Context, path string, query url.Values, accept string) (*RawResponse, error) {
+	return c.doRaw(ctx, &Request{
+		Method:  http.MethodGet,
+		Path:    path,
+		Query:   query,
+		Headers: map[string]string{"Accept": accept},
+	})
+}
+
+// doRaw executes a request and returns the body without decoding it
+func (c *Client) doRaw(ctx context.Context, req *Request) (*RawResponse, error) {
+	resp, err := c.doRequest(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+
+	return &RawResponse{
+		ContentType: resp.Header.Get("Content-Type"),
+		Body:        body,
+		Meta:        newResponseMeta(resp),
+	}, nil
+}
@@ -1,6 +1,21 @@
 package americanexpress
 
 import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 )
@@ -74,6 +89,107 @@ func TestNewSDK(t *testing.T) {
 	if sdk.Merchant == nil {
 		t.Fatal("Expected merchant service to be non-nil")
 	}
+
+	if sdk.Disputes == nil {
+		t.Fatal("Expected disputes service to be non-nil")
+	}
+
+	if sdk.ThreeDS == nil {
+		t.Fatal("Expected 3DS service to be non-nil")
+	}
+
+	if sdk.Subscriptions == nil {
+		t.Fatal("Expected subscriptions service to be non-nil")
+	}
+
+	if sdk.Customers == nil {
+		t.Fatal("Expected customers service to be non-nil")
+	}
+}
+
+func TestConfigFromEnv(t *testing.T) {
+	tests := []struct {
+		name        string
+		env         map[string]string
+		wantErr     string
+		wantEnv     Environment
+		wantBaseURL string
+	}{
+		{
+			name:    "credentials only",
+			env:     map[string]string{EnvAPIKey: "key-secret", EnvSecretKey: "secret-secret"},
+			wantEnv: "",
+		},
+		{
+			name:        "sandbox with base URL",
+			env:         map[string]string{EnvAPIKey: "key-secret", EnvSecretKey: "secret-secret", EnvEnvironment: " Sandbox ", EnvBaseURL: "https://proxy.example.com"},
+			wantEnv:     Sandbox,
+			wantBaseURL: "https://proxy.example.com",
+		},
+		{
+			name:    "missing secret key",
+			env:     map[string]string{EnvAPIKey: "key-secret"},
+			wantErr: "missing environment variables AMEX_SECRET_KEY",
+		},
+		{
+			name:    "missing both",
+			env:     map[string]string{},
+			wantErr: "missing environment variables AMEX_API_KEY, AMEX_SECRET_KEY",
+		},
+		{
+			name:    "invalid environment",
+			env:     map[string]string{EnvAPIKey: "key-secret", EnvSecretKey: "secret-secret", EnvEnvironment: "staging"},
+			wantErr: "AMEX_ENVIRONMENT must be",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, name := range []string{EnvAPIKey, EnvSecretKey, EnvBaseURL, EnvEnvironment} {
+				t.Setenv(name, tt.env[name])
+			}
+
+			config, err := ConfigFromEnv()
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Expected error containing %q, got %v", tt.wantErr, err)
+				}
+				if strings.Contains(err.Error(), "-secret") {
+					t.Errorf("Expected the error not to contain credentials, got %q", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ConfigFromEnv() error = %v", err)
+			}
+			if config.APIKey != "key-secret" || config.SecretKey != "secret-secret" {
+				t.Errorf("Expected the credentials to be read, got %q and %q", config.APIKey, config.SecretKey)
+			}
+			if config.Environment != tt.wantEnv || config.BaseURL != tt.wantBaseURL {
+				t.Errorf("Expected environment %q and base URL %q, got %q and %q", tt.wantEnv, tt.wantBaseURL, config.Environment, config.BaseURL)
+			}
+		})
+	}
+}
+
+func TestNewSDKFromEnv(t *testing.T) {
+	t.Setenv(EnvAPIKey, "key")
+	t.Setenv(EnvSecretKey, "secret")
+	t.Setenv(EnvBaseURL, "")
+	t.Setenv(EnvEnvironment, "sandbox")
+
+	sdk, err := NewSDKFromEnv()
+	if err != nil {
+		t.Fatalf("NewSDKFromEnv() error = %v", err)
+	}
+	if sdk.baseURL != SandboxBaseURL || sdk.apiKey != "key" {
+		t.Errorf("Expected a sandbox client with the API key, got %q", sdk.baseURL)
+	}
+
+	t.Setenv(EnvAPIKey, "")
+	if _, err := NewSDKFromEnv(); err == nil {
+		t.Error("Expected an error when AMEX_API_KEY is not set")
+	}
 }
 
 func TestVersion(t *testing.T) {
@@ -95,4 +211,3317 @@ func TestAPIError(t *testing.T) {
 	if err.Error() != expected {
 		t.Errorf("Expected error message to be '%s', got '%s'", expected, err.Error())
 	}
-}
\ No newline at end of file
+}
+
+func TestAPIErrorClassification(t *testing.T) {
+	tests := []struct {
+		statusCode    int
+		wantRetryable bool
+		wantAuth      bool
+	}{
+		{400, false, false},
+		{401, false, true},
+		{403, false, true},
+		{404, false, false},
+		{429, true, false},
+		{500, true, false},
+		{501, false, false},
+		{502, true, false},
+		{503, true, false},
+		{504, true, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(http.StatusText(tt.statusCode), func(t *testing.T) {
+			// Service methods wrap API errors, so classify through errors.As
+			err := fmt.Errorf("failed to get transaction: %w", &APIError{StatusCode: tt.statusCode})
+
+			var apiErr *APIError
+			if !errors.As(err, &apiErr) {
+				t.Fatal("Expected wrapped error to be an *APIError")
+			}
+			if got := apiErr.IsRetryable(); got != tt.wantRetryable {
+				t.Errorf("IsRetryable() = %v, want %v", got, tt.wantRetryable)
+			}
+			if got := apiErr.IsAuthError(); got != tt.wantAuth {
+				t.Errorf("IsAuthError() = %v, want %v", got, tt.wantAuth)
+			}
+		})
+	}
+}
+
+func TestDecodeAPIError(t *testing.T) {
+	newResponse := func(statusCode int, body string) *http.Response {
+		return &http.Response{StatusCode: statusCode, Header: http.Header{}, Body: io.NopCloser(strings.NewReader(body))}
+	}
+
+	resp := newResponse(http.StatusBadRequest, `{"message":"Bad Request","code":"INVALID_REQUEST","details":"Missing amount"}`)
+	apiErr := DecodeAPIError(resp)
+	if apiErr == nil || apiErr.StatusCode != 400 || apiErr.Code != "INVALID_REQUEST" || apiErr.Details != "Missing amount" {
+		t.Fatalf("Unexpected error %+v", apiErr)
+	}
+	// The body can still be read
+	body, _ := io.ReadAll(resp.Body)
+	if !strings.Contains(string(body), "INVALID_REQUEST") {
+		t.Errorf("Expected the body to be readable again, got %q", body)
+	}
+
+	apiErr = DecodeAPIError(newResponse(http.StatusBadGateway, "<html>Bad Gateway</html>"))
+	if apiErr == nil || apiErr.StatusCode != 502 || apiErr.Message != "<html>Bad Gateway</html>" {
+		t.Errorf("Expected a non-JSON body to become the message, got %+v", apiErr)
+	}
+
+	if apiErr := DecodeAPIError(newResponse(http.StatusOK, `{"id":"txn_123"}`)); apiErr != nil {
+		t.Errorf("Expected nil for a successful response, got %v", apiErr)
+	}
+	if apiErr := DecodeAPIError(nil); apiErr != nil {
+		t.Errorf("Expected nil for a nil response, got %v", apiErr)
+	}
+}
+
+func TestETagCache(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		fmt.Fprint(w, `{"id":"merchant_123","name":"Test Merchant"}`)
+	}))
+	defer server.Close()
+
+	sdk := NewSDK(&Config{
+		BaseURL:         server.URL,
+		Cache:           NewMemoryCache(),
+		EnableETagCache: true,
+	})
+
+	for i := 0; i < 2; i++ {
+		merchant, err := sdk.Merchant.GetMerchantInfo(context.Background(), "merchant_123")
+		if err != nil {
+			t.Fatalf("GetMerchantInfo() error = %v", err)
+		}
+		if merchant.Name != "Test Merchant" {
+			t.Errorf("Expected merchant name to be 'Test Merchant', got '%s'", merchant.Name)
+		}
+	}
+
+	if requests != 2 {
+		t.Errorf("Expected 2 requests, got %d", requests)
+	}
+}
+
+func TestETagCacheExpiry(t *testing.T) {
+	clock := newFakeClock()
+	var conditional int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("If-None-Match") != "" {
+			conditional++
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		fmt.Fprint(w, `{"transactions":[]}`)
+	}))
+	defer server.Close()
+
+	cache := NewMemoryCacheWithClock(clock)
+	sdk := NewSDK(&Config{BaseURL: server.URL, Cache: cache, EnableETagCache: true, ETagCacheTTL: time.Minute, Clock: clock})
+	ctx := context.Background()
+
+	// Paging through a list caches every page, until the entries expire
+	for offset := 0; offset < 200; offset += 10 {
+		if _, err := sdk.Transactions.ListTransactions(ctx, &ListTransactionsRequest{Limit: 10, Offset: offset}); err != nil {
+			t.Fatalf("ListTransactions() error = %v", err)
+		}
+	}
+	if _, err := sdk.Transactions.ListTransactions(ctx, &ListTransactionsRequest{Limit: 10, Offset: 0}); err != nil {
+		t.Fatalf("ListTransactions() error = %v", err)
+	}
+	if conditional != 1 {
+		t.Errorf("Expected a conditional request for a cached page, got %d", conditional)
+	}
+
+	clock.Advance(time.Minute)
+	if _, err := sdk.Transactions.ListTransactions(ctx, &ListTransactionsRequest{Limit: 10, Offset: 0}); err != nil {
+		t.Fatalf("ListTransactions() error = %v", err)
+	}
+	if conditional != 1 {
+		t.Errorf("Expected an expired page to be fetched unconditionally, got %d conditional requests", conditional)
+	}
+
+	// Expired pages that are never read again are swept as the cache grows
+	for offset := 200; offset < 1200; offset += 10 {
+		if _, err := sdk.Transactions.ListTransactions(ctx, &ListTransactionsRequest{Limit: 10, Offset: offset}); err != nil {
+			t.Fatalf("ListTransactions() error = %v", err)
+		}
+	}
+	cache.mu.Lock()
+	size := len(cache.entries)
+	cache.mu.Unlock()
+	if size > 101 {
+		t.Errorf("Expected expired entries to be swept, got %d entries", size)
+	}
+}
+
+func TestETagCacheKeyedByAcceptAndCredentials(t *testing.T) {
+	var notModified int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		accept, apiKey := r.Header.Get("Accept"), r.Header.Get("X-AMEX-API-KEY")
+		etag := fmt.Sprintf(`"%s %s"`, accept, apiKey)
+		if r.Header.Get("If-None-Match") == etag {
+			notModified++
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		if r.Header.Get("If-None-Match") != "" {
+			t.Errorf("Expected no validator of another representation, got %s for %s", r.Header.Get("If-None-Match"), etag)
+		}
+		w.Header().Set("ETag", etag)
+		w.Header().Set("Content-Type", accept)
+		fmt.Fprintf(w, "statement for %s as %s", apiKey, accept)
+	}))
+	defer server.Close()
+
+	ctx := context.Background()
+	cache := NewMemoryCache()
+	a := NewSDK(&Config{BaseURL: server.URL, APIKey: "key_a", Cache: cache, EnableETagCache: true})
+	b := NewSDK(&Config{BaseURL: server.URL, APIKey: "key_b", Cache: cache, EnableETagCache: true})
+
+	tests := []struct {
+		sdk    *SDK
+		format StatementFormat
+		want   string
+	}{
+		{a, StatementPDF, "statement for key_a as application/pdf"},
+		{a, StatementCSV, "statement for key_a as text/csv"},
+		{b, StatementPDF, "statement for key_b as application/pdf"},
+	}
+	// The second round is served from the cache after a 304
+	for round := 0; round < 2; round++ {
+		for _, tt := range tests {
+			body, _, err := tt.sdk.Merchant.GetStatementAs(ctx, "merchant_123", "2024-01", tt.format)
+			if err != nil {
+				t.Fatalf("GetStatementAs() error = %v", err)
+			}
+			if string(body) != tt.want {
+				t.Errorf("Expected %q, got %q", tt.want, body)
+			}
+		}
+	}
+	if notModified != len(tests) {
+		t.Errorf("Expected every representation to be revalidated once, got %d 304s", notModified)
+	}
+}
+
+func TestETagCacheUnexpectedNotModified(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotModified)
+	}))
+	defer server.Close()
+
+	for _, config := range []*Config{
+		{BaseURL: server.URL},
+		{BaseURL: server.URL, Cache: NewMemoryCache(), EnableETagCache: true},
+	} {
+		_, err := NewSDK(config).Merchant.GetMerchantInfo(context.Background(), "merchant_123")
+		if !errors.Is(err, ErrUnexpectedNotModified) {
+			t.Errorf("Expected ErrUnexpectedNotModified, got %v", err)
+		}
+	}
+}
+
+func TestETagCacheDisabledByDefault(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("If-None-Match") != "" {
+			t.Error("Expected no If-None-Match header when ETag caching is disabled")
+		}
+		w.Header().Set("ETag", `"v1"`)
+		fmt.Fprint(w, `{"id":"merchant_123"}`)
+	}))
+	defer server.Close()
+
+	sdk := NewSDK(&Config{BaseURL: server.URL, Cache: NewMemoryCache()})
+
+	for i := 0; i < 2; i++ {
+		if _, err := sdk.Merchant.GetMerchantInfo(context.Background(), "merchant_123"); err != nil {
+			t.Fatalf("GetMerchantInfo() error = %v", err)
+		}
+	}
+}
+
+// fakeClock is a Clock whose time only moves when advanced
+type fakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{now: time.Date(2024, 1, 15, 12, 0, 0, 0, time.UTC)}
+}
+
+func (fc *fakeClock) Now() time.Time {
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+	return fc.now
+}
+
+func (fc *fakeClock) Advance(d time.Duration) {
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+	fc.now = fc.now.Add(d)
+}
+
+func TestMemoryCacheTTL(t *testing.T) {
+	clock := newFakeClock()
+	cache := NewMemoryCacheWithClock(clock)
+	cache.Set("permanent", "value", 0)
+	cache.Set("expired", "value", time.Minute)
+	cache.Set("fresh", "value", 2*time.Minute)
+	clock.Advance(time.Minute)
+
+	if _, ok := cache.Get("permanent"); !ok {
+		t.Error("Expected entry without TTL to be present")
+	}
+	if _, ok := cache.Get("expired"); ok {
+		t.Error("Expected expired entry to be evicted")
+	}
+	if _, ok := cache.Get("fresh"); !ok {
+		t.Error("Expected unexpired entry to be present")
+	}
+
+	cache.Delete("permanent")
+	if _, ok := cache.Get("permanent"); ok {
+		t.Error("Expected deleted entry to be absent")
+	}
+}
+
+func TestPathPrefix(t *testing.T) {
+	var gotPaths []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPaths = append(gotPaths, r.URL.Path)
+		fmt.Fprint(w, `{}`)
+	}))
+	defer server.Close()
+
+	sdk := NewSDK(&Config{BaseURL: server.URL, PathPrefix: "v2/"})
+	sdk.Tokens.SetPathPrefix("/tokenization/v1")
+
+	ctx := context.Background()
+	if _, err := sdk.Merchant.GetMerchantInfo(ctx, "merchant_123"); err != nil {
+		t.Fatalf("GetMerchantInfo() error = %v", err)
+	}
+	if _, err := sdk.Tokens.GetToken(ctx, "token_123"); err != nil {
+		t.Fatalf("GetToken() error = %v", err)
+	}
+
+	expected := []string{"/v2/merchants/merchant_123", "/tokenization/v1/tokens/token_123"}
+	for i, path := range expected {
+		if i >= len(gotPaths) || gotPaths[i] != path {
+			t.Errorf("Expected request %d path to be '%s', got %v", i, path, gotPaths)
+		}
+	}
+}
+
+func TestServiceBaseURL(t *testing.T) {
+	newHost := func(name string, hits *[]string) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			*hits = append(*hits, name+" "+r.URL.Path)
+			fmt.Fprint(w, `{}`)
+		}))
+	}
+	var hits []string
+	gateway := newHost("gateway", &hits)
+	defer gateway.Close()
+	tokens := newHost("tokens", &hits)
+	defer tokens.Close()
+	reporting := newHost("reporting", &hits)
+	defer reporting.Close()
+
+	sdk := NewSDK(&Config{
+		BaseURL:          gateway.URL,
+		TokenBaseURL:     tokens.URL + "/",
+		ReportingBaseURL: reporting.URL,
+		PathPrefix:       "/v2",
+	})
+
+	ctx := context.Background()
+	if _, err := sdk.Tokens.GetToken(ctx, "token_123"); err != nil {
+		t.Fatalf("GetToken() error = %v", err)
+	}
+	if _, err := sdk.Merchant.GetMerchantInfo(ctx, "merchant_123"); err != nil {
+		t.Fatalf("GetMerchantInfo() error = %v", err)
+	}
+	if _, err := sdk.Payments.GetPayment(ctx, "pay_123"); err != nil {
+		t.Fatalf("GetPayment() error = %v", err)
+	}
+
+	// A service override can be changed or cleared with SetBaseURL
+	sdk.Tokens.SetBaseURL("")
+	if _, err := sdk.Tokens.GetToken(ctx, "token_456"); err != nil {
+		t.Fatalf("GetToken() error = %v", err)
+	}
+	sdk.Payments.SetBaseURL(reporting.URL)
+	if _, err := sdk.Payments.GetPayment(ctx, "pay_456"); err != nil {
+		t.Fatalf("GetPayment() error = %v", err)
+	}
+
+	expected := []string{
+		"tokens /v2/tokens/token_123",
+		"reporting /v2/merchants/merchant_123",
+		"gateway /v2/payments/pay_123",
+		"gateway /v2/tokens/token_456",
+		"reporting /v2/payments/pay_456",
+	}
+	if strings.Join(hits, ", ") != strings.Join(expected, ", ") {
+		t.Errorf("Expected requests %v, got %v", expected, hits)
+	}
+	if got := sdk.Tokens.BaseURL(); got != gateway.URL {
+		t.Errorf("Expected Tokens.BaseURL() %q, got %q", gateway.URL, got)
+	}
+	if got := sdk.Merchant.BaseURL(); got != reporting.URL {
+		t.Errorf("Expected Merchant.BaseURL() %q, got %q", reporting.URL, got)
+	}
+}
+
+func TestNormalizePathPrefix(t *testing.T) {
+	tests := []struct {
+		prefix string
+		want   string
+	}{
+		{"", ""},
+		{"/", ""},
+		{"v2", "/v2"},
+		{"/v2/", "/v2"},
+		{"/payments/v1", "/payments/v1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.prefix, func(t *testing.T) {
+			if got := normalizePathPrefix(tt.prefix); got != tt.want {
+				t.Errorf("normalizePathPrefix() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAPIVersionHeader(t *testing.T) {
+	tests := []struct {
+		name       string
+		apiVersion string
+		want       string
+	}{
+		{"default version", "", DefaultAPIVersion},
+		{"pinned version", "2025-06-01", "2025-06-01"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if got := r.Header.Get(APIVersionHeader); got != tt.want {
+					t.Errorf("Expected API version header to be '%s', got '%s'", tt.want, got)
+				}
+				w.Header().Set(APIVersionHeader, "2025-09-30")
+				fmt.Fprint(w, `{"id":"txn_123"}`)
+			}))
+			defer server.Close()
+
+			sdk := NewSDK(&Config{BaseURL: server.URL, APIVersion: tt.apiVersion})
+			transaction, err := sdk.Transactions.GetTransaction(context.Background(), "txn_123")
+			if err != nil {
+				t.Fatalf("GetTransaction() error = %v", err)
+			}
+
+			if transaction.Meta == nil {
+				t.Fatal("Expected response meta to be non-nil")
+			}
+			if transaction.Meta.APIVersion != "2025-09-30" {
+				t.Errorf("Expected server API version to be '2025-09-30', got '%s'", transaction.Meta.APIVersion)
+			}
+			if transaction.Meta.StatusCode != http.StatusOK {
+				t.Errorf("Expected status code to be %d, got %d", http.StatusOK, transaction.Meta.StatusCode)
+			}
+		})
+	}
+}
+
+func TestRequestTimeoutErrors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-time.After(200 * time.Millisecond):
+		case <-r.Context().Done():
+		}
+		fmt.Fprint(w, `{}`)
+	}))
+	defer server.Close()
+
+	t.Run("context deadline", func(t *testing.T) {
+		sdk := NewSDK(&Config{BaseURL: server.URL})
+		ctx, cancel := context.WithTimeout(context.Background(), time.Nanosecond)
+		defer cancel()
+
+		_, err := sdk.Transactions.GetTransaction(ctx, "txn_123")
+		if !errors.Is(err, ErrContextDeadline) {
+			t.Errorf("Expected ErrContextDeadline, got %v", err)
+		}
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("Expected error to still wrap context.DeadlineExceeded, got %v", err)
+		}
+	})
+
+	t.Run("context canceled", func(t *testing.T) {
+		sdk := NewSDK(&Config{BaseURL: server.URL})
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		_, err := sdk.Transactions.GetTransaction(ctx, "txn_123")
+		if !errors.Is(err, ErrContextCanceled) {
+			t.Errorf("Expected ErrContextCanceled, got %v", err)
+		}
+	})
+
+	t.Run("gateway timeout", func(t *testing.T) {
+		sdk := NewSDK(&Config{BaseURL: server.URL, Timeout: 20 * time.Millisecond})
+
+		_, err := sdk.Transactions.GetTransaction(context.Background(), "txn_123")
+		if !errors.Is(err, ErrGatewayTimeout) {
+			t.Errorf("Expected ErrGatewayTimeout, got %v", err)
+		}
+		if errors.Is(err, ErrContextDeadline) {
+			t.Errorf("Expected gateway timeout not to be reported as a context deadline, got %v", err)
+		}
+	})
+}
+
+func TestClockDefaults(t *testing.T) {
+	if client := NewClient(nil); client.clock != SystemClock {
+		t.Errorf("Expected default clock to be SystemClock, got %T", client.clock)
+	}
+
+	clock := newFakeClock()
+	client := NewClient(&Config{Clock: clock})
+	if !client.now().Equal(clock.Now()) {
+		t.Errorf("Expected client time to be %v, got %v", clock.Now(), client.now())
+	}
+}
+
+func TestTransportTimeouts(t *testing.T) {
+	client := NewClient(&Config{Timeout: time.Minute})
+	if client.httpClient.Timeout != time.Minute || client.httpClient.Transport != nil {
+		t.Errorf("Expected the default transport with an overall timeout, got %+v", client.httpClient)
+	}
+
+	client = NewClient(&Config{
+		Timeout:               2 * time.Minute,
+		DialTimeout:           2 * time.Second,
+		TLSHandshakeTimeout:   3 * time.Second,
+		ResponseHeaderTimeout: 20 * time.Second,
+	})
+	transport, ok := client.httpClient.Transport.(*http.Transport)
+	if !ok {
+		t.Fatalf("Expected an *http.Transport, got %T", client.httpClient.Transport)
+	}
+	if client.httpClient.Timeout != 2*time.Minute {
+		t.Errorf("Expected the overall timeout to be kept, got %v", client.httpClient.Timeout)
+	}
+	if transport.TLSHandshakeTimeout != 3*time.Second || transport.ResponseHeaderTimeout != 20*time.Second || transport.DialContext == nil {
+		t.Errorf("Expected the phase timeouts to be set, got TLS %v and response header %v", transport.TLSHandshakeTimeout, transport.ResponseHeaderTimeout)
+	}
+	if transport == http.DefaultTransport {
+		t.Error("Expected the default transport not to be modified")
+	}
+
+	// A slow gateway fails on the response header timeout
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(100 * time.Millisecond)
+		fmt.Fprint(w, `{"id":"merchant_123"}`)
+	}))
+	defer server.Close()
+
+	sdk := NewSDK(&Config{BaseURL: server.URL, ResponseHeaderTimeout: 10 * time.Millisecond})
+	if _, err := sdk.Merchant.GetMerchantInfo(context.Background(), "merchant_123"); err == nil {
+		t.Error("Expected the response header timeout to fail the request")
+	}
+}
+
+func TestClockSkew(t *testing.T) {
+	clock := newFakeClock()
+	serverTime := clock.Now().Add(10 * time.Minute)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Date", serverTime.Format(http.TimeFormat))
+		if r.URL.Path == "/merchants/missing" {
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `{"message":"not found"}`)
+			return
+		}
+		fmt.Fprint(w, `{"id":"merchant_123"}`)
+	}))
+	defer server.Close()
+
+	sdk := NewSDK(&Config{BaseURL: server.URL, Clock: clock})
+	ctx := context.Background()
+	if skew := sdk.ClockSkew(); skew != 0 {
+		t.Errorf("Expected no skew before the first response, got %v", skew)
+	}
+
+	if _, err := sdk.Merchant.GetMerchantInfo(ctx, "merchant_123"); err != nil {
+		t.Fatalf("GetMerchantInfo() error = %v", err)
+	}
+	if skew := sdk.ClockSkew(); skew != 10*time.Minute {
+		t.Errorf("Expected the gateway to be 10m ahead, got %v", skew)
+	}
+
+	// Error responses report the gateway time too
+	serverTime = clock.Now().Add(-5 * time.Minute)
+	if _, err := sdk.Merchant.GetMerchantInfo(ctx, "missing"); err == nil {
+		t.Fatal("Expected an error for a missing merchant")
+	}
+	if skew := sdk.ClockSkew(); skew != -5*time.Minute {
+		t.Errorf("Expected the gateway to be 5m behind, got %v", skew)
+	}
+}
+
+func TestClockSkewSignedRetry(t *testing.T) {
+	clock := newFakeClock()
+	serverTime := clock.Now().Add(10 * time.Minute)
+	var attempts int
+	var rejectAll bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		w.Header().Set("Date", serverTime.Format(http.TimeFormat))
+		created, err := verifySignature(r, "test-api-key", "test-secret-key")
+		if rejectAll || err == nil && (created.Sub(serverTime) > 5*time.Minute || serverTime.Sub(created) > 5*time.Minute) {
+			w.WriteHeader(http.StatusUnauthorized)
+			fmt.Fprintf(w, `{"message":"timestamp out of range","code":%q}`, ErrorCodeInvalidTimestamp)
+			return
+		}
+		fmt.Fprint(w, `{"id":"merchant_123"}`)
+	}))
+	defer server.Close()
+
+	logger := &recordingLogger{}
+	sdk := NewSDK(&Config{BaseURL: server.URL, APIKey: "test-api-key", SecretKey: "test-secret-key", Clock: clock, SignRequests: true, Logger: logger})
+	ctx := context.Background()
+
+	// The rejected request is resent once with the gateway's time
+	if _, err := sdk.Merchant.GetMerchantInfo(ctx, "merchant_123"); err != nil {
+		t.Fatalf("GetMerchantInfo() error = %v", err)
+	}
+	if attempts != 2 {
+		t.Errorf("Expected the request to be resent once, got %d attempts", attempts)
+	}
+	if len(logger.messages) != 1 {
+		t.Errorf("Expected the resend to be logged, got %v", logger.messages)
+	}
+
+	// The offset is kept for later requests
+	attempts = 0
+	if _, err := sdk.Merchant.GetMerchantInfo(ctx, "merchant_123"); err != nil {
+		t.Fatalf("GetMerchantInfo() error = %v", err)
+	}
+	if attempts != 1 {
+		t.Errorf("Expected the adjusted timestamp to be accepted, got %d attempts", attempts)
+	}
+
+	// A request is resent only once, even if the gateway keeps rejecting it
+	rejectAll = true
+	attempts = 0
+	_, err := sdk.Merchant.GetMerchantInfo(ctx, "merchant_123")
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.Code != ErrorCodeInvalidTimestamp {
+		t.Errorf("Expected the timestamp rejection, got %v", err)
+	}
+	if attempts != 2 {
+		t.Errorf("Expected a single resend, got %d attempts", attempts)
+	}
+
+	// Uploads of files that can't be rewound are not resent
+	attempts = 0
+	evidence := &Evidence{Rebuttal: "Delivered"}
+	file := EvidenceFile{Type: "receipt", FileName: "receipt.pdf", Content: io.MultiReader(strings.NewReader("receipt"))}
+	_, err = sdk.Disputes.SubmitEvidence(ctx, "dsp_123", evidence, file)
+	if !errors.As(err, &apiErr) || apiErr.Code != ErrorCodeInvalidTimestamp {
+		t.Errorf("Expected the timestamp rejection of a non-seekable upload, got %v", err)
+	}
+	if attempts != 1 {
+		t.Errorf("Expected a non-seekable upload not to be resent, got %d attempts", attempts)
+	}
+
+	// Unsigned requests are not resent
+	sdk = NewSDK(&Config{BaseURL: server.URL, Clock: clock})
+	attempts = 0
+	if _, err := sdk.Merchant.GetMerchantInfo(ctx, "merchant_123"); err == nil {
+		t.Error("Expected the rejection of an unsigned request to be returned")
+	}
+	if attempts != 1 {
+		t.Errorf("Expected no resend of an unsigned request, got %d attempts", attempts)
+	}
+}
+
+func TestMemoryDedupeStoreWindow(t *testing.T) {
+	clock := newFakeClock()
+	store := NewMemoryDedupeStoreWithClock(clock)
+
+	if !store.Reserve("key", time.Minute) {
+		t.Fatal("Expected first reservation to succeed")
+	}
+	if store.Reserve("key", time.Minute) {
+		t.Error("Expected second reservation within the window to fail")
+	}
+
+	clock.Advance(time.Minute)
+	if !store.Reserve("key", time.Minute) {
+		t.Error("Expected reservation after the window to succeed")
+	}
+}
+
+func TestEnvironmentBaseURL(t *testing.T) {
+	tests := []struct {
+		name   string
+		config *Config
+		want   string
+	}{
+		{"default", &Config{}, ProductionBaseURL},
+		{"production", &Config{Environment: Production}, ProductionBaseURL},
+		{"sandbox", &Config{Environment: Sandbox}, SandboxBaseURL},
+		{"explicit base URL wins", &Config{Environment: Sandbox, BaseURL: "https://proxy.example.com/api/"}, "https://proxy.example.com/api"},
+		{"north america", &Config{Region: RegionNA}, ProductionBaseURL},
+		{"europe", &Config{Region: RegionEU}, "https://gateway-eu.americanexpress.com/api"},
+		{"asia pacific sandbox", &Config{Region: RegionAPAC, Environment: Sandbox}, "https://sandbox-gateway-apac.americanexpress.com/api"},
+		{"explicit base URL overrides region", &Config{Region: RegionLATAM, BaseURL: "https://proxy.example.com/api"}, "https://proxy.example.com/api"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := NewClient(tt.config)
+			if client.baseURL != tt.want {
+				t.Errorf("Expected base URL to be '%s', got '%s'", tt.want, client.baseURL)
+			}
+		})
+	}
+}
+
+func TestRegionDefaultCurrency(t *testing.T) {
+	var sent []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Currency string `json:"currency"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("Failed to decode request body: %v", err)
+		}
+		sent = append(sent, body.Currency)
+		fmt.Fprint(w, `{"id":"pay_123","status":"succeeded"}`)
+	}))
+	defer server.Close()
+
+	newPayment := func(currency string) *PaymentRequest {
+		return &PaymentRequest{
+			Amount:     100.00,
+			Currency:   currency,
+			MerchantID: "merchant_123",
+			CardDetails: &CardDetails{
+				Number:      "4111111111111111",
+				ExpiryMonth: 12,
+				ExpiryYear:  2025,
+				CVV:         "123",
+				HolderName:  "John Doe",
+			},
+		}
+	}
+
+	ctx := context.Background()
+	sdk := NewSDK(&Config{BaseURL: server.URL, Region: RegionEU})
+	if _, err := sdk.Payments.CreatePayment(ctx, newPayment("")); err != nil {
+		t.Fatalf("CreatePayment() error = %v", err)
+	}
+	if _, err := sdk.Payments.CreatePayment(ctx, newPayment("gbp")); err != nil {
+		t.Fatalf("CreatePayment() error = %v", err)
+	}
+	sdk = NewSDK(&Config{BaseURL: server.URL, Region: RegionEU, DefaultCurrency: "chf"})
+	if _, err := sdk.Payments.CreatePayment(ctx, newPayment("")); err != nil {
+		t.Fatalf("CreatePayment() error = %v", err)
+	}
+
+	expected := []string{"EUR", "GBP", "CHF"}
+	if strings.Join(sent, ",") != strings.Join(expected, ",") {
+		t.Errorf("Expected currencies %v, got %v", expected, sent)
+	}
+
+	// Without a region or default currency, the currency stays required
+	sdk = NewSDK(&Config{BaseURL: server.URL})
+	if _, err := sdk.Payments.CreatePayment(ctx, newPayment("")); err == nil {
+		t.Error("Expected a payment without a currency to fail validation")
+	}
+
+	if _, err := NewClientWithError(&Config{Region: "mars"}); err == nil {
+		t.Error("Expected an unknown region to be rejected")
+	}
+}
+
+func TestDefaultCaptureMode(t *testing.T) {
+	var sent string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			CaptureMode string `json:"capture_mode"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("Failed to decode request body: %v", err)
+		}
+		sent = body.CaptureMode
+		fmt.Fprint(w, `{"id":"txn_123","status":"authorized"}`)
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name        string
+		defaultMode string
+		requestMode string
+		expected    string
+	}{
+		{"no default", "", "", CaptureModeManual},
+		{"default fills in", CaptureModeAuto, "", CaptureModeAuto},
+		{"request wins over default", CaptureModeAuto, CaptureModeManual, CaptureModeManual},
+		{"request without default", "", CaptureModeAuto, CaptureModeAuto},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sdk, err := NewSDKWithError(&Config{BaseURL: server.URL, DefaultCaptureMode: tt.defaultMode})
+			if err != nil {
+				t.Fatalf("NewSDKWithError() error = %v", err)
+			}
+			req := &TransactionRequest{
+				MerchantID:  "merchant_123",
+				Amount:      10,
+				Currency:    "USD",
+				CardToken:   "tok_123",
+				CaptureMode: tt.requestMode,
+			}
+			if _, err := sdk.Transactions.AuthorizeTransaction(context.Background(), req); err != nil {
+				t.Fatalf("AuthorizeTransaction() error = %v", err)
+			}
+			if sent != tt.expected {
+				t.Errorf("Expected capture mode %q, got %q", tt.expected, sent)
+			}
+			if req.CaptureMode != tt.requestMode {
+				t.Errorf("Expected the caller's request to be left untouched, got %q", req.CaptureMode)
+			}
+		})
+	}
+
+	if _, err := NewClientWithError(&Config{DefaultCaptureMode: "later"}); err == nil {
+		t.Error("Expected an unknown default capture mode to be rejected")
+	}
+}
+
+func TestClientDo(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-AMEX-API-KEY") != "test-key" {
+			t.Errorf("Expected API key header to be sent, got '%s'", r.Header.Get("X-AMEX-API-KEY"))
+		}
+		switch r.URL.Path {
+		case "/installments/plans":
+			if r.Method != http.MethodPost {
+				t.Errorf("Expected POST, got %s", r.Method)
+			}
+			fmt.Fprint(w, `{"id":"plan_123","count":3}`)
+		case "/installments/plans/plan_123":
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `{"message":"not found","code":"not_found"}`)
+		}
+	}))
+	defer server.Close()
+
+	client := NewClient(&Config{BaseURL: server.URL, APIKey: "test-key"})
+	ctx := context.Background()
+
+	var plan struct {
+		ID    string `json:"id"`
+		Count int    `json:"count"`
+	}
+	if err := client.Do(ctx, http.MethodPost, "/installments/plans", map[string]int{"count": 3}, &plan); err != nil {
+		t.Fatalf("Do() error = %v", err)
+	}
+	if plan.ID != "plan_123" || plan.Count != 3 {
+		t.Errorf("Expected decoded plan, got %+v", plan)
+	}
+
+	if err := client.Do(ctx, http.MethodDelete, "/installments/plans/plan_123", nil, nil); err != nil {
+		t.Errorf("Do() with nil out error = %v", err)
+	}
+
+	err := client.Do(ctx, http.MethodGet, "/unknown", nil, &plan)
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusNotFound {
+		t.Errorf("Expected *APIError with status 404, got %v", err)
+	}
+}
+
+func TestCorrelationID(t *testing.T) {
+	var gotIDs []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotIDs = append(gotIDs, r.Header.Get(CorrelationIDHeader))
+		fmt.Fprint(w, `{"id":"merchant_123"}`)
+	}))
+	defer server.Close()
+
+	ctx := context.Background()
+
+	sdk := NewSDK(&Config{BaseURL: server.URL})
+	info, err := sdk.Merchant.GetMerchantInfo(WithCorrelationID(ctx, "corr-123"), "merchant_123")
+	if err != nil {
+		t.Fatalf("GetMerchantInfo() error = %v", err)
+	}
+	if gotIDs[0] != "corr-123" {
+		t.Errorf("Expected correlation header 'corr-123', got '%s'", gotIDs[0])
+	}
+	if info.Meta.CorrelationID != "corr-123" {
+		t.Errorf("Expected response correlation ID 'corr-123', got '%s'", info.Meta.CorrelationID)
+	}
+
+	if _, err := sdk.Merchant.GetMerchantInfo(ctx, "merchant_123"); err != nil {
+		t.Fatalf("GetMerchantInfo() error = %v", err)
+	}
+	if gotIDs[1] != "" {
+		t.Errorf("Expected no correlation header by default, got '%s'", gotIDs[1])
+	}
+
+	sdk = NewSDK(&Config{BaseURL: server.URL, GenerateCorrelationID: true})
+	info, err = sdk.Merchant.GetMerchantInfo(ctx, "merchant_123")
+	if err != nil {
+		t.Fatalf("GetMerchantInfo() error = %v", err)
+	}
+	if len(gotIDs[2]) != 36 {
+		t.Errorf("Expected a generated UUID correlation header, got '%s'", gotIDs[2])
+	}
+	if info.Meta.CorrelationID != gotIDs[2] {
+		t.Errorf("Expected response correlation ID '%s', got '%s'", gotIDs[2], info.Meta.CorrelationID)
+	}
+}
+
+func TestRequestOptions(t *testing.T) {
+	var requests int
+	var header http.Header
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		header = r.Header
+		fmt.Fprint(w, `{"id":"payment_123","status":"captured"}`)
+	}))
+	defer server.Close()
+
+	sdk := NewSDK(&Config{BaseURL: server.URL, APIKey: "key", GenerateIdempotencyKeys: true})
+	ctx := WithRequestOptions(context.Background(), WithHeader("x-partner-route", "eu-1"))
+	ctx = WithRequestOptions(ctx, WithHeader("X-Partner-Trace", "abc"), WithHeader(IdempotencyKeyHeader, "key-1"))
+
+	if _, err := sdk.Payments.GetPayment(ctx, "payment_123"); err != nil {
+		t.Fatalf("GetPayment() error = %v", err)
+	}
+	if header.Get("X-Partner-Route") != "eu-1" || header.Get("X-Partner-Trace") != "abc" {
+		t.Errorf("Expected the option headers to be sent, got %v", header)
+	}
+	if _, err := sdk.Payments.VoidPayment(ctx, "payment_123"); err != nil {
+		t.Fatalf("VoidPayment() error = %v", err)
+	}
+	if header.Get(IdempotencyKeyHeader) != "key-1" {
+		t.Errorf("Expected the idempotency key option to replace the generated key, got %q", header.Get(IdempotencyKeyHeader))
+	}
+
+	// Reserved headers can't be overwritten, whatever their case
+	for _, key := range []string{"x-amex-api-key", "Authorization", "content-type", "Signature"} {
+		requests = 0
+		ctx := WithRequestOptions(context.Background(), WithHeader(key, "forged"))
+		_, err := sdk.Payments.GetPayment(ctx, "payment_123")
+		if !errors.Is(err, ErrReservedHeader) {
+			t.Errorf("Expected ErrReservedHeader for %s, got %v", key, err)
+		}
+		if requests != 0 {
+			t.Errorf("Expected no request to be sent with a reserved %s header", key)
+		}
+	}
+}
+
+type recordingObserver struct {
+	mu     sync.Mutex
+	events []RetryEvent
+}
+
+func (o *recordingObserver) OnRetry(event RetryEvent) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.events = append(o.events, event)
+}
+
+type recordingLogger struct {
+	mu       sync.Mutex
+	messages []string
+}
+
+func (l *recordingLogger) Warn(msg string, args ...interface{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.messages = append(l.messages, msg)
+}
+
+func TestTestCardWarning(t *testing.T) {
+	ctx := context.Background()
+	card := &CardDetails{Number: "3700 000000 00002", ExpiryMonth: 12, ExpiryYear: 2025, CVV: "1234", HolderName: "John Doe"}
+
+	tests := []struct {
+		name   string
+		config Config
+		card   *CardDetails
+		warned bool
+	}{
+		{"production", Config{}, card, true},
+		{"production region", Config{Region: RegionEU}, card, true},
+		{"sandbox", Config{Environment: Sandbox}, card, false},
+		{"custom base URL", Config{BaseURL: "https://proxy.example.com/api"}, card, false},
+		{"regular card", Config{}, &CardDetails{Number: "4111111111111111"}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logger := &recordingLogger{}
+			tt.config.Logger = logger
+			sdk := NewSDK(&tt.config)
+
+			sdk.Payments.preparePaymentRequest(ctx, &PaymentRequest{CardDetails: tt.card})
+			sdk.Transactions.prepareTransactionRequest(ctx, &TransactionRequest{CardDetails: tt.card})
+			sdk.Tokens.prepareTokenRequest(&TokenRequest{CardDetails: tt.card})
+
+			want := 0
+			if tt.warned {
+				want = 3
+			}
+			if len(logger.messages) != want {
+				t.Errorf("Expected %d warnings, got %v", want, logger.messages)
+			}
+		})
+	}
+}
+
+func TestIdempotencyKeys(t *testing.T) {
+	var keys []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		keys = append(keys, r.Header.Get(IdempotencyKeyHeader))
+		if r.Method == http.MethodPost && len(keys) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			fmt.Fprint(w, `{"message":"unavailable"}`)
+			return
+		}
+		fmt.Fprint(w, `{"id":"merchant_123"}`)
+	}))
+	defer server.Close()
+
+	ctx := context.Background()
+	retry := RetryPolicy{MaxRetries: 1, RetryWaitMin: time.Millisecond, RetryWaitMax: time.Millisecond}
+	merchant := &MerchantRequest{Name: "Acme", Email: "billing@example.com"}
+
+	tests := []struct {
+		name    string
+		config  Config
+		wantKey func(key string) bool
+	}{
+		{
+			name:    "disabled",
+			config:  Config{},
+			wantKey: func(key string) bool { return key == "" },
+		},
+		{
+			name:    "uuid by default",
+			config:  Config{GenerateIdempotencyKeys: true},
+			wantKey: func(key string) bool { return len(key) == 36 && key[14] == '4' },
+		},
+		{
+			name:    "content hash",
+			config:  Config{GenerateIdempotencyKeys: true, IdempotencyKeyFunc: ContentHashIdempotencyKey},
+			wantKey: func(key string) bool { return key == ContentHashIdempotencyKey(merchant) },
+		},
+		{
+			name: "custom",
+			config: Config{GenerateIdempotencyKeys: true, IdempotencyKeyFunc: func(req interface{}) string {
+				return "checkout-" + req.(*MerchantRequest).Name
+			}},
+			wantKey: func(key string) bool { return key == "checkout-Acme" },
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			keys = nil
+			config := tt.config
+			config.BaseURL = server.URL
+			config.Retry = retry
+			sdk := NewSDK(&config)
+
+			_, err := sdk.Merchant.CreateMerchant(ctx, merchant)
+			if tt.config.GenerateIdempotencyKeys {
+				if err != nil {
+					t.Fatalf("CreateMerchant() error = %v", err)
+				}
+				if len(keys) != 2 || keys[0] != keys[1] {
+					t.Fatalf("Expected the retry to reuse the idempotency key, got %q", keys)
+				}
+			} else if err == nil || len(keys) != 1 {
+				t.Fatalf("Expected the POST without a key not to be retried, got %d attempts", len(keys))
+			}
+			if !tt.wantKey(keys[0]) {
+				t.Errorf("Unexpected idempotency key %q", keys[0])
+			}
+
+			keys = nil
+			if _, err := sdk.Merchant.GetMerchantInfo(ctx, "merchant_123"); err != nil {
+				t.Fatalf("GetMerchantInfo() error = %v", err)
+			}
+			if keys[0] != "" {
+				t.Errorf("Expected no idempotency key on GET, got %q", keys[0])
+			}
+		})
+	}
+}
+
+func TestLanguage(t *testing.T) {
+	var languages []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		language := r.Header.Get("Accept-Language")
+		languages = append(languages, language)
+		if language != "" {
+			w.Header().Set("Content-Language", language)
+		}
+		if r.Method == http.MethodPost {
+			w.WriteHeader(http.StatusPaymentRequired)
+			fmt.Fprint(w, `{"message":"Fonds insuffisants","processor_code":"51"}`)
+			return
+		}
+		fmt.Fprint(w, `{"id":"merchant_123"}`)
+	}))
+	defer server.Close()
+
+	ctx := context.Background()
+
+	// No header is sent by default
+	sdk := NewSDK(&Config{BaseURL: server.URL})
+	merchant, err := sdk.Merchant.GetMerchantInfo(ctx, "merchant_123")
+	if err != nil {
+		t.Fatalf("GetMerchantInfo() error = %v", err)
+	}
+	if languages[0] != "" || merchant.Meta.Language != "" {
+		t.Errorf("Expected no language, got %q and %q", languages[0], merchant.Meta.Language)
+	}
+
+	sdk = NewSDK(&Config{BaseURL: server.URL, Language: "fr-CA"})
+	merchant, err = sdk.Merchant.GetMerchantInfo(ctx, "merchant_123")
+	if err != nil {
+		t.Fatalf("GetMerchantInfo() error = %v", err)
+	}
+	if languages[1] != "fr-CA" || merchant.Meta.Language != "fr-CA" {
+		t.Errorf("Expected fr-CA, got %q and %q", languages[1], merchant.Meta.Language)
+	}
+
+	// A request option overrides the configured language, and the language
+	// of a decline message is reported on the error
+	_, err = sdk.Transactions.AuthorizeTransaction(WithRequestOptions(ctx, WithLanguage("fr-FR")),
+		&TransactionRequest{Amount: 10, Currency: "EUR", MerchantID: "merchant_123", CardToken: "tok_123"})
+	var declineErr *DeclineError
+	if !errors.As(err, &declineErr) {
+		t.Fatalf("Expected a *DeclineError, got %v", err)
+	}
+	if languages[2] != "fr-FR" || declineErr.Err.Language != "fr-FR" || declineErr.Reason != "Fonds insuffisants" {
+		t.Errorf("Expected a fr-FR decline reason, got %q, %q and %q", languages[2], declineErr.Err.Language, declineErr.Reason)
+	}
+}
+
+func TestIdempotencyReplayed(t *testing.T) {
+	var attempts int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		if r.Method == http.MethodPost && attempts == 1 {
+			// The payment went through but the response was lost
+			w.WriteHeader(http.StatusServiceUnavailable)
+			fmt.Fprint(w, `{"message":"unavailable"}`)
+			return
+		}
+		if r.Method == http.MethodPost {
+			w.Header().Set(IdempotencyReplayedHeader, "true")
+		}
+		fmt.Fprint(w, `{"id":"pay_123","status":"succeeded"}`)
+	}))
+	defer server.Close()
+
+	sdk := NewSDK(&Config{
+		BaseURL:                 server.URL,
+		GenerateIdempotencyKeys: true,
+		Retry:                   RetryPolicy{MaxRetries: 1, RetryWaitMin: time.Millisecond},
+	})
+	ctx := context.Background()
+
+	payment, err := sdk.Payments.CreatePayment(ctx, &PaymentRequest{Amount: 10, Currency: "USD", MerchantID: "merchant_123", CardToken: "tok_123"})
+	if err != nil {
+		t.Fatalf("CreatePayment() error = %v", err)
+	}
+	if attempts != 2 || !payment.Meta.IdempotencyReplayed {
+		t.Errorf("Expected the retry to be reported as replayed, got %d attempts and %v", attempts, payment.Meta.IdempotencyReplayed)
+	}
+
+	payment, err = sdk.Payments.GetPayment(ctx, "pay_123")
+	if err != nil {
+		t.Fatalf("GetPayment() error = %v", err)
+	}
+	if payment.Meta.IdempotencyReplayed {
+		t.Error("Expected a response without the header not to be reported as replayed")
+	}
+}
+
+func TestContentHashIdempotencyKey(t *testing.T) {
+	a := ContentHashIdempotencyKey(&TransactionRequest{Amount: 10, Currency: "USD", MerchantID: "m"})
+	b := ContentHashIdempotencyKey(&TransactionRequest{Amount: 10, Currency: "USD", MerchantID: "m"})
+	c := ContentHashIdempotencyKey(&TransactionRequest{Amount: 11, Currency: "USD", MerchantID: "m"})
+	if a != b {
+		t.Errorf("Expected identical requests to share a key, got %q and %q", a, b)
+	}
+	if a == c {
+		t.Error("Expected different requests to have different keys")
+	}
+}
+
+func TestRetryPolicy(t *testing.T) {
+	var attempts int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		if attempts < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			fmt.Fprint(w, `{"message":"unavailable"}`)
+			return
+		}
+		fmt.Fprint(w, `{"id":"merchant_123"}`)
+	}))
+	defer server.Close()
+
+	observer := &recordingObserver{}
+	logger := &recordingLogger{}
+	sdk := NewSDK(&Config{
+		BaseURL:  server.URL,
+		Retry:    RetryPolicy{MaxRetries: 3, RetryWaitMin: time.Millisecond, RetryWaitMax: 2 * time.Millisecond},
+		Logger:   logger,
+		Observer: observer,
+	})
+
+	if _, err := sdk.Merchant.GetMerchantInfo(context.Background(), "merchant_123"); err != nil {
+		t.Fatalf("GetMerchantInfo() error = %v", err)
+	}
+	if attempts != 3 {
+		t.Errorf("Expected 3 attempts, got %d", attempts)
+	}
+	if len(observer.events) != 2 || len(logger.messages) != 2 {
+		t.Fatalf("Expected 2 retry events and log messages, got %d and %d", len(observer.events), len(logger.messages))
+	}
+
+	wantDelays := []time.Duration{time.Millisecond, 2 * time.Millisecond}
+	for i, event := range observer.events {
+		if event.Attempt != i+1 {
+			t.Errorf("Expected event %d attempt to be %d, got %d", i, i+1, event.Attempt)
+		}
+		if event.StatusCode != http.StatusServiceUnavailable {
+			t.Errorf("Expected event %d status to be 503, got %d", i, event.StatusCode)
+		}
+		if event.Delay != wantDelays[i] {
+			t.Errorf("Expected event %d delay to be %v, got %v", i, wantDelays[i], event.Delay)
+		}
+		if event.Method != http.MethodGet || event.Path != "/merchants/merchant_123" {
+			t.Errorf("Expected event %d for GET /merchants/merchant_123, got %s %s", i, event.Method, event.Path)
+		}
+	}
+}
+
+func TestRetryPredicate(t *testing.T) {
+	var attempts int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		switch r.URL.Path {
+		case "/merchants/merchant_123":
+			if attempts == 1 {
+				w.WriteHeader(http.StatusBadRequest)
+				fmt.Fprint(w, `{"message":"slow down","code":"rate_limited"}`)
+				return
+			}
+			fmt.Fprint(w, `{"id":"merchant_123"}`)
+		default:
+			w.WriteHeader(http.StatusServiceUnavailable)
+			fmt.Fprint(w, `{"message":"unavailable"}`)
+		}
+	}))
+	defer server.Close()
+
+	var bodies []string
+	predicate := func(resp *http.Response, err error) bool {
+		var apiErr *APIError
+		if errors.As(err, &apiErr) && apiErr.Code == "rate_limited" {
+			body, _ := io.ReadAll(resp.Body)
+			bodies = append(bodies, string(body))
+			return resp.StatusCode == http.StatusBadRequest
+		}
+		return false
+	}
+	sdk := NewSDK(&Config{
+		BaseURL: server.URL,
+		Retry:   RetryPolicy{MaxRetries: 3, RetryWaitMin: time.Millisecond, Predicate: predicate},
+	})
+	ctx := context.Background()
+
+	if _, err := sdk.Merchant.GetMerchantInfo(ctx, "merchant_123"); err != nil {
+		t.Fatalf("GetMerchantInfo() error = %v", err)
+	}
+	if attempts != 2 {
+		t.Errorf("Expected the 400 to be retried, got %d attempts", attempts)
+	}
+	if len(bodies) != 1 || !strings.Contains(bodies[0], "slow down") {
+		t.Errorf("Expected the predicate to read the error body, got %q", bodies)
+	}
+
+	// The predicate replaces the default rules, so a 503 is not retried
+	attempts = 0
+	if _, err := sdk.Merchant.GetMerchantInfo(ctx, "merchant_456"); err == nil {
+		t.Fatal("Expected an error")
+	}
+	if attempts != 1 {
+		t.Errorf("Expected 1 attempt, got %d", attempts)
+	}
+
+	// DefaultRetryPredicate keeps the standard rules
+	if !DefaultRetryPredicate(nil, &APIError{StatusCode: http.StatusServiceUnavailable}) || DefaultRetryPredicate(nil, &APIError{StatusCode: http.StatusBadRequest}) {
+		t.Error("Expected DefaultRetryPredicate to retry 503 only")
+	}
+}
+
+func TestRetryPolicyLimits(t *testing.T) {
+	var attempts int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	ctx := context.Background()
+	retry := RetryPolicy{MaxRetries: 2, RetryWaitMin: time.Millisecond}
+
+	tests := []struct {
+		name     string
+		config   *Config
+		call     func(c *Client) error
+		attempts int
+	}{
+		{
+			name:     "disabled by default",
+			config:   &Config{BaseURL: server.URL},
+			call:     func(c *Client) error { return c.Do(ctx, http.MethodGet, "/merchants/merchant_123", nil, nil) },
+			attempts: 1,
+		},
+		{
+			name:     "retries exhausted",
+			config:   &Config{BaseURL: server.URL, Retry: retry},
+			call:     func(c *Client) error { return c.Do(ctx, http.MethodGet, "/merchants/merchant_123", nil, nil) },
+			attempts: 3,
+		},
+		{
+			name:     "POST without idempotency key",
+			config:   &Config{BaseURL: server.URL, Retry: retry},
+			call:     func(c *Client) error { return c.Do(ctx, http.MethodPost, "/transactions/authorize", nil, nil) },
+			attempts: 1,
+		},
+		{
+			name:   "POST with idempotency key",
+			config: &Config{BaseURL: server.URL, Retry: retry},
+			call: func(c *Client) error {
+				_, err := c.doRequest(ctx, &Request{
+					Method:  http.MethodPost,
+					Path:    "/transactions/authorize",
+					Headers: map[string]string{"Idempotency-Key": "key_123"},
+				})
+				return err
+			},
+			attempts: 3,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			attempts = 0
+			err := tt.call(NewClient(tt.config))
+
+			var apiErr *APIError
+			if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusServiceUnavailable {
+				t.Errorf("Expected the last *APIError to be returned, got %v", err)
+			}
+			if attempts != tt.attempts {
+				t.Errorf("Expected %d attempts, got %d", tt.attempts, attempts)
+			}
+		})
+	}
+}
+
+func TestRetryMaxElapsedTime(t *testing.T) {
+	clock := newFakeClock()
+	var attempts int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		// Every attempt takes 40ms of the budget
+		clock.Advance(40 * time.Millisecond)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	t.Run("budget exhausted", func(t *testing.T) {
+		attempts = 0
+		client := NewClient(&Config{
+			BaseURL: server.URL,
+			Clock:   clock,
+			Retry:   RetryPolicy{MaxRetries: 10, RetryWaitMin: time.Millisecond, RetryWaitMax: time.Millisecond, MaxElapsedTime: 100 * time.Millisecond},
+		})
+
+		err := client.Do(context.Background(), http.MethodGet, "/merchants/merchant_123", nil, nil)
+		if !errors.Is(err, ErrRetryBudgetExhausted) {
+			t.Fatalf("Expected ErrRetryBudgetExhausted, got %v", err)
+		}
+		var apiErr *APIError
+		if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusServiceUnavailable {
+			t.Errorf("Expected the last *APIError to be wrapped, got %v", err)
+		}
+		// 40ms and 80ms leave room for another attempt; 120ms does not
+		if attempts != 3 {
+			t.Errorf("Expected 3 attempts, got %d", attempts)
+		}
+	})
+
+	t.Run("context deadline tighter", func(t *testing.T) {
+		attempts = 0
+		client := NewClient(&Config{
+			BaseURL: server.URL,
+			Clock:   clock,
+			Retry:   RetryPolicy{MaxRetries: 10, RetryWaitMin: time.Second, MaxElapsedTime: time.Hour},
+		})
+
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+
+		err := client.Do(ctx, http.MethodGet, "/merchants/merchant_123", nil, nil)
+		if !errors.Is(err, ErrContextDeadline) {
+			t.Fatalf("Expected ErrContextDeadline, got %v", err)
+		}
+		if errors.Is(err, ErrRetryBudgetExhausted) {
+			t.Errorf("Expected the retry budget not to be reported, got %v", err)
+		}
+		if attempts != 1 {
+			t.Errorf("Expected 1 attempt, got %d", attempts)
+		}
+	})
+}
+
+func TestRetryPolicyValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		policy  RetryPolicy
+		field   string
+		wantErr bool
+	}{
+		{"zero value", RetryPolicy{}, "", false},
+		{"typical", RetryPolicy{MaxRetries: 3, RetryWaitMin: 100 * time.Millisecond, RetryWaitMax: time.Second, MaxElapsedTime: 10 * time.Second}, "", false},
+		{"max retries at limit", RetryPolicy{MaxRetries: MaxRetriesLimit}, "", false},
+		{"negative max retries", RetryPolicy{MaxRetries: -1}, "max_retries", true},
+		{"max retries over limit", RetryPolicy{MaxRetries: MaxRetriesLimit + 1}, "max_retries", true},
+		{"negative wait min", RetryPolicy{RetryWaitMin: -time.Second}, "retry_wait_min", true},
+		{"negative wait max", RetryPolicy{RetryWaitMax: -time.Second}, "retry_wait_max", true},
+		{"negative max elapsed time", RetryPolicy{MaxElapsedTime: -time.Second}, "max_elapsed_time", true},
+		{"min above max", RetryPolicy{RetryWaitMin: 2 * time.Second, RetryWaitMax: time.Second}, "retry_wait_min", true},
+		{"min above default max", RetryPolicy{RetryWaitMin: time.Minute}, "retry_wait_min", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.policy.Validate()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			var validationErr *ValidationError
+			if tt.wantErr && (!errors.As(err, &validationErr) || validationErr.Field != tt.field) {
+				t.Errorf("Expected a *ValidationError on %s, got %v", tt.field, err)
+			}
+		})
+	}
+}
+
+func TestNewClientWithError(t *testing.T) {
+	client, err := NewClientWithError(&Config{Retry: RetryPolicy{MaxRetries: 3}})
+	if err != nil || client == nil {
+		t.Fatalf("NewClientWithError() = %v, %v", client, err)
+	}
+	if client.retry.RetryWaitMin != DefaultRetryWaitMin {
+		t.Errorf("Expected the default wait min, got %v", client.retry.RetryWaitMin)
+	}
+
+	if _, err := NewClientWithError(nil); err != nil {
+		t.Errorf("Expected a nil config to be valid, got %v", err)
+	}
+
+	_, err = NewClientWithError(&Config{Retry: RetryPolicy{RetryWaitMin: time.Second, RetryWaitMax: time.Millisecond}})
+	var validationErr *ValidationError
+	if !errors.As(err, &validationErr) || validationErr.Field != "retry.retry_wait_min" {
+		t.Errorf("Expected a *ValidationError on retry.retry_wait_min, got %v", err)
+	}
+
+	if sdk, err := NewSDKWithError(&Config{Retry: RetryPolicy{MaxRetries: -1}}); err == nil || sdk != nil {
+		t.Errorf("Expected NewSDKWithError to reject the config, got %v, %v", sdk, err)
+	}
+	if sdk, err := NewSDKWithError(&Config{}); err != nil || sdk.Transactions == nil {
+		t.Errorf("NewSDKWithError() = %v, %v", sdk, err)
+	}
+}
+
+func TestSetCredentials(t *testing.T) {
+	var mu sync.Mutex
+	keys := make(map[string]int)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		keys[r.Header.Get("X-AMEX-API-KEY")]++
+		mu.Unlock()
+		fmt.Fprint(w, `{"id":"merchant_123"}`)
+	}))
+	defer server.Close()
+
+	sdk := NewSDK(&Config{BaseURL: server.URL, APIKey: "key-1", SecretKey: "secret-1"})
+	ctx := context.Background()
+
+	// Rotate while requests are in flight; run with -race
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 10; j++ {
+				if _, err := sdk.Merchant.GetMerchantInfo(ctx, "merchant_123"); err != nil {
+					t.Errorf("GetMerchantInfo() error = %v", err)
+				}
+			}
+		}()
+	}
+	for i := 2; i <= 5; i++ {
+		sdk.SetCredentials(fmt.Sprintf("key-%d", i), fmt.Sprintf("secret-%d", i))
+	}
+	wg.Wait()
+
+	for key := range keys {
+		if !strings.HasPrefix(key, "key-") {
+			t.Errorf("Unexpected API key %q", key)
+		}
+	}
+
+	// Requests after the rotation use the new key
+	keys = make(map[string]int)
+	if _, err := sdk.Merchant.GetMerchantInfo(ctx, "merchant_123"); err != nil {
+		t.Fatalf("GetMerchantInfo() error = %v", err)
+	}
+	if keys["key-5"] != 1 {
+		t.Errorf("Expected the rotated key to be sent, got %v", keys)
+	}
+	if apiKey, secretKey := sdk.credentials(); apiKey != "key-5" || secretKey != "secret-5" {
+		t.Errorf("Expected key-5 and secret-5, got %q and %q", apiKey, secretKey)
+	}
+}
+
+func TestNewClientWithErrorBaseURL(t *testing.T) {
+	tests := []struct {
+		name   string
+		config Config
+		field  string
+	}{
+		{"missing scheme", Config{BaseURL: "gateway.example.com/api"}, "BaseURL"},
+		{"unsupported scheme", Config{BaseURL: "ftp://gateway.example.com"}, "BaseURL"},
+		{"empty host", Config{BaseURL: "https:///api"}, "BaseURL"},
+		{"malformed", Config{BaseURL: "https://gateway example.com"}, "BaseURL"},
+		{"service URL", Config{TokenBaseURL: "tokens.example.com"}, "TokenBaseURL"},
+		{"valid", Config{BaseURL: "http://localhost:8080/api", ReportingBaseURL: "https://reports.example.com"}, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewClientWithError(&tt.config)
+			if tt.field == "" {
+				if err != nil {
+					t.Errorf("Expected a valid config, got %v", err)
+				}
+				return
+			}
+			var urlErr *InvalidBaseURLError
+			if !errors.As(err, &urlErr) || urlErr.Field != tt.field {
+				t.Errorf("Expected an *InvalidBaseURLError on %s, got %v", tt.field, err)
+			}
+		})
+	}
+
+	client, err := NewClientWithError(&Config{BaseURL: "https://gateway.example.com/api/", PaymentBaseURL: "https://payments.example.com/"})
+	if err != nil {
+		t.Fatalf("NewClientWithError() error = %v", err)
+	}
+	if client.baseURL != "https://gateway.example.com/api" || client.paymentBaseURL != "https://payments.example.com" {
+		t.Errorf("Expected trailing slashes to be trimmed, got %q and %q", client.baseURL, client.paymentBaseURL)
+	}
+}
+
+func TestRetryBackoff(t *testing.T) {
+	policy := RetryPolicy{RetryWaitMin: 100 * time.Millisecond, RetryWaitMax: time.Second}
+	want := []time.Duration{100 * time.Millisecond, 200 * time.Millisecond, 400 * time.Millisecond, 800 * time.Millisecond, time.Second, time.Second}
+	for i, delay := range want {
+		if got := policy.backoff(i + 1); got != delay {
+			t.Errorf("Expected retry %d backoff to be %v, got %v", i+1, delay, got)
+		}
+	}
+}
+
+func TestParseContentRange(t *testing.T) {
+	tests := []struct {
+		header  string
+		want    *ContentRange
+		hasMore bool
+		wantErr bool
+	}{
+		{"items 0-99/1234", &ContentRange{Unit: "items", Start: 0, End: 99, Total: 1234}, true, false},
+		{"items 1200-1233/1234", &ContentRange{Unit: "items", Start: 1200, End: 1233, Total: 1234}, false, false},
+		{"items 0-99/*", &ContentRange{Unit: "items", Start: 0, End: 99, Total: -1}, true, false},
+		{"items 99-0/100", nil, false, true},
+		{"bogus", nil, false, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.header, func(t *testing.T) {
+			got, err := parseContentRange(tt.header)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseContentRange() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if *got != *tt.want {
+				t.Errorf("Expected %+v, got %+v", tt.want, got)
+			}
+			if got.HasMore() != tt.hasMore {
+				t.Errorf("Expected HasMore() to be %v", tt.hasMore)
+			}
+		})
+	}
+}
+
+func TestMerchantService_IterateSettlements(t *testing.T) {
+	tests := []struct {
+		name  string
+		pages map[string]string
+	}{
+		{
+			name: "bare lists",
+			pages: map[string]string{
+				"":  `[{"id":"stl_1"},{"id":"stl_2"}]`,
+				"2": `[{"id":"stl_3"}]`,
+			},
+		},
+		{
+			name: "paged envelopes",
+			pages: map[string]string{
+				"":  `{"settlements":[{"id":"stl_1"},{"id":"stl_2"}],"total":3,"has_more":true}`,
+				"2": `{"settlements":[{"id":"stl_3"}],"total":3,"has_more":true}`,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var requests int
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				requests++
+				if r.URL.Path != "/merchants/merchant_123/settlements" {
+					t.Errorf("Unexpected path %s", r.URL.Path)
+				}
+				body, ok := tt.pages[r.URL.Query().Get("offset")]
+				if !ok {
+					t.Errorf("Unexpected offset %s", r.URL.Query().Get("offset"))
+					body = `[]`
+				}
+				fmt.Fprint(w, body)
+			}))
+			defer server.Close()
+
+			sdk := NewSDK(&Config{BaseURL: server.URL})
+			it := sdk.Merchant.IterateSettlements(&ListSettlementsRequest{MerchantID: "merchant_123", Limit: 2})
+
+			var ids []string
+			for it.Next(context.Background()) {
+				ids = append(ids, it.Item().ID)
+			}
+			if err := it.Err(); err != nil {
+				t.Fatalf("Iterator error = %v", err)
+			}
+			if fmt.Sprint(ids) != "[stl_1 stl_2 stl_3]" {
+				t.Errorf("Expected all three settlements, got %v", ids)
+			}
+			// The short second page ends the data without a third request
+			if requests != 2 {
+				t.Errorf("Expected 2 requests, got %d", requests)
+			}
+		})
+	}
+}
+
+func TestMerchantService_CreateAndUpdateMerchant(t *testing.T) {
+	var methods []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		methods = append(methods, r.Method+" "+r.URL.Path)
+		fmt.Fprint(w, `{"id":"merchant_456","name":"Acme Store","status":"active"}`)
+	}))
+	defer server.Close()
+
+	sdk := NewSDK(&Config{BaseURL: server.URL})
+	ctx := context.Background()
+
+	merchant, err := sdk.Merchant.CreateMerchant(ctx, &MerchantRequest{Name: "Acme Store", Website: "https://acme.example"})
+	if err != nil {
+		t.Fatalf("CreateMerchant() error = %v", err)
+	}
+	if merchant.ID != "merchant_456" {
+		t.Errorf("Expected merchant ID 'merchant_456', got '%s'", merchant.ID)
+	}
+
+	if _, err := sdk.Merchant.UpdateMerchant(ctx, "merchant_456", &UpdateMerchantRequest{Email: Ptr("ops@acme.example")}); err != nil {
+		t.Fatalf("UpdateMerchant() error = %v", err)
+	}
+
+	if _, err := sdk.Merchant.UpdateMerchant(ctx, "merchant_456", &UpdateMerchantRequest{Email: Ptr("invalid")}); err == nil {
+		t.Error("Expected UpdateMerchant() to reject an invalid email")
+	}
+
+	expected := []string{"POST /merchants", "PUT /merchants/merchant_456"}
+	if fmt.Sprint(methods) != fmt.Sprint(expected) {
+		t.Errorf("Expected requests %v, got %v", expected, methods)
+	}
+}
+
+func TestMerchantService_UpdateMerchantPartial(t *testing.T) {
+	var body map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body = nil
+		json.NewDecoder(r.Body).Decode(&body)
+		fmt.Fprint(w, `{"id":"merchant_456","name":"Acme Store","status":"active"}`)
+	}))
+	defer server.Close()
+
+	sdk := NewSDK(&Config{BaseURL: server.URL})
+
+	tests := []struct {
+		name     string
+		req      *UpdateMerchantRequest
+		expected map[string]interface{}
+	}{
+		{"nil fields are left unchanged", &UpdateMerchantRequest{}, map[string]interface{}{}},
+		{"empty fields are cleared", &UpdateMerchantRequest{Website: Ptr(""), Phone: Ptr("")}, map[string]interface{}{"website": "", "phone": ""}},
+		{"set fields are changed", &UpdateMerchantRequest{Description: Ptr("Hardware")}, map[string]interface{}{"description": "Hardware"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := sdk.Merchant.UpdateMerchant(context.Background(), "merchant_456", tt.req); err != nil {
+				t.Fatalf("UpdateMerchant() error = %v", err)
+			}
+			if fmt.Sprint(body) != fmt.Sprint(tt.expected) {
+				t.Errorf("Expected body %v, got %v", tt.expected, body)
+			}
+		})
+	}
+}
+
+func TestUserAgentSuffix(t *testing.T) {
+	base := "AmexSDK-Go/" + SDKVersion
+	tests := []struct {
+		name   string
+		suffix string
+		want   string
+	}{
+		{"unset", "", base},
+		{"application", "mycheckout/2.3.1", base + " mycheckout/2.3.1"},
+		{"with comment", " mycheckout/2.3.1  (linux) ", base + " mycheckout/2.3.1 (linux)"},
+		{"invalid characters", "my\"checkout\r\nX-Injected: 1", base + " my_checkout X-Injected: 1"},
+		{"non-ASCII", "kassé/1.0", base + " kass_/1.0"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got string
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				got = r.Header.Get("User-Agent")
+				fmt.Fprint(w, `{}`)
+			}))
+			defer server.Close()
+
+			client := NewClient(&Config{BaseURL: server.URL, UserAgentSuffix: tt.suffix})
+			if err := client.Do(context.Background(), http.MethodGet, "/ping", nil, nil); err != nil {
+				t.Fatalf("Do() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Expected User-Agent '%s', got '%s'", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestSlowRequestCallback(t *testing.T) {
+	clock := newFakeClock()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slow" {
+			clock.Advance(3 * time.Second)
+		} else {
+			clock.Advance(500 * time.Millisecond)
+		}
+		fmt.Fprint(w, `{}`)
+	}))
+	defer server.Close()
+
+	type slowCall struct {
+		method   string
+		path     string
+		duration time.Duration
+	}
+	var calls []slowCall
+	client := NewClient(&Config{
+		BaseURL:              server.URL,
+		Clock:                clock,
+		SlowRequestThreshold: 2 * time.Second,
+		SlowRequestCallback: func(method, path string, duration time.Duration) {
+			calls = append(calls, slowCall{method, path, duration})
+		},
+	})
+
+	ctx := context.Background()
+	if err := client.Do(ctx, http.MethodGet, "/fast", nil, nil); err != nil {
+		t.Fatalf("Do() error = %v", err)
+	}
+	if err := client.Do(ctx, http.MethodPost, "/slow", nil, nil); err != nil {
+		t.Fatalf("Do() error = %v", err)
+	}
+
+	if len(calls) != 1 {
+		t.Fatalf("Expected 1 slow request, got %v", calls)
+	}
+	if calls[0] != (slowCall{http.MethodPost, "/slow", 3 * time.Second}) {
+		t.Errorf("Expected slow POST /slow taking 3s, got %+v", calls[0])
+	}
+}
+
+func TestMerchantService_GetCapabilities(t *testing.T) {
+	var requests int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if r.URL.Path != "/merchants/merchant_123/capabilities" {
+			t.Errorf("Expected path /merchants/merchant_123/capabilities, got %s", r.URL.Path)
+		}
+		fmt.Fprint(w, `{"merchant_id":"merchant_123","multi_currency":true,"three_d_secure":true,"wallets":["applepay"],"supported_currencies":["USD","EUR"]}`)
+	}))
+	defer server.Close()
+
+	ctx := context.Background()
+	clock := newFakeClock()
+	sdk := NewSDK(&Config{
+		BaseURL:              server.URL,
+		Clock:                clock,
+		Cache:                NewMemoryCacheWithClock(clock),
+		CapabilitiesCacheTTL: time.Minute,
+	})
+
+	capabilities, err := sdk.Merchant.GetCapabilities(ctx, "merchant_123")
+	if err != nil {
+		t.Fatalf("GetCapabilities() error = %v", err)
+	}
+	if !capabilities.MultiCurrency || !capabilities.ThreeDSecure || capabilities.IncrementalAuthorization {
+		t.Errorf("Unexpected capabilities %+v", capabilities)
+	}
+	if !capabilities.SupportsCurrency("eur") || capabilities.SupportsCurrency("JPY") {
+		t.Error("Expected EUR to be supported and JPY not")
+	}
+	if !capabilities.SupportsWallet(WalletApplePay) || capabilities.SupportsWallet(WalletGooglePay) {
+		t.Error("Expected Apple Pay to be supported and Google Pay not")
+	}
+
+	// Changing a returned value must not change the cached one
+	capabilities.SupportedCurrencies[0] = "GBP"
+
+	cached, err := sdk.Merchant.GetCapabilities(ctx, "merchant_123")
+	if err != nil {
+		t.Fatalf("GetCapabilities() error = %v", err)
+	}
+	if requests != 1 {
+		t.Errorf("Expected cached capabilities to be served, got %d requests", requests)
+	}
+	if !cached.SupportsCurrency("USD") {
+		t.Error("Expected cached capabilities to be unchanged")
+	}
+
+	clock.Advance(time.Minute)
+	if _, err := sdk.Merchant.GetCapabilities(ctx, "merchant_123"); err != nil {
+		t.Fatalf("GetCapabilities() error = %v", err)
+	}
+	if requests != 2 {
+		t.Errorf("Expected capabilities to be fetched again after the TTL, got %d requests", requests)
+	}
+
+	// Without a cache every call reaches the gateway
+	requests = 0
+	uncached := NewSDK(&Config{BaseURL: server.URL})
+	for i := 0; i < 2; i++ {
+		if _, err := uncached.Merchant.GetCapabilities(ctx, "merchant_123"); err != nil {
+			t.Fatalf("GetCapabilities() error = %v", err)
+		}
+	}
+	if requests != 2 {
+		t.Errorf("Expected 2 requests without a cache, got %d", requests)
+	}
+}
+
+func TestSurchargeNotAllowed(t *testing.T) {
+	var requests int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		switch r.URL.Path {
+		case "/merchants/merchant_eu/capabilities":
+			fmt.Fprint(w, `{"merchant_id":"merchant_eu","surcharging":false}`)
+		case "/merchants/merchant_us/capabilities":
+			fmt.Fprint(w, `{"merchant_id":"merchant_us","surcharging":true}`)
+		default:
+			fmt.Fprint(w, `{"id":"txn_123"}`)
+		}
+	}))
+	defer server.Close()
+
+	ctx := context.Background()
+	sdk := NewSDK(&Config{BaseURL: server.URL, Cache: NewMemoryCache()})
+	newRequest := func(merchantID string) *TransactionRequest {
+		return &TransactionRequest{Amount: 103, Currency: "USD", MerchantID: merchantID, CardToken: "tok_123", SurchargeAmount: Ptr(3.0)}
+	}
+
+	// Without cached capabilities the gateway decides
+	if _, err := sdk.Transactions.AuthorizeTransaction(ctx, newRequest("merchant_eu")); err != nil {
+		t.Fatalf("AuthorizeTransaction() error = %v", err)
+	}
+	if requests != 1 {
+		t.Errorf("Expected capabilities not to be fetched for the check, got %d requests", requests)
+	}
+
+	for _, merchantID := range []string{"merchant_eu", "merchant_us"} {
+		if _, err := sdk.Merchant.GetCapabilities(ctx, merchantID); err != nil {
+			t.Fatalf("GetCapabilities() error = %v", err)
+		}
+	}
+
+	_, err := sdk.Transactions.AuthorizeTransaction(ctx, newRequest("merchant_eu"))
+	if !errors.Is(err, ErrSurchargeNotAllowed) {
+		t.Errorf("Expected ErrSurchargeNotAllowed, got %v", err)
+	}
+	_, err = sdk.Payments.CreatePayment(ctx, &PaymentRequest{Amount: 103, Currency: "USD", MerchantID: "merchant_eu", CardToken: "tok_123", SurchargeAmount: Ptr(3.0)})
+	if !errors.Is(err, ErrSurchargeNotAllowed) {
+		t.Errorf("Expected ErrSurchargeNotAllowed for the payment, got %v", err)
+	}
+	if _, err := sdk.Transactions.AuthorizeTransaction(ctx, newRequest("merchant_us")); err != nil {
+		t.Errorf("Expected the surcharge to be allowed, got %v", err)
+	}
+
+	// A merchant that may not surcharge can still itemize tax
+	req := newRequest("merchant_eu")
+	req.SurchargeAmount, req.TaxAmount = nil, Ptr(3.0)
+	if _, err := sdk.Transactions.AuthorizeTransaction(ctx, req); err != nil {
+		t.Errorf("Expected tax to be allowed, got %v", err)
+	}
+}
+
+func TestMerchantService_GetSupportedCurrencies(t *testing.T) {
+	var requests int
+	body := `{"merchant_id":"merchant_123","supported_currencies":["usd","EUR"]}`
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if r.URL.Path == "/merchants/merchant_down/capabilities" {
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `{"error":{"code":"not_found","message":"merchant not found"}}`)
+			return
+		}
+		fmt.Fprint(w, body)
+	}))
+	defer server.Close()
+
+	ctx := context.Background()
+	clock := newFakeClock()
+	sdk := NewSDK(&Config{
+		BaseURL:              server.URL,
+		Clock:                clock,
+		Cache:                NewMemoryCacheWithClock(clock),
+		CapabilitiesCacheTTL: time.Minute,
+	})
+
+	currencies, err := sdk.Merchant.GetSupportedCurrencies(ctx, "merchant_123")
+	if err != nil {
+		t.Fatalf("GetSupportedCurrencies() error = %v", err)
+	}
+	if len(currencies) != 2 || currencies[0] != "USD" || currencies[1] != "EUR" {
+		t.Errorf("Expected [USD EUR], got %v", currencies)
+	}
+
+	// The dynamic list wins over the static one, both ways
+	if !sdk.Merchant.IsSupportedCurrency(ctx, "merchant_123", "eur") {
+		t.Error("Expected EUR to be supported")
+	}
+	if sdk.Merchant.IsSupportedCurrency(ctx, "merchant_123", "GBP") {
+		t.Error("Expected GBP not to be supported by the merchant")
+	}
+	if requests != 1 {
+		t.Errorf("Expected the cached list to be served, got %d requests", requests)
+	}
+
+	// An empty list falls back to the static one
+	body = `{"merchant_id":"merchant_456"}`
+	if !sdk.Merchant.IsSupportedCurrency(ctx, "merchant_456", "GBP") {
+		t.Error("Expected GBP to be supported by the static list")
+	}
+	if sdk.Merchant.IsSupportedCurrency(ctx, "merchant_456", "XYZ") {
+		t.Error("Expected XYZ not to be supported")
+	}
+
+	// So does a failed request
+	if _, err := sdk.Merchant.GetSupportedCurrencies(ctx, "merchant_down"); err == nil {
+		t.Error("Expected an error for an unknown merchant")
+	}
+	if !sdk.Merchant.IsSupportedCurrency(ctx, "merchant_down", "USD") {
+		t.Error("Expected USD to be supported by the static list")
+	}
+}
+
+func TestGetServiceStatus(t *testing.T) {
+	var requests int
+	wantPath := "/status"
+	body := `{"status":{"indicator":"none","description":"All Systems Operational"},"components":[{"id":"c1","name":"Payments API","status":"operational"}]}`
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if r.URL.Path != wantPath {
+			t.Errorf("Expected path %s, got %s", wantPath, r.URL.Path)
+		}
+		fmt.Fprint(w, body)
+	}))
+	defer server.Close()
+
+	ctx := context.Background()
+	clock := newFakeClock()
+	sdk := NewSDK(&Config{BaseURL: server.URL, Clock: clock, StatusCacheTTL: time.Minute})
+
+	status, err := sdk.GetServiceStatus(ctx)
+	if err != nil {
+		t.Fatalf("GetServiceStatus() error = %v", err)
+	}
+	if status.IsDegraded() || len(status.Components) != 1 || status.Meta == nil {
+		t.Errorf("Unexpected status %+v", status)
+	}
+
+	// The status is reused until the TTL passes
+	body = `{"status":{"indicator":"minor","description":"Partially Degraded Service"},"components":[{"id":"c1","name":"Payments API","status":"operational"},{"id":"c2","name":"Tokenization","status":"degraded_performance"}]}`
+	if _, err := sdk.GetServiceStatus(ctx); err != nil {
+		t.Fatalf("GetServiceStatus() error = %v", err)
+	}
+	if requests != 1 {
+		t.Errorf("Expected the cached status to be served, got %d requests", requests)
+	}
+
+	clock.Advance(time.Minute)
+	status, err = sdk.GetServiceStatus(ctx)
+	if err != nil {
+		t.Fatalf("GetServiceStatus() error = %v", err)
+	}
+	if requests != 2 {
+		t.Errorf("Expected the status to be fetched again after the TTL, got %d requests", requests)
+	}
+	if !status.IsDegraded() {
+		t.Error("Expected the status to be degraded")
+	}
+	if degraded := status.DegradedComponents(); len(degraded) != 1 || degraded[0].Name != "Tokenization" {
+		t.Errorf("Expected Tokenization to be degraded, got %+v", degraded)
+	}
+
+	// A component outage alone marks the status degraded
+	outage := &ServiceStatus{
+		Status:     StatusSummary{Indicator: StatusIndicatorNone},
+		Components: []StatusComponent{{Name: "Disputes", Status: ComponentMajorOutage}},
+	}
+	if !outage.IsDegraded() {
+		t.Error("Expected a component outage to mark the status degraded")
+	}
+
+	// The status endpoint is requested under the path prefix
+	wantPath = "/v2/status"
+	sdk = NewSDK(&Config{BaseURL: server.URL, PathPrefix: "v2"})
+	if _, err := sdk.GetServiceStatus(ctx); err != nil {
+		t.Fatalf("GetServiceStatus() error = %v", err)
+	}
+}
+
+func TestDisputeService_SubmitEvidenceFiles(t *testing.T) {
+	var attempts int
+	var evidence map[string]interface{}
+	var files []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		evidence, files = nil, nil
+		if err := r.ParseMultipartForm(1 << 20); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		json.Unmarshal([]byte(r.FormValue("evidence")), &evidence)
+		for _, header := range r.MultipartForm.File["files"] {
+			file, _ := header.Open()
+			content, _ := io.ReadAll(file)
+			file.Close()
+			files = append(files, header.Filename+" "+header.Header.Get("Content-Type")+" "+string(content))
+		}
+		if attempts == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		fmt.Fprint(w, `{"id":"dispute_123","status":"under_review"}`)
+	}))
+	defer server.Close()
+
+	sdk := NewSDK(&Config{BaseURL: server.URL, Retry: RetryPolicy{MaxRetries: 1, RetryWaitMin: time.Millisecond, RetryWaitMax: time.Millisecond}, GenerateIdempotencyKeys: true})
+	ctx := context.Background()
+
+	dispute, err := sdk.Disputes.SubmitEvidence(ctx, "dispute_123", &Evidence{Rebuttal: "Delivered"},
+		EvidenceFile{Type: "receipt", FileName: "receipt.pdf", Content: strings.NewReader("receipt")},
+		EvidenceFile{Type: "proof_of_delivery", FileName: "signature.png", Content: strings.NewReader("signature")},
+	)
+	if err != nil {
+		t.Fatalf("SubmitEvidence() error = %v", err)
+	}
+	if dispute.Status != "under_review" {
+		t.Errorf("Expected status under_review, got %s", dispute.Status)
+	}
+	// Seekable files are rewound and sent again on retry
+	if attempts != 2 {
+		t.Errorf("Expected the upload to be retried once, got %d attempts", attempts)
+	}
+	expected := []string{"receipt.pdf application/pdf receipt", "signature.png image/png signature"}
+	if fmt.Sprint(files) != fmt.Sprint(expected) {
+		t.Errorf("Expected files %v, got %v", expected, files)
+	}
+	if evidence["rebuttal"] != "Delivered" || len(evidence["files"].([]interface{})) != 2 {
+		t.Errorf("Unexpected evidence part %v", evidence)
+	}
+
+	// A stream that can't be rewound is not retried
+	attempts = 0
+	_, err = sdk.Disputes.SubmitEvidence(ctx, "dispute_123", &Evidence{Rebuttal: "Delivered"},
+		EvidenceFile{Type: "receipt", FileName: "receipt.pdf", Content: io.MultiReader(strings.NewReader("receipt"))},
+	)
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || attempts != 1 {
+		t.Errorf("Expected a single failed attempt, got %d attempts and %v", attempts, err)
+	}
+
+	// Streams of unknown size are checked as they are uploaded
+	attempts = 0
+	large := io.MultiReader(strings.NewReader(strings.Repeat("a", MaxEvidenceFileSize+1)))
+	_, err = sdk.Disputes.SubmitEvidence(ctx, "dispute_123", &Evidence{Rebuttal: "Delivered"},
+		EvidenceFile{Type: "receipt", FileName: "receipt.pdf", Content: large},
+	)
+	var validationErr *ValidationError
+	if !errors.As(err, &validationErr) || validationErr.Field != "files[0]" {
+		t.Errorf("Expected a file size error, got %v", err)
+	}
+}
+
+func TestCanonicalRequest(t *testing.T) {
+	emptyBodyHash := "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+	tests := []struct {
+		name   string
+		method string
+		url    string
+		want   string
+	}{
+		{
+			name:   "no query",
+			method: http.MethodGet,
+			url:    "https://api.example.com/transactions/txn_123",
+			want:   "GET\n/transactions/txn_123\n\n" + emptyBodyHash,
+		},
+		{
+			name:   "multiple keys sorted",
+			method: http.MethodGet,
+			url:    "https://api.example.com/transactions?status=captured&limit=10&currency=USD",
+			want:   "GET\n/transactions\ncurrency=USD&limit=10&status=captured\n" + emptyBodyHash,
+		},
+		{
+			name:   "repeated keys sorted by value",
+			method: http.MethodGet,
+			url:    "https://api.example.com/transactions?status=voided&limit=10&status=captured",
+			want:   "GET\n/transactions\nlimit=10&status=captured&status=voided\n" + emptyBodyHash,
+		},
+		{
+			name:   "escaped values",
+			method: http.MethodGet,
+			url:    "https://api.example.com/transactions/search?q=john+doe&merchant_id=m%2F1",
+			want:   "GET\n/transactions/search\nmerchant_id=m%2F1&q=john%20doe\n" + emptyBodyHash,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.url, nil)
+			if got := canonicalRequest(req, nil); got != tt.want {
+				t.Errorf("canonicalRequest() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	// The canonical form does not depend on the order parameters were sent in
+	a := httptest.NewRequest(http.MethodGet, "https://api.example.com/tokens?offset=20&customer_id=c1&limit=10", nil)
+	b := httptest.NewRequest(http.MethodGet, "https://api.example.com/tokens?limit=10&offset=20&customer_id=c1", nil)
+	if canonicalRequest(a, nil) != canonicalRequest(b, nil) {
+		t.Error("Expected parameter order not to change the canonical request")
+	}
+
+	// The body is part of the signed string
+	if canonicalRequest(a, []byte(`{"amount":1}`)) == canonicalRequest(a, []byte(`{"amount":2}`)) {
+		t.Error("Expected different bodies to produce different canonical requests")
+	}
+}
+
+func TestCanonicalRequestMatchesSentQuery(t *testing.T) {
+	// Compute the canonical request on both ends of the wire for the
+	// endpoints that send query parameters
+	var serverSide string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		serverSide = canonicalRequest(r, nil)
+		fmt.Fprint(w, `{}`)
+	}))
+	defer server.Close()
+
+	ctx := context.Background()
+	sdk := NewSDK(&Config{BaseURL: server.URL})
+
+	tests := []struct {
+		name  string
+		call  func() error
+		query string
+	}{
+		{
+			name: "ListTransactions",
+			call: func() error {
+				_, err := sdk.Transactions.ListTransactions(ctx, &ListTransactionsRequest{Status: "captured", Currency: "USD", Limit: 10})
+				return err
+			},
+			query: "currency=USD&limit=10&status=captured",
+		},
+		{
+			name: "SearchTransactions",
+			call: func() error {
+				_, err := sdk.Transactions.SearchTransactions(ctx, &SearchTransactionsRequest{Query: "john doe", Limit: 5})
+				return err
+			},
+			query: "limit=5&q=john%20doe",
+		},
+		{
+			name: "ListTokens",
+			call: func() error {
+				_, err := sdk.Tokens.ListTokens(ctx, &ListTokensRequest{CustomerID: "customer_123", Limit: 10, Offset: 20})
+				return err
+			},
+			query: "customer_id=customer_123&limit=10&offset=20",
+		},
+		{
+			name: "GetSettlements",
+			call: func() error {
+				_, err := sdk.Merchant.GetSettlements(ctx, "merchant_123", 50, 100)
+				return err
+			},
+			query: "limit=50&offset=100",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.call(); err != nil {
+				t.Fatalf("%s() error = %v", tt.name, err)
+			}
+			if got := strings.Split(serverSide, "\n")[2]; got != tt.query {
+				t.Errorf("Expected canonical query %q, got %q", tt.query, got)
+			}
+		})
+	}
+}
+
+// signatureCreatedRegex extracts the creation time from a Signature-Input
+// header
+var signatureCreatedRegex = regexp.MustCompile(`keyid="([^"]*)";alg="hmac-sha256";created=(\d+)$`)
+
+// verifySignature checks the signature of a request received by a test
+// server the way the gateway would, returning the signing time
+func verifySignature(r *http.Request, apiKey, secretKey string) (time.Time, error) {
+	match := signatureCreatedRegex.FindStringSubmatch(r.Header.Get(SignatureInputHeader))
+	if match == nil {
+		return time.Time{}, fmt.Errorf("malformed %s %q", SignatureInputHeader, r.Header.Get(SignatureInputHeader))
+	}
+	if match[1] != apiKey {
+		return time.Time{}, fmt.Errorf("unexpected key ID %q", match[1])
+	}
+	body, _ := io.ReadAll(r.Body)
+	mac := hmac.New(sha256.New, []byte(secretKey))
+	io.WriteString(mac, match[2]+"\n"+canonicalRequest(r, body))
+	if r.Header.Get(SignatureHeader) != base64.StdEncoding.EncodeToString(mac.Sum(nil)) {
+		return time.Time{}, errors.New("signature mismatch")
+	}
+	created, _ := strconv.ParseInt(match[2], 10, 64)
+	return time.Unix(created, 0), nil
+}
+
+func TestSignRequests(t *testing.T) {
+	clock := newFakeClock()
+	var signErr error
+	var created time.Time
+	var signed bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Date", clock.Now().Format(http.TimeFormat))
+		signed = r.Header.Get(SignatureHeader) != ""
+		if signed {
+			created, signErr = verifySignature(r, "test-api-key", "test-secret-key")
+		}
+		fmt.Fprint(w, `{"id":"txn_123","transactions":[]}`)
+	}))
+	defer server.Close()
+
+	ctx := context.Background()
+	sdk := NewSDK(&Config{BaseURL: server.URL, APIKey: "test-api-key", SecretKey: "test-secret-key", Clock: clock, SignRequests: true})
+
+	calls := map[string]func() error{
+		"query": func() error {
+			_, err := sdk.Transactions.ListTransactions(ctx, &ListTransactionsRequest{Status: "captured", Limit: 10})
+			return err
+		},
+		"body": func() error {
+			_, err := sdk.Transactions.AuthorizeTransaction(ctx, &TransactionRequest{Amount: 10, Currency: "USD", MerchantID: "merchant_123", CardToken: "tok_123"})
+			return err
+		},
+	}
+	for name, call := range calls {
+		t.Run(name, func(t *testing.T) {
+			signed, signErr = false, nil
+			if err := call(); err != nil {
+				t.Fatalf("request error = %v", err)
+			}
+			if !signed || signErr != nil {
+				t.Fatalf("Expected a valid signature, got signed = %v, error %v", signed, signErr)
+			}
+			if !created.Equal(clock.Now().Truncate(time.Second)) {
+				t.Errorf("Expected the request to be signed at %v, got %v", clock.Now(), created)
+			}
+		})
+	}
+
+	// A tampered body fails verification
+	req := httptest.NewRequest(http.MethodPost, server.URL+"/transactions/authorize", strings.NewReader(`{"amount":10}`))
+	sdk.Client.signRequest(req, []byte(`{"amount":10}`), clock.Now())
+	req.Body = io.NopCloser(strings.NewReader(`{"amount":1000}`))
+	if _, err := verifySignature(req, "test-api-key", "test-secret-key"); err == nil {
+		t.Error("Expected a tampered body to fail verification")
+	}
+
+	// Requests are not signed by default
+	sdk = NewSDK(&Config{BaseURL: server.URL, SecretKey: "test-secret-key"})
+	if _, err := sdk.Transactions.GetTransaction(ctx, "txn_123"); err != nil {
+		t.Fatalf("GetTransaction() error = %v", err)
+	}
+	if signed {
+		t.Error("Expected no signature without SignRequests")
+	}
+
+	if _, err := NewClientWithError(&Config{SignRequests: true}); err == nil {
+		t.Error("Expected SignRequests without a SecretKey to be rejected")
+	}
+}
+
+func TestCustomerService_CRUD(t *testing.T) {
+	var requests []string
+	var bodies []CustomerRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.Method+" "+r.URL.EscapedPath())
+		if r.Body != nil && (r.Method == http.MethodPost || r.Method == http.MethodPut) {
+			var body CustomerRequest
+			if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+				t.Errorf("Failed to decode request body: %v", err)
+			}
+			bodies = append(bodies, body)
+		}
+		if r.Method == http.MethodDelete {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		fmt.Fprint(w, `{"id":"cus/123","email":"jane@example.com","name":"Jane Doe","default_payment_method":"tok_1"}`)
+	}))
+	defer server.Close()
+
+	sdk := NewSDK(&Config{BaseURL: server.URL})
+	ctx := context.Background()
+
+	customer, err := sdk.Customers.CreateCustomer(ctx, &CustomerRequest{Email: "jane@example.com", Name: "Jane Doe"})
+	if err != nil {
+		t.Fatalf("CreateCustomer() error = %v", err)
+	}
+	if customer.ID != "cus/123" || customer.DefaultPaymentMethod != "tok_1" || customer.Meta == nil {
+		t.Errorf("Unexpected customer: %+v", customer)
+	}
+	if _, err := sdk.Customers.GetCustomer(ctx, "cus/123"); err != nil {
+		t.Fatalf("GetCustomer() error = %v", err)
+	}
+	if _, err := sdk.Customers.UpdateCustomer(ctx, "cus/123", &UpdateCustomerRequest{DefaultPaymentMethod: Ptr("tok_2")}); err != nil {
+		t.Fatalf("UpdateCustomer() error = %v", err)
+	}
+	if err := sdk.Customers.DeleteCustomer(ctx, "cus/123"); err != nil {
+		t.Fatalf("DeleteCustomer() error = %v", err)
+	}
+
+	expected := []string{
+		"POST /customers",
+		"GET /customers/cus%2F123",
+		"PUT /customers/cus%2F123",
+		"DELETE /customers/cus%2F123",
+	}
+	if strings.Join(requests, ", ") != strings.Join(expected, ", ") {
+		t.Errorf("Expected requests %v, got %v", expected, requests)
+	}
+	if len(bodies) != 2 || bodies[0].Email != "jane@example.com" || bodies[1].DefaultPaymentMethod != "tok_2" || bodies[1].Email != "" {
+		t.Errorf("Unexpected request bodies: %+v", bodies)
+	}
+
+	// Invalid requests are not sent
+	if _, err := sdk.Customers.CreateCustomer(ctx, &CustomerRequest{Email: "jane"}); err == nil {
+		t.Error("Expected CreateCustomer() to reject an invalid email")
+	}
+	if _, err := sdk.Customers.GetCustomer(ctx, ""); err == nil {
+		t.Error("Expected GetCustomer() to reject an empty customer ID")
+	}
+	if len(requests) != len(expected) {
+		t.Errorf("Expected invalid requests not to be sent, got %v", requests)
+	}
+}
+
+func TestCustomerService_PaymentMethods(t *testing.T) {
+	var defaultBody map[string]string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.EscapedPath() {
+		case "/customers/cus%2F123/payment_methods":
+			fmt.Fprint(w, `{"payment_methods":[
+				{"id":"tok_1","card_brand":"amex","card_last4":"0005","expiry_month":12,"expiry_year":2030,"is_default":true},
+				{"id":"tok_2","card_brand":"amex","card_last4":"1000","expiry_month":6,"expiry_year":2029}
+			]}`)
+		case "/customers/cus%2F123/payment_methods/default":
+			if r.Method != http.MethodPost {
+				t.Errorf("Expected POST, got %s", r.Method)
+			}
+			if err := json.NewDecoder(r.Body).Decode(&defaultBody); err != nil {
+				t.Errorf("Failed to decode request body: %v", err)
+			}
+			fmt.Fprint(w, `{"id":"tok_2","card_last4":"1000","is_default":true}`)
+		default:
+			t.Errorf("Unexpected path %s", r.URL.EscapedPath())
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	ctx := context.Background()
+	sdk := NewSDK(&Config{BaseURL: server.URL})
+
+	methods, err := sdk.Customers.ListPaymentMethods(ctx, "cus/123")
+	if err != nil {
+		t.Fatalf("ListPaymentMethods() error = %v", err)
+	}
+	if len(methods) != 2 {
+		t.Fatalf("Expected 2 payment methods, got %d", len(methods))
+	}
+	if !methods[0].IsDefault || methods[1].IsDefault {
+		t.Error("Expected only the first payment method to be the default")
+	}
+	if methods[0].CardBrand != "amex" || methods[0].CardLast4 != "0005" || methods[0].ExpiryYear != 2030 {
+		t.Errorf("Expected card display details, got %+v", methods[0].TokenResponse)
+	}
+
+	method, err := sdk.Customers.SetDefaultPaymentMethod(ctx, "cus/123", "tok_2")
+	if err != nil {
+		t.Fatalf("SetDefaultPaymentMethod() error = %v", err)
+	}
+	if !method.IsDefault || method.ID != "tok_2" || method.Meta == nil {
+		t.Errorf("Expected tok_2 to be the default, got %+v", method)
+	}
+	if defaultBody["token_id"] != "tok_2" {
+		t.Errorf("Expected token_id tok_2 to be sent, got %v", defaultBody)
+	}
+
+	for _, customerID := range []string{"", "  ", "cus 123", "cus\n123"} {
+		if _, err := sdk.Customers.ListPaymentMethods(ctx, customerID); err == nil {
+			t.Errorf("Expected customer ID %q to be rejected", customerID)
+		}
+	}
+	if _, err := sdk.Customers.SetDefaultPaymentMethod(ctx, "cus_123", ""); err == nil {
+		t.Error("Expected an empty token ID to be rejected")
+	}
+}
+
+func TestTokenService_CreateTokenBillingAddress(t *testing.T) {
+	var body struct {
+		BillingAddr *Address `json:"billing_address"`
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("Failed to decode request body: %v", err)
+		}
+		fmt.Fprint(w, `{"id":"tok_1","card_last4":"1111","billing_address":{"postal_code":"78701","country":"US"}}`)
+	}))
+	defer server.Close()
+
+	sdk := NewSDK(&Config{BaseURL: server.URL})
+	token, err := sdk.Tokens.CreateToken(context.Background(), &TokenRequest{
+		CardDetails: &CardDetails{Number: "4111111111111111", ExpiryMonth: 12, ExpiryYear: 2025, CVV: "123", HolderName: "John Doe"},
+		BillingAddr: &Address{Line1: "1 Main St", City: "Austin", PostalCode: "78701", Country: "US"},
+	})
+	if err != nil {
+		t.Fatalf("CreateToken() error = %v", err)
+	}
+	if body.BillingAddr == nil || body.BillingAddr.Line1 != "1 Main St" || body.BillingAddr.PostalCode != "78701" {
+		t.Errorf("Expected the billing address to be sent, got %+v", body.BillingAddr)
+	}
+	if token.BillingAddr == nil || token.BillingAddr.PostalCode != "78701" || token.BillingAddr.Country != "US" {
+		t.Errorf("Expected the billing address summary, got %+v", token.BillingAddr)
+	}
+}
+
+func TestTokenService_EnsureToken(t *testing.T) {
+	var created int
+	var keys []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			created++
+			keys = append(keys, r.Header.Get(IdempotencyKeyHeader))
+			fmt.Fprint(w, `{"id":"tok_new","card_last4":"0005","expiry_month":1,"expiry_year":2025}`)
+			return
+		}
+		if r.Method == http.MethodDelete {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		if r.URL.Query().Get("customer_id") != "cus_123" {
+			t.Errorf("Expected tokens of cus_123 to be listed, got %s", r.URL.RawQuery)
+		}
+		fmt.Fprint(w, `{"tokens":[
+			{"id":"tok_single","card_last4":"0005","expiry_month":1,"expiry_year":2025,"single_use":true},
+			{"id":"tok_visa","card_last4":"1111","expiry_month":12,"expiry_year":2025}
+		]}`)
+	}))
+	defer server.Close()
+
+	sdk := NewSDK(&Config{BaseURL: server.URL, SecretKey: "secret"})
+	ctx := context.Background()
+
+	// An existing token for the card is reused
+	token, err := sdk.Tokens.EnsureToken(ctx, "cus_123", &CardDetails{Number: "4111 1111 1111 1111", ExpiryMonth: 12, ExpiryYear: 2025, CVV: "123", HolderName: "John Doe"})
+	if err != nil {
+		t.Fatalf("EnsureToken() error = %v", err)
+	}
+	if token.ID != "tok_visa" || created != 0 {
+		t.Errorf("Expected tok_visa to be reused, got %s after %d creations", token.ID, created)
+	}
+
+	// Otherwise one is created, with the same idempotency key every time
+	amex := &CardDetails{Number: "378282246310005", ExpiryMonth: 1, ExpiryYear: 2025, CVV: "1234", HolderName: "John Doe"}
+	for i := 0; i < 2; i++ {
+		token, err = sdk.Tokens.EnsureToken(ctx, "cus_123", amex)
+		if err != nil {
+			t.Fatalf("EnsureToken() error = %v", err)
+		}
+		if token.ID != "tok_new" {
+			t.Errorf("Expected tok_new to be created, got %s", token.ID)
+		}
+	}
+	if len(keys) != 2 || keys[0] == "" || keys[0] != keys[1] || strings.Contains(keys[0], amex.Number) {
+		t.Errorf("Expected a stable idempotency key that hides the card number, got %v", keys)
+	}
+
+	// The key survives a credential rotation
+	sdk.SetCredentials("new-key", "new-secret")
+	if key := sdk.Tokens.ensureTokenKey("cus_123", amex); key != keys[0] {
+		t.Errorf("Expected the idempotency key to be kept after rotating credentials, got %s and %s", keys[0], key)
+	}
+
+	// Deleting the created token changes the key, so the gateway does not
+	// replay the deleted token, and leaves other customers' keys alone
+	other := sdk.Tokens.ensureTokenKey("cus_456", amex)
+	if err := sdk.Tokens.DeleteToken(ctx, "tok_new"); err != nil {
+		t.Fatalf("DeleteToken() error = %v", err)
+	}
+	for i := 0; i < 2; i++ {
+		if _, err := sdk.Tokens.EnsureToken(ctx, "cus_123", amex); err != nil {
+			t.Fatalf("EnsureToken() error = %v", err)
+		}
+	}
+	if len(keys) != 4 || keys[2] == keys[0] || keys[2] != keys[3] {
+		t.Errorf("Expected a new stable idempotency key after deleting the token, got %v", keys)
+	}
+	if key := sdk.Tokens.ensureTokenKey("cus_456", amex); key != other {
+		t.Errorf("Expected the key of another customer to be kept, got %s and %s", other, key)
+	}
+
+	// Removing a token of an unknown customer changes every key
+	if err := sdk.Tokens.DeleteToken(ctx, "tok_unseen"); err != nil {
+		t.Fatalf("DeleteToken() error = %v", err)
+	}
+	if sdk.Tokens.ensureTokenKey("cus_123", amex) == keys[2] || sdk.Tokens.ensureTokenKey("cus_456", amex) == other {
+		t.Error("Expected the keys to change after deleting a token of an unknown customer")
+	}
+
+	// Without a secret key the card number is still keyed with a random
+	// salt, and clients sharing a salt derive the same key
+	a := NewSDK(&Config{BaseURL: server.URL})
+	b := NewSDK(&Config{BaseURL: server.URL})
+	if a.Tokens.ensureTokenKey("cus_123", amex) == b.Tokens.ensureTokenKey("cus_123", amex) {
+		t.Error("Expected clients without an IdempotencySalt to use different salts")
+	}
+	a = NewSDK(&Config{BaseURL: server.URL, IdempotencySalt: "shared-salt"})
+	b = NewSDK(&Config{BaseURL: server.URL, IdempotencySalt: "shared-salt", SecretKey: "other"})
+	if a.Tokens.ensureTokenKey("cus_123", amex) != b.Tokens.ensureTokenKey("cus_123", amex) {
+		t.Error("Expected clients sharing an IdempotencySalt to derive the same key")
+	}
+
+	if _, err := sdk.Tokens.EnsureToken(ctx, "", amex); err == nil {
+		t.Error("Expected EnsureToken() to require a customer ID")
+	}
+	if _, err := sdk.Tokens.EnsureToken(ctx, "cus_123", &CardDetails{Number: "123"}); err == nil {
+		t.Error("Expected EnsureToken() to validate the card")
+	}
+}
+
+func TestTokenService_CreateTokenFromTransaction(t *testing.T) {
+	var requests int
+	var body map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if r.Method != http.MethodPost || r.URL.Path != "/tokens" {
+			t.Errorf("Expected POST /tokens, got %s %s", r.Method, r.URL.Path)
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		fmt.Fprint(w, `{"id":"tok_1","customer_id":"customer_123","card_last4":"0005","card_brand":"amex"}`)
+	}))
+	defer server.Close()
+
+	sdk := NewSDK(&Config{BaseURL: server.URL})
+	ctx := context.Background()
+
+	token, err := sdk.Tokens.CreateTokenFromTransaction(ctx, "txn_123", "customer_123")
+	if err != nil {
+		t.Fatalf("CreateTokenFromTransaction() error = %v", err)
+	}
+	if token.ID != "tok_1" || token.CardLast4 != "0005" {
+		t.Errorf("Unexpected token %+v", token)
+	}
+	if body["transaction_id"] != "txn_123" || body["customer_id"] != "customer_123" {
+		t.Errorf("Expected the transaction and customer to be sent, got %v", body)
+	}
+	if _, ok := body["card_details"]; ok {
+		t.Errorf("Expected no card details to be sent, got %v", body)
+	}
+
+	tests := []struct {
+		name          string
+		transactionID string
+		customerID    string
+		field         string
+	}{
+		{"missing transaction", " ", "customer_123", "transaction_id"},
+		{"missing customer", "txn_123", "", "customer_id"},
+		{"invalid customer", "txn_123", "customer 123", "customer_id"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := sdk.Tokens.CreateTokenFromTransaction(ctx, tt.transactionID, tt.customerID)
+			var validationErr *ValidationError
+			if !errors.As(err, &validationErr) || validationErr.Field != tt.field {
+				t.Errorf("Expected a validation error for %s, got %v", tt.field, err)
+			}
+		})
+	}
+	if requests != 1 {
+		t.Errorf("Expected invalid requests not to be sent, got %d requests", requests)
+	}
+}
+
+func TestTokenService_DisableToken(t *testing.T) {
+	var requests []string
+	var body map[string]string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.Method+" "+r.URL.Path)
+		switch {
+		case r.Method == http.MethodPut:
+			json.NewDecoder(r.Body).Decode(&body)
+			fmt.Fprint(w, `{"id":"tok_1","card_last4":"1111","status":"disabled"}`)
+		case r.URL.Path == "/tokens":
+			fmt.Fprint(w, `{"tokens":[{"id":"tok_1","status":"disabled"},{"id":"tok_2","status":"active"}]}`)
+		default:
+			fmt.Fprint(w, `{"id":"txn_1","status":"authorized"}`)
+		}
+	}))
+	defer server.Close()
+
+	sdk := NewSDK(&Config{BaseURL: server.URL})
+	ctx := context.Background()
+
+	token, err := sdk.Tokens.DisableToken(ctx, "tok_1")
+	if err != nil {
+		t.Fatalf("DisableToken() error = %v", err)
+	}
+	if token.IsActive() || token.Status != TokenStatusDisabled {
+		t.Errorf("Expected a disabled token, got %+v", token)
+	}
+	if body["status"] != "disabled" {
+		t.Errorf("Expected status disabled to be sent, got %v", body)
+	}
+
+	// Disabled tokens remain listable
+	tokens, err := sdk.Tokens.ListTokens(ctx, nil)
+	if err != nil {
+		t.Fatalf("ListTokens() error = %v", err)
+	}
+	if len(tokens.Tokens) != 2 || tokens.Tokens[0].IsActive() || !tokens.Tokens[1].IsActive() {
+		t.Errorf("Unexpected tokens %+v", tokens.Tokens)
+	}
+
+	// Charging a disabled token fails before reaching the gateway
+	requests = nil
+	_, err = sdk.Transactions.AuthorizeTransaction(ctx, &TransactionRequest{MerchantID: "merchant_123", Amount: 10, Currency: "USD", CardToken: "tok_1"})
+	if !errors.Is(err, ErrTokenDisabled) {
+		t.Errorf("Expected ErrTokenDisabled from AuthorizeTransaction(), got %v", err)
+	}
+	_, err = sdk.Payments.CreatePayment(ctx, &PaymentRequest{MerchantID: "merchant_123", Amount: 10, Currency: "USD", CardToken: "tok_1"})
+	if !errors.Is(err, ErrTokenDisabled) {
+		t.Errorf("Expected ErrTokenDisabled from CreatePayment(), got %v", err)
+	}
+	if len(requests) != 0 {
+		t.Errorf("Expected no requests for a disabled token, got %v", requests)
+	}
+	if _, err := sdk.Transactions.AuthorizeTransaction(ctx, &TransactionRequest{MerchantID: "merchant_123", Amount: 10, Currency: "USD", CardToken: "tok_2"}); err != nil {
+		t.Errorf("AuthorizeTransaction() with an active token error = %v", err)
+	}
+
+	if _, err := sdk.Tokens.DisableToken(ctx, " "); err == nil {
+		t.Error("Expected DisableToken() to require a token ID")
+	}
+}
+
+func TestTokenService_DeleteDisabledToken(t *testing.T) {
+	var requests []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.Method+" "+r.URL.Path)
+		switch {
+		case r.Method == http.MethodPut:
+			fmt.Fprint(w, `{"id":"tok_1","card_last4":"0005","expiry_month":1,"expiry_year":2025,"status":"disabled"}`)
+		case r.Method == http.MethodDelete:
+			w.WriteHeader(http.StatusNoContent)
+		case r.URL.Path == "/tokens" && r.Method == http.MethodGet:
+			fmt.Fprint(w, `{"tokens":[]}`)
+		case r.URL.Path == "/tokens":
+			// The gateway reissues the deleted token's ID
+			fmt.Fprint(w, `{"id":"tok_1","card_last4":"0005","expiry_month":1,"expiry_year":2025,"status":"active"}`)
+		default:
+			fmt.Fprint(w, `{"id":"txn_1","status":"authorized"}`)
+		}
+	}))
+	defer server.Close()
+
+	sdk := NewSDK(&Config{BaseURL: server.URL})
+	ctx := context.Background()
+
+	if _, err := sdk.Tokens.DisableToken(ctx, "tok_1"); err != nil {
+		t.Fatalf("DisableToken() error = %v", err)
+	}
+	if err := sdk.Tokens.DeleteToken(ctx, "tok_1"); err != nil {
+		t.Fatalf("DeleteToken() error = %v", err)
+	}
+	token, err := sdk.Tokens.EnsureToken(ctx, "cus_123", &CardDetails{Number: "378282246310005", ExpiryMonth: 1, ExpiryYear: 2025, CVV: "1234", HolderName: "John Doe"})
+	if err != nil {
+		t.Fatalf("EnsureToken() error = %v", err)
+	}
+
+	requests = nil
+	_, err = sdk.Transactions.AuthorizeTransaction(ctx, &TransactionRequest{MerchantID: "merchant_123", Amount: 10, Currency: "USD", CardToken: token.ID})
+	if err != nil {
+		t.Errorf("AuthorizeTransaction() with a reissued token error = %v", err)
+	}
+	if len(requests) != 1 {
+		t.Errorf("Expected the charge to reach the gateway, got %v", requests)
+	}
+}
+
+func TestRetainRawResponses(t *testing.T) {
+	raw := `{"id":"merchant_123","name":"Acme","unknown_field":{"nested":true}}`
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, raw)
+	}))
+	defer server.Close()
+
+	ctx := context.Background()
+
+	merchant, err := NewSDK(&Config{BaseURL: server.URL}).Merchant.GetMerchantInfo(ctx, "merchant_123")
+	if err != nil {
+		t.Fatalf("GetMerchantInfo() error = %v", err)
+	}
+	if merchant.Meta.Raw != nil {
+		t.Errorf("Expected raw response not to be retained by default, got %s", merchant.Meta.Raw)
+	}
+
+	merchant, err = NewSDK(&Config{BaseURL: server.URL, RetainRawResponses: true}).Merchant.GetMerchantInfo(ctx, "merchant_123")
+	if err != nil {
+		t.Fatalf("GetMerchantInfo() error = %v", err)
+	}
+	if string(merchant.Meta.Raw) != raw {
+		t.Errorf("Expected raw response %s, got %s", raw, merchant.Meta.Raw)
+	}
+	if merchant.Name != "Acme" {
+		t.Errorf("Expected the typed response to be decoded, got %+v", merchant)
+	}
+}
+
+func TestMerchantService_ExportSettlements(t *testing.T) {
+	var pages int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		if query.Get("start_date") != "2024-01-01" || query.Get("end_date") != "2024-01-31" {
+			t.Errorf("Expected the date range to be sent, got %s", r.URL.RawQuery)
+		}
+		pages++
+
+		switch query.Get("offset") {
+		case "":
+			// A full page; the offset of the next one follows from its size
+			settlements := make([]string, exportSettlementsPageSize)
+			for i := range settlements {
+				settlements[i] = fmt.Sprintf(`{"id":"stl_%d","merchant_id":"merchant_123","amount":10.5,"currency":"USD","status":"paid"}`, i)
+			}
+			fmt.Fprintf(w, `[%s]`, strings.Join(settlements, ","))
+		default:
+			fmt.Fprint(w, `[{"id":"stl_last","merchant_id":"merchant_123","amount":1200,"currency":"JPY","status":"paid","reference":"batch \"7\", final","settled_at":"2024-01-31T23:00:00-05:00"}]`)
+		}
+	}))
+	defer server.Close()
+
+	sdk := NewSDK(&Config{BaseURL: server.URL})
+
+	var out strings.Builder
+	if err := sdk.Merchant.ExportSettlements(context.Background(), "merchant_123", "2024-01-01", "2024-01-31", &out); err != nil {
+		t.Fatalf("ExportSettlements() error = %v", err)
+	}
+	if pages != 2 {
+		t.Errorf("Expected 2 pages to be fetched, got %d", pages)
+	}
+
+	lines := strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n")
+	if len(lines) != exportSettlementsPageSize+2 {
+		t.Fatalf("Expected a header and %d rows, got %d lines", exportSettlementsPageSize+1, len(lines))
+	}
+	if lines[0] != "id,merchant_id,amount,currency,status,reference,settled_at,created_at" {
+		t.Errorf("Unexpected header %q", lines[0])
+	}
+	if lines[1] != "stl_0,merchant_123,10.50,USD,paid,,," {
+		t.Errorf("Unexpected first row %q", lines[1])
+	}
+	if want := `stl_last,merchant_123,1200,JPY,paid,"batch ""7"", final",2024-02-01T04:00:00Z,`; lines[len(lines)-1] != want {
+		t.Errorf("Expected last row %q, got %q", want, lines[len(lines)-1])
+	}
+
+	// Errors from the gateway are returned
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer failing.Close()
+
+	err := NewSDK(&Config{BaseURL: failing.URL}).Merchant.ExportSettlements(context.Background(), "merchant_123", "", "", &out)
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		t.Errorf("Expected *APIError, got %v", err)
+	}
+}
+
+func TestClientGetRaw(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/reports/missing" {
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `{"message":"report not found","code":"not_found"}`)
+			return
+		}
+		if accept := r.Header.Get("Accept"); accept != "text/csv" {
+			t.Errorf("Expected Accept text/csv, got %s", accept)
+		}
+		if r.URL.Query().Get("month") != "2024-01" {
+			t.Errorf("Expected month query, got %s", r.URL.RawQuery)
+		}
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+		fmt.Fprint(w, "id,amount\ntxn_1,10.00\n")
+	}))
+	defer server.Close()
+
+	client := NewClient(&Config{BaseURL: server.URL})
+	ctx := context.Background()
+
+	raw, err := client.GetRaw(ctx, "/reports/daily", url.Values{"month": {"2024-01"}}, "text/csv")
+	if err != nil {
+		t.Fatalf("GetRaw() error = %v", err)
+	}
+	if raw.ContentType != "text/csv; charset=utf-8" {
+		t.Errorf("Expected CSV content type, got %s", raw.ContentType)
+	}
+	if string(raw.Body) != "id,amount\ntxn_1,10.00\n" {
+		t.Errorf("Expected the body unchanged, got %q", raw.Body)
+	}
+	if raw.Meta == nil || raw.Meta.StatusCode != http.StatusOK {
+		t.Errorf("Expected response metadata, got %+v", raw.Meta)
+	}
+
+	_, err = client.GetRaw(ctx, "/reports/missing", nil, "text/csv")
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusNotFound || apiErr.Code != "not_found" {
+		t.Errorf("Expected *APIError with status 404, got %v", err)
+	}
+}
+
+func TestMerchantService_GetStatement(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/merchants/merchant_123/statements/2024-01":
+			w.Header().Set("Content-Type", r.Header.Get("Accept"))
+			fmt.Fprint(w, "statement")
+		case "/merchants/merchant_123/statements/2023-12":
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `{"message":"no statement for period"}`)
+		default:
+			w.WriteHeader(http.StatusInternalServerError)
+			fmt.Fprint(w, `{"message":"internal error"}`)
+		}
+	}))
+	defer server.Close()
+
+	sdk := NewSDK(&Config{BaseURL: server.URL})
+	ctx := context.Background()
+
+	body, contentType, err := sdk.Merchant.GetStatement(ctx, "merchant_123", "2024-01")
+	if err != nil {
+		t.Fatalf("GetStatement() error = %v", err)
+	}
+	if string(body) != "statement" || contentType != "application/pdf" {
+		t.Errorf("Expected a PDF statement, got %q as %s", body, contentType)
+	}
+
+	_, contentType, err = sdk.Merchant.GetStatementAs(ctx, "merchant_123", "2024-01", StatementCSV)
+	if err != nil || contentType != "text/csv" {
+		t.Errorf("Expected a CSV statement, got %s, %v", contentType, err)
+	}
+
+	_, _, err = sdk.Merchant.GetStatement(ctx, "merchant_123", "2023-12")
+	var apiErr *APIError
+	if !errors.Is(err, ErrStatementNotFound) || !errors.As(err, &apiErr) {
+		t.Errorf("Expected ErrStatementNotFound wrapping an *APIError, got %v", err)
+	}
+
+	_, _, err = sdk.Merchant.GetStatement(ctx, "merchant_123", "2023-11")
+	if err == nil || errors.Is(err, ErrStatementNotFound) {
+		t.Errorf("Expected a server error other than ErrStatementNotFound, got %v", err)
+	}
+
+	for _, period := range []string{"", "2024-1", "2024-13", "2024-01-01", "January 2024"} {
+		if _, _, err := sdk.Merchant.GetStatement(ctx, "merchant_123", period); err == nil {
+			t.Errorf("Expected period %q to be rejected", period)
+		}
+	}
+}
+
+// roundTripFunc is an http.RoundTripper stub
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// TestSDKConcurrentUse fires concurrent requests through one SDK so that
+// `go test -race` catches unsynchronized state in the client and its caches
+func TestSDKConcurrentUse(t *testing.T) {
+	var failedKeys sync.Map
+	transport := roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		respond := func(status int, header http.Header, body string) (*http.Response, error) {
+			if header == nil {
+				header = http.Header{}
+			}
+			return &http.Response{
+				StatusCode: status,
+				Header:     header,
+				Body:       io.NopCloser(strings.NewReader(body)),
+				Request:    r,
+			}, nil
+		}
+
+		// Fail the first attempt of every POST so it is retried
+		if key := r.Header.Get(IdempotencyKeyHeader); key != "" {
+			if _, failed := failedKeys.LoadOrStore(key, true); !failed {
+				return respond(http.StatusServiceUnavailable, nil, `{"message":"unavailable"}`)
+			}
+		}
+
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/capabilities"):
+			return respond(http.StatusOK, nil, `{"merchant_id":"merchant_123","wallets":["applepay"],"supported_currencies":["USD"]}`)
+		case r.Method == http.MethodGet && strings.HasPrefix(r.URL.Path, "/merchants/"):
+			if r.Header.Get("If-None-Match") == `"v1"` {
+				return respond(http.StatusNotModified, nil, "")
+			}
+			return respond(http.StatusOK, http.Header{"Etag": {`"v1"`}}, `{"id":"merchant_123"}`)
+		case strings.HasSuffix(r.URL.Path, "/refund"):
+			return respond(http.StatusOK, nil, `{"id":"refund_123","status":"succeeded"}`)
+		default:
+			return respond(http.StatusOK, nil, `{"id":"merchant_123"}`)
+		}
+	})
+
+	config := &Config{
+		BaseURL:                 "https://amex.test",
+		HTTPClient:              &http.Client{Transport: transport},
+		Cache:                   NewMemoryCache(),
+		EnableETagCache:         true,
+		GenerateCorrelationID:   true,
+		GenerateIdempotencyKeys: true,
+		DefaultMetadata:         map[string]string{"service": "checkout"},
+		Retry:                   RetryPolicy{MaxRetries: 2, RetryWaitMin: time.Millisecond, RetryWaitMax: time.Millisecond},
+		Logger:                  &recordingLogger{},
+		Observer:                &recordingObserver{},
+		SlowRequestThreshold:    time.Nanosecond,
+		SlowRequestCallback:     func(method, path string, duration time.Duration) {},
+	}
+	sdk := NewSDK(config)
+	ctx := context.Background()
+
+	shared := &Config{BaseURL: "https://amex.test"}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 200)
+	for i := 0; i < 50; i++ {
+		wg.Add(3)
+		go func() {
+			defer wg.Done()
+			if _, err := sdk.Merchant.GetMerchantInfo(ctx, "merchant_123"); err != nil {
+				errs <- fmt.Errorf("GetMerchantInfo() error = %w", err)
+			}
+			capabilities, err := sdk.Merchant.GetCapabilities(ctx, "merchant_123")
+			if err != nil {
+				errs <- fmt.Errorf("GetCapabilities() error = %w", err)
+				return
+			}
+			capabilities.Wallets[0] = WalletGooglePay
+		}()
+		go func(i int) {
+			defer wg.Done()
+			if _, err := sdk.Merchant.CreateMerchant(ctx, &MerchantRequest{Name: "Acme"}); err != nil {
+				errs <- fmt.Errorf("CreateMerchant() error = %w", err)
+			}
+			req := &RefundTransactionRequest{Amount: 10, Currency: "USD", Reference: fmt.Sprintf("refund_%d", i)}
+			if _, err := sdk.Transactions.RefundTransaction(ctx, "txn_123", req); err != nil {
+				errs <- fmt.Errorf("RefundTransaction() error = %w", err)
+			}
+		}(i)
+		// Clients sharing a Config are created concurrently too
+		go func() {
+			defer wg.Done()
+			NewClient(shared)
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		t.Error(err)
+	}
+}
+
+func TestEmptyResponses(t *testing.T) {
+	status := http.StatusNoContent
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	sdk := NewSDK(&Config{BaseURL: server.URL})
+	ctx := context.Background()
+
+	for _, code := range []int{http.StatusNoContent, http.StatusOK} {
+		status = code
+
+		if err := sdk.Tokens.DeleteToken(ctx, "token_123"); err != nil {
+			t.Errorf("DeleteToken() with status %d error = %v", code, err)
+		}
+
+		payment, err := sdk.Payments.VoidPayment(ctx, "payment_123")
+		if err != nil {
+			t.Fatalf("VoidPayment() with status %d error = %v", code, err)
+		}
+		// Voids derive their status from the HTTP status code
+		if payment.ID != "payment_123" || payment.Status != PaymentStatusVoided || payment.Meta == nil || payment.Meta.StatusCode != code {
+			t.Errorf("Expected a voided payment with status %d, got %+v", code, payment)
+		}
+
+		transaction, err := sdk.Transactions.VoidTransaction(ctx, "txn_123", nil)
+		if err != nil {
+			t.Fatalf("VoidTransaction() with status %d error = %v", code, err)
+		}
+		if transaction.ID != "txn_123" || transaction.Status != TransactionStatusVoided || transaction.Meta == nil || transaction.Meta.StatusCode != code {
+			t.Errorf("Expected a voided transaction with status %d, got %+v", code, transaction)
+		}
+	}
+}
+
+func TestVoidBareStatusResponses(t *testing.T) {
+	tests := []struct {
+		name       string
+		strict     bool
+		statusCode int
+		body       string
+		wantStatus TransactionStatus
+		wantErr    bool
+	}{
+		{"empty body", false, http.StatusOK, "", TransactionStatusVoided, false},
+		{"empty body accepted", false, http.StatusAccepted, "", TransactionStatusPending, false},
+		{"bare JSON string", false, http.StatusOK, `"voided"`, TransactionStatusVoided, false},
+		{"bare plain text", false, http.StatusOK, "VOIDED\n", TransactionStatusVoided, false},
+		{"JSON object", false, http.StatusOK, `{"id":"txn_9","status":"reversed"}`, TransactionStatusReversed, false},
+		{"HTML page", false, http.StatusOK, "<html><body>Maintenance</body></html>", "", true},
+		{"strict bare string", true, http.StatusOK, `"voided"`, "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "text/plain")
+				w.WriteHeader(tt.statusCode)
+				fmt.Fprint(w, tt.body)
+			}))
+			defer server.Close()
+
+			sdk := NewSDK(&Config{BaseURL: server.URL, StrictVoidResponses: tt.strict})
+			transaction, err := sdk.Transactions.VoidTransaction(context.Background(), "txn_123", nil)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("VoidTransaction() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && transaction.Status != tt.wantStatus {
+				t.Errorf("Expected status %q, got %q", tt.wantStatus, transaction.Status)
+			}
+
+			payment, err := sdk.Payments.VoidPayment(context.Background(), "payment_123")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("VoidPayment() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && string(payment.Status) != string(tt.wantStatus) {
+				t.Errorf("Expected status %q, got %q", tt.wantStatus, payment.Status)
+			}
+		})
+	}
+}
+
+func TestUnexpectedContentType(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		wantErr     bool
+	}{
+		{"html error page", "text/html; charset=utf-8", "<html><body>502 Bad Gateway</body></html>", true},
+		{"unlabelled html", "", "<html></html>", true},
+		{"json", "application/json", `{"id":"merchant_123"}`, false},
+		{"problem json", "application/problem+json", `{"id":"merchant_123"}`, false},
+		{"mislabelled json", "text/plain", `{"id":"merchant_123"}`, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header()["Content-Type"] = []string{tt.contentType}
+				fmt.Fprint(w, tt.body)
+			}))
+			defer server.Close()
+
+			sdk := NewSDK(&Config{BaseURL: server.URL})
+			_, err := sdk.Merchant.GetMerchantInfo(context.Background(), "merchant_123")
+
+			var ctErr *UnexpectedContentTypeError
+			if !tt.wantErr {
+				if err != nil {
+					t.Errorf("GetMerchantInfo() error = %v", err)
+				}
+				return
+			}
+			if !errors.As(err, &ctErr) {
+				t.Fatalf("Expected *UnexpectedContentTypeError, got %v", err)
+			}
+			if ctErr.StatusCode != http.StatusOK || ctErr.ContentType != tt.contentType || ctErr.Snippet != tt.body {
+				t.Errorf("Unexpected error fields: %+v", ctErr)
+			}
+		})
+	}
+}
+
+func TestBodySnippet(t *testing.T) {
+	long := strings.Repeat("a", maxBodySnippet-1) + "é and more"
+	got := bodySnippet([]byte(long))
+	if want := strings.Repeat("a", maxBodySnippet-1) + "..."; got != want {
+		t.Errorf("Expected snippet cut before the multi-byte character, got %q", got)
+	}
+	if got := bodySnippet([]byte("  short\n")); got != "short" {
+		t.Errorf("Expected trimmed snippet, got %q", got)
+	}
+}
+
+func TestResponseTransformer(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"transactions":[{"id":"txn_1","authorizationCode":"A1"}],"hasMore":true}`)
+	}))
+	defer server.Close()
+
+	var paths []string
+	rename := RenameJSONFields(map[string]string{"authorizationCode": "authorization_code", "hasMore": "has_more"})
+	sdk := NewSDK(&Config{
+		BaseURL:            server.URL,
+		RetainRawResponses: true,
+		ResponseTransformer: func(resp *http.Response, body []byte) ([]byte, error) {
+			paths = append(paths, resp.Request.URL.Path)
+			return rename(resp, body)
+		},
+	})
+
+	list, err := sdk.Transactions.ListTransactions(context.Background(), nil)
+	if err != nil {
+		t.Fatalf("ListTransactions() error = %v", err)
+	}
+	if !list.HasMore || list.Transactions[0].AuthorizationCode != "A1" {
+		t.Errorf("Expected renamed fields to be decoded, got %+v", list)
+	}
+	if len(paths) != 1 || paths[0] != "/transactions" {
+		t.Errorf("Expected the transformer to see the request path, got %v", paths)
+	}
+	if !strings.Contains(string(list.Meta.Raw), "hasMore") {
+		t.Errorf("Expected the raw body as received, got %s", list.Meta.Raw)
+	}
+
+	// Transformer errors fail the call
+	sdk = NewSDK(&Config{
+		BaseURL: server.URL,
+		ResponseTransformer: func(resp *http.Response, body []byte) ([]byte, error) {
+			return nil, errors.New("boom")
+		},
+	})
+	if _, err := sdk.Transactions.ListTransactions(context.Background(), nil); err == nil || !strings.Contains(err.Error(), "boom") {
+		t.Errorf("Expected the transformer error, got %v", err)
+	}
+}
+
+func TestRenameJSONFields(t *testing.T) {
+	rename := RenameJSONFields(map[string]string{"transactionId": "transaction_id"})
+
+	got, err := rename(nil, []byte(`{"items":[{"transactionId":"txn_1","amount":12345678901234567890}]}`))
+	if err != nil {
+		t.Fatalf("RenameJSONFields() error = %v", err)
+	}
+	if want := `{"items":[{"amount":12345678901234567890,"transaction_id":"txn_1"}]}`; string(got) != want {
+		t.Errorf("Expected %s, got %s", want, got)
+	}
+
+	// Bodies that are not JSON are passed through
+	if got, err := rename(nil, []byte("<html>")); err != nil || string(got) != "<html>" {
+		t.Errorf("Expected non-JSON body unchanged, got %q, %v", got, err)
+	}
+}
+
+func TestInterceptors(t *testing.T) {
+	var attempts int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		if r.Header.Get("X-Tenant") != "acme" {
+			t.Errorf("Expected the interceptor header, got %q", r.Header.Get("X-Tenant"))
+		}
+		if attempts == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			fmt.Fprint(w, `{"message":"unavailable"}`)
+			return
+		}
+		fmt.Fprint(w, `{"id":"merchant_123"}`)
+	}))
+	defer server.Close()
+
+	var calls []string
+	record := func(name string) Interceptor {
+		return func(ctx context.Context, req *Request, next Handler) (*http.Response, error) {
+			calls = append(calls, name+" before")
+			resp, err := next(ctx, req)
+			calls = append(calls, name+" after")
+			return resp, err
+		}
+	}
+	setHeader := func(ctx context.Context, req *Request, next Handler) (*http.Response, error) {
+		if req.Headers == nil {
+			req.Headers = map[string]string{}
+		}
+		req.Headers["X-Tenant"] = "acme"
+		return next(ctx, req)
+	}
+
+	sdk := NewSDK(&Config{
+		BaseURL:      server.URL,
+		Retry:        RetryPolicy{MaxRetries: 1, RetryWaitMin: time.Millisecond, RetryWaitMax: time.Millisecond},
+		Interceptors: []Interceptor{record("outer"), record("inner"), setHeader},
+	})
+	if _, err := sdk.Merchant.GetMerchantInfo(context.Background(), "merchant_123"); err != nil {
+		t.Fatalf("GetMerchantInfo() error = %v", err)
+	}
+
+	want := []string{"outer before", "inner before", "inner after", "outer after"}
+	if strings.Join(calls, ", ") != strings.Join(want, ", ") {
+		t.Errorf("Expected interceptors to run once around the retries as %v, got %v", want, calls)
+	}
+	if attempts != 2 {
+		t.Errorf("Expected 2 attempts, got %d", attempts)
+	}
+}
+
+func TestInterceptorErrors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("Unexpected request to %s", r.URL.Path)
+	}))
+	defer server.Close()
+
+	errBlocked := errors.New("blocked")
+	sdk := NewSDK(&Config{
+		BaseURL: server.URL,
+		Interceptors: []Interceptor{
+			func(ctx context.Context, req *Request, next Handler) (*http.Response, error) {
+				if req.Method == http.MethodPost {
+					return nil, errBlocked
+				}
+				return next(ctx, req)
+			},
+		},
+	})
+
+	_, err := sdk.Merchant.CreateMerchant(context.Background(), &MerchantRequest{Name: "Acme"})
+	if !errors.Is(err, errBlocked) {
+		t.Errorf("Expected the interceptor error to be returned, got %v", err)
+	}
+}
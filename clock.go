@@ -0,0 +1,77 @@
+package americanexpress
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+)
+
+// Clock provides the current time. Replace it through Config.Clock to make
+// expiry checks, signing timestamps and retries deterministic in tests.
+type Clock interface {
+	Now() time.Time
+}
+
+// systemClock is the Clock backed by time.Now
+type systemClock struct{}
+
+// Now returns the current local time
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+// SystemClock is the default Clock, backed by time.Now
+var SystemClock Clock = systemClock{}
+
+// ClockSkew returns how far the gateway's clock is ahead of the client's
+// (negative when it is behind), as reported by the Date header of the most
+// recent response. It is accurate to about a second and zero until the
+// first response arrives.
+func (c *Client) ClockSkew() time.Duration {
+	return time.Duration(c.clockSkew.Load())
+}
+
+// gatewayNow returns the current time on the gateway's clock, as estimated
+// from the clock skew. Signed requests are timestamped with it.
+func (c *Client) gatewayNow() time.Time {
+	return c.now().Add(c.ClockSkew())
+}
+
+// ErrorCodeInvalidTimestamp is the APIError code of a 401 rejecting a
+// signed request whose timestamp is too far from the gateway's clock
+const ErrorCodeInvalidTimestamp = "invalid_timestamp"
+
+// sendSynced sends a request, resending a signed request once when the
+// gateway rejects its timestamp. The rejection's Date header has updated
+// the clock skew by then, so the second attempt carries the gateway's time.
+// Requests whose streamed body can't be read again are not resent.
+func (c *Client) sendSynced(ctx context.Context, req *Request) (*http.Response, error) {
+	resp, err := c.send(ctx, req)
+	var apiErr *APIError
+	if !c.signing || !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusUnauthorized || apiErr.Code != ErrorCodeInvalidTimestamp {
+		return resp, err
+	}
+	if !isReplayable(req) {
+		return resp, err
+	}
+	if c.logger != nil {
+		c.logger.Warn("amex request timestamp rejected, resending with the gateway clock",
+			"method", req.Method,
+			"path", req.Path,
+			"clock_skew", c.ClockSkew(),
+		)
+	}
+	return c.send(ctx, req)
+}
+
+// observeServerTime records the clock skew from a response's Date header
+func (c *Client) observeServerTime(header http.Header) {
+	serverTime, err := http.ParseTime(header.Get("Date"))
+	if err != nil {
+		return
+	}
+	// The Date header has a resolution of one second
+	skew := serverTime.Sub(c.now().Truncate(time.Second))
+	c.clockSkew.Store(int64(skew))
+}
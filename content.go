@@ -0,0 +1,114 @@
+package americanexpress
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+)
+
+// maxBodySnippet is the number of body bytes kept in an
+// UnexpectedContentTypeError
+const maxBodySnippet = 256
+
+// UnexpectedContentTypeError is returned when a successful response is not
+// JSON, e.g. an HTML error page served by a load balancer or proxy in front
+// of the gateway. It usually points at a misconfigured base URL or network.
+type UnexpectedContentTypeError struct {
+	StatusCode  int
+	ContentType string // Content-Type header of the response
+	Snippet     string // Start of the response body, truncated to 256 bytes
+}
+
+func (e *UnexpectedContentTypeError) Error() string {
+	return fmt.Sprintf("unexpected response content type %q (status %d): %s", e.ContentType, e.StatusCode, e.Snippet)
+}
+
+// isJSONContentType reports whether a Content-Type header denotes JSON,
+// including "+json" media types such as application/problem+json
+func isJSONContentType(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	return mediaType == "application/json" || strings.HasSuffix(mediaType, "+json")
+}
+
+// bodySnippet returns the start of a response body for error messages,
+// truncated at a character boundary
+func bodySnippet(body []byte) string {
+	snippet := strings.TrimSpace(string(body))
+	if len(snippet) <= maxBodySnippet {
+		return snippet
+	}
+	cut := maxBodySnippet
+	for cut > 0 && !utf8.RuneStart(snippet[cut]) {
+		cut--
+	}
+	return snippet[:cut] + "..."
+}
+
+// maxBareStatus is the longest plain-text body accepted as a bare status
+const maxBareStatus = 64
+
+// decodeVoidResponse decodes the response of a void endpoint. Besides a JSON
+// object, void endpoints may answer with an empty body or a bare status
+// such as "voided". Those leave v unchanged and the status is returned
+// instead, taken from the body or derived from the HTTP status code. With
+// Config.StrictVoidResponses every body is decoded as by decodeResponse.
+func (c *Client) decodeVoidResponse(resp *http.Response, v interface{}) (*ResponseMeta, string, error) {
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to read response: %w", err)
+	}
+
+	status, ok := bareStatus(bytes.TrimSpace(body))
+	if c.strictVoid || !ok {
+		resp.Body = io.NopCloser(bytes.NewReader(body))
+		meta, err := c.decodeResponse(resp, v)
+		return meta, "", err
+	}
+
+	if status == "" {
+		status = voidStatusFromCode(resp.StatusCode)
+	}
+	meta := newResponseMeta(resp)
+	if c.retainRaw {
+		meta.Raw = body
+	}
+	return meta, status, nil
+}
+
+// bareStatus parses a body that is not a JSON object: an empty body, a JSON
+// string or a single word of plain text. It reports false for anything
+// else, e.g. an object or an HTML page.
+func bareStatus(body []byte) (string, bool) {
+	if len(body) == 0 {
+		return "", true
+	}
+	if body[0] == '"' {
+		var status string
+		if err := json.Unmarshal(body, &status); err != nil {
+			return "", false
+		}
+		return strings.ToLower(strings.TrimSpace(status)), true
+	}
+	if len(body) > maxBareStatus || bytes.ContainsAny(body, " \t\r\n<>{}[]\"") {
+		return "", false
+	}
+	return strings.ToLower(string(body)), true
+}
+
+// voidStatusFromCode derives the status of a void from a successful HTTP
+// status code: 202 Accepted means it is still being processed
+func voidStatusFromCode(statusCode int) string {
+	if statusCode == http.StatusAccepted {
+		return string(TransactionStatusPending)
+	}
+	return string(TransactionStatusVoided)
+}
@@ -0,0 +1,49 @@
+package americanexpress
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+)
+
+// CorrelationIDHeader is the header used to send the request correlation ID
+const CorrelationIDHeader = "X-Correlation-Id"
+
+// correlationIDKey is the context key under which the correlation ID is stored
+type correlationIDKey struct{}
+
+// WithCorrelationID returns a copy of ctx carrying a correlation ID that is
+// sent with every request made using the context. Correlation IDs are only
+// used for tracing and are never used as idempotency keys.
+func WithCorrelationID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, correlationIDKey{}, id)
+}
+
+// CorrelationIDFromContext returns the correlation ID carried by ctx, if any
+func CorrelationIDFromContext(ctx context.Context) (string, bool) {
+	id, ok := ctx.Value(correlationIDKey{}).(string)
+	return id, ok && id != ""
+}
+
+// correlationID returns the correlation ID to send for a request made with
+// ctx, generating one if the client is configured to do so
+func (c *Client) correlationID(ctx context.Context) string {
+	if id, ok := CorrelationIDFromContext(ctx); ok {
+		return id
+	}
+	if c.generateCorrelationID {
+		return newUUID()
+	}
+	return ""
+}
+
+// newUUID returns a random (version 4) UUID
+func newUUID() string {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		panic(fmt.Sprintf("americanexpress: failed to read random bytes: %v", err))
+	}
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
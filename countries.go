@@ -0,0 +1,380 @@
+package americanexpress
+
+import "strings"
+
+// countryCodes is the set of ISO 3166-1 alpha-2 country codes
+var countryCodes = map[string]struct{}{
+	"AD": {}, "AE": {}, "AF": {}, "AG": {}, "AI": {}, "AL": {}, "AM": {}, "AO": {}, "AQ": {}, "AR": {},
+	"AS": {}, "AT": {}, "AU": {}, "AW": {}, "AX": {}, "AZ": {}, "BA": {}, "BB": {}, "BD": {}, "BE": {},
+	"BF": {}, "BG": {}, "BH": {}, "BI": {}, "BJ": {}, "BL": {}, "BM": {}, "BN": {}, "BO": {}, "BQ": {},
+	"BR": {}, "BS": {}, "BT": {}, "BV": {}, "BW": {}, "BY": {}, "BZ": {}, "CA": {}, "CC": {}, "CD": {},
+	"CF": {}, "CG": {}, "CH": {}, "CI": {}, "CK": {}, "CL": {}, "CM": {}, "CN": {}, "CO": {}, "CR": {},
+	"CU": {}, "CV": {}, "CW": {}, "CX": {}, "CY": {}, "CZ": {}, "DE": {}, "DJ": {}, "DK": {}, "DM": {},
+	"DO": {}, "DZ": {}, "EC": {}, "EE": {}, "EG": {}, "EH": {}, "ER": {}, "ES": {}, "ET": {}, "FI": {},
+	"FJ": {}, "FK": {}, "FM": {}, "FO": {}, "FR": {}, "GA": {}, "GB": {}, "GD": {}, "GE": {}, "GF": {},
+	"GG": {}, "GH": {}, "GI": {}, "GL": {}, "GM": {}, "GN": {}, "GP": {}, "GQ": {}, "GR": {}, "GS": {},
+	"GT": {}, "GU": {}, "GW": {}, "GY": {}, "HK": {}, "HM": {}, "HN": {}, "HR": {}, "HT": {}, "HU": {},
+	"ID": {}, "IE": {}, "IL": {}, "IM": {}, "IN": {}, "IO": {}, "IQ": {}, "IR": {}, "IS": {}, "IT": {},
+	"JE": {}, "JM": {}, "JO": {}, "JP": {}, "KE": {}, "KG": {}, "KH": {}, "KI": {}, "KM": {}, "KN": {},
+	"KP": {}, "KR": {}, "KW": {}, "KY": {}, "KZ": {}, "LA": {}, "LB": {}, "LC": {}, "LI": {}, "LK": {},
+	"LR": {}, "LS": {}, "LT": {}, "LU": {}, "LV": {}, "LY": {}, "MA": {}, "MC": {}, "MD": {}, "ME": {},
+	"MF": {}, "MG": {}, "MH": {}, "MK": {}, "ML": {}, "MM": {}, "MN": {}, "MO": {}, "MP": {}, "MQ": {},
+	"MR": {}, "MS": {}, "MT": {}, "MU": {}, "MV": {}, "MW": {}, "MX": {}, "MY": {}, "MZ": {}, "NA": {},
+	"NC": {}, "NE": {}, "NF": {}, "NG": {}, "NI": {}, "NL": {}, "NO": {}, "NP": {}, "NR": {}, "NU": {},
+	"NZ": {}, "OM": {}, "PA": {}, "PE": {}, "PF": {}, "PG": {}, "PH": {}, "PK": {}, "PL": {}, "PM": {},
+	"PN": {}, "PR": {}, "PS": {}, "PT": {}, "PW": {}, "PY": {}, "QA": {}, "RE": {}, "RO": {}, "RS": {},
+	"RU": {}, "RW": {}, "SA": {}, "SB": {}, "SC": {}, "SD": {}, "SE": {}, "SG": {}, "SH": {}, "SI": {},
+	"SJ": {}, "SK": {}, "SL": {}, "SM": {}, "SN": {}, "SO": {}, "SR": {}, "SS": {}, "ST": {}, "SV": {},
+	"SX": {}, "SY": {}, "SZ": {}, "TC": {}, "TD": {}, "TF": {}, "TG": {}, "TH": {}, "TJ": {}, "TK": {},
+	"TL": {}, "TM": {}, "TN": {}, "TO": {}, "TR": {}, "TT": {}, "TV": {}, "TW": {}, "TZ": {}, "UA": {},
+	"UG": {}, "UM": {}, "US": {}, "UY": {}, "UZ": {}, "VA": {}, "VC": {}, "VE": {}, "VG": {}, "VI": {},
+	"VN": {}, "VU": {}, "WF": {}, "WS": {}, "YE": {}, "YT": {}, "ZA": {}, "ZM": {}, "ZW": {},
+}
+
+// countryAlpha3 maps ISO 3166-1 alpha-3 country codes to alpha-2
+var countryAlpha3 = map[string]string{
+	"ABW": "AW", "AFG": "AF", "AGO": "AO", "AIA": "AI", "ALA": "AX", "ALB": "AL", "AND": "AD", "ARE": "AE",
+	"ARG": "AR", "ARM": "AM", "ASM": "AS", "ATA": "AQ", "ATF": "TF", "ATG": "AG", "AUS": "AU", "AUT": "AT",
+	"AZE": "AZ", "BDI": "BI", "BEL": "BE", "BEN": "BJ", "BES": "BQ", "BFA": "BF", "BGD": "BD", "BGR": "BG",
+	"BHR": "BH", "BHS": "BS", "BIH": "BA", "BLM": "BL", "BLR": "BY", "BLZ": "BZ", "BMU": "BM", "BOL": "BO",
+	"BRA": "BR", "BRB": "BB", "BRN": "BN", "BTN": "BT", "BVT": "BV", "BWA": "BW", "CAF": "CF", "CAN": "CA",
+	"CCK": "CC", "CHE": "CH", "CHL": "CL", "CHN": "CN", "CIV": "CI", "CMR": "CM", "COD": "CD", "COG": "CG",
+	"COK": "CK", "COL": "CO", "COM": "KM", "CPV": "CV", "CRI": "CR", "CUB": "CU", "CUW": "CW", "CXR": "CX",
+	"CYM": "KY", "CYP": "CY", "CZE": "CZ", "DEU": "DE", "DJI": "DJ", "DMA": "DM", "DNK": "DK", "DOM": "DO",
+	"DZA": "DZ", "ECU": "EC", "EGY": "EG", "ERI": "ER", "ESH": "EH", "ESP": "ES", "EST": "EE", "ETH": "ET",
+	"FIN": "FI", "FJI": "FJ", "FLK": "FK", "FRA": "FR", "FRO": "FO", "FSM": "FM", "GAB": "GA", "GBR": "GB",
+	"GEO": "GE", "GGY": "GG", "GHA": "GH", "GIB": "GI", "GIN": "GN", "GLP": "GP", "GMB": "GM", "GNB": "GW",
+	"GNQ": "GQ", "GRC": "GR", "GRD": "GD", "GRL": "GL", "GTM": "GT", "GUF": "GF", "GUM": "GU", "GUY": "GY",
+	"HKG": "HK", "HMD": "HM", "HND": "HN", "HRV": "HR", "HTI": "HT", "HUN": "HU", "IDN": "ID", "IMN": "IM",
+	"IND": "IN", "IOT": "IO", "IRL": "IE", "IRN": "IR", "IRQ": "IQ", "ISL": "IS", "ISR": "IL", "ITA": "IT",
+	"JAM": "JM", "JEY": "JE", "JOR": "JO", "JPN": "JP", "KAZ": "KZ", "KEN": "KE", "KGZ": "KG", "KHM": "KH",
+	"KIR": "KI", "KNA": "KN", "KOR": "KR", "KWT": "KW", "LAO": "LA", "LBN": "LB", "LBR": "LR", "LBY": "LY",
+	"LCA": "LC", "LIE": "LI", "LKA": "LK", "LSO": "LS", "LTU": "LT", "LUX": "LU", "LVA": "LV", "MAC": "MO",
+	"MAF": "MF", "MAR": "MA", "MCO": "MC", "MDA": "MD", "MDG": "MG", "MDV": "MV", "MEX": "MX", "MHL": "MH",
+	"MKD": "MK", "MLI": "ML", "MLT": "MT", "MMR": "MM", "MNE": "ME", "MNG": "MN", "MNP": "MP", "MOZ": "MZ",
+	"MRT": "MR", "MSR": "MS", "MTQ": "MQ", "MUS": "MU", "MWI": "MW", "MYS": "MY", "MYT": "YT", "NAM": "NA",
+	"NCL": "NC", "NER": "NE", "NFK": "NF", "NGA": "NG", "NIC": "NI", "NIU": "NU", "NLD": "NL", "NOR": "NO",
+	"NPL": "NP", "NRU": "NR", "NZL": "NZ", "OMN": "OM", "PAK": "PK", "PAN": "PA", "PCN": "PN", "PER": "PE",
+	"PHL": "PH", "PLW": "PW", "PNG": "PG", "POL": "PL", "PRI": "PR", "PRK": "KP", "PRT": "PT", "PRY": "PY",
+	"PSE": "PS", "PYF": "PF", "QAT": "QA", "REU": "RE", "ROU": "RO", "RUS": "RU", "RWA": "RW", "SAU": "SA",
+	"SDN": "SD", "SEN": "SN", "SGP": "SG", "SGS": "GS", "SHN": "SH", "SJM": "SJ", "SLB": "SB", "SLE": "SL",
+	"SLV": "SV", "SMR": "SM", "SOM": "SO", "SPM": "PM", "SRB": "RS", "SSD": "SS", "STP": "ST", "SUR": "SR",
+	"SVK": "SK", "SVN": "SI", "SWE": "SE", "SWZ": "SZ", "SXM": "SX", "SYC": "SC", "SYR": "SY", "TCA": "TC",
+	"TCD": "TD", "TGO": "TG", "THA": "TH", "TJK": "TJ", "TKL": "TK", "TKM": "TM", "TLS": "TL", "TON": "TO",
+	"TTO": "TT", "TUN": "TN", "TUR": "TR", "TUV": "TV", "TWN": "TW", "TZA": "TZ", "UGA": "UG", "UKR": "UA",
+	"UMI": "UM", "URY": "UY", "USA": "US", "UZB": "UZ", "VAT": "VA", "VCT": "VC", "VEN": "VE", "VGB": "VG",
+	"VIR": "VI", "VNM": "VN", "VUT": "VU", "WLF": "WF", "WSM": "WS", "YEM": "YE", "ZAF": "ZA", "ZMB": "ZM",
+	"ZWE": "ZW",
+}
+
+// countryNames maps lower-case English country names, including common
+// short forms, to ISO 3166-1 alpha-2 codes
+var countryNames = map[string]string{
+	"afghanistan":                            "AF",
+	"aland islands":                          "AX",
+	"albania":                                "AL",
+	"algeria":                                "DZ",
+	"american samoa":                         "AS",
+	"andorra":                                "AD",
+	"angola":                                 "AO",
+	"anguilla":                               "AI",
+	"antarctica":                             "AQ",
+	"antigua and barbuda":                    "AG",
+	"argentina":                              "AR",
+	"armenia":                                "AM",
+	"aruba":                                  "AW",
+	"australia":                              "AU",
+	"austria":                                "AT",
+	"azerbaijan":                             "AZ",
+	"bahamas":                                "BS",
+	"bahrain":                                "BH",
+	"bangladesh":                             "BD",
+	"barbados":                               "BB",
+	"belarus":                                "BY",
+	"belgium":                                "BE",
+	"belize":                                 "BZ",
+	"benin":                                  "BJ",
+	"bermuda":                                "BM",
+	"bhutan":                                 "BT",
+	"bolivia":                                "BO",
+	"bolivia, plurinational state of":        "BO",
+	"bonaire, sint eustatius and saba":       "BQ",
+	"bosnia and herzegovina":                 "BA",
+	"botswana":                               "BW",
+	"bouvet island":                          "BV",
+	"brazil":                                 "BR",
+	"british indian ocean territory":         "IO",
+	"brunei":                                 "BN",
+	"brunei darussalam":                      "BN",
+	"bulgaria":                               "BG",
+	"burkina faso":                           "BF",
+	"burundi":                                "BI",
+	"cabo verde":                             "CV",
+	"cambodia":                               "KH",
+	"cameroon":                               "CM",
+	"canada":                                 "CA",
+	"cape verde":                             "CV",
+	"cayman islands":                         "KY",
+	"central african republic":               "CF",
+	"chad":                                   "TD",
+	"chile":                                  "CL",
+	"china":                                  "CN",
+	"christmas island":                       "CX",
+	"cocos (keeling) islands":                "CC",
+	"colombia":                               "CO",
+	"comoros":                                "KM",
+	"congo":                                  "CG",
+	"congo, the democratic republic of the":  "CD",
+	"cook islands":                           "CK",
+	"costa rica":                             "CR",
+	"cote d'ivoire":                          "CI",
+	"croatia":                                "HR",
+	"cuba":                                   "CU",
+	"curacao":                                "CW",
+	"curaçao":                                "CW",
+	"cyprus":                                 "CY",
+	"czech republic":                         "CZ",
+	"czechia":                                "CZ",
+	"côte d'ivoire":                          "CI",
+	"denmark":                                "DK",
+	"djibouti":                               "DJ",
+	"dominica":                               "DM",
+	"dominican republic":                     "DO",
+	"ecuador":                                "EC",
+	"egypt":                                  "EG",
+	"el salvador":                            "SV",
+	"equatorial guinea":                      "GQ",
+	"eritrea":                                "ER",
+	"estonia":                                "EE",
+	"eswatini":                               "SZ",
+	"ethiopia":                               "ET",
+	"falkland islands (malvinas)":            "FK",
+	"faroe islands":                          "FO",
+	"fiji":                                   "FJ",
+	"finland":                                "FI",
+	"france":                                 "FR",
+	"french guiana":                          "GF",
+	"french polynesia":                       "PF",
+	"french southern territories":            "TF",
+	"gabon":                                  "GA",
+	"gambia":                                 "GM",
+	"georgia":                                "GE",
+	"germany":                                "DE",
+	"ghana":                                  "GH",
+	"gibraltar":                              "GI",
+	"great britain":                          "GB",
+	"greece":                                 "GR",
+	"greenland":                              "GL",
+	"grenada":                                "GD",
+	"guadeloupe":                             "GP",
+	"guam":                                   "GU",
+	"guatemala":                              "GT",
+	"guernsey":                               "GG",
+	"guinea":                                 "GN",
+	"guinea-bissau":                          "GW",
+	"guyana":                                 "GY",
+	"haiti":                                  "HT",
+	"heard island and mcdonald islands":      "HM",
+	"holy see (vatican city state)":          "VA",
+	"honduras":                               "HN",
+	"hong kong":                              "HK",
+	"hungary":                                "HU",
+	"iceland":                                "IS",
+	"india":                                  "IN",
+	"indonesia":                              "ID",
+	"iran":                                   "IR",
+	"iran, islamic republic of":              "IR",
+	"iraq":                                   "IQ",
+	"ireland":                                "IE",
+	"isle of man":                            "IM",
+	"israel":                                 "IL",
+	"italy":                                  "IT",
+	"ivory coast":                            "CI",
+	"jamaica":                                "JM",
+	"japan":                                  "JP",
+	"jersey":                                 "JE",
+	"jordan":                                 "JO",
+	"kazakhstan":                             "KZ",
+	"kenya":                                  "KE",
+	"kiribati":                               "KI",
+	"korea, democratic people's republic of": "KP",
+	"korea, republic of":                     "KR",
+	"kuwait":                                 "KW",
+	"kyrgyzstan":                             "KG",
+	"lao people's democratic republic":       "LA",
+	"laos":                                   "LA",
+	"latvia":                                 "LV",
+	"lebanon":                                "LB",
+	"lesotho":                                "LS",
+	"liberia":                                "LR",
+	"libya":                                  "LY",
+	"liechtenstein":                          "LI",
+	"lithuania":                              "LT",
+	"luxembourg":                             "LU",
+	"macao":                                  "MO",
+	"macedonia":                              "MK",
+	"madagascar":                             "MG",
+	"malawi":                                 "MW",
+	"malaysia":                               "MY",
+	"maldives":                               "MV",
+	"mali":                                   "ML",
+	"malta":                                  "MT",
+	"marshall islands":                       "MH",
+	"martinique":                             "MQ",
+	"mauritania":                             "MR",
+	"mauritius":                              "MU",
+	"mayotte":                                "YT",
+	"mexico":                                 "MX",
+	"micronesia":                             "FM",
+	"micronesia, federated states of":        "FM",
+	"moldova":                                "MD",
+	"moldova, republic of":                   "MD",
+	"monaco":                                 "MC",
+	"mongolia":                               "MN",
+	"montenegro":                             "ME",
+	"montserrat":                             "MS",
+	"morocco":                                "MA",
+	"mozambique":                             "MZ",
+	"myanmar":                                "MM",
+	"namibia":                                "NA",
+	"nauru":                                  "NR",
+	"nepal":                                  "NP",
+	"netherlands":                            "NL",
+	"new caledonia":                          "NC",
+	"new zealand":                            "NZ",
+	"nicaragua":                              "NI",
+	"niger":                                  "NE",
+	"nigeria":                                "NG",
+	"niue":                                   "NU",
+	"norfolk island":                         "NF",
+	"north korea":                            "KP",
+	"north macedonia":                        "MK",
+	"northern mariana islands":               "MP",
+	"norway":                                 "NO",
+	"oman":                                   "OM",
+	"pakistan":                               "PK",
+	"palau":                                  "PW",
+	"palestine":                              "PS",
+	"palestine, state of":                    "PS",
+	"panama":                                 "PA",
+	"papua new guinea":                       "PG",
+	"paraguay":                               "PY",
+	"peru":                                   "PE",
+	"philippines":                            "PH",
+	"pitcairn":                               "PN",
+	"poland":                                 "PL",
+	"portugal":                               "PT",
+	"puerto rico":                            "PR",
+	"qatar":                                  "QA",
+	"reunion":                                "RE",
+	"romania":                                "RO",
+	"russia":                                 "RU",
+	"russian federation":                     "RU",
+	"rwanda":                                 "RW",
+	"réunion":                                "RE",
+	"saint barthelemy":                       "BL",
+	"saint barthélemy":                       "BL",
+	"saint helena, ascension and tristan da cunha": "SH",
+	"saint kitts and nevis":                        "KN",
+	"saint lucia":                                  "LC",
+	"saint martin (french part)":                   "MF",
+	"saint pierre and miquelon":                    "PM",
+	"saint vincent and the grenadines":             "VC",
+	"samoa":                                        "WS",
+	"san marino":                                   "SM",
+	"sao tome and principe":                        "ST",
+	"saudi arabia":                                 "SA",
+	"senegal":                                      "SN",
+	"serbia":                                       "RS",
+	"seychelles":                                   "SC",
+	"sierra leone":                                 "SL",
+	"singapore":                                    "SG",
+	"sint maarten (dutch part)":                    "SX",
+	"slovakia":                                     "SK",
+	"slovenia":                                     "SI",
+	"solomon islands":                              "SB",
+	"somalia":                                      "SO",
+	"south africa":                                 "ZA",
+	"south georgia and the south sandwich islands": "GS",
+	"south korea":                          "KR",
+	"south sudan":                          "SS",
+	"spain":                                "ES",
+	"sri lanka":                            "LK",
+	"sudan":                                "SD",
+	"suriname":                             "SR",
+	"svalbard and jan mayen":               "SJ",
+	"swaziland":                            "SZ",
+	"sweden":                               "SE",
+	"switzerland":                          "CH",
+	"syria":                                "SY",
+	"syrian arab republic":                 "SY",
+	"taiwan":                               "TW",
+	"taiwan, province of china":            "TW",
+	"tajikistan":                           "TJ",
+	"tanzania":                             "TZ",
+	"tanzania, united republic of":         "TZ",
+	"thailand":                             "TH",
+	"timor-leste":                          "TL",
+	"togo":                                 "TG",
+	"tokelau":                              "TK",
+	"tonga":                                "TO",
+	"trinidad and tobago":                  "TT",
+	"tunisia":                              "TN",
+	"turkey":                               "TR",
+	"turkiye":                              "TR",
+	"turkmenistan":                         "TM",
+	"turks and caicos islands":             "TC",
+	"tuvalu":                               "TV",
+	"türkiye":                              "TR",
+	"uganda":                               "UG",
+	"uk":                                   "GB",
+	"ukraine":                              "UA",
+	"united arab emirates":                 "AE",
+	"united kingdom":                       "GB",
+	"united states":                        "US",
+	"united states minor outlying islands": "UM",
+	"united states of america":             "US",
+	"uruguay":                              "UY",
+	"uzbekistan":                           "UZ",
+	"vanuatu":                              "VU",
+	"vatican city":                         "VA",
+	"venezuela":                            "VE",
+	"venezuela, bolivarian republic of":    "VE",
+	"viet nam":                             "VN",
+	"vietnam":                              "VN",
+	"virgin islands, british":              "VG",
+	"virgin islands, u.s.":                 "VI",
+	"wallis and futuna":                    "WF",
+	"western sahara":                       "EH",
+	"yemen":                                "YE",
+	"zambia":                               "ZM",
+	"zimbabwe":                             "ZW",
+	"åland islands":                        "AX",
+}
+
+// IsValidCountryCode reports whether code is an ISO 3166-1 alpha-2 country
+// code, e.g. "US". The check is case-insensitive.
+func IsValidCountryCode(code string) bool {
+	_, ok := countryCodes[strings.ToUpper(code)]
+	return ok
+}
+
+// NormalizeCountryCode returns the ISO 3166-1 alpha-2 code for a country
+// given as an alpha-2 or alpha-3 code or an English name, e.g. "us", "USA"
+// or "United States" all give "US". Input that can't be mapped
+// unambiguously is returned trimmed but otherwise unchanged, so validation
+// can report it.
+func NormalizeCountryCode(country string) string {
+	country = strings.TrimSpace(country)
+	upper := strings.ToUpper(country)
+	if IsValidCountryCode(upper) {
+		return upper
+	}
+	if code, ok := countryAlpha3[upper]; ok {
+		return code
+	}
+	if code, ok := countryNames[strings.ToLower(strings.Join(strings.Fields(country), " "))]; ok {
+		return code
+	}
+	return country
+}
@@ -0,0 +1,193 @@
+package americanexpress
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"time"
+)
+
+// CustomerService handles customers and their saved payment methods
+type CustomerService struct {
+	service
+}
+
+// NewCustomerService creates a new customer service
+func NewCustomerService(client *Client) *CustomerService {
+	return &CustomerService{service: newService(client)}
+}
+
+// CustomerRequest represents a request to create a customer
+type CustomerRequest struct {
+	Email                string            `json:"email,omitempty"` // Required
+	Name                 string            `json:"name,omitempty"`
+	DefaultPaymentMethod string            `json:"default_payment_method,omitempty"` // Token ID
+	Metadata             map[string]string `json:"metadata,omitempty"`
+}
+
+// UpdateCustomerRequest represents a partial update of a customer. A nil
+// field is left unchanged and a field set to an empty string, e.g. with
+// Ptr(""), is cleared. Only the fields that are set are sent.
+type UpdateCustomerRequest struct {
+	Email                *string           `json:"email,omitempty"` // Cannot be cleared
+	Name                 *string           `json:"name,omitempty"`
+	DefaultPaymentMethod *string           `json:"default_payment_method,omitempty"` // Token ID
+	Metadata             map[string]string `json:"metadata,omitempty"`               // Replaces the whole metadata
+}
+
+// Customer represents a customer, the owner of saved tokens
+type Customer struct {
+	ID                   string            `json:"id"`
+	Email                string            `json:"email"`
+	Name                 string            `json:"name,omitempty"`
+	DefaultPaymentMethod string            `json:"default_payment_method,omitempty"` // Token ID
+	Metadata             map[string]string `json:"metadata,omitempty"`
+	CreatedAt            time.Time         `json:"created_at"`
+	UpdatedAt            time.Time         `json:"updated_at"`
+	Meta                 *ResponseMeta     `json:"-"`
+}
+
+// CreateCustomer creates a customer that tokens can be saved for
+func (cs *CustomerService) CreateCustomer(ctx context.Context, req *CustomerRequest) (*Customer, error) {
+	// Validate the customer request
+	if err := ValidateCustomerRequest(req); err != nil {
+		return nil, fmt.Errorf("validation failed: %w", err)
+	}
+	if err := cs.validateMetadata(req.Metadata); err != nil {
+		return nil, err
+	}
+
+	resp, err := cs.post(ctx, "/customers", req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create customer: %w", err)
+	}
+
+	var customer Customer
+	meta, err := cs.decode(resp, &customer)
+	if err != nil {
+		return nil, err
+	}
+	customer.Meta = meta
+
+	return &customer, nil
+}
+
+// GetCustomer retrieves a customer by ID
+func (cs *CustomerService) GetCustomer(ctx context.Context, customerID string) (*Customer, error) {
+	if err := ValidateCustomerID(customerID); err != nil {
+		return nil, fmt.Errorf("validation failed: %w", err)
+	}
+
+	resp, err := cs.get(ctx, fmt.Sprintf("/customers/%s", url.PathEscape(customerID)), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get customer: %w", err)
+	}
+
+	var customer Customer
+	meta, err := cs.decode(resp, &customer)
+	if err != nil {
+		return nil, err
+	}
+	customer.Meta = meta
+
+	return &customer, nil
+}
+
+// UpdateCustomer updates the fields of a customer set in req
+func (cs *CustomerService) UpdateCustomer(ctx context.Context, customerID string, req *UpdateCustomerRequest) (*Customer, error) {
+	if err := ValidateCustomerID(customerID); err != nil {
+		return nil, fmt.Errorf("validation failed: %w", err)
+	}
+	// Validate the fields being updated
+	if err := ValidateCustomerUpdate(req); err != nil {
+		return nil, fmt.Errorf("validation failed: %w", err)
+	}
+	if err := cs.validateMetadata(req.Metadata); err != nil {
+		return nil, err
+	}
+
+	resp, err := cs.put(ctx, fmt.Sprintf("/customers/%s", url.PathEscape(customerID)), req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update customer: %w", err)
+	}
+
+	var customer Customer
+	meta, err := cs.decode(resp, &customer)
+	if err != nil {
+		return nil, err
+	}
+	customer.Meta = meta
+
+	return &customer, nil
+}
+
+// DeleteCustomer deletes a customer
+func (cs *CustomerService) DeleteCustomer(ctx context.Context, customerID string) error {
+	if err := ValidateCustomerID(customerID); err != nil {
+		return fmt.Errorf("validation failed: %w", err)
+	}
+
+	resp, err := cs.delete(ctx, fmt.Sprintf("/customers/%s", url.PathEscape(customerID)))
+	if err != nil {
+		return fmt.Errorf("failed to delete customer: %w", err)
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// PaymentMethod is a card saved on file for a customer. The card brand,
+// last four digits and expiry come from the underlying token.
+type PaymentMethod struct {
+	TokenResponse
+	IsDefault bool `json:"is_default"`
+}
+
+// listPaymentMethodsResponse is the envelope of the payment methods list
+type listPaymentMethodsResponse struct {
+	PaymentMethods []PaymentMethod `json:"payment_methods"`
+}
+
+// ListPaymentMethods retrieves the payment methods saved for a customer
+func (cs *CustomerService) ListPaymentMethods(ctx context.Context, customerID string) ([]PaymentMethod, error) {
+	if err := ValidateCustomerID(customerID); err != nil {
+		return nil, fmt.Errorf("validation failed: %w", err)
+	}
+
+	resp, err := cs.get(ctx, fmt.Sprintf("/customers/%s/payment_methods", url.PathEscape(customerID)), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list payment methods: %w", err)
+	}
+
+	var methods listPaymentMethodsResponse
+	if _, err := cs.decode(resp, &methods); err != nil {
+		return nil, err
+	}
+
+	return methods.PaymentMethods, nil
+}
+
+// SetDefaultPaymentMethod makes a saved token the customer's default
+// payment method
+func (cs *CustomerService) SetDefaultPaymentMethod(ctx context.Context, customerID, tokenID string) (*PaymentMethod, error) {
+	if err := ValidateCustomerID(customerID); err != nil {
+		return nil, fmt.Errorf("validation failed: %w", err)
+	}
+	if tokenID == "" {
+		return nil, fmt.Errorf("validation failed: %w", validationError("token_id", ValidationCodeRequired, "token ID cannot be empty"))
+	}
+
+	body := map[string]string{"token_id": tokenID}
+	resp, err := cs.post(ctx, fmt.Sprintf("/customers/%s/payment_methods/default", url.PathEscape(customerID)), body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to set default payment method: %w", err)
+	}
+
+	var method PaymentMethod
+	meta, err := cs.decode(resp, &method)
+	if err != nil {
+		return nil, err
+	}
+	method.Meta = meta
+
+	return &method, nil
+}
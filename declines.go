@@ -0,0 +1,166 @@
+package americanexpress
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// DeclineType classifies why a transaction was declined, which decides
+// whether it is worth presenting again
+type DeclineType string
+
+// Decline types
+const (
+	DeclineNone DeclineType = ""     // The transaction was not declined
+	DeclineSoft DeclineType = "soft" // Temporary; the transaction may succeed if presented later
+	DeclineHard DeclineType = "hard" // Permanent; don't retry with the same card
+)
+
+// String returns the name of the decline type
+func (d DeclineType) String() string {
+	if d == DeclineNone {
+		return "none"
+	}
+	return string(d)
+}
+
+// declineCode is the classification of a processor response code
+type declineCode struct {
+	declineType DeclineType
+	retryAfter  time.Duration
+}
+
+// declineCodes categorizes the processor response codes of declined
+// transactions. Codes that are not listed are treated as hard declines, so
+// an unknown decline is never re-presented automatically.
+//
+//	Code  Meaning                        Type  Retry after
+//	05    Do not honor                   soft  24h
+//	19    Re-enter transaction           soft  1m
+//	51    Insufficient funds             soft  72h
+//	61    Exceeds withdrawal limit       soft  24h
+//	65    Exceeds withdrawal frequency   soft  24h
+//	91    Issuer unavailable             soft  1h
+//	96    System malfunction             soft  1h
+//	04    Pick up card                   hard
+//	07    Pick up card, special          hard
+//	14    Invalid card number            hard
+//	15    No such issuer                 hard
+//	41    Lost card                      hard
+//	43    Stolen card                    hard
+//	54    Expired card                   hard
+//	57    Not permitted to cardholder    hard
+//	62    Restricted card                hard
+var declineCodes = map[string]declineCode{
+	"05": {DeclineSoft, 24 * time.Hour},
+	"19": {DeclineSoft, time.Minute},
+	"51": {DeclineSoft, 72 * time.Hour},
+	"61": {DeclineSoft, 24 * time.Hour},
+	"65": {DeclineSoft, 24 * time.Hour},
+	"91": {DeclineSoft, time.Hour},
+	"96": {DeclineSoft, time.Hour},
+
+	"04": {DeclineHard, 0},
+	"07": {DeclineHard, 0},
+	"14": {DeclineHard, 0},
+	"15": {DeclineHard, 0},
+	"41": {DeclineHard, 0},
+	"43": {DeclineHard, 0},
+	"54": {DeclineHard, 0},
+	"57": {DeclineHard, 0},
+	"62": {DeclineHard, 0},
+}
+
+// decline returns the classification of a declined transaction
+func (tr *TransactionResponse) decline() declineCode {
+	if tr.Status != TransactionStatusDeclined {
+		return declineCode{declineType: DeclineNone}
+	}
+
+	code := tr.ProcessorResponse
+	if code == "" {
+		code = tr.FailureCode
+	}
+	return classifyDecline(code)
+}
+
+// classifyDecline returns the classification of a processor response code
+// of a declined transaction
+func classifyDecline(code string) declineCode {
+	if classified, ok := declineCodes[code]; ok {
+		return classified
+	}
+	return declineCode{declineType: DeclineHard}
+}
+
+// DeclineType reports whether the transaction was soft or hard declined,
+// based on its processor response code. It returns DeclineNone for
+// transactions that were not declined.
+func (tr *TransactionResponse) DeclineType() DeclineType {
+	return tr.decline().declineType
+}
+
+// RetryAfter returns how long to wait before presenting a soft-declined
+// transaction again. It returns zero for any other transaction.
+func (tr *TransactionResponse) RetryAfter() time.Duration {
+	return tr.decline().retryAfter
+}
+
+// DeclineError is returned when the gateway declines a request with
+// 402 Payment Required. It is distinct from validation (400) and server
+// (5xx) errors; the underlying *APIError is still available through
+// errors.As.
+type DeclineError struct {
+	ProcessorCode string        // Processor response code, e.g. "51"
+	Reason        string        // Decline reason reported by the gateway
+	Type          DeclineType   // DeclineSoft or DeclineHard
+	Retryable     bool          // Whether presenting the request again later may succeed
+	RetryAfter    time.Duration // Suggested wait before presenting a soft decline again
+	Err           *APIError
+}
+
+func (e *DeclineError) Error() string {
+	msg := fmt.Sprintf("amex decline: %s decline", e.Type)
+	if e.Reason != "" {
+		msg += " - " + e.Reason
+	}
+	if e.ProcessorCode != "" {
+		msg += fmt.Sprintf(" (processor code %s)", e.ProcessorCode)
+	}
+	return msg
+}
+
+// Unwrap returns the underlying API error
+func (e *DeclineError) Unwrap() error {
+	return e.Err
+}
+
+// newDeclineError builds a DeclineError from a 402 response body
+func newDeclineError(apiErr *APIError, body []byte) *DeclineError {
+	var decline struct {
+		ProcessorCode     string `json:"processor_code"`
+		ProcessorResponse string `json:"processor_response"`
+		DeclineReason     string `json:"decline_reason"`
+	}
+	_ = json.Unmarshal(body, &decline)
+
+	code := decline.ProcessorCode
+	if code == "" {
+		code = decline.ProcessorResponse
+	}
+	reason := decline.DeclineReason
+	if reason == "" {
+		reason = apiErr.Message
+	}
+
+	classified := classifyDecline(code)
+	return &DeclineError{
+		ProcessorCode: code,
+		Reason:        reason,
+		Type:          classified.declineType,
+		Retryable:     classified.declineType == DeclineSoft,
+		RetryAfter:    classified.retryAfter,
+		Err:           apiErr,
+	}
+}
@@ -0,0 +1,66 @@
+package americanexpress
+
+import (
+	"sync"
+	"time"
+)
+
+// DefaultRefundDedupeWindow is how long a refund reference is remembered to
+// prevent the same refund from being issued twice
+const DefaultRefundDedupeWindow = 5 * time.Minute
+
+// DedupeStore tracks recently used keys for client-side request
+// deduplication. Implementations must be safe for concurrent use.
+type DedupeStore interface {
+	// Reserve records key for the given window. It returns false if the key
+	// is already reserved.
+	Reserve(key string, window time.Duration) bool
+	// Release removes a reservation so the key can be used again
+	Release(key string)
+}
+
+// MemoryDedupeStore is an in-memory DedupeStore implementation
+type MemoryDedupeStore struct {
+	mu    sync.Mutex
+	keys  map[string]time.Time
+	clock Clock
+}
+
+// NewMemoryDedupeStore creates a new in-memory dedupe store
+func NewMemoryDedupeStore() *MemoryDedupeStore {
+	return NewMemoryDedupeStoreWithClock(SystemClock)
+}
+
+// NewMemoryDedupeStoreWithClock creates a new in-memory dedupe store that
+// reads the current time from clock when expiring reservations
+func NewMemoryDedupeStoreWithClock(clock Clock) *MemoryDedupeStore {
+	return &MemoryDedupeStore{keys: make(map[string]time.Time), clock: clock}
+}
+
+// Reserve records key for the given window. It returns false if the key is
+// already reserved.
+func (ds *MemoryDedupeStore) Reserve(key string, window time.Duration) bool {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+
+	now := ds.clock.Now()
+	for k, expiresAt := range ds.keys {
+		if !now.Before(expiresAt) {
+			delete(ds.keys, k)
+		}
+	}
+
+	if _, ok := ds.keys[key]; ok {
+		return false
+	}
+	ds.keys[key] = now.Add(window)
+	return true
+}
+
+// Release removes a reservation so the key can be used again
+func (ds *MemoryDedupeStore) Release(key string) {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+
+	delete(ds.keys, key)
+}
@@ -0,0 +1,171 @@
+package americanexpress
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// DisputeService handles chargeback and dispute operations
+type DisputeService struct {
+	service
+}
+
+// NewDisputeService creates a new dispute service
+func NewDisputeService(client *Client) *DisputeService {
+	return &DisputeService{service: newService(client)}
+}
+
+// Dispute represents a chargeback raised against a transaction
+type Dispute struct {
+	ID            string        `json:"id"`
+	TransactionID string        `json:"transaction_id"`
+	MerchantID    string        `json:"merchant_id"`
+	Status        string        `json:"status"` // "open", "under_review", "won", "lost", "accepted"
+	ReasonCode    string        `json:"reason_code"`
+	Reason        string        `json:"reason"`
+	Amount        float64       `json:"amount"`
+	Currency      string        `json:"currency"`
+	DueDate       time.Time     `json:"due_date"`
+	CreatedAt     time.Time     `json:"created_at"`
+	UpdatedAt     time.Time     `json:"updated_at"`
+	Evidence      *Evidence     `json:"evidence,omitempty"`
+	Meta          *ResponseMeta `json:"-"`
+}
+
+// clone returns a copy of the dispute that shares no slices or pointers
+// with d, or nil
+func (d *Dispute) clone() *Dispute {
+	if d == nil {
+		return nil
+	}
+	clone := *d
+	if d.Evidence != nil {
+		evidence := *d.Evidence
+		evidence.Documents = append([]EvidenceDocument(nil), d.Evidence.Documents...)
+		clone.Evidence = &evidence
+	}
+	clone.Meta = d.Meta.clone()
+	return &clone
+}
+
+// Evidence represents the merchant's response to a dispute
+type Evidence struct {
+	Rebuttal  string             `json:"rebuttal"`
+	Documents []EvidenceDocument `json:"documents,omitempty"`
+}
+
+// EvidenceDocument references a document supporting a dispute response
+type EvidenceDocument struct {
+	ID          string `json:"id"`
+	Type        string `json:"type"` // e.g. "receipt", "proof_of_delivery", "correspondence"
+	Description string `json:"description,omitempty"`
+}
+
+// GetDispute retrieves a dispute by ID
+func (ds *DisputeService) GetDispute(ctx context.Context, disputeID string) (*Dispute, error) {
+	resp, err := ds.get(ctx, fmt.Sprintf("/disputes/%s", disputeID), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get dispute: %w", err)
+	}
+
+	var dispute Dispute
+	meta, err := ds.decode(resp, &dispute)
+	if err != nil {
+		return nil, err
+	}
+	dispute.Meta = meta
+
+	return &dispute, nil
+}
+
+// ListDisputesRequest represents parameters for listing disputes
+type ListDisputesRequest struct {
+	MerchantID string `url:"merchant_id,omitempty"`
+	Status     string `url:"status,omitempty"`
+	StartDate  string `url:"start_date,omitempty"`
+	EndDate    string `url:"end_date,omitempty"`
+	Limit      int    `url:"limit,omitempty"`
+	Offset     int    `url:"offset,omitempty"`
+
+	// StartTime and EndTime are alternatives to StartDate and EndDate, sent
+	// as the date in UTC. A date string set as well takes precedence.
+	StartTime time.Time `url:"-"`
+	EndTime   time.Time `url:"-"`
+}
+
+// ListDisputesResponse represents a list of disputes response
+type ListDisputesResponse struct {
+	Disputes   []Dispute     `json:"disputes"`
+	TotalCount int           `json:"total_count"`
+	HasMore    bool          `json:"has_more"`
+	Meta       *ResponseMeta `json:"-"`
+}
+
+// ListDisputes retrieves a list of disputes. The merchant set with
+// WithMerchant is used when req.MerchantID is empty.
+func (ds *DisputeService) ListDisputes(ctx context.Context, req *ListDisputesRequest) (*ListDisputesResponse, error) {
+	if req == nil {
+		req = &ListDisputesRequest{}
+	}
+	query, err := encodeQuery(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode query: %w", err)
+	}
+	if id := merchantID(ctx, req.MerchantID); id != "" {
+		query.Set("merchant_id", id)
+	}
+	query.Del("start_date")
+	query.Del("end_date")
+	ds.client.addDateRange(query, req.StartDate, req.StartTime, req.EndDate, req.EndTime)
+
+	resp, err := ds.get(ctx, "/disputes", query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list disputes: %w", err)
+	}
+
+	var disputes ListDisputesResponse
+	meta, err := ds.decode(resp, &disputes)
+	if err != nil {
+		return nil, err
+	}
+	disputes.Meta = meta
+
+	return &disputes, nil
+}
+
+// SubmitEvidence submits the merchant's rebuttal and supporting documents
+// for a dispute. Files, if any, are uploaded along with the evidence as
+// multipart/form-data; see EvidenceFile.
+func (ds *DisputeService) SubmitEvidence(ctx context.Context, disputeID string, evidence *Evidence, files ...EvidenceFile) (*Dispute, error) {
+	// Validate the evidence
+	if err := ValidateEvidence(evidence); err != nil {
+		return nil, fmt.Errorf("validation failed: %w", err)
+	}
+	if err := ValidateEvidenceFiles(files); err != nil {
+		return nil, fmt.Errorf("validation failed: %w", err)
+	}
+
+	var body interface{} = evidence
+	if len(files) > 0 {
+		upload, err := newEvidenceUpload(evidence, files)
+		if err != nil {
+			return nil, err
+		}
+		body = upload
+	}
+
+	resp, err := ds.post(ctx, fmt.Sprintf("/disputes/%s/evidence", disputeID), body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to submit evidence: %w", err)
+	}
+
+	var dispute Dispute
+	meta, err := ds.decode(resp, &dispute)
+	if err != nil {
+		return nil, err
+	}
+	dispute.Meta = meta
+
+	return &dispute, nil
+}
@@ -0,0 +1,58 @@
+package americanexpress
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Environment variables read by ConfigFromEnv
+const (
+	EnvAPIKey      = "AMEX_API_KEY"     // Required
+	EnvSecretKey   = "AMEX_SECRET_KEY"  // Required
+	EnvBaseURL     = "AMEX_BASE_URL"    // Optional, overrides the environment's base URL
+	EnvEnvironment = "AMEX_ENVIRONMENT" // Optional, "production" or "sandbox"
+)
+
+// ConfigFromEnv builds a configuration from the AMEX_* environment
+// variables. It returns an error naming the required variables that are
+// not set; their values never appear in errors. Fields of the returned
+// config can be set before passing it to NewSDK to override or extend it.
+func ConfigFromEnv() (*Config, error) {
+	config := &Config{
+		APIKey:    strings.TrimSpace(os.Getenv(EnvAPIKey)),
+		SecretKey: strings.TrimSpace(os.Getenv(EnvSecretKey)),
+		BaseURL:   strings.TrimSpace(os.Getenv(EnvBaseURL)),
+	}
+
+	var missing []string
+	if config.APIKey == "" {
+		missing = append(missing, EnvAPIKey)
+	}
+	if config.SecretKey == "" {
+		missing = append(missing, EnvSecretKey)
+	}
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("invalid config: missing environment variables %s", strings.Join(missing, ", "))
+	}
+
+	switch env := Environment(strings.ToLower(strings.TrimSpace(os.Getenv(EnvEnvironment)))); env {
+	case "":
+	case Production, Sandbox:
+		config.Environment = env
+	default:
+		return nil, fmt.Errorf("invalid config: %s must be %q or %q", EnvEnvironment, Production, Sandbox)
+	}
+
+	return config, nil
+}
+
+// NewSDKFromEnv creates a new American Express SDK instance configured from
+// the environment. See ConfigFromEnv.
+func NewSDKFromEnv() (*SDK, error) {
+	config, err := ConfigFromEnv()
+	if err != nil {
+		return nil, err
+	}
+	return NewSDKWithError(config)
+}
@@ -11,9 +11,9 @@ import (
 func main() {
 	// Initialize the SDK
 	config := &amex.Config{
-		APIKey:    "your-api-key",
-		SecretKey: "your-secret-key",
-		// BaseURL: "https://sandbox-gateway-na.americanexpress.com/api", // For sandbox
+		APIKey:      "your-api-key",
+		SecretKey:   "your-secret-key",
+		Environment: amex.Sandbox, // Use amex.Production for live traffic
 	}
 
 	sdk := amex.NewSDK(config)
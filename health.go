@@ -0,0 +1,129 @@
+package americanexpress
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// DefaultStatusCacheTTL is how long the result of GetServiceStatus is reused
+const DefaultStatusCacheTTL = 30 * time.Second
+
+// ComponentStatus is the health of one gateway component, using the values
+// of common status pages
+type ComponentStatus string
+
+// Component statuses
+const (
+	ComponentOperational         ComponentStatus = "operational"
+	ComponentDegradedPerformance ComponentStatus = "degraded_performance"
+	ComponentPartialOutage       ComponentStatus = "partial_outage"
+	ComponentMajorOutage         ComponentStatus = "major_outage"
+	ComponentUnderMaintenance    ComponentStatus = "under_maintenance"
+)
+
+// StatusIndicator summarizes the health of the whole gateway
+type StatusIndicator string
+
+// Status indicators
+const (
+	StatusIndicatorNone        StatusIndicator = "none"
+	StatusIndicatorMinor       StatusIndicator = "minor"
+	StatusIndicatorMajor       StatusIndicator = "major"
+	StatusIndicatorCritical    StatusIndicator = "critical"
+	StatusIndicatorMaintenance StatusIndicator = "maintenance"
+)
+
+// StatusSummary is the overall status of the gateway
+type StatusSummary struct {
+	Indicator   StatusIndicator `json:"indicator"`
+	Description string          `json:"description"` // e.g. "All Systems Operational"
+}
+
+// StatusComponent is the health of one gateway component, e.g. "Payments API"
+type StatusComponent struct {
+	ID        string          `json:"id"`
+	Name      string          `json:"name"`
+	Status    ComponentStatus `json:"status"`
+	UpdatedAt time.Time       `json:"updated_at"`
+}
+
+// ServiceStatus is the health of the gateway and its components
+type ServiceStatus struct {
+	Status     StatusSummary     `json:"status"`
+	Components []StatusComponent `json:"components"`
+	Meta       *ResponseMeta     `json:"-"`
+}
+
+// IsDegraded reports whether the gateway or any of its components is not
+// fully operational, including planned maintenance
+func (s *ServiceStatus) IsDegraded() bool {
+	if s.Status.Indicator != "" && s.Status.Indicator != StatusIndicatorNone {
+		return true
+	}
+	return len(s.DegradedComponents()) > 0
+}
+
+// DegradedComponents returns the components that are not fully operational
+func (s *ServiceStatus) DegradedComponents() []StatusComponent {
+	var degraded []StatusComponent
+	for _, component := range s.Components {
+		if component.Status != ComponentOperational {
+			degraded = append(degraded, component)
+		}
+	}
+	return degraded
+}
+
+// clone returns a copy of the status that does not share its components
+func (s *ServiceStatus) clone() *ServiceStatus {
+	c := *s
+	c.Components = append([]StatusComponent(nil), s.Components...)
+	return &c
+}
+
+// statusCache holds the most recent ServiceStatus
+type statusCache struct {
+	mu        sync.Mutex
+	status    *ServiceStatus
+	fetchedAt time.Time
+}
+
+// GetServiceStatus retrieves the health of the gateway and its components,
+// e.g. to show a banner or shed load while it is degraded. The result is
+// reused for Config.StatusCacheTTL so frequent callers don't hammer the
+// status endpoint. The endpoint is requested from the client's base URL
+// under Config.PathPrefix; the token, payment and reporting base URLs don't
+// apply, as their health is reported as components of the same status.
+func (c *Client) GetServiceStatus(ctx context.Context) (*ServiceStatus, error) {
+	if c.statusTTL > 0 {
+		c.status.mu.Lock()
+		cached, fetchedAt := c.status.status, c.status.fetchedAt
+		c.status.mu.Unlock()
+		if cached != nil && c.now().Sub(fetchedAt) < c.statusTTL {
+			return cached.clone(), nil
+		}
+	}
+
+	health := newService(c)
+	resp, err := health.get(ctx, "/status", nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get service status: %w", err)
+	}
+
+	var status ServiceStatus
+	meta, err := c.decodeResponse(resp, &status)
+	if err != nil {
+		return nil, err
+	}
+	status.Meta = meta
+
+	if c.statusTTL > 0 {
+		c.status.mu.Lock()
+		c.status.status, c.status.fetchedAt = status.clone(), c.now()
+		c.status.mu.Unlock()
+	}
+
+	return &status, nil
+}
@@ -0,0 +1,64 @@
+package americanexpress
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+)
+
+// IdempotencyKeyHeader is the header used to send a request's idempotency key
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+// IdempotencyReplayedHeader is the response header the gateway sets to
+// "true" when it answers from its idempotency cache instead of executing
+// the request again
+const IdempotencyReplayedHeader = "Idempotency-Replayed"
+
+// IdempotencyKeyFunc returns the idempotency key for the body of a POST
+// request. Returning an empty string sends the request without a key. It
+// may be called concurrently.
+type IdempotencyKeyFunc func(req interface{}) string
+
+// UUIDIdempotencyKey generates a random (version 4) UUID for every request.
+// Retries of the same call reuse the key, but calling a method twice with
+// the same request creates two transactions.
+func UUIDIdempotencyKey(req interface{}) string {
+	return newUUID()
+}
+
+// ContentHashIdempotencyKey derives the key from a SHA-256 hash of the
+// request's JSON encoding, so semantically identical requests share a key
+// and the gateway processes only the first of them. Requests that are
+// meant to be repeated, e.g. two identical charges, must then differ in
+// some field such as a reference or metadata.
+func ContentHashIdempotencyKey(req interface{}) string {
+	body, err := json.Marshal(req)
+	if err != nil {
+		return ""
+	}
+	sum := sha256.Sum256(body)
+	return hex.EncodeToString(sum[:])
+}
+
+// withIdempotencyKey returns the request with a generated idempotency key
+// header, if the client generates keys and the request is a POST without
+// one. The caller's header map is not modified.
+func (c *Client) withIdempotencyKey(req *Request) *Request {
+	if c.idempotencyKey == nil || req.Method != http.MethodPost || req.Headers[IdempotencyKeyHeader] != "" {
+		return req
+	}
+
+	key := c.idempotencyKey(req.Body)
+	if key == "" {
+		return req
+	}
+
+	withKey := *req
+	withKey.Headers = make(map[string]string, len(req.Headers)+1)
+	for k, v := range req.Headers {
+		withKey.Headers[k] = v
+	}
+	withKey.Headers[IdempotencyKeyHeader] = key
+	return &withKey
+}
@@ -0,0 +1,30 @@
+package americanexpress
+
+import (
+	"context"
+	"net/http"
+)
+
+// Handler sends a request and returns the gateway's response
+type Handler func(ctx context.Context, req *Request) (*http.Response, error)
+
+// Interceptor wraps every request the client makes, e.g. to add headers,
+// record metrics or rewrite requests. It calls next to continue the chain
+// and may inspect or replace the request before, and the response or error
+// after. Returning without calling next short-circuits the request.
+//
+// The request belongs to the call and may be modified. An interceptor that
+// reads the response body must replace it so the SDK can still decode it.
+type Interceptor func(ctx context.Context, req *Request, next Handler) (*http.Response, error)
+
+// chainInterceptors wraps handler with the interceptors, the first one
+// outermost: it sees the request first and the response last
+func chainInterceptors(handler Handler, interceptors []Interceptor) Handler {
+	for i := len(interceptors) - 1; i >= 0; i-- {
+		interceptor, next := interceptors[i], handler
+		handler = func(ctx context.Context, req *Request) (*http.Response, error) {
+			return interceptor(ctx, req, next)
+		}
+	}
+	return handler
+}
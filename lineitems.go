@@ -0,0 +1,67 @@
+package americanexpress
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// LineItem is an item of an order captured in a partial shipment. Line
+// items are recorded with the capture as Level 3 data and can back dispute
+// evidence.
+type LineItem struct {
+	SKU      string  `json:"sku"`
+	Quantity int     `json:"quantity"`
+	Amount   float64 `json:"amount"` // Total for the line, not the unit price
+}
+
+// validateLineItems checks that every line item has a SKU, a positive
+// quantity and a positive amount, and that the amounts add up to the total
+func validateLineItems(items []LineItem, total float64, currency string) error {
+	var sum float64
+	for i, item := range items {
+		field := fmt.Sprintf("line_items[%d]", i)
+		if strings.TrimSpace(item.SKU) == "" {
+			return validationError(field+".sku", ValidationCodeRequired, fmt.Sprintf("line item %d: SKU cannot be empty", i))
+		}
+		if item.Quantity <= 0 {
+			return validationError(field+".quantity", ValidationCodeOutOfRange, fmt.Sprintf("line item %d: quantity must be positive", i))
+		}
+		if item.Amount <= 0 {
+			return sentinelError(field+".amount", ValidationCodeOutOfRange, ErrInvalidAmount, fmt.Sprintf("line item %d: amount must be positive", i))
+		}
+		if err := ValidateAmountPrecision(item.Amount, currency); err != nil {
+			return nestValidationError(field, fmt.Sprintf("line item %d", i), err)
+		}
+		sum += item.Amount
+	}
+
+	tolerance := 0.5 * math.Pow10(-CurrencyExponent(currency))
+	if math.Abs(total-sum) >= tolerance {
+		return validationError("line_items", ValidationCodeConflict, "line item amounts must equal the capture amount")
+	}
+	return nil
+}
+
+// decimalLineItem is a LineItem with its amount as a decimal string
+type decimalLineItem struct {
+	SKU      string `json:"sku"`
+	Quantity int    `json:"quantity"`
+	Amount   string `json:"amount"`
+}
+
+// formatLineItems formats the amounts of line items as decimal strings
+func formatLineItems(items []LineItem, currency string) []decimalLineItem {
+	if items == nil {
+		return nil
+	}
+	formatted := make([]decimalLineItem, len(items))
+	for i, item := range items {
+		formatted[i] = decimalLineItem{
+			SKU:      item.SKU,
+			Quantity: item.Quantity,
+			Amount:   formatAmount(item.Amount, currency),
+		}
+	}
+	return formatted
+}
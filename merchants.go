@@ -1,60 +1,274 @@
 package americanexpress
 
 import (
+	"bytes"
 	"context"
+	"encoding/csv"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"net/http"
 	"net/url"
 	"time"
 )
 
 // MerchantService handles merchant-related operations
 type MerchantService struct {
-	client *Client
+	service
 }
 
 // NewMerchantService creates a new merchant service
 func NewMerchantService(client *Client) *MerchantService {
-	return &MerchantService{client: client}
+	return &MerchantService{service: newServiceAt(client, client.reportingBaseURL)}
+}
+
+// merchantIDKey is the context key under which the merchant ID is stored
+type merchantIDKey struct{}
+
+// WithMerchant returns a copy of ctx carrying the merchant requests made
+// with the context act on behalf of. Transaction, payment and list requests
+// that leave MerchantID empty use it; a MerchantID set on the request wins.
+func WithMerchant(ctx context.Context, merchantID string) context.Context {
+	return context.WithValue(ctx, merchantIDKey{}, merchantID)
+}
+
+// MerchantFromContext returns the merchant ID carried by ctx, if any
+func MerchantFromContext(ctx context.Context) (string, bool) {
+	id, ok := ctx.Value(merchantIDKey{}).(string)
+	return id, ok && id != ""
+}
+
+// merchantID returns id, or the merchant ID carried by ctx when id is empty
+func merchantID(ctx context.Context, id string) string {
+	if id != "" {
+		return id
+	}
+	id, _ = MerchantFromContext(ctx)
+	return id
 }
 
 // MerchantInfo represents merchant information
 type MerchantInfo struct {
-	ID            string    `json:"id"`
-	Name          string    `json:"name"`
-	Description   string    `json:"description"`
-	Website       string    `json:"website"`
-	Email         string    `json:"email"`
-	Phone         string    `json:"phone"`
-	Address       *Address  `json:"address"`
-	BusinessType  string    `json:"business_type"`
-	Status        string    `json:"status"`
-	CreatedAt     time.Time `json:"created_at"`
-	UpdatedAt     time.Time `json:"updated_at"`
+	ID           string        `json:"id"`
+	Name         string        `json:"name"`
+	Description  string        `json:"description"`
+	Website      string        `json:"website"`
+	Email        string        `json:"email"`
+	Phone        string        `json:"phone"`
+	Address      *Address      `json:"address"`
+	BusinessType string        `json:"business_type"`
+	Status       string        `json:"status"`
+	CreatedAt    time.Time     `json:"created_at"`
+	UpdatedAt    time.Time     `json:"updated_at"`
+	Meta         *ResponseMeta `json:"-"`
 }
 
 // GetMerchantInfo retrieves merchant information
 func (ms *MerchantService) GetMerchantInfo(ctx context.Context, merchantID string) (*MerchantInfo, error) {
-	resp, err := ms.client.Get(ctx, fmt.Sprintf("/merchants/%s", merchantID), nil)
+	resp, err := ms.get(ctx, fmt.Sprintf("/merchants/%s", merchantID), nil)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get merchant info: %w", err)
 	}
-	defer resp.Body.Close()
 
-	body, err := io.ReadAll(resp.Body)
+	var merchant MerchantInfo
+	meta, err := ms.decode(resp, &merchant)
+	if err != nil {
+		return nil, err
+	}
+	merchant.Meta = meta
+
+	return &merchant, nil
+}
+
+// MerchantRequest represents a request to create a merchant
+type MerchantRequest struct {
+	Name         string   `json:"name,omitempty"`
+	Description  string   `json:"description,omitempty"`
+	BusinessType string   `json:"business_type,omitempty"`
+	Email        string   `json:"email,omitempty"`
+	Phone        string   `json:"phone,omitempty"`
+	Website      string   `json:"website,omitempty"` // Must use https
+	Address      *Address `json:"address,omitempty"`
+}
+
+// UpdateMerchantRequest represents a partial update of a merchant. A nil
+// field is left unchanged and a field set to an empty string, e.g. with
+// Ptr(""), is cleared. Only the fields that are set are sent.
+type UpdateMerchantRequest struct {
+	Name         *string  `json:"name,omitempty"` // Cannot be cleared
+	Description  *string  `json:"description,omitempty"`
+	BusinessType *string  `json:"business_type,omitempty"`
+	Email        *string  `json:"email,omitempty"`
+	Phone        *string  `json:"phone,omitempty"`
+	Website      *string  `json:"website,omitempty"` // Must use https
+	Address      *Address `json:"address,omitempty"` // Replaces the whole address
+}
+
+// CreateMerchant onboards a new (sub-)merchant
+func (ms *MerchantService) CreateMerchant(ctx context.Context, req *MerchantRequest) (*MerchantInfo, error) {
+	// Validate the merchant request
+	if err := ValidateMerchantRequest(req); err != nil {
+		return nil, fmt.Errorf("validation failed: %w", err)
+	}
+
+	resp, err := ms.post(ctx, "/merchants", req)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read response: %w", err)
+		return nil, fmt.Errorf("failed to create merchant: %w", err)
 	}
 
 	var merchant MerchantInfo
-	if err := json.Unmarshal(body, &merchant); err != nil {
-		return nil, fmt.Errorf("failed to unmarshal response: %w", err)
+	meta, err := ms.decode(resp, &merchant)
+	if err != nil {
+		return nil, err
 	}
+	merchant.Meta = meta
 
 	return &merchant, nil
 }
 
+// UpdateMerchant updates the fields of a merchant set in req
+func (ms *MerchantService) UpdateMerchant(ctx context.Context, merchantID string, req *UpdateMerchantRequest) (*MerchantInfo, error) {
+	// Validate the fields being updated
+	if err := ValidateMerchantUpdate(req); err != nil {
+		return nil, fmt.Errorf("validation failed: %w", err)
+	}
+
+	resp, err := ms.put(ctx, fmt.Sprintf("/merchants/%s", merchantID), req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update merchant: %w", err)
+	}
+
+	var merchant MerchantInfo
+	meta, err := ms.decode(resp, &merchant)
+	if err != nil {
+		return nil, err
+	}
+	merchant.Meta = meta
+
+	return &merchant, nil
+}
+
+// DefaultCapabilitiesCacheTTL is how long merchant capabilities are cached
+// when the client has a Cache
+const DefaultCapabilitiesCacheTTL = 10 * time.Minute
+
+// MerchantCapabilities describes the features a merchant is provisioned for
+type MerchantCapabilities struct {
+	MerchantID               string        `json:"merchant_id"`
+	MultiCurrency            bool          `json:"multi_currency"`
+	IncrementalAuthorization bool          `json:"incremental_authorization"`
+	ThreeDSecure             bool          `json:"three_d_secure"`
+	NetworkTokens            bool          `json:"network_tokens"`
+	Subscriptions            bool          `json:"subscriptions"`
+	Wallets                  []WalletType  `json:"wallets,omitempty"`
+	SupportedCurrencies      []string      `json:"supported_currencies,omitempty"`
+	Surcharging              *bool         `json:"surcharging,omitempty"` // Whether the merchant may surcharge; nil when not reported
+	Meta                     *ResponseMeta `json:"-"`
+}
+
+// SupportsCurrency reports whether the merchant can transact in currency
+func (mc *MerchantCapabilities) SupportsCurrency(currency string) bool {
+	currency = NormalizeCurrency(currency)
+	for _, c := range mc.SupportedCurrencies {
+		if NormalizeCurrency(c) == currency {
+			return true
+		}
+	}
+	return false
+}
+
+// SupportsWallet reports whether the merchant accepts the given wallet
+func (mc *MerchantCapabilities) SupportsWallet(wallet WalletType) bool {
+	for _, w := range mc.Wallets {
+		if w == wallet {
+			return true
+		}
+	}
+	return false
+}
+
+// capabilitiesCacheKey returns the cache key for a merchant's capabilities
+func capabilitiesCacheKey(merchantID string) string {
+	return "capabilities:" + merchantID
+}
+
+// GetCapabilities retrieves the features a merchant is provisioned for.
+// When the client has a Cache, the result is cached for
+// Config.CapabilitiesCacheTTL.
+func (ms *MerchantService) GetCapabilities(ctx context.Context, merchantID string) (*MerchantCapabilities, error) {
+	cache, ttl := ms.client.cache, ms.client.capsTTL
+	if cache != nil && ttl > 0 {
+		if value, ok := cache.Get(capabilitiesCacheKey(merchantID)); ok {
+			if cached, ok := value.(*MerchantCapabilities); ok {
+				return cached.clone(), nil
+			}
+		}
+	}
+
+	resp, err := ms.get(ctx, fmt.Sprintf("/merchants/%s/capabilities", merchantID), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get merchant capabilities: %w", err)
+	}
+
+	var capabilities MerchantCapabilities
+	meta, err := ms.decode(resp, &capabilities)
+	if err != nil {
+		return nil, err
+	}
+	capabilities.Meta = meta
+
+	if cache != nil && ttl > 0 {
+		cache.Set(capabilitiesCacheKey(merchantID), capabilities.clone(), ttl)
+	}
+
+	return &capabilities, nil
+}
+
+// clone returns a copy that shares no slices with mc, so cached values
+// can't be changed through a returned one
+func (mc *MerchantCapabilities) clone() *MerchantCapabilities {
+	clone := *mc
+	clone.Wallets = append([]WalletType(nil), mc.Wallets...)
+	clone.SupportedCurrencies = append([]string(nil), mc.SupportedCurrencies...)
+	return &clone
+}
+
+// GetSupportedCurrencies retrieves the currencies the merchant is enabled
+// for, in upper case. Unlike SupportedCurrencies, which lists every currency
+// the SDK knows, it reflects what the gateway accepts for the merchant. It
+// reads the merchant capabilities, so the result is cached with them.
+func (ms *MerchantService) GetSupportedCurrencies(ctx context.Context, merchantID string) ([]string, error) {
+	capabilities, err := ms.GetCapabilities(ctx, merchantID)
+	if err != nil {
+		return nil, err
+	}
+
+	currencies := make([]string, len(capabilities.SupportedCurrencies))
+	for i, currency := range capabilities.SupportedCurrencies {
+		currencies[i] = NormalizeCurrency(currency)
+	}
+	return currencies, nil
+}
+
+// IsSupportedCurrency reports whether the merchant can transact in
+// currency, according to GetSupportedCurrencies. When the gateway's list is
+// unavailable or empty, it falls back to the static IsSupportedCurrency.
+func (ms *MerchantService) IsSupportedCurrency(ctx context.Context, merchantID, currency string) bool {
+	currencies, err := ms.GetSupportedCurrencies(ctx, merchantID)
+	if err != nil || len(currencies) == 0 {
+		return IsSupportedCurrency(currency)
+	}
+
+	currency = NormalizeCurrency(currency)
+	for _, c := range currencies {
+		if c == currency {
+			return true
+		}
+	}
+	return false
+}
+
 // TransactionSummary represents transaction summary data
 type TransactionSummary struct {
 	Date            string  `json:"date"`
@@ -80,20 +294,14 @@ func (ms *MerchantService) GetTransactionSummary(ctx context.Context, merchantID
 		urlValues.Add(k, v)
 	}
 
-	resp, err := ms.client.Get(ctx, fmt.Sprintf("/merchants/%s/transactions/summary", merchantID), urlValues)
+	resp, err := ms.get(ctx, fmt.Sprintf("/merchants/%s/transactions/summary", merchantID), urlValues)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get transaction summary: %w", err)
 	}
-	defer resp.Body.Close()
-
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read response: %w", err)
-	}
 
 	var summary []TransactionSummary
-	if err := json.Unmarshal(body, &summary); err != nil {
-		return nil, fmt.Errorf("failed to unmarshal response: %w", err)
+	if _, err := ms.decode(resp, &summary); err != nil {
+		return nil, err
 	}
 
 	return summary, nil
@@ -111,31 +319,221 @@ type SettlementInfo struct {
 	Reference   string    `json:"reference"`
 }
 
-// GetSettlements retrieves settlement information
-func (ms *MerchantService) GetSettlements(ctx context.Context, merchantID string, limit, offset int) ([]SettlementInfo, error) {
+// ListSettlementsRequest represents parameters for listing settlements
+type ListSettlementsRequest struct {
+	MerchantID string
+	StartDate  string // YYYY-MM-DD, inclusive
+	EndDate    string // YYYY-MM-DD, inclusive
+	Limit      int
+	Offset     int
+
+	// StartTime and EndTime are alternatives to StartDate and EndDate, sent
+	// as the date in UTC. A date string set as well takes precedence.
+	StartTime time.Time
+	EndTime   time.Time
+}
+
+// ListSettlementsResponse represents a page of settlements
+type ListSettlementsResponse struct {
+	Settlements []SettlementInfo `json:"settlements"`
+	Total       int              `json:"total"`
+	Limit       int              `json:"limit"`
+	Offset      int              `json:"offset"`
+	HasMore     bool             `json:"has_more"`
+	Meta        *ResponseMeta    `json:"-"`
+}
+
+// ListSettlements retrieves a page of settlements. The gateway may answer
+// with a bare list of settlements or a paged envelope; either way a page
+// with fewer than Limit settlements is treated as the end of the data.
+func (ms *MerchantService) ListSettlements(ctx context.Context, req *ListSettlementsRequest) (*ListSettlementsResponse, error) {
+	if req == nil {
+		req = &ListSettlementsRequest{}
+	}
+	merchant := merchantID(ctx, req.MerchantID)
+	if merchant == "" {
+		return nil, fmt.Errorf("merchant ID is required")
+	}
+
 	query := url.Values{}
-	if limit > 0 {
-		query.Add("limit", fmt.Sprintf("%d", limit))
+	ms.client.addDateRange(query, req.StartDate, req.StartTime, req.EndDate, req.EndTime)
+	if req.Limit > 0 {
+		query.Add("limit", fmt.Sprintf("%d", req.Limit))
 	}
-	if offset > 0 {
-		query.Add("offset", fmt.Sprintf("%d", offset))
+	if req.Offset > 0 {
+		query.Add("offset", fmt.Sprintf("%d", req.Offset))
 	}
 
-	resp, err := ms.client.Get(ctx, fmt.Sprintf("/merchants/%s/settlements", merchantID), query)
+	resp, err := ms.get(ctx, fmt.Sprintf("/merchants/%s/settlements", merchant), query)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get settlements: %w", err)
 	}
-	defer resp.Body.Close()
 
-	body, err := io.ReadAll(resp.Body)
+	var body json.RawMessage
+	meta, err := ms.decode(resp, &body)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read response: %w", err)
+		return nil, err
 	}
 
-	var settlements []SettlementInfo
-	if err := json.Unmarshal(body, &settlements); err != nil {
+	settlements := ListSettlementsResponse{Limit: req.Limit, Offset: req.Offset}
+	if trimmed := bytes.TrimSpace(body); len(trimmed) > 0 && trimmed[0] == '[' {
+		// A bare list carries no paging information; a full page may have more
+		if err := json.Unmarshal(body, &settlements.Settlements); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal response: %w", err)
+		}
+		settlements.HasMore = req.Limit > 0 && len(settlements.Settlements) == req.Limit
+	} else if err := json.Unmarshal(body, &settlements); err != nil {
 		return nil, fmt.Errorf("failed to unmarshal response: %w", err)
 	}
+	if req.Limit > 0 && len(settlements.Settlements) < req.Limit {
+		settlements.HasMore = false
+	}
+	settlements.Meta = meta
+
+	return &settlements, nil
+}
+
+// SettlementIterator iterates over every settlement of a merchant
+type SettlementIterator = Iterator[SettlementInfo]
+
+// IterateSettlements returns an iterator over every settlement of a
+// merchant, starting at req.Offset and fetching req.Limit settlements per
+// page
+func (ms *MerchantService) IterateSettlements(req *ListSettlementsRequest) *SettlementIterator {
+	filter := ListSettlementsRequest{}
+	if req != nil {
+		filter = *req
+	}
+
+	return newIterator(filter.Offset, ms.client.maxPages, func(ctx context.Context, offset int) (*page[SettlementInfo], error) {
+		filter.Offset = offset
+		settlements, err := ms.ListSettlements(ctx, &filter)
+		if err != nil {
+			return nil, err
+		}
+		return &page[SettlementInfo]{
+			items:      settlements.Settlements,
+			nextOffset: offset + len(settlements.Settlements),
+			hasMore:    settlements.HasMore,
+		}, nil
+	})
+}
+
+// GetSettlements retrieves settlement information. Use ListSettlements or
+// IterateSettlements to page through all settlements.
+func (ms *MerchantService) GetSettlements(ctx context.Context, merchantID string, limit, offset int) ([]SettlementInfo, error) {
+	settlements, err := ms.ListSettlements(ctx, &ListSettlementsRequest{
+		MerchantID: merchantID,
+		Limit:      limit,
+		Offset:     offset,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return settlements.Settlements, nil
+}
+
+// exportSettlementsPageSize is the number of settlements fetched per page
+// by ExportSettlements
+const exportSettlementsPageSize = 100
+
+// settlementCSVHeader is the column order of ExportSettlements
+var settlementCSVHeader = []string{"id", "merchant_id", "amount", "currency", "status", "reference", "settled_at", "created_at"}
+
+// ExportSettlements writes every settlement of a merchant between startDate
+// and endDate (YYYY-MM-DD, inclusive) to w as CSV, starting with a header
+// row. Settlements are fetched a page at a time and written as they arrive,
+// so large ranges are never held in memory. Amounts use the currency's
+// number of decimal places and times are RFC 3339 in UTC.
+func (ms *MerchantService) ExportSettlements(ctx context.Context, merchantID, startDate, endDate string, w io.Writer) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(settlementCSVHeader); err != nil {
+		return fmt.Errorf("failed to write settlements: %w", err)
+	}
 
-	return settlements, nil
-}
\ No newline at end of file
+	it := ms.IterateSettlements(&ListSettlementsRequest{
+		MerchantID: merchantID,
+		StartDate:  startDate,
+		EndDate:    endDate,
+		Limit:      exportSettlementsPageSize,
+	})
+	for it.Next(ctx) {
+		settlement := it.Item()
+		if err := cw.Write([]string{
+			settlement.ID,
+			settlement.MerchantID,
+			formatAmount(settlement.Amount, settlement.Currency),
+			settlement.Currency,
+			settlement.Status,
+			settlement.Reference,
+			formatCSVTime(settlement.SettledAt),
+			formatCSVTime(settlement.CreatedAt),
+		}); err != nil {
+			return fmt.Errorf("failed to write settlements: %w", err)
+		}
+	}
+	if err := it.Err(); err != nil {
+		return err
+	}
+
+	cw.Flush()
+	if err := cw.Error(); err != nil {
+		return fmt.Errorf("failed to write settlements: %w", err)
+	}
+	return nil
+}
+
+// formatCSVTime formats a time for CSV export, leaving unset times empty
+func formatCSVTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
+
+// StatementFormat is the document format a statement is downloaded in
+type StatementFormat string
+
+// Statement formats
+const (
+	StatementPDF StatementFormat = "application/pdf"
+	StatementCSV StatementFormat = "text/csv"
+)
+
+// ErrStatementNotFound is returned when the merchant has no statement for
+// the requested period
+var ErrStatementNotFound = errors.New("statement not found")
+
+// GetStatement downloads a merchant's monthly statement as a PDF. The
+// period is a month in YYYY-MM format. It returns the document and its
+// content type, or ErrStatementNotFound when there is no statement for the
+// period.
+func (ms *MerchantService) GetStatement(ctx context.Context, merchantID, period string) ([]byte, string, error) {
+	return ms.GetStatementAs(ctx, merchantID, period, StatementPDF)
+}
+
+// GetStatementAs downloads a merchant's monthly statement in the given
+// format. See GetStatement.
+func (ms *MerchantService) GetStatementAs(ctx context.Context, merchantID, period string, format StatementFormat) ([]byte, string, error) {
+	if merchantID == "" {
+		return nil, "", fmt.Errorf("validation failed: %w", validationError("merchant_id", ValidationCodeRequired, "merchant ID cannot be empty"))
+	}
+	if _, err := time.Parse("2006-01", period); err != nil {
+		return nil, "", fmt.Errorf("validation failed: %w", validationError("period", ValidationCodeInvalid, "period must be in YYYY-MM format"))
+	}
+	if format == "" {
+		format = StatementPDF
+	}
+
+	raw, err := ms.getRaw(ctx, fmt.Sprintf("/merchants/%s/statements/%s", url.PathEscape(merchantID), period), nil, string(format))
+	if err != nil {
+		var apiErr *APIError
+		if errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusNotFound {
+			return nil, "", fmt.Errorf("failed to get statement: %w: %w", ErrStatementNotFound, err)
+		}
+		return nil, "", fmt.Errorf("failed to get statement: %w", err)
+	}
+
+	return raw.Body, raw.ContentType, nil
+}
@@ -0,0 +1,40 @@
+package americanexpress
+
+import "time"
+
+// Logger receives diagnostic messages from the client as a message followed
+// by alternating key/value pairs. *slog.Logger satisfies this interface.
+// Implementations must be safe for concurrent use.
+type Logger interface {
+	Warn(msg string, args ...interface{})
+}
+
+// Observer is notified of the client's retry decisions, e.g. to export
+// metrics. Implementations must be safe for concurrent use.
+type Observer interface {
+	// OnRetry is called before a failed request is retried
+	OnRetry(event RetryEvent)
+}
+
+// RetryEvent describes a failed attempt that is about to be retried
+type RetryEvent struct {
+	Method string
+	Path   string
+	// Attempt is the number of the retry about to be made, starting at 1
+	Attempt int
+	// StatusCode is the HTTP status of the failed attempt, or zero when it
+	// failed without a response
+	StatusCode int
+	// Err is the error the failed attempt returned
+	Err error
+	// Delay is the backoff before the retry is sent
+	Delay time.Duration
+}
+
+// reportSlowRequest calls the slow request callback when a request that
+// started at start took longer than the configured threshold
+func (c *Client) reportSlowRequest(req *Request, start time.Time) {
+	if duration := c.now().Sub(start); duration > c.slowRequestThreshold {
+		c.slowRequestCallback(req.Method, req.Path, duration)
+	}
+}
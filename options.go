@@ -0,0 +1,98 @@
+package americanexpress
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// ErrReservedHeader is returned when a request option sets a header the SDK
+// manages itself
+var ErrReservedHeader = errors.New("header is reserved")
+
+// reservedHeaders are managed by the client and cannot be set with
+// WithHeader, keyed by canonical name
+var reservedHeaders = map[string]bool{
+	"Authorization":      true,
+	"X-Amex-Api-Key":     true,
+	"Content-Type":       true,
+	"Content-Length":     true,
+	"Host":               true,
+	SignatureHeader:      true, // Reserved for request signing
+	SignatureInputHeader: true,
+}
+
+// RequestOption customizes the requests made with a context; see
+// WithRequestOptions
+type RequestOption func(*requestOptions)
+
+// requestOptions holds the settings applied by request options
+type requestOptions struct {
+	headers map[string]string
+}
+
+// WithHeader sends an extra header, e.g. a partner routing token the SDK
+// does not model. It replaces a header of the same name set by the SDK,
+// except for the reserved authentication, content and signature headers,
+// which fail the request with ErrReservedHeader.
+func WithHeader(key, value string) RequestOption {
+	return func(o *requestOptions) {
+		o.headers[http.CanonicalHeaderKey(key)] = value
+	}
+}
+
+// WithLanguage sends an Accept-Language header, overriding Config.Language,
+// e.g. to localize decline messages for the cardholder at checkout
+func WithLanguage(language string) RequestOption {
+	return WithHeader("Accept-Language", language)
+}
+
+// requestOptionsKey is the context key under which request options are stored
+type requestOptionsKey struct{}
+
+// WithRequestOptions returns a copy of ctx that applies opts, after any
+// options ctx already carries, to every request made using the context:
+//
+//	ctx = amex.WithRequestOptions(ctx, amex.WithHeader("X-Partner-Route", "eu-1"))
+//	payment, err := sdk.Payments.CreatePayment(ctx, req)
+func WithRequestOptions(ctx context.Context, opts ...RequestOption) context.Context {
+	options := requestOptions{headers: map[string]string{}}
+	if parent, ok := ctx.Value(requestOptionsKey{}).(*requestOptions); ok {
+		for key, value := range parent.headers {
+			options.headers[key] = value
+		}
+	}
+	for _, opt := range opts {
+		opt(&options)
+	}
+	return context.WithValue(ctx, requestOptionsKey{}, &options)
+}
+
+// withRequestOptions returns the request with the headers of the options
+// carried by ctx merged in. The caller's header map is not modified.
+func withRequestOptions(ctx context.Context, req *Request) (*Request, error) {
+	options, ok := ctx.Value(requestOptionsKey{}).(*requestOptions)
+	if !ok || len(options.headers) == 0 {
+		return req, nil
+	}
+
+	withHeaders := *req
+	withHeaders.Headers = make(map[string]string, len(req.Headers)+len(options.headers))
+	for k, v := range req.Headers {
+		withHeaders.Headers[k] = v
+	}
+	for key, value := range options.headers {
+		if reservedHeaders[key] {
+			return nil, fmt.Errorf("invalid request option: %w: %s", ErrReservedHeader, key)
+		}
+		// Replace the SDK's header whatever the case of its name
+		for k := range withHeaders.Headers {
+			if http.CanonicalHeaderKey(k) == key {
+				delete(withHeaders.Headers, k)
+			}
+		}
+		withHeaders.Headers[key] = value
+	}
+	return &withHeaders, nil
+}
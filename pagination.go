@@ -0,0 +1,170 @@
+package americanexpress
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// DefaultMaxPages is the default number of pages an iterator fetches
+// before giving up
+const DefaultMaxPages = 10000
+
+var (
+	// ErrPaginationStalled is returned when the server reports more items
+	// but the next page would not start after the current one
+	ErrPaginationStalled = errors.New("pagination did not advance")
+	// ErrTooManyPages is returned when an iteration would exceed
+	// Config.MaxPages
+	ErrTooManyPages = errors.New("too many pages")
+)
+
+// PaginationError stops an iteration that looks like it would never end,
+// e.g. because the server always reports more items. Err is
+// ErrPaginationStalled or ErrTooManyPages.
+type PaginationError struct {
+	Offset int // Offset of the page that was not fetched
+	Pages  int // Pages fetched before stopping
+	Err    error
+}
+
+func (e *PaginationError) Error() string {
+	return fmt.Sprintf("pagination stopped at offset %d after %d pages: %v", e.Offset, e.Pages, e.Err)
+}
+
+// Unwrap returns the underlying error
+func (e *PaginationError) Unwrap() error {
+	return e.Err
+}
+
+// ContentRange is the range of items returned by a partial (206) list
+// response, parsed from a header such as "Content-Range: items 0-99/1234"
+type ContentRange struct {
+	Unit  string
+	Start int
+	End   int
+	// Total is the total number of items, or -1 when the server reports it
+	// as unknown ("*")
+	Total int
+}
+
+// HasMore reports whether items remain after this range
+func (r *ContentRange) HasMore() bool {
+	if r.Total < 0 {
+		return true
+	}
+	return r.End+1 < r.Total
+}
+
+// parseContentRange parses a Content-Range header of the form
+// "<unit> <start>-<end>/<total>"
+func parseContentRange(header string) (*ContentRange, error) {
+	unit, rest, ok := strings.Cut(strings.TrimSpace(header), " ")
+	if !ok {
+		return nil, fmt.Errorf("invalid content range %q", header)
+	}
+
+	var r ContentRange
+	r.Unit = unit
+	span, total, ok := strings.Cut(rest, "/")
+	if !ok {
+		return nil, fmt.Errorf("invalid content range %q", header)
+	}
+	if _, err := fmt.Sscanf(span, "%d-%d", &r.Start, &r.End); err != nil || r.End < r.Start {
+		return nil, fmt.Errorf("invalid content range %q", header)
+	}
+	if total == "*" {
+		r.Total = -1
+	} else if _, err := fmt.Sscanf(total, "%d", &r.Total); err != nil {
+		return nil, fmt.Errorf("invalid content range %q", header)
+	}
+	return &r, nil
+}
+
+// page is one page of list results along with where the next page starts
+type page[T any] struct {
+	items      []T
+	nextOffset int
+	hasMore    bool
+}
+
+// Iterator walks every item of a paged list endpoint, fetching further
+// pages as needed:
+//
+//	it := sdk.Transactions.IterateTransactions(req)
+//	for it.Next(ctx) {
+//		txn := it.Item()
+//	}
+//	if err := it.Err(); err != nil {
+//		// handle error
+//	}
+//
+// An iteration that would not end, because the server keeps reporting more
+// items without advancing or beyond Config.MaxPages, stops with a
+// *PaginationError.
+type Iterator[T any] struct {
+	fetch    func(ctx context.Context, offset int) (*page[T], error)
+	offset   int
+	items    []T
+	index    int
+	item     T
+	done     bool
+	err      error
+	pages    int
+	maxPages int // Zero or less for no limit
+}
+
+// newIterator creates an iterator that fetches at most maxPages pages
+// starting at offset
+func newIterator[T any](offset, maxPages int, fetch func(ctx context.Context, offset int) (*page[T], error)) *Iterator[T] {
+	return &Iterator[T]{fetch: fetch, offset: offset, maxPages: maxPages}
+}
+
+// Next advances to the next item, fetching the next page when the current
+// one is exhausted. It returns false when there are no more items or an
+// error occurred.
+func (it *Iterator[T]) Next(ctx context.Context) bool {
+	for it.index >= len(it.items) {
+		if it.done || it.err != nil {
+			return false
+		}
+		if it.maxPages > 0 && it.pages >= it.maxPages {
+			it.err = &PaginationError{Offset: it.offset, Pages: it.pages, Err: ErrTooManyPages}
+			return false
+		}
+
+		p, err := it.fetch(ctx, it.offset)
+		if err != nil {
+			it.err = err
+			return false
+		}
+		it.pages++
+		// A page that claims more items but does not move forward would be
+		// fetched again and again
+		if p.hasMore && len(p.items) > 0 && p.nextOffset <= it.offset {
+			it.err = &PaginationError{Offset: p.nextOffset, Pages: it.pages, Err: ErrPaginationStalled}
+			return false
+		}
+		it.items = p.items
+		it.index = 0
+		it.offset = p.nextOffset
+		// An empty page ends the iteration even if the server claims
+		// there is more
+		it.done = !p.hasMore || len(p.items) == 0
+	}
+
+	it.item = it.items[it.index]
+	it.index++
+	return true
+}
+
+// Item returns the current item
+func (it *Iterator[T]) Item() T {
+	return it.item
+}
+
+// Err returns the error that stopped the iteration, if any
+func (it *Iterator[T]) Err() error {
+	return it.err
+}
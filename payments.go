@@ -2,20 +2,20 @@ package americanexpress
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
-	"io"
+	"strings"
 	"time"
+	"unicode"
 )
 
 // PaymentService handles payment-related operations
 type PaymentService struct {
-	client *Client
+	service
 }
 
 // NewPaymentService creates a new payment service
 func NewPaymentService(client *Client) *PaymentService {
-	return &PaymentService{client: client}
+	return &PaymentService{service: newServiceAt(client, client.paymentBaseURL)}
 }
 
 // PaymentRequest represents a payment request
@@ -30,12 +30,23 @@ type PaymentRequest struct {
 	BillingAddr  *Address           `json:"billing_address,omitempty"`
 	ShippingAddr *Address           `json:"shipping_address,omitempty"`
 	Metadata     map[string]string  `json:"metadata,omitempty"`
+	NetworkToken *NetworkToken      `json:"network_token,omitempty"`
+	Splits       []PaymentSplit     `json:"splits,omitempty"` // Marketplace payouts to sub-merchants
+
+	// Tax and surcharge itemized in Amount, for merchants that must report
+	// them separately. When BaseAmount is set, Amount must equal BaseAmount
+	// plus TaxAmount plus SurchargeAmount.
+	BaseAmount      *float64 `json:"base_amount,omitempty"`
+	TaxAmount       *float64 `json:"tax_amount,omitempty"`
+	SurchargeAmount *float64 `json:"surcharge_amount,omitempty"`
+
+	decimalStringAmounts bool // Set from the client config; see MarshalJSON
 }
 
 // PaymentResponse represents a payment response
 type PaymentResponse struct {
 	ID                string            `json:"id"`
-	Status            string            `json:"status"`
+	Status            PaymentStatus     `json:"status"`
 	Amount            float64           `json:"amount"`
 	Currency          string            `json:"currency"`
 	Description       string            `json:"description"`
@@ -46,6 +57,9 @@ type PaymentResponse struct {
 	ProcessedAt       *time.Time        `json:"processed_at,omitempty"`
 	Metadata          map[string]string `json:"metadata,omitempty"`
 	FailureReason     string            `json:"failure_reason,omitempty"`
+	CVVResult         CVVResult         `json:"cvv_result,omitempty"`
+	AVSResult         AVSResult         `json:"avs_result,omitempty"`
+	Meta              *ResponseMeta     `json:"-"`
 }
 
 // CardDetails represents card information
@@ -57,6 +71,56 @@ type CardDetails struct {
 	HolderName  string `json:"holder_name"`
 }
 
+// NetworkToken represents a card network token (DPAN) and the cryptogram
+// generated for this payment. Network tokens are issued by the card network
+// and are distinct from the gateway tokens managed by TokenService.
+type NetworkToken struct {
+	Token       string `json:"token"`
+	Cryptogram  string `json:"cryptogram"`
+	ECI         string `json:"eci,omitempty"`
+	ExpiryMonth int    `json:"expiry_month"`
+	ExpiryYear  int    `json:"expiry_year"`
+}
+
+// WalletType identifies the digital wallet a payment came from
+type WalletType string
+
+const (
+	// WalletApplePay is an Apple Pay payment token
+	WalletApplePay WalletType = "applepay"
+	// WalletGooglePay is a Google Pay payment token
+	WalletGooglePay WalletType = "googlepay"
+)
+
+// WalletPayment carries the encrypted payment token a digital wallet hands
+// to the merchant. The SDK forwards the payload untouched; decryption
+// happens on the gateway, never in the SDK.
+type WalletPayment struct {
+	Type    WalletType `json:"type"`
+	Payload string     `json:"payload"` // Opaque encrypted token exactly as received from the wallet
+}
+
+// Normalize returns a copy of the card details with the number reduced to
+// its digits and the holder name trimmed and uppercased, as card networks
+// expect. The receiver is not modified.
+func (c *CardDetails) Normalize() *CardDetails {
+	if c == nil {
+		return nil
+	}
+
+	normalized := *c
+	normalized.Number = strings.Map(func(r rune) rune {
+		if r >= '0' && r <= '9' {
+			return r
+		}
+		return -1
+	}, c.Number)
+	normalized.CVV = strings.TrimSpace(c.CVV)
+	normalized.HolderName = strings.ToUpper(strings.Join(strings.FieldsFunc(c.HolderName, unicode.IsSpace), " "))
+
+	return &normalized
+}
+
 // Address represents billing or shipping address
 type Address struct {
 	Line1      string `json:"line1"`
@@ -64,52 +128,88 @@ type Address struct {
 	City       string `json:"city"`
 	State      string `json:"state"`
 	PostalCode string `json:"postal_code"`
-	Country    string `json:"country"`
+	Country    string `json:"country"` // ISO 3166-1 alpha-2, e.g. "US"
+}
+
+// Normalize returns a copy of the address with the country converted to an
+// ISO 3166-1 alpha-2 code where it can be mapped unambiguously, e.g. "USA"
+// or "United States" to "US". See NormalizeCountryCode. The receiver is not
+// modified.
+func (a *Address) Normalize() *Address {
+	if a == nil {
+		return nil
+	}
+
+	normalized := *a
+	normalized.Country = NormalizeCountryCode(a.Country)
+	return &normalized
+}
+
+// preparePaymentRequest returns the copy of a payment request that is sent
+// to the gateway, leaving the caller's request untouched
+func (ps *PaymentService) preparePaymentRequest(ctx context.Context, req *PaymentRequest) *PaymentRequest {
+	if req == nil {
+		return nil
+	}
+
+	prepared := *req
+	prepared.MerchantID = merchantID(ctx, req.MerchantID)
+	prepared.Currency = ps.client.currencyOrDefault(NormalizeCurrency(req.Currency))
+	prepared.decimalStringAmounts = ps.client.decimalStringAmounts
+	prepared.CardDetails = req.CardDetails.Normalize()
+	prepared.BillingAddr = req.BillingAddr.Normalize()
+	prepared.ShippingAddr = req.ShippingAddr.Normalize()
+	prepared.Metadata = mergeMetadata(ps.client.defaultMetadata, req.Metadata)
+	ps.client.warnTestCard(prepared.CardDetails)
+	return &prepared
 }
 
 // CreatePayment creates a new payment
 func (ps *PaymentService) CreatePayment(ctx context.Context, req *PaymentRequest) (*PaymentResponse, error) {
+	req = ps.preparePaymentRequest(ctx, req)
+
 	// Validate the payment request
 	if err := ValidatePaymentRequest(req); err != nil {
 		return nil, fmt.Errorf("validation failed: %w", err)
 	}
-
-	resp, err := ps.client.Post(ctx, "/payments", req)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create payment: %w", err)
+	if err := ps.client.validateTokenActive("card_token", req.CardToken); err != nil {
+		return nil, fmt.Errorf("validation failed: %w", err)
+	}
+	if err := ps.client.validateSurchargeAllowed(req.MerchantID, req.SurchargeAmount); err != nil {
+		return nil, fmt.Errorf("validation failed: %w", err)
+	}
+	if err := ps.validateMetadata(req.Metadata); err != nil {
+		return nil, err
 	}
-	defer resp.Body.Close()
 
-	body, err := io.ReadAll(resp.Body)
+	resp, err := ps.post(ctx, "/payments", req)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read response: %w", err)
+		return nil, fmt.Errorf("failed to create payment: %w", err)
 	}
 
 	var payment PaymentResponse
-	if err := json.Unmarshal(body, &payment); err != nil {
-		return nil, fmt.Errorf("failed to unmarshal response: %w", err)
+	meta, err := ps.decode(resp, &payment)
+	if err != nil {
+		return nil, err
 	}
+	payment.Meta = meta
 
 	return &payment, nil
 }
 
 // GetPayment retrieves a payment by ID
 func (ps *PaymentService) GetPayment(ctx context.Context, paymentID string) (*PaymentResponse, error) {
-	resp, err := ps.client.Get(ctx, fmt.Sprintf("/payments/%s", paymentID), nil)
+	resp, err := ps.get(ctx, fmt.Sprintf("/payments/%s", paymentID), nil)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get payment: %w", err)
 	}
-	defer resp.Body.Close()
-
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read response: %w", err)
-	}
 
 	var payment PaymentResponse
-	if err := json.Unmarshal(body, &payment); err != nil {
-		return nil, fmt.Errorf("failed to unmarshal response: %w", err)
+	meta, err := ps.decode(resp, &payment)
+	if err != nil {
+		return nil, err
 	}
+	payment.Meta = meta
 
 	return &payment, nil
 }
@@ -121,42 +221,39 @@ func (ps *PaymentService) CapturePayment(ctx context.Context, paymentID string,
 		captureReq["amount"] = *amount
 	}
 
-	resp, err := ps.client.Post(ctx, fmt.Sprintf("/payments/%s/capture", paymentID), captureReq)
+	resp, err := ps.post(ctx, fmt.Sprintf("/payments/%s/capture", paymentID), captureReq)
 	if err != nil {
 		return nil, fmt.Errorf("failed to capture payment: %w", err)
 	}
-	defer resp.Body.Close()
-
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read response: %w", err)
-	}
 
 	var payment PaymentResponse
-	if err := json.Unmarshal(body, &payment); err != nil {
-		return nil, fmt.Errorf("failed to unmarshal response: %w", err)
+	meta, err := ps.decode(resp, &payment)
+	if err != nil {
+		return nil, err
 	}
+	payment.Meta = meta
 
 	return &payment, nil
 }
 
-// VoidPayment voids an authorized payment
+// VoidPayment voids an authorized payment. When the gateway answers with an
+// empty body or a bare status, only the ID and Status of the result are set.
 func (ps *PaymentService) VoidPayment(ctx context.Context, paymentID string) (*PaymentResponse, error) {
-	resp, err := ps.client.Post(ctx, fmt.Sprintf("/payments/%s/void", paymentID), nil)
+	resp, err := ps.post(ctx, fmt.Sprintf("/payments/%s/void", paymentID), nil)
 	if err != nil {
 		return nil, fmt.Errorf("failed to void payment: %w", err)
 	}
-	defer resp.Body.Close()
 
-	body, err := io.ReadAll(resp.Body)
+	var payment PaymentResponse
+	meta, status, err := ps.decodeVoid(resp, &payment)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read response: %w", err)
+		return nil, err
 	}
-
-	var payment PaymentResponse
-	if err := json.Unmarshal(body, &payment); err != nil {
-		return nil, fmt.Errorf("failed to unmarshal response: %w", err)
+	if status != "" {
+		payment.ID = paymentID
+		payment.Status = PaymentStatus(status)
 	}
+	payment.Meta = meta
 
 	return &payment, nil
 }
@@ -171,34 +268,31 @@ type RefundRequest struct {
 
 // RefundResponse represents a refund response
 type RefundResponse struct {
-	ID          string    `json:"id"`
-	PaymentID   string    `json:"payment_id"`
-	Amount      float64   `json:"amount"`
-	Currency    string    `json:"currency"`
-	Status      string    `json:"status"`
-	Reason      string    `json:"reason"`
-	Reference   string    `json:"reference"`
-	CreatedAt   time.Time `json:"created_at"`
-	ProcessedAt time.Time `json:"processed_at"`
+	ID          string        `json:"id"`
+	PaymentID   string        `json:"payment_id"`
+	Amount      float64       `json:"amount"`
+	Currency    string        `json:"currency"`
+	Status      string        `json:"status"`
+	Reason      string        `json:"reason"`
+	Reference   string        `json:"reference"`
+	CreatedAt   time.Time     `json:"created_at"`
+	ProcessedAt time.Time     `json:"processed_at"`
+	Meta        *ResponseMeta `json:"-"`
 }
 
 // CreateRefund creates a refund for a payment
 func (ps *PaymentService) CreateRefund(ctx context.Context, req *RefundRequest) (*RefundResponse, error) {
-	resp, err := ps.client.Post(ctx, "/refunds", req)
+	resp, err := ps.post(ctx, "/refunds", req)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create refund: %w", err)
 	}
-	defer resp.Body.Close()
-
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read response: %w", err)
-	}
 
 	var refund RefundResponse
-	if err := json.Unmarshal(body, &refund); err != nil {
-		return nil, fmt.Errorf("failed to unmarshal response: %w", err)
+	meta, err := ps.decode(resp, &refund)
+	if err != nil {
+		return nil, err
 	}
+	refund.Meta = meta
 
 	return &refund, nil
 }
\ No newline at end of file
@@ -0,0 +1,62 @@
+package americanexpress
+
+import "fmt"
+
+// RefundReason is a standardized reason for refunding a transaction. The
+// free-text Reason of a refund request can add detail.
+type RefundReason string
+
+// Refund reasons
+const (
+	RefundReasonCustomerRequest    RefundReason = "customer_request"
+	RefundReasonDuplicate          RefundReason = "duplicate"
+	RefundReasonFraud              RefundReason = "fraud"
+	RefundReasonProductUnavailable RefundReason = "product_unavailable"
+	RefundReasonProductReturned    RefundReason = "product_returned"
+	RefundReasonServiceNotProvided RefundReason = "service_not_provided"
+	RefundReasonOther              RefundReason = "other"
+)
+
+// IsValid reports whether the reason is one of the known refund reasons
+func (r RefundReason) IsValid() bool {
+	switch r {
+	case RefundReasonCustomerRequest, RefundReasonDuplicate, RefundReasonFraud, RefundReasonProductUnavailable,
+		RefundReasonProductReturned, RefundReasonServiceNotProvided, RefundReasonOther:
+		return true
+	default:
+		return false
+	}
+}
+
+// VoidReason is a standardized reason for voiding a transaction. The
+// free-text Reason of a void request can add detail.
+type VoidReason string
+
+// Void reasons
+const (
+	VoidReasonCustomerRequest    VoidReason = "customer_request"
+	VoidReasonDuplicate          VoidReason = "duplicate"
+	VoidReasonFraud              VoidReason = "fraud"
+	VoidReasonProductUnavailable VoidReason = "product_unavailable"
+	VoidReasonOrderChanged       VoidReason = "order_changed"
+	VoidReasonOther              VoidReason = "other"
+)
+
+// IsValid reports whether the reason is one of the known void reasons
+func (r VoidReason) IsValid() bool {
+	switch r {
+	case VoidReasonCustomerRequest, VoidReasonDuplicate, VoidReasonFraud, VoidReasonProductUnavailable,
+		VoidReasonOrderChanged, VoidReasonOther:
+		return true
+	default:
+		return false
+	}
+}
+
+// validateReasonCode checks that a reason code, if given, is recognized
+func validateReasonCode(code string, valid bool) error {
+	if code != "" && !valid {
+		return validationError("reason_code", ValidationCodeUnsupported, fmt.Sprintf("unknown reason code %q", code))
+	}
+	return nil
+}
@@ -0,0 +1,69 @@
+package americanexpress
+
+import "fmt"
+
+// Region selects the regional gateway a merchant is boarded on
+type Region string
+
+// Regions
+const (
+	RegionNA    Region = "na"    // North America. It is the default.
+	RegionEU    Region = "eu"    // Europe
+	RegionAPAC  Region = "apac"  // Asia Pacific
+	RegionLATAM Region = "latam" // Latin America
+)
+
+// regionConfig holds the settings a region implies
+type regionConfig struct {
+	host     string // Production gateway host; the sandbox host adds a "sandbox-" prefix
+	currency string // Default currency of transactions and payments
+}
+
+// regions maps each region to its gateway. Adding a region takes one entry.
+var regions = map[Region]regionConfig{
+	RegionNA:    {host: "gateway-na.americanexpress.com", currency: "USD"},
+	RegionEU:    {host: "gateway-eu.americanexpress.com", currency: "EUR"},
+	RegionAPAC:  {host: "gateway-apac.americanexpress.com", currency: "AUD"},
+	RegionLATAM: {host: "gateway-latam.americanexpress.com", currency: "MXN"},
+}
+
+// IsValid reports whether the region is one of the known regions
+func (r Region) IsValid() bool {
+	_, ok := regions[r]
+	return ok
+}
+
+// config returns the settings of the region, falling back to North America
+// for an empty or unknown region
+func (r Region) config() regionConfig {
+	if rc, ok := regions[r]; ok {
+		return rc
+	}
+	return regions[RegionNA]
+}
+
+// baseURL returns the gateway base URL of the region in an environment
+func (r Region) baseURL(env Environment) string {
+	host := r.config().host
+	if env == Sandbox {
+		host = "sandbox-" + host
+	}
+	return "https://" + host + "/api"
+}
+
+// validateRegion checks that a configured region, if any, is known
+func validateRegion(region Region) error {
+	if region != "" && !region.IsValid() {
+		return fmt.Errorf("invalid config: unknown region %q", region)
+	}
+	return nil
+}
+
+// currencyOrDefault returns the currency, or the client's default currency
+// when it is empty
+func (c *Client) currencyOrDefault(currency string) string {
+	if currency == "" {
+		return c.defaultCurrency
+	}
+	return currency
+}
@@ -0,0 +1,198 @@
+package americanexpress
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"time"
+)
+
+const (
+	// DefaultRetryWaitMin is the default backoff before the first retry
+	DefaultRetryWaitMin = 500 * time.Millisecond
+	// DefaultRetryWaitMax is the default cap on the backoff between retries
+	DefaultRetryWaitMax = 5 * time.Second
+	// MaxRetriesLimit is the largest MaxRetries a RetryPolicy accepts
+	MaxRetriesLimit = 10
+)
+
+// RetryPolicy controls automatic retries of failed requests. The zero value
+// disables retries.
+//
+// Requests are retried on network errors and on API errors for which
+// APIError.IsRetryable reports true. Only idempotent methods are retried,
+// plus POST requests carrying an Idempotency-Key header, so a retry can't
+// charge a card twice. A Predicate replaces both rules.
+type RetryPolicy struct {
+	// MaxRetries is the number of retries after the first attempt
+	MaxRetries int
+	// RetryWaitMin is the backoff before the first retry. It doubles on
+	// every further retry. Defaults to DefaultRetryWaitMin.
+	RetryWaitMin time.Duration
+	// RetryWaitMax caps the backoff between retries. Defaults to
+	// DefaultRetryWaitMax.
+	RetryWaitMax time.Duration
+	// MaxElapsedTime caps the total time spent on a request, including
+	// backoffs. No retry is made once the next attempt would start after
+	// the budget; the last error is then returned wrapped in
+	// ErrRetryBudgetExhausted. Zero means no cap. A context deadline still
+	// applies, so whichever limit is tighter wins.
+	MaxElapsedTime time.Duration
+	// Predicate, if set, decides whether a failed attempt is retried
+	// instead of the default rules, e.g. to also retry a 400 the gateway
+	// uses for transient throttling. It is consulted for every method, so
+	// a predicate that retries a POST without an Idempotency-Key header can
+	// charge a card twice. Requests are still never retried once the
+	// context is done or when their body can't be sent again.
+	Predicate RetryPredicate
+}
+
+// RetryPredicate decides whether a failed attempt is retried. For API
+// errors, err is the *APIError (or a *DeclineError wrapping it) with the
+// parsed error code, and resp is the error response, its body readable
+// again. For network errors resp is nil.
+type RetryPredicate func(resp *http.Response, err error) bool
+
+// DefaultRetryPredicate implements the default retry rules for errors:
+// network errors and API errors for which APIError.IsRetryable reports
+// true. A custom RetryPredicate can fall back to it.
+func DefaultRetryPredicate(resp *http.Response, err error) bool {
+	// Invalid input, e.g. an upload found too large as it streams, fails
+	// the same way every time
+	var validationErr *ValidationError
+	if errors.As(err, &validationErr) {
+		return false
+	}
+
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.IsRetryable()
+	}
+
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
+
+// Validate checks the policy for misconfigurations. MaxRetries must be
+// between 0 and MaxRetriesLimit, the wait times and MaxElapsedTime must not
+// be negative, and RetryWaitMin must not exceed RetryWaitMax once the
+// defaults are applied.
+func (p RetryPolicy) Validate() error {
+	if p.MaxRetries < 0 || p.MaxRetries > MaxRetriesLimit {
+		return validationError("max_retries", ValidationCodeOutOfRange, fmt.Sprintf("max retries must be between 0 and %d", MaxRetriesLimit))
+	}
+	if p.RetryWaitMin < 0 {
+		return validationError("retry_wait_min", ValidationCodeOutOfRange, "retry wait min cannot be negative")
+	}
+	if p.RetryWaitMax < 0 {
+		return validationError("retry_wait_max", ValidationCodeOutOfRange, "retry wait max cannot be negative")
+	}
+	if p.MaxElapsedTime < 0 {
+		return validationError("max_elapsed_time", ValidationCodeOutOfRange, "max elapsed time cannot be negative")
+	}
+
+	p = p.withDefaults()
+	if p.RetryWaitMin > p.RetryWaitMax {
+		return validationError("retry_wait_min", ValidationCodeConflict, fmt.Sprintf("retry wait min %s exceeds retry wait max %s", p.RetryWaitMin, p.RetryWaitMax))
+	}
+	return nil
+}
+
+// withDefaults returns the policy with unset wait times filled in
+func (p RetryPolicy) withDefaults() RetryPolicy {
+	if p.RetryWaitMin == 0 {
+		p.RetryWaitMin = DefaultRetryWaitMin
+	}
+	if p.RetryWaitMax == 0 {
+		p.RetryWaitMax = DefaultRetryWaitMax
+	}
+	return p
+}
+
+// backoff returns the delay before the given retry, starting at 1
+func (p RetryPolicy) backoff(retry int) time.Duration {
+	delay := p.RetryWaitMin
+	for i := 1; i < retry && delay < p.RetryWaitMax; i++ {
+		delay *= 2
+	}
+	if delay > p.RetryWaitMax {
+		delay = p.RetryWaitMax
+	}
+	return delay
+}
+
+// shouldRetry reports whether a failed attempt at a request is retried,
+// according to the policy's Predicate or the default rules
+func (c *Client) shouldRetry(ctx context.Context, req *Request, err error) bool {
+	if ctx.Err() != nil || !isReplayable(req) {
+		return false
+	}
+	if c.retry.Predicate == nil {
+		return isRetryableRequest(req) && DefaultRetryPredicate(nil, err)
+	}
+
+	var resp *http.Response
+	var apiErr *APIError
+	if errors.As(err, &apiErr) && apiErr.response != nil {
+		resp = apiErr.response
+		resp.Body = io.NopCloser(bytes.NewReader(apiErr.body))
+	}
+	return c.retry.Predicate(resp, err)
+}
+
+// isReplayable reports whether the body of a request can be sent again. A
+// streamed body may not be readable twice.
+func isReplayable(req *Request) bool {
+	stream, ok := req.Body.(streamingBody)
+	return !ok || stream.replayable()
+}
+
+// isRetryableRequest reports whether a request can safely be sent again
+func isRetryableRequest(req *Request) bool {
+	if !isReplayable(req) {
+		return false
+	}
+
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodPut, http.MethodDelete, http.MethodOptions:
+		return true
+	case http.MethodPost:
+		return req.Headers[IdempotencyKeyHeader] != ""
+	default:
+		return false
+	}
+}
+
+// notifyRetry reports a retry decision to the configured logger and observer
+func (c *Client) notifyRetry(event RetryEvent) {
+	if c.logger != nil {
+		c.logger.Warn("retrying amex api request",
+			"method", event.Method,
+			"path", event.Path,
+			"attempt", event.Attempt,
+			"status", event.StatusCode,
+			"error", event.Err,
+			"delay", event.Delay,
+		)
+	}
+	if c.observer != nil {
+		c.observer.OnRetry(event)
+	}
+}
+
+// wait blocks for the backoff delay or until the context is done
+func wait(ctx context.Context, delay time.Duration) error {
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -1,24 +1,46 @@
 package americanexpress
 
-// SDK represents the main American Express SDK client with all services
+// SDK represents the main American Express SDK client with all services.
+// A single SDK is safe for concurrent use by multiple goroutines; see Client.
 type SDK struct {
 	*Client
-	Payments     *PaymentService
-	Tokens       *TokenService
-	Merchant     *MerchantService
-	Transactions *TransactionService
+	Payments      *PaymentService
+	Tokens        *TokenService
+	Merchant      *MerchantService
+	Transactions  *TransactionService
+	Disputes      *DisputeService
+	ThreeDS       *ThreeDSService
+	Subscriptions *SubscriptionService
+	Customers     *CustomerService
 }
 
 // NewSDK creates a new American Express SDK instance
 func NewSDK(config *Config) *SDK {
-	client := NewClient(config)
-	
+	return newSDK(NewClient(config))
+}
+
+// NewSDKWithError creates a new American Express SDK instance after checking
+// the configuration. See NewClientWithError.
+func NewSDKWithError(config *Config) (*SDK, error) {
+	client, err := NewClientWithError(config)
+	if err != nil {
+		return nil, err
+	}
+	return newSDK(client), nil
+}
+
+// newSDK wires every service to the client
+func newSDK(client *Client) *SDK {
 	return &SDK{
-		Client:       client,
-		Payments:     NewPaymentService(client),
-		Tokens:       NewTokenService(client),
-		Merchant:     NewMerchantService(client),
-		Transactions: NewTransactionService(client),
+		Client:        client,
+		Payments:      NewPaymentService(client),
+		Tokens:        NewTokenService(client),
+		Merchant:      NewMerchantService(client),
+		Transactions:  NewTransactionService(client),
+		Disputes:      NewDisputeService(client),
+		ThreeDS:       NewThreeDSService(client),
+		Subscriptions: NewSubscriptionService(client),
+		Customers:     NewCustomerService(client),
 	}
 }
 
@@ -0,0 +1,133 @@
+package americanexpress
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// service holds the state shared by all API services
+type service struct {
+	client     *Client
+	pathPrefix string
+	baseURL    string // Empty to use the client's base URL
+}
+
+// newService creates the shared service state, inheriting the client's
+// default path prefix
+func newService(client *Client) service {
+	return service{client: client, pathPrefix: client.pathPrefix}
+}
+
+// newServiceAt creates the shared service state for a service that may be
+// served from its own host. An empty base URL uses the client's.
+func newServiceAt(client *Client, baseURL string) service {
+	s := newService(client)
+	s.baseURL = baseURL
+	return s
+}
+
+// SetBaseURL points the service at another host, e.g. when tokens are
+// served separately from payments. An empty URL reverts to the client's
+// base URL. Like SetPathPrefix, it must be called before the service is
+// used.
+func (s *service) SetBaseURL(baseURL string) {
+	s.baseURL = strings.TrimSuffix(baseURL, "/")
+}
+
+// BaseURL returns the base URL the service sends requests to
+func (s *service) BaseURL() string {
+	if s.baseURL != "" {
+		return s.baseURL
+	}
+	return s.client.baseURL
+}
+
+// SetPathPrefix sets the prefix prepended to every path requested by the
+// service, e.g. "/v2". It must be called before the service is used, as it
+// is not safe to call concurrently with requests.
+func (s *service) SetPathPrefix(prefix string) {
+	s.pathPrefix = normalizePathPrefix(prefix)
+}
+
+// PathPrefix returns the prefix prepended to every path requested by the service
+func (s *service) PathPrefix() string {
+	return s.pathPrefix
+}
+
+// path prepends the service path prefix to an endpoint path
+func (s *service) path(p string) string {
+	return s.pathPrefix + p
+}
+
+// request builds a request to the service's host, relative to its path
+// prefix
+func (s *service) request(method, path string) *Request {
+	return &Request{Method: method, Path: s.path(path), BaseURL: s.baseURL}
+}
+
+// get performs a GET request relative to the service path prefix
+func (s *service) get(ctx context.Context, path string, query url.Values) (*http.Response, error) {
+	req := s.request(http.MethodGet, path)
+	req.Query = query
+	return s.client.doRequest(ctx, req)
+}
+
+// post performs a POST request relative to the service path prefix
+func (s *service) post(ctx context.Context, path string, body interface{}) (*http.Response, error) {
+	req := s.request(http.MethodPost, path)
+	req.Body = body
+	return s.client.doRequest(ctx, req)
+}
+
+// getRaw performs a GET request relative to the service path prefix that
+// asks for the given content type and returns the undecoded body
+func (s *service) getRaw(ctx context.Context, path string, query url.Values, accept string) (*RawResponse, error) {
+	req := s.request(http.MethodGet, path)
+	req.Query = query
+	req.Headers = map[string]string{"Accept": accept}
+	return s.client.doRaw(ctx, req)
+}
+
+// put performs a PUT request relative to the service path prefix
+func (s *service) put(ctx context.Context, path string, body interface{}) (*http.Response, error) {
+	req := s.request(http.MethodPut, path)
+	req.Body = body
+	return s.client.doRequest(ctx, req)
+}
+
+// delete performs a DELETE request relative to the service path prefix
+func (s *service) delete(ctx context.Context, path string) (*http.Response, error) {
+	return s.client.doRequest(ctx, s.request(http.MethodDelete, path))
+}
+
+// validateMetadata checks request metadata against the client's limits
+func (s *service) validateMetadata(metadata map[string]string) error {
+	if err := validateMetadata(metadata, s.client.metadataLimits); err != nil {
+		return fmt.Errorf("validation failed: %w", err)
+	}
+	return nil
+}
+
+// decode reads and unmarshals a response body into v
+func (s *service) decode(resp *http.Response, v interface{}) (*ResponseMeta, error) {
+	return s.client.decodeResponse(resp, v)
+}
+
+// decodeVoid reads the response of a void endpoint into v; see
+// Client.decodeVoidResponse
+func (s *service) decodeVoid(resp *http.Response, v interface{}) (*ResponseMeta, string, error) {
+	return s.client.decodeVoidResponse(resp, v)
+}
+
+// normalizePathPrefix ensures a prefix starts with a slash and has no
+// trailing slash, so it can be joined directly with endpoint paths
+func normalizePathPrefix(prefix string) string {
+	prefix = strings.Trim(prefix, "/")
+	if prefix == "" {
+		return ""
+	}
+	return "/" + prefix
+}
@@ -0,0 +1,72 @@
+package americanexpress
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// PaymentSplit assigns part of a marketplace charge to a sub-merchant
+type PaymentSplit struct {
+	MerchantID string   `json:"merchant_id"`   // Destination sub-merchant
+	Amount     float64  `json:"amount"`        // Paid out to the destination
+	Fee        *float64 `json:"fee,omitempty"` // Platform fee withheld from this split
+}
+
+// validateSplits checks that every split has a destination and a positive
+// amount, and that the amounts plus the platform fees add up to the total
+func validateSplits(splits []PaymentSplit, total float64, currency string) error {
+	var sum float64
+	for i, split := range splits {
+		field := fmt.Sprintf("splits[%d]", i)
+		if strings.TrimSpace(split.MerchantID) == "" {
+			return validationError(field+".merchant_id", ValidationCodeRequired, fmt.Sprintf("split %d: destination merchant ID cannot be empty", i))
+		}
+		if split.Amount <= 0 {
+			return sentinelError(field+".amount", ValidationCodeOutOfRange, ErrInvalidAmount, fmt.Sprintf("split %d: amount must be positive", i))
+		}
+		if err := ValidateAmountPrecision(split.Amount, currency); err != nil {
+			return nestValidationError(field, fmt.Sprintf("split %d", i), err)
+		}
+		sum += split.Amount
+
+		if split.Fee != nil {
+			if *split.Fee < 0 {
+				return sentinelError(field+".fee", ValidationCodeOutOfRange, ErrInvalidAmount, fmt.Sprintf("split %d: fee cannot be negative", i))
+			}
+			if ValidateAmountPrecision(*split.Fee, currency) != nil {
+				return sentinelError(field+".fee", ValidationCodeTooManyDecimals, ErrInvalidAmount, fmt.Sprintf("split %d: fee allows at most %d decimal places", i, CurrencyExponent(currency)))
+			}
+			sum += *split.Fee
+		}
+	}
+
+	tolerance := 0.5 * math.Pow10(-CurrencyExponent(currency))
+	if math.Abs(total-sum) >= tolerance {
+		return validationError("splits", ValidationCodeConflict, "split amounts plus fees must equal the total amount")
+	}
+	return nil
+}
+
+// decimalSplit is a PaymentSplit with its amounts as decimal strings
+type decimalSplit struct {
+	MerchantID string  `json:"merchant_id"`
+	Amount     string  `json:"amount"`
+	Fee        *string `json:"fee,omitempty"`
+}
+
+// formatSplits formats the amounts of splits as decimal strings
+func formatSplits(splits []PaymentSplit, currency string) []decimalSplit {
+	if splits == nil {
+		return nil
+	}
+	formatted := make([]decimalSplit, len(splits))
+	for i, split := range splits {
+		formatted[i] = decimalSplit{
+			MerchantID: split.MerchantID,
+			Amount:     formatAmount(split.Amount, currency),
+			Fee:        formatOptionalAmount(split.Fee, currency),
+		}
+	}
+	return formatted
+}
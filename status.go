@@ -0,0 +1,115 @@
+package americanexpress
+
+// TransactionStatus is the state of a transaction. Statuses the SDK does not
+// know yet decode unchanged, so comparisons against the constants below
+// simply don't match them.
+type TransactionStatus string
+
+// Transaction statuses
+const (
+	TransactionStatusPending           TransactionStatus = "pending"
+	TransactionStatusAuthorized        TransactionStatus = "authorized"
+	TransactionStatusCaptured          TransactionStatus = "captured"
+	TransactionStatusSettled           TransactionStatus = "settled"
+	TransactionStatusPartiallyRefunded TransactionStatus = "partially_refunded"
+	TransactionStatusRefunded          TransactionStatus = "refunded"
+	TransactionStatusVoided            TransactionStatus = "voided"
+	TransactionStatusReversed          TransactionStatus = "reversed"
+	TransactionStatusDeclined          TransactionStatus = "declined"
+	TransactionStatusFailed            TransactionStatus = "failed"
+)
+
+// String returns the wire value of the status
+func (s TransactionStatus) String() string {
+	return string(s)
+}
+
+// IsValid reports whether the status is one of the statuses above
+func (s TransactionStatus) IsValid() bool {
+	switch s {
+	case TransactionStatusPending, TransactionStatusAuthorized, TransactionStatusCaptured, TransactionStatusSettled,
+		TransactionStatusPartiallyRefunded, TransactionStatusRefunded, TransactionStatusVoided, TransactionStatusReversed,
+		TransactionStatusDeclined, TransactionStatusFailed:
+		return true
+	default:
+		return false
+	}
+}
+
+// IsTerminal reports whether the transaction can no longer change state
+func (s TransactionStatus) IsTerminal() bool {
+	switch s {
+	case TransactionStatusRefunded, TransactionStatusVoided, TransactionStatusReversed,
+		TransactionStatusDeclined, TransactionStatusFailed:
+		return true
+	default:
+		return false
+	}
+}
+
+// IsSuccess reports whether the transaction was approved and its funds are
+// held or moved
+func (s TransactionStatus) IsSuccess() bool {
+	switch s {
+	case TransactionStatusAuthorized, TransactionStatusCaptured, TransactionStatusSettled:
+		return true
+	default:
+		return false
+	}
+}
+
+// PaymentStatus is the state of a payment. Statuses the SDK does not know
+// yet decode unchanged, so comparisons against the constants below simply
+// don't match them.
+type PaymentStatus string
+
+// Payment statuses
+const (
+	PaymentStatusPending    PaymentStatus = "pending"
+	PaymentStatusAuthorized PaymentStatus = "authorized"
+	PaymentStatusCaptured   PaymentStatus = "captured"
+	PaymentStatusRefunded   PaymentStatus = "refunded"
+	PaymentStatusVoided     PaymentStatus = "voided"
+	PaymentStatusDeclined   PaymentStatus = "declined"
+	PaymentStatusFailed     PaymentStatus = "failed"
+)
+
+// String returns the wire value of the status
+func (s PaymentStatus) String() string {
+	return string(s)
+}
+
+// IsTerminal reports whether the payment can no longer change state
+func (s PaymentStatus) IsTerminal() bool {
+	switch s {
+	case PaymentStatusRefunded, PaymentStatusVoided, PaymentStatusDeclined, PaymentStatusFailed:
+		return true
+	default:
+		return false
+	}
+}
+
+// IsSuccess reports whether the payment was approved and its funds are held
+// or moved
+func (s PaymentStatus) IsSuccess() bool {
+	switch s {
+	case PaymentStatusAuthorized, PaymentStatusCaptured:
+		return true
+	default:
+		return false
+	}
+}
+
+// TokenStatus is the state of a stored token
+type TokenStatus string
+
+// Token statuses
+const (
+	TokenStatusActive   TokenStatus = "active"
+	TokenStatusDisabled TokenStatus = "disabled" // Kept for audit but can't be charged
+)
+
+// String returns the wire value of the status
+func (s TokenStatus) String() string {
+	return string(s)
+}
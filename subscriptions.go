@@ -0,0 +1,186 @@
+package americanexpress
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// SubscriptionService handles recurring billing schedules run by the gateway
+type SubscriptionService struct {
+	service
+	tokens *TokenService
+}
+
+// NewSubscriptionService creates a new subscription service
+func NewSubscriptionService(client *Client) *SubscriptionService {
+	return &SubscriptionService{service: newService(client), tokens: NewTokenService(client)}
+}
+
+// SubscriptionInterval is the unit of a subscription's billing cycle
+type SubscriptionInterval string
+
+// Subscription billing intervals
+const (
+	IntervalDay   SubscriptionInterval = "day"
+	IntervalWeek  SubscriptionInterval = "week"
+	IntervalMonth SubscriptionInterval = "month"
+	IntervalYear  SubscriptionInterval = "year"
+)
+
+// SubscriptionRequest represents a request to create a subscription
+type SubscriptionRequest struct {
+	MerchantID    string               `json:"merchant_id"`
+	CustomerID    string               `json:"customer_id,omitempty"`
+	TokenID       string               `json:"token_id"` // Must be a multi-use token
+	Amount        float64              `json:"amount"`
+	Currency      string               `json:"currency"`
+	Interval      SubscriptionInterval `json:"interval"`
+	IntervalCount int                  `json:"interval_count,omitempty"` // Cycles per charge, e.g. 3 months; defaults to 1
+	StartDate     string               `json:"start_date"`               // YYYY-MM-DD
+	Description   string               `json:"description,omitempty"`
+	Reference     string               `json:"reference,omitempty"`
+	Metadata      map[string]string    `json:"metadata,omitempty"`
+}
+
+// UpdateSubscriptionRequest represents a change to a subscription. Fields
+// left empty are not changed.
+type UpdateSubscriptionRequest struct {
+	TokenID       string               `json:"token_id,omitempty"`
+	Amount        *float64             `json:"amount,omitempty"`
+	Interval      SubscriptionInterval `json:"interval,omitempty"`
+	IntervalCount int                  `json:"interval_count,omitempty"`
+	Description   string               `json:"description,omitempty"`
+	Metadata      map[string]string    `json:"metadata,omitempty"`
+}
+
+// Subscription represents a recurring billing schedule
+type Subscription struct {
+	ID              string               `json:"id"`
+	Status          string               `json:"status"` // "active", "past_due", "canceled"
+	MerchantID      string               `json:"merchant_id"`
+	CustomerID      string               `json:"customer_id"`
+	TokenID         string               `json:"token_id"`
+	Amount          float64              `json:"amount"`
+	Currency        string               `json:"currency"`
+	Interval        SubscriptionInterval `json:"interval"`
+	IntervalCount   int                  `json:"interval_count"`
+	StartDate       string               `json:"start_date"`
+	NextBillingDate string               `json:"next_billing_date,omitempty"`
+	Description     string               `json:"description"`
+	Reference       string               `json:"reference"`
+	Metadata        map[string]string    `json:"metadata,omitempty"`
+	CreatedAt       time.Time            `json:"created_at"`
+	CanceledAt      *time.Time           `json:"canceled_at,omitempty"`
+	Meta            *ResponseMeta        `json:"-"`
+}
+
+// requireMultiUseToken checks that a token can be charged repeatedly
+func (ss *SubscriptionService) requireMultiUseToken(ctx context.Context, tokenID string) error {
+	token, err := ss.tokens.GetToken(ctx, tokenID)
+	if err != nil {
+		return err
+	}
+	if token.SingleUse {
+		return fmt.Errorf("validation failed: %w", validationError("token_id", ValidationCodeInvalid, "subscriptions require a multi-use token"))
+	}
+	if !token.IsActive() {
+		return fmt.Errorf("validation failed: %w", sentinelError("token_id", ValidationCodeInvalid, ErrTokenDisabled, fmt.Sprintf("token %s is disabled", tokenID)))
+	}
+	return nil
+}
+
+// CreateSubscription creates a recurring billing schedule. The token is
+// fetched first to check that it is not single-use.
+func (ss *SubscriptionService) CreateSubscription(ctx context.Context, req *SubscriptionRequest) (*Subscription, error) {
+	// Validate the subscription request
+	if err := ValidateSubscriptionRequest(req); err != nil {
+		return nil, fmt.Errorf("validation failed: %w", err)
+	}
+	if err := ss.validateMetadata(req.Metadata); err != nil {
+		return nil, err
+	}
+	if err := ss.requireMultiUseToken(ctx, req.TokenID); err != nil {
+		return nil, err
+	}
+
+	resp, err := ss.post(ctx, "/subscriptions", req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create subscription: %w", err)
+	}
+
+	var subscription Subscription
+	meta, err := ss.decode(resp, &subscription)
+	if err != nil {
+		return nil, err
+	}
+	subscription.Meta = meta
+
+	return &subscription, nil
+}
+
+// GetSubscription retrieves a subscription by ID
+func (ss *SubscriptionService) GetSubscription(ctx context.Context, subscriptionID string) (*Subscription, error) {
+	resp, err := ss.get(ctx, fmt.Sprintf("/subscriptions/%s", subscriptionID), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get subscription: %w", err)
+	}
+
+	var subscription Subscription
+	meta, err := ss.decode(resp, &subscription)
+	if err != nil {
+		return nil, err
+	}
+	subscription.Meta = meta
+
+	return &subscription, nil
+}
+
+// UpdateSubscription changes the amount, schedule or token of a
+// subscription. A new token is fetched first to check that it is not
+// single-use.
+func (ss *SubscriptionService) UpdateSubscription(ctx context.Context, subscriptionID string, req *UpdateSubscriptionRequest) (*Subscription, error) {
+	// Validate the fields being updated
+	if err := ValidateUpdateSubscriptionRequest(req); err != nil {
+		return nil, fmt.Errorf("validation failed: %w", err)
+	}
+	if err := ss.validateMetadata(req.Metadata); err != nil {
+		return nil, err
+	}
+	if req.TokenID != "" {
+		if err := ss.requireMultiUseToken(ctx, req.TokenID); err != nil {
+			return nil, err
+		}
+	}
+
+	resp, err := ss.put(ctx, fmt.Sprintf("/subscriptions/%s", subscriptionID), req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update subscription: %w", err)
+	}
+
+	var subscription Subscription
+	meta, err := ss.decode(resp, &subscription)
+	if err != nil {
+		return nil, err
+	}
+	subscription.Meta = meta
+
+	return &subscription, nil
+}
+
+// CancelSubscription stops all future charges of a subscription
+func (ss *SubscriptionService) CancelSubscription(ctx context.Context, subscriptionID string) (*Subscription, error) {
+	resp, err := ss.post(ctx, fmt.Sprintf("/subscriptions/%s/cancel", subscriptionID), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to cancel subscription: %w", err)
+	}
+
+	var subscription Subscription
+	meta, err := ss.decode(resp, &subscription)
+	if err != nil {
+		return nil, err
+	}
+	subscription.Meta = meta
+
+	return &subscription, nil
+}
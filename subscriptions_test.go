@@ -0,0 +1,117 @@
+package americanexpress
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestValidateSubscriptionRequest(t *testing.T) {
+	valid := func() *SubscriptionRequest {
+		return &SubscriptionRequest{
+			MerchantID: "merchant_123",
+			TokenID:    "token_123",
+			Amount:     9.99,
+			Currency:   "USD",
+			Interval:   IntervalMonth,
+			StartDate:  "2026-11-01",
+		}
+	}
+
+	tests := []struct {
+		name    string
+		modify  func(req *SubscriptionRequest)
+		wantErr bool
+	}{
+		{"valid request", func(req *SubscriptionRequest) {}, false},
+		{"quarterly", func(req *SubscriptionRequest) { req.IntervalCount = 3 }, false},
+		{"zero amount", func(req *SubscriptionRequest) { req.Amount = 0 }, true},
+		{"missing token", func(req *SubscriptionRequest) { req.TokenID = "" }, true},
+		{"unsupported interval", func(req *SubscriptionRequest) { req.Interval = "fortnight" }, true},
+		{"missing interval", func(req *SubscriptionRequest) { req.Interval = "" }, true},
+		{"negative interval count", func(req *SubscriptionRequest) { req.IntervalCount = -1 }, true},
+		{"invalid start date", func(req *SubscriptionRequest) { req.StartDate = "11/01/2026" }, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := valid()
+			tt.modify(req)
+			err := ValidateSubscriptionRequest(req)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateSubscriptionRequest() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestSubscriptionService(t *testing.T) {
+	var requests []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.Method+" "+r.URL.Path)
+		switch r.URL.Path {
+		case "/tokens/token_multi":
+			fmt.Fprint(w, `{"id":"token_multi","single_use":false}`)
+		case "/tokens/token_single":
+			fmt.Fprint(w, `{"id":"token_single","single_use":true}`)
+		case "/subscriptions/sub_123/cancel":
+			fmt.Fprint(w, `{"id":"sub_123","status":"canceled"}`)
+		default:
+			fmt.Fprint(w, `{"id":"sub_123","status":"active","interval":"month"}`)
+		}
+	}))
+	defer server.Close()
+
+	sdk := NewSDK(&Config{BaseURL: server.URL})
+	ctx := context.Background()
+	req := &SubscriptionRequest{
+		MerchantID: "merchant_123",
+		TokenID:    "token_single",
+		Amount:     9.99,
+		Currency:   "USD",
+		Interval:   IntervalMonth,
+		StartDate:  "2026-11-01",
+	}
+
+	_, err := sdk.Subscriptions.CreateSubscription(ctx, req)
+	var ve *ValidationError
+	if !errors.As(err, &ve) || ve.Field != "token_id" {
+		t.Errorf("Expected a token_id validation error for a single-use token, got %v", err)
+	}
+
+	req.TokenID = "token_multi"
+	subscription, err := sdk.Subscriptions.CreateSubscription(ctx, req)
+	if err != nil {
+		t.Fatalf("CreateSubscription() error = %v", err)
+	}
+	if subscription.ID != "sub_123" || subscription.Interval != IntervalMonth {
+		t.Errorf("Unexpected subscription %+v", subscription)
+	}
+
+	amount := 19.99
+	if _, err := sdk.Subscriptions.UpdateSubscription(ctx, "sub_123", &UpdateSubscriptionRequest{Amount: &amount}); err != nil {
+		t.Fatalf("UpdateSubscription() error = %v", err)
+	}
+
+	canceled, err := sdk.Subscriptions.CancelSubscription(ctx, "sub_123")
+	if err != nil {
+		t.Fatalf("CancelSubscription() error = %v", err)
+	}
+	if canceled.Status != "canceled" {
+		t.Errorf("Expected status 'canceled', got '%s'", canceled.Status)
+	}
+
+	expected := []string{
+		"GET /tokens/token_single",
+		"GET /tokens/token_multi",
+		"POST /subscriptions",
+		"PUT /subscriptions/sub_123",
+		"POST /subscriptions/sub_123/cancel",
+	}
+	if fmt.Sprint(requests) != fmt.Sprint(expected) {
+		t.Errorf("Expected requests %v, got %v", expected, requests)
+	}
+}
@@ -0,0 +1,75 @@
+package americanexpress
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+)
+
+// ErrSurchargeNotAllowed is returned when a request carries a surcharge
+// the merchant is not permitted to add, e.g. in a region that bans
+// surcharging
+var ErrSurchargeNotAllowed = errors.New("surcharge not allowed")
+
+// validateAmountBreakdown checks the tax and surcharge itemized in a total
+// amount: neither may be negative, and when the base amount is given, base
+// plus tax plus surcharge must equal the total within half a minor unit of
+// the currency. Without a base amount, tax and surcharge must leave a
+// positive base.
+func validateAmountBreakdown(amount float64, base, tax, surcharge *float64, currency string) error {
+	parts := []struct {
+		field string
+		value *float64
+	}{
+		{"tax_amount", tax},
+		{"surcharge_amount", surcharge},
+	}
+	var sum float64
+	for _, part := range parts {
+		if part.value == nil {
+			continue
+		}
+		name := strings.ReplaceAll(part.field, "_", " ")
+		if *part.value < 0 {
+			return sentinelError(part.field, ValidationCodeOutOfRange, ErrInvalidAmount, name+" cannot be negative")
+		}
+		if ValidateAmountPrecision(*part.value, currency) != nil {
+			return sentinelError(part.field, ValidationCodeTooManyDecimals, ErrInvalidAmount, fmt.Sprintf("%s allows at most %d decimal places", name, CurrencyExponent(currency)))
+		}
+		sum += *part.value
+	}
+
+	tolerance := 0.5 * math.Pow10(-CurrencyExponent(currency))
+	if base == nil {
+		if (tax != nil || surcharge != nil) && amount-sum < tolerance {
+			return validationError("amount", ValidationCodeConflict, "amount must exceed the tax plus the surcharge")
+		}
+		return nil
+	}
+
+	if *base <= 0 {
+		return sentinelError("base_amount", ValidationCodeOutOfRange, ErrInvalidAmount, "")
+	}
+	if math.Abs(amount-(*base+sum)) >= tolerance {
+		return validationError("amount", ValidationCodeConflict, "amount must equal the base amount plus the tax and surcharge")
+	}
+	return nil
+}
+
+// validateSurchargeAllowed rejects a surcharge when the merchant's cached
+// capabilities say it may not surcharge. Capabilities are not fetched for
+// the check, so it only applies when they are cached and report it.
+func (c *Client) validateSurchargeAllowed(merchantID string, surcharge *float64) error {
+	if surcharge == nil || *surcharge == 0 || c.cache == nil || c.capsTTL <= 0 {
+		return nil
+	}
+	value, ok := c.cache.Get(capabilitiesCacheKey(merchantID))
+	if !ok {
+		return nil
+	}
+	if capabilities, ok := value.(*MerchantCapabilities); ok && capabilities.Surcharging != nil && !*capabilities.Surcharging {
+		return sentinelError("surcharge_amount", ValidationCodeUnsupported, ErrSurchargeNotAllowed, fmt.Sprintf("merchant %s may not surcharge", merchantID))
+	}
+	return nil
+}
@@ -0,0 +1,70 @@
+package americanexpress
+
+// Sandbox test card scenarios
+const (
+	TestCardApproved          = "approved"
+	TestCardDeclined          = "declined"
+	TestCardInsufficientFunds = "insufficient_funds"
+	TestCardExpired           = "expired_card"
+	TestCardLostOrStolen      = "lost_or_stolen"
+	TestCardProcessingError   = "processing_error"
+	TestCardChallenge         = "three_d_secure_challenge"
+)
+
+// testCards maps the card numbers that trigger a specific response in the
+// sandbox to their scenario. It is read on request paths, so it must not be
+// modified; TestCardScenarios hands out copies.
+var testCards = map[string]string{
+	"378282246310005": TestCardApproved,
+	"371449635398431": TestCardApproved,
+	"378734493671000": TestCardApproved,
+	"370000000000002": TestCardDeclined,
+	"340000000000009": TestCardInsufficientFunds,
+	"341111111111111": TestCardExpired,
+	"371111111111114": TestCardLostOrStolen,
+	"374245455400126": TestCardProcessingError,
+	"376449047333005": TestCardChallenge,
+}
+
+// TestCardScenarios returns the card numbers that trigger a specific
+// response in the sandbox, mapped to their scenario. Any other valid number
+// is approved. The map is a copy and can be modified freely.
+func TestCardScenarios() map[string]string {
+	scenarios := make(map[string]string, len(testCards))
+	for number, scenario := range testCards {
+		scenarios[number] = scenario
+	}
+	return scenarios
+}
+
+// IsTestCard reports whether number is a sandbox test card and the scenario
+// it triggers. Spaces and dashes in number are ignored.
+func IsTestCard(number string) (scenario string, ok bool) {
+	scenario, ok = testCards[(&CardDetails{Number: number}).Normalize().Number]
+	return scenario, ok
+}
+
+// isProductionBaseURL reports whether a base URL is the production gateway
+// of one of the regions
+func isProductionBaseURL(baseURL string) bool {
+	for region := range regions {
+		if baseURL == region.baseURL(Production) {
+			return true
+		}
+	}
+	return false
+}
+
+// warnTestCard logs a warning when a sandbox test card is sent to the
+// production gateway, where it is declined like any invalid card
+func (c *Client) warnTestCard(card *CardDetails) {
+	if card == nil || c.logger == nil || !isProductionBaseURL(c.baseURL) {
+		return
+	}
+	if scenario, ok := IsTestCard(card.Number); ok {
+		c.logger.Warn("amex sandbox test card used against the production gateway",
+			"scenario", scenario,
+			"base_url", c.baseURL,
+		)
+	}
+}
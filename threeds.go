@@ -0,0 +1,150 @@
+package americanexpress
+
+import (
+	"context"
+	"fmt"
+)
+
+// ThreeDSService handles American Express SafeKey (3-D Secure 2) operations
+type ThreeDSService struct {
+	service
+}
+
+// NewThreeDSService creates a new 3-D Secure service
+func NewThreeDSService(client *Client) *ThreeDSService {
+	return &ThreeDSService{service: newService(client)}
+}
+
+// BrowserInfo describes the cardholder's browser for risk-based authentication
+type BrowserInfo struct {
+	AcceptHeader   string `json:"accept_header,omitempty"`
+	UserAgent      string `json:"user_agent,omitempty"`
+	Language       string `json:"language,omitempty"`
+	IPAddress      string `json:"ip_address,omitempty"`
+	ColorDepth     int    `json:"color_depth,omitempty"`
+	ScreenHeight   int    `json:"screen_height,omitempty"`
+	ScreenWidth    int    `json:"screen_width,omitempty"`
+	TimeZoneOffset int    `json:"time_zone_offset,omitempty"`
+	JavaEnabled    bool   `json:"java_enabled,omitempty"`
+}
+
+// DeviceInfo describes the cardholder's device for in-app authentication
+type DeviceInfo struct {
+	Channel    string `json:"channel"` // "browser", "app"
+	DeviceID   string `json:"device_id,omitempty"`
+	SDKAppID   string `json:"sdk_app_id,omitempty"`
+	SDKVersion string `json:"sdk_version,omitempty"`
+}
+
+// EnrollmentRequest represents a SafeKey enrollment check request
+type EnrollmentRequest struct {
+	Amount      float64      `json:"amount"`
+	Currency    string       `json:"currency"`
+	MerchantID  string       `json:"merchant_id"`
+	CardToken   string       `json:"card_token,omitempty"`
+	CardDetails *CardDetails `json:"card_details,omitempty"`
+	Browser     *BrowserInfo `json:"browser,omitempty"`
+	Device      *DeviceInfo  `json:"device,omitempty"`
+	ReturnURL   string       `json:"return_url,omitempty"`
+}
+
+// EnrollmentResponse represents the result of a SafeKey enrollment check
+type EnrollmentResponse struct {
+	ID                   string        `json:"id"`
+	Status               string        `json:"status"` // "enrolled", "not_enrolled", "unavailable"
+	Version              string        `json:"version"`
+	ThreeDSServerTransID string        `json:"three_ds_server_trans_id"`
+	ChallengeRequired    bool          `json:"challenge_required"`
+	ACSURL               string        `json:"acs_url,omitempty"`
+	ChallengeData        string        `json:"challenge_data,omitempty"`
+	Meta                 *ResponseMeta `json:"-"`
+}
+
+// IsEnrolled reports whether the card is enrolled in SafeKey
+func (r *EnrollmentResponse) IsEnrolled() bool {
+	return r.Status == "enrolled"
+}
+
+// AuthenticationRequest completes a SafeKey authentication
+type AuthenticationRequest struct {
+	ThreeDSServerTransID string `json:"three_ds_server_trans_id"`
+	ChallengeResponse    string `json:"challenge_response,omitempty"`
+}
+
+// AuthenticationResponse represents the outcome of a SafeKey authentication
+type AuthenticationResponse struct {
+	ID                   string        `json:"id"`
+	Status               string        `json:"status"` // "authenticated", "attempted", "failed", "rejected"
+	Version              string        `json:"version"`
+	ThreeDSServerTransID string        `json:"three_ds_server_trans_id"`
+	DSTransactionID      string        `json:"ds_transaction_id"`
+	AuthenticationValue  string        `json:"authentication_value"`
+	ECI                  string        `json:"eci"`
+	FailureReason        string        `json:"failure_reason,omitempty"`
+	Meta                 *ResponseMeta `json:"-"`
+}
+
+// ThreeDSecure carries the SafeKey authentication result on a transaction
+type ThreeDSecure struct {
+	Version             string `json:"version"`
+	AuthenticationValue string `json:"authentication_value"`
+	ECI                 string `json:"eci"`
+	DSTransactionID     string `json:"ds_transaction_id,omitempty"`
+}
+
+// ThreeDSecure returns the authentication data to attach to a transaction
+func (r *AuthenticationResponse) ThreeDSecure() *ThreeDSecure {
+	return &ThreeDSecure{
+		Version:             r.Version,
+		AuthenticationValue: r.AuthenticationValue,
+		ECI:                 r.ECI,
+		DSTransactionID:     r.DSTransactionID,
+	}
+}
+
+// CheckEnrollment checks whether a card is enrolled in SafeKey and starts
+// the 3-D Secure flow. When a challenge is required, redirect the
+// cardholder to ACSURL with ChallengeData.
+func (ts *ThreeDSService) CheckEnrollment(ctx context.Context, req *EnrollmentRequest) (*EnrollmentResponse, error) {
+	// Validate the enrollment request
+	if err := ValidateEnrollmentRequest(req); err != nil {
+		return nil, fmt.Errorf("validation failed: %w", err)
+	}
+
+	resp, err := ts.post(ctx, "/3ds/enrollment", req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check enrollment: %w", err)
+	}
+
+	var enrollment EnrollmentResponse
+	meta, err := ts.decode(resp, &enrollment)
+	if err != nil {
+		return nil, err
+	}
+	enrollment.Meta = meta
+
+	return &enrollment, nil
+}
+
+// Authenticate completes the SafeKey flow, optionally with the challenge
+// response returned by the issuer
+func (ts *ThreeDSService) Authenticate(ctx context.Context, req *AuthenticationRequest) (*AuthenticationResponse, error) {
+	// Validate the authentication request
+	if err := ValidateAuthenticationRequest(req); err != nil {
+		return nil, fmt.Errorf("validation failed: %w", err)
+	}
+
+	resp, err := ts.post(ctx, "/3ds/authenticate", req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to authenticate: %w", err)
+	}
+
+	var authentication AuthenticationResponse
+	meta, err := ts.decode(resp, &authentication)
+	if err != nil {
+		return nil, err
+	}
+	authentication.Meta = meta
+
+	return &authentication, nil
+}
@@ -2,20 +2,26 @@ package americanexpress
 
 import (
 	"context"
-	"encoding/json"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
-	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
 	"time"
 )
 
 // TokenService handles token management operations
 type TokenService struct {
-	client *Client
+	service
 }
 
 // NewTokenService creates a new token service
 func NewTokenService(client *Client) *TokenService {
-	return &TokenService{client: client}
+	return &TokenService{service: newServiceAt(client, client.tokenBaseURL)}
 }
 
 // TokenRequest represents a token creation request
@@ -24,80 +30,177 @@ type TokenRequest struct {
 	CustomerID   string       `json:"customer_id,omitempty"`
 	Description  string       `json:"description,omitempty"`
 	SingleUse    bool         `json:"single_use,omitempty"`
+	BillingAddr  *Address     `json:"billing_address,omitempty"` // Stored for AVS on transactions using the token
 }
 
 // TokenResponse represents a token response
 type TokenResponse struct {
-	ID           string    `json:"id"`
-	Token        string    `json:"token"`
-	CustomerID   string    `json:"customer_id"`
-	Description  string    `json:"description"`
-	CardLast4    string    `json:"card_last4"`
-	CardBrand    string    `json:"card_brand"`
-	ExpiryMonth  int       `json:"expiry_month"`
-	ExpiryYear   int       `json:"expiry_year"`
-	SingleUse    bool      `json:"single_use"`
-	Used         bool      `json:"used"`
-	CreatedAt    time.Time `json:"created_at"`
-	ExpiresAt    time.Time `json:"expires_at"`
+	ID          string          `json:"id"`
+	Token       string          `json:"token"`
+	CustomerID  string          `json:"customer_id"`
+	Description string          `json:"description"`
+	CardLast4   string          `json:"card_last4"`
+	CardBrand   string          `json:"card_brand"`
+	ExpiryMonth int             `json:"expiry_month"`
+	ExpiryYear  int             `json:"expiry_year"`
+	SingleUse   bool            `json:"single_use"`
+	Used        bool            `json:"used"`
+	CreatedAt   time.Time       `json:"created_at"`
+	ExpiresAt   time.Time       `json:"expires_at"`
+	BillingAddr *AddressSummary `json:"billing_address,omitempty"` // Set when an address was stored with the token
+	Status      TokenStatus     `json:"status,omitempty"`          // Empty for gateways that predate disabling tokens
+	Meta        *ResponseMeta   `json:"-"`
+}
+
+// IsActive reports whether the token can be used for new charges
+func (t *TokenResponse) IsActive() bool {
+	return t.Status != TokenStatusDisabled
+}
+
+// AddressSummary is the part of a stored address that is returned for display
+type AddressSummary struct {
+	PostalCode string `json:"postal_code"`
+	Country    string `json:"country"`
+}
+
+// prepareTokenRequest returns the copy of a token request that is sent to
+// the gateway, leaving the caller's request untouched
+func (ts *TokenService) prepareTokenRequest(req *TokenRequest) *TokenRequest {
+	if req == nil {
+		return nil
+	}
+
+	prepared := *req
+	prepared.CardDetails = req.CardDetails.Normalize()
+	prepared.BillingAddr = req.BillingAddr.Normalize()
+	ts.client.warnTestCard(prepared.CardDetails)
+	return &prepared
 }
 
 // CreateToken creates a new payment token
 func (ts *TokenService) CreateToken(ctx context.Context, req *TokenRequest) (*TokenResponse, error) {
+	req = ts.prepareTokenRequest(req)
+
 	// Validate the token request
 	if err := ValidateTokenRequest(req); err != nil {
 		return nil, fmt.Errorf("validation failed: %w", err)
 	}
 
-	resp, err := ts.client.Post(ctx, "/tokens", req)
+	resp, err := ts.post(ctx, "/tokens", req)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create token: %w", err)
 	}
-	defer resp.Body.Close()
 
-	body, err := io.ReadAll(resp.Body)
+	var token TokenResponse
+	meta, err := ts.decode(resp, &token)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read response: %w", err)
+		return nil, err
+	}
+	token.Meta = meta
+
+	return &token, nil
+}
+
+// tokenFromTransactionRequest is the body of a request tokenizing the card
+// of a prior transaction
+type tokenFromTransactionRequest struct {
+	TransactionID string `json:"transaction_id"`
+	CustomerID    string `json:"customer_id"`
+}
+
+// CreateTokenFromTransaction tokenizes the card used in a prior transaction
+// for the customer, e.g. to offer "save this card" after a guest checkout
+// without collecting the card number again. The gateway looks the card up
+// by the transaction, so this only works while it still holds the
+// transaction's card reference; once that has expired the request fails
+// and the card must be collected again.
+func (ts *TokenService) CreateTokenFromTransaction(ctx context.Context, transactionID, customerID string) (*TokenResponse, error) {
+	if strings.TrimSpace(transactionID) == "" {
+		return nil, fmt.Errorf("validation failed: %w", validationError("transaction_id", ValidationCodeRequired, "transaction ID cannot be empty"))
+	}
+	if err := ValidateCustomerID(customerID); err != nil {
+		return nil, fmt.Errorf("validation failed: %w", err)
+	}
+
+	body := &tokenFromTransactionRequest{TransactionID: transactionID, CustomerID: customerID}
+	resp, err := ts.post(ctx, "/tokens", body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create token from transaction: %w", err)
 	}
 
 	var token TokenResponse
-	if err := json.Unmarshal(body, &token); err != nil {
-		return nil, fmt.Errorf("failed to unmarshal response: %w", err)
+	meta, err := ts.decode(resp, &token)
+	if err != nil {
+		return nil, err
 	}
+	token.Meta = meta
 
 	return &token, nil
 }
 
 // GetToken retrieves a token by ID
 func (ts *TokenService) GetToken(ctx context.Context, tokenID string) (*TokenResponse, error) {
-	resp, err := ts.client.Get(ctx, fmt.Sprintf("/tokens/%s", tokenID), nil)
+	resp, err := ts.get(ctx, fmt.Sprintf("/tokens/%s", tokenID), nil)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get token: %w", err)
 	}
-	defer resp.Body.Close()
-
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read response: %w", err)
-	}
 
 	var token TokenResponse
-	if err := json.Unmarshal(body, &token); err != nil {
-		return nil, fmt.Errorf("failed to unmarshal response: %w", err)
+	meta, err := ts.decode(resp, &token)
+	if err != nil {
+		return nil, err
 	}
+	token.Meta = meta
+	ts.client.observeTokenStatus(tokenID, token.Status)
+	ts.client.tokenGenerations.observe(tokenID, token.CustomerID)
 
 	return &token, nil
 }
 
-// DeleteToken deletes a token
+// DeleteToken deletes a token. The client forgets having seen it disabled,
+// so a token the gateway later issues under the same ID can be charged.
 func (ts *TokenService) DeleteToken(ctx context.Context, tokenID string) error {
-	_, err := ts.client.Delete(ctx, fmt.Sprintf("/tokens/%s", tokenID))
+	resp, err := ts.delete(ctx, fmt.Sprintf("/tokens/%s", tokenID))
 	if err != nil {
 		return fmt.Errorf("failed to delete token: %w", err)
 	}
+	resp.Body.Close()
+	ts.client.disabledTokens.ids.Delete(tokenID)
+	ts.client.tokenGenerations.remove(tokenID, true)
 	return nil
 }
 
+// DisableToken marks a token inactive instead of deleting it, for
+// compliance regimes that require keeping token records for audit. A
+// disabled token can't be used for new charges but is still returned by
+// GetToken and ListTokens.
+func (ts *TokenService) DisableToken(ctx context.Context, tokenID string) (*TokenResponse, error) {
+	if strings.TrimSpace(tokenID) == "" {
+		return nil, fmt.Errorf("validation failed: %w", validationError("token_id", ValidationCodeRequired, "token ID cannot be empty"))
+	}
+
+	body := map[string]TokenStatus{"status": TokenStatusDisabled}
+	resp, err := ts.put(ctx, fmt.Sprintf("/tokens/%s", url.PathEscape(tokenID)), body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to disable token: %w", err)
+	}
+
+	var token TokenResponse
+	meta, err := ts.decode(resp, &token)
+	if err != nil {
+		return nil, err
+	}
+	token.Meta = meta
+	if token.Status == "" {
+		token.Status = TokenStatusDisabled
+	}
+	ts.client.observeTokenStatus(tokenID, token.Status)
+	ts.client.tokenGenerations.observe(tokenID, token.CustomerID)
+	ts.client.tokenGenerations.remove(tokenID, false)
+
+	return &token, nil
+}
+
 // ListTokensRequest represents parameters for listing tokens
 type ListTokensRequest struct {
 	CustomerID string `url:"customer_id,omitempty"`
@@ -110,6 +213,7 @@ type ListTokensResponse struct {
 	Tokens     []TokenResponse `json:"tokens"`
 	TotalCount int             `json:"total_count"`
 	HasMore    bool            `json:"has_more"`
+	Meta       *ResponseMeta   `json:"-"`
 }
 
 // ListTokens retrieves a list of tokens
@@ -119,21 +223,208 @@ func (ts *TokenService) ListTokens(ctx context.Context, req *ListTokensRequest)
 		return nil, fmt.Errorf("failed to encode query: %w", err)
 	}
 
-	resp, err := ts.client.Get(ctx, "/tokens", query)
+	resp, err := ts.get(ctx, "/tokens", query)
 	if err != nil {
 		return nil, fmt.Errorf("failed to list tokens: %w", err)
 	}
-	defer resp.Body.Close()
 
-	body, err := io.ReadAll(resp.Body)
+	var tokens ListTokensResponse
+	meta, err := ts.decode(resp, &tokens)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read response: %w", err)
+		return nil, err
 	}
-
-	var tokens ListTokensResponse
-	if err := json.Unmarshal(body, &tokens); err != nil {
-		return nil, fmt.Errorf("failed to unmarshal response: %w", err)
+	tokens.Meta = meta
+	for _, token := range tokens.Tokens {
+		ts.client.observeTokenStatus(token.ID, token.Status)
+		customerID := token.CustomerID
+		if customerID == "" && req != nil {
+			customerID = req.CustomerID
+		}
+		ts.client.tokenGenerations.observe(token.ID, customerID)
 	}
 
 	return &tokens, nil
-}
\ No newline at end of file
+}
+
+// TokenIterator iterates over every token matching a filter
+type TokenIterator = Iterator[TokenResponse]
+
+// IterateTokens returns an iterator over every token matching req, starting
+// at req.Offset and fetching req.Limit tokens per page
+func (ts *TokenService) IterateTokens(req *ListTokensRequest) *TokenIterator {
+	filter := ListTokensRequest{}
+	if req != nil {
+		filter = *req
+	}
+
+	return newIterator(filter.Offset, ts.client.maxPages, func(ctx context.Context, offset int) (*page[TokenResponse], error) {
+		filter.Offset = offset
+		tokens, err := ts.ListTokens(ctx, &filter)
+		if err != nil {
+			return nil, err
+		}
+		return &page[TokenResponse]{
+			items:      tokens.Tokens,
+			nextOffset: offset + len(tokens.Tokens),
+			hasMore:    tokens.HasMore,
+		}, nil
+	})
+}
+
+// EnsureToken returns a reusable token of the customer for the card,
+// creating one only if the customer has none yet. It spares callers the
+// list-then-create dance of "tokenize this card unless it already is".
+//
+// A token matches when its last four digits and expiry date equal the
+// card's. The gateway returns no more of the card, so two different cards
+// of the customer sharing those would be taken for the same one; the
+// holder name and billing address are not compared either. Single-use
+// tokens never match.
+//
+// The token is created with an idempotency key derived from the customer
+// and the card, so concurrent calls for the same card create one token.
+// The key changes once the client deletes or disables one of the
+// customer's tokens, so the gateway doesn't answer with the removed token.
+func (ts *TokenService) EnsureToken(ctx context.Context, customerID string, card *CardDetails) (*TokenResponse, error) {
+	if err := ValidateCustomerID(customerID); err != nil {
+		return nil, fmt.Errorf("validation failed: %w", err)
+	}
+	req := ts.prepareTokenRequest(&TokenRequest{CardDetails: card, CustomerID: customerID})
+	if err := ValidateTokenRequest(req); err != nil {
+		return nil, fmt.Errorf("validation failed: %w", err)
+	}
+	card = req.CardDetails
+
+	tokens := ts.IterateTokens(&ListTokensRequest{CustomerID: customerID})
+	for tokens.Next(ctx) {
+		if token := tokens.Item(); token.matches(card) {
+			return &token, nil
+		}
+	}
+	if err := tokens.Err(); err != nil {
+		return nil, err
+	}
+
+	httpReq := ts.request(http.MethodPost, "/tokens")
+	httpReq.Body = req
+	httpReq.Headers = map[string]string{IdempotencyKeyHeader: ts.ensureTokenKey(customerID, card)}
+	resp, err := ts.client.doRequest(ctx, httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create token: %w", err)
+	}
+
+	var token TokenResponse
+	meta, err := ts.decode(resp, &token)
+	if err != nil {
+		return nil, err
+	}
+	token.Meta = meta
+	ts.client.tokenGenerations.observe(token.ID, customerID)
+
+	return &token, nil
+}
+
+// matches reports whether a reusable token was created for the card,
+// judging by its last four digits and expiry date
+func (t *TokenResponse) matches(card *CardDetails) bool {
+	if t.SingleUse || !t.IsActive() || len(card.Number) < 4 {
+		return false
+	}
+	return t.CardLast4 == card.Number[len(card.Number)-4:] &&
+		t.ExpiryMonth == card.ExpiryMonth && t.ExpiryYear == card.ExpiryYear
+}
+
+// ensureTokenKey derives the idempotency key EnsureToken creates a token
+// with. The card number is keyed with the client's token salt so the key
+// does not reveal it; unlike the secret key, the salt is never empty and
+// does not change when the credentials are rotated. The customer's token
+// generation is mixed in so the key changes after a token is removed.
+func (ts *TokenService) ensureTokenKey(customerID string, card *CardDetails) string {
+	mac := hmac.New(sha256.New, []byte(ts.client.tokenSalt))
+	fmt.Fprintf(mac, "%s\n%s\n%02d/%d\n%s", customerID, card.Number, card.ExpiryMonth, card.ExpiryYear,
+		ts.client.tokenGenerations.of(customerID))
+	return "ensure-token-" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// tokenGenerations counts the tokens the client has deleted or disabled
+// per customer, so that EnsureToken stops sending an idempotency key the
+// gateway would answer with a removed token
+type tokenGenerations struct {
+	mu        sync.Mutex
+	customers map[string]string // Customer of each token seen
+	counts    map[string]uint64
+	unknown   uint64 // Removals of tokens whose customer is unknown
+}
+
+// observe records the customer a token belongs to
+func (g *tokenGenerations) observe(tokenID, customerID string) {
+	if tokenID == "" || customerID == "" {
+		return
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.customers == nil {
+		g.customers = make(map[string]string)
+	}
+	g.customers[tokenID] = customerID
+}
+
+// remove advances the generation of the token's customer, or of every
+// customer if the token hasn't been seen. A deleted token is forgotten.
+func (g *tokenGenerations) remove(tokenID string, deleted bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	customerID, ok := g.customers[tokenID]
+	if !ok {
+		g.unknown++
+		return
+	}
+	if g.counts == nil {
+		g.counts = make(map[string]uint64)
+	}
+	g.counts[customerID]++
+	if deleted {
+		delete(g.customers, tokenID)
+	}
+}
+
+// of returns the generation of a customer's tokens
+func (g *tokenGenerations) of(customerID string) string {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return fmt.Sprintf("%d.%d", g.counts[customerID], g.unknown)
+}
+
+// ErrTokenDisabled is returned when a charge uses a token known to be
+// disabled
+var ErrTokenDisabled = errors.New("token is disabled")
+
+// disabledTokens records the tokens the client has seen disabled, so that
+// charges using them fail before reaching the gateway
+type disabledTokens struct {
+	ids sync.Map
+}
+
+// observeTokenStatus records the status of a token returned by the gateway
+func (c *Client) observeTokenStatus(tokenID string, status TokenStatus) {
+	if tokenID == "" {
+		return
+	}
+	if status == TokenStatusDisabled {
+		c.disabledTokens.ids.Store(tokenID, struct{}{})
+	} else {
+		c.disabledTokens.ids.Delete(tokenID)
+	}
+}
+
+// validateTokenActive checks that a token used for a charge has not been
+// seen disabled. Tokens the client hasn't seen are left to the gateway.
+func (c *Client) validateTokenActive(field, tokenID string) error {
+	if tokenID == "" {
+		return nil
+	}
+	if _, disabled := c.disabledTokens.ids.Load(tokenID); disabled {
+		return sentinelError(field, ValidationCodeInvalid, ErrTokenDisabled, fmt.Sprintf("token %s is disabled", tokenID))
+	}
+	return nil
+}
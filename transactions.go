@@ -2,44 +2,100 @@ package americanexpress
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
-	"io"
+	"math"
 	"net/url"
+	"sort"
+	"strings"
+	"sync"
 	"time"
 )
 
 // TransactionService handles transaction-related operations
 type TransactionService struct {
-	client *Client
+	service
 }
 
 // NewTransactionService creates a new transaction service
 func NewTransactionService(client *Client) *TransactionService {
-	return &TransactionService{client: client}
+	return &TransactionService{service: newService(client)}
 }
 
 // TransactionRequest represents a transaction authorization request
 type TransactionRequest struct {
-	Amount       float64           `json:"amount"`
-	Currency     string            `json:"currency"`
-	MerchantID   string            `json:"merchant_id"`
-	Description  string            `json:"description,omitempty"`
-	Reference    string            `json:"reference,omitempty"`
-	CardToken    string            `json:"card_token,omitempty"`
-	CardDetails  *CardDetails      `json:"card_details,omitempty"`
-	BillingAddr  *Address          `json:"billing_address,omitempty"`
-	ShippingAddr *Address          `json:"shipping_address,omitempty"`
-	Metadata     map[string]string `json:"metadata,omitempty"`
-	CaptureMode  string            `json:"capture_mode,omitempty"` // "auto", "manual"
-	CVVCheck     bool              `json:"cvv_check,omitempty"`
-	AVSCheck     bool              `json:"avs_check,omitempty"`
+	Amount        float64           `json:"amount"`
+	Currency      string            `json:"currency"`
+	MerchantID    string            `json:"merchant_id"`
+	Description   string            `json:"description,omitempty"`
+	Reference     string            `json:"reference,omitempty"`
+	CardToken     string            `json:"card_token,omitempty"`
+	CardDetails   *CardDetails      `json:"card_details,omitempty"`
+	BillingAddr   *Address          `json:"billing_address,omitempty"`
+	ShippingAddr  *Address          `json:"shipping_address,omitempty"`
+	Metadata      map[string]string `json:"metadata,omitempty"`
+	CaptureMode   string            `json:"capture_mode,omitempty"` // CaptureModeManual (default) or CaptureModeAuto
+	CVVCheck      bool              `json:"cvv_check,omitempty"`
+	AVSCheck      bool              `json:"avs_check,omitempty"`
+	SaveCard      bool              `json:"save_card,omitempty"`   // Tokenize CardDetails and return the token
+	CustomerID    string            `json:"customer_id,omitempty"` // Customer the saved token belongs to
+	ThreeDSecure  *ThreeDSecure     `json:"three_d_secure,omitempty"`
+	NetworkToken  *NetworkToken     `json:"network_token,omitempty"`
+	WalletPayment *WalletPayment    `json:"wallet,omitempty"`
+	MCC           string            `json:"mcc,omitempty"` // Merchant category code override, e.g. for marketplaces
+	Splits        []PaymentSplit    `json:"splits,omitempty"` // Marketplace payouts to sub-merchants
+
+	// AuthorizationExpiry requests when the hold placed by a manual capture
+	// authorization lapses, within MaxAuthorizationHold. The gateway's
+	// default applies when nil; the actual expiry is returned in ExpiresAt.
+	AuthorizationExpiry *time.Time `json:"authorization_expires_at,omitempty"`
+
+	// Tax and surcharge itemized in Amount, for merchants that must report
+	// them separately. When BaseAmount is set, Amount must equal BaseAmount
+	// plus TaxAmount plus SurchargeAmount.
+	BaseAmount      *float64 `json:"base_amount,omitempty"`
+	TaxAmount       *float64 `json:"tax_amount,omitempty"`
+	SurchargeAmount *float64 `json:"surcharge_amount,omitempty"`
+
+	// SettlementCurrency settles a cross-border transaction in the
+	// merchant's currency while the cardholder is charged Amount in
+	// Currency. The merchant picks the rate: when SettlementAmount is set,
+	// it must equal Amount converted at ExchangeRate (settlement units per
+	// unit of Currency), rounded to the settlement currency. Otherwise the
+	// gateway converts at its own rate. Unlike dynamic currency conversion,
+	// the cardholder is not offered a choice of currency.
+	SettlementCurrency string   `json:"settlement_currency,omitempty"`
+	SettlementAmount   *float64 `json:"settlement_amount,omitempty"`
+	ExchangeRate       float64  `json:"exchange_rate,omitempty"`
+
+	// DescriptorCity and DescriptorPhone are printed next to the merchant
+	// name on the cardholder's statement, helping them recognize the
+	// charge. They are sent in the descriptor object; see MarshalJSON.
+	DescriptorCity  string `json:"-"` // Up to 13 characters
+	DescriptorPhone string `json:"-"` // E.164 digits, e.g. "+18005550100"
+
+	decimalStringAmounts bool // Set from the client config; see MarshalJSON
+}
+
+// transactionDescriptor is the descriptor object of a transaction request
+type transactionDescriptor struct {
+	City  string `json:"city,omitempty"`
+	Phone string `json:"phone,omitempty"`
+}
+
+// descriptor returns the descriptor object of the request, or nil when
+// it has no descriptor fields
+func (r *TransactionRequest) descriptor() *transactionDescriptor {
+	if r.DescriptorCity == "" && r.DescriptorPhone == "" {
+		return nil
+	}
+	return &transactionDescriptor{City: r.DescriptorCity, Phone: r.DescriptorPhone}
 }
 
 // TransactionResponse represents a transaction response
 type TransactionResponse struct {
 	ID                string            `json:"id"`
-	Status            string            `json:"status"`
+	Status            TransactionStatus `json:"status"`
 	Type              string            `json:"type"`
 	Amount            float64           `json:"amount"`
 	Currency          string            `json:"currency"`
@@ -55,127 +111,587 @@ type TransactionResponse struct {
 	Metadata          map[string]string `json:"metadata,omitempty"`
 	FailureReason     string            `json:"failure_reason,omitempty"`
 	FailureCode       string            `json:"failure_code,omitempty"`
-	CVVResult         string            `json:"cvv_result,omitempty"`
-	AVSResult         string            `json:"avs_result,omitempty"`
+	CVVResult         CVVResult         `json:"cvv_result,omitempty"`
+	AVSResult         AVSResult         `json:"avs_result,omitempty"`
+	CardToken         string            `json:"card_token,omitempty"` // Set when the request had SaveCard
+	RemainingAmount   *float64          `json:"remaining_authorized_amount,omitempty"`
+	CaptureMode       string            `json:"capture_mode,omitempty"`
+	BaseAmount        *float64          `json:"base_amount,omitempty"` // Set on captures with a tip
+	TipAmount         *float64          `json:"tip_amount,omitempty"`
+	FraudScore        *float64          `json:"fraud_score,omitempty"` // 0 (lowest risk) to 100; nil unless fraud scoring is enabled
+	LineItems         []LineItem        `json:"line_items,omitempty"`  // Captured items, when the gateway echoes them
+	Meta              *ResponseMeta     `json:"-"`
+
+	// Settlement figures of a cross-border transaction, set when it was
+	// requested with a SettlementCurrency. They are the amounts actually
+	// settled, which may differ from the requested ones.
+	SettlementAmount   *float64 `json:"settlement_amount,omitempty"`
+	SettlementCurrency string   `json:"settlement_currency,omitempty"`
+	ExchangeRate       *float64 `json:"exchange_rate,omitempty"`
+
+	// Related resources, populated only when requested through
+	// GetTransactionWithExpand
+	Refunds  []RefundTransactionResponse `json:"refunds,omitempty"`
+	Captures []CaptureResponse           `json:"captures,omitempty"`
+	Dispute  *Dispute                    `json:"dispute,omitempty"`
 }
 
-// AuthorizeTransaction creates a new transaction authorization
+// CaptureResponse represents a single capture made against a transaction
+type CaptureResponse struct {
+	ID            string            `json:"id"`
+	TransactionID string            `json:"transaction_id"`
+	Amount        float64           `json:"amount"`
+	Currency      string            `json:"currency"`
+	Status        string            `json:"status"`
+	Reference     string            `json:"reference"`
+	CreatedAt     time.Time         `json:"created_at"`
+	Metadata      map[string]string `json:"metadata,omitempty"`
+	LineItems     []LineItem        `json:"line_items,omitempty"`
+}
+
+// Capture modes of a transaction request
+const (
+	// CaptureModeManual authorizes the amount only; the funds are moved by
+	// a later CaptureTransaction. This is the default unless
+	// Config.DefaultCaptureMode says otherwise.
+	CaptureModeManual = "manual"
+	// CaptureModeAuto authorizes and captures in one step (a sale)
+	CaptureModeAuto = "auto"
+)
+
+// MaxAuthorizationHold is the longest an authorization hold may be kept
+// before capture, the limit Amex allows for lodging and car rental
+// pre-authorizations
+const MaxAuthorizationHold = 30 * 24 * time.Hour
+
+// Related resources that can be embedded in a transaction response
+const (
+	ExpandRefunds  = "refunds"
+	ExpandCaptures = "captures"
+	ExpandDispute  = "dispute"
+)
+
+// prepareTransactionRequest returns the copy of a transaction request that
+// is sent to the gateway, leaving the caller's request untouched
+func (ts *TransactionService) prepareTransactionRequest(ctx context.Context, req *TransactionRequest) *TransactionRequest {
+	if req == nil {
+		return nil
+	}
+
+	prepared := *req
+	prepared.MerchantID = merchantID(ctx, req.MerchantID)
+	prepared.Currency = ts.client.currencyOrDefault(NormalizeCurrency(req.Currency))
+	prepared.SettlementCurrency = NormalizeCurrency(req.SettlementCurrency)
+	prepared.DescriptorCity = strings.TrimSpace(req.DescriptorCity)
+	prepared.DescriptorPhone = normalizePhone(req.DescriptorPhone)
+	prepared.decimalStringAmounts = ts.client.decimalStringAmounts
+	prepared.CardDetails = req.CardDetails.Normalize()
+	prepared.BillingAddr = req.BillingAddr.Normalize()
+	prepared.ShippingAddr = req.ShippingAddr.Normalize()
+	prepared.Metadata = mergeMetadata(ts.client.defaultMetadata, req.Metadata)
+	if prepared.CaptureMode == "" {
+		// Send the default explicitly so the gateway never has to guess
+		prepared.CaptureMode = ts.client.defaultCaptureMode
+	}
+	ts.client.warnTestCard(prepared.CardDetails)
+	return &prepared
+}
+
+// AuthorizeTransaction creates a new transaction authorization. Unless
+// CaptureMode is CaptureModeAuto, the transaction is authorized only and
+// must be captured with CaptureTransaction.
 func (ts *TransactionService) AuthorizeTransaction(ctx context.Context, req *TransactionRequest) (*TransactionResponse, error) {
+	req = ts.prepareTransactionRequest(ctx, req)
+
 	// Validate the transaction request
 	if err := ValidateTransactionRequest(req); err != nil {
 		return nil, fmt.Errorf("validation failed: %w", err)
 	}
-
-	resp, err := ts.client.Post(ctx, "/transactions/authorize", req)
-	if err != nil {
-		return nil, fmt.Errorf("failed to authorize transaction: %w", err)
+	if err := ts.client.validateTokenActive("card_token", req.CardToken); err != nil {
+		return nil, fmt.Errorf("validation failed: %w", err)
+	}
+	if err := ts.client.validateSurchargeAllowed(req.MerchantID, req.SurchargeAmount); err != nil {
+		return nil, fmt.Errorf("validation failed: %w", err)
+	}
+	if err := ts.validateMetadata(req.Metadata); err != nil {
+		return nil, err
+	}
+	if req.AuthorizationExpiry != nil {
+		if err := validateAuthorizationExpiry(*req.AuthorizationExpiry, ts.client.now()); err != nil {
+			return nil, fmt.Errorf("validation failed: %w", err)
+		}
 	}
-	defer resp.Body.Close()
 
-	body, err := io.ReadAll(resp.Body)
+	resp, err := ts.post(ctx, "/transactions/authorize", req)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read response: %w", err)
+		return nil, fmt.Errorf("failed to authorize transaction: %w", err)
 	}
 
 	var transaction TransactionResponse
-	if err := json.Unmarshal(body, &transaction); err != nil {
-		return nil, fmt.Errorf("failed to unmarshal response: %w", err)
+	meta, err := ts.decode(resp, &transaction)
+	if err != nil {
+		return nil, err
 	}
+	transaction.Meta = meta
 
 	return &transaction, nil
 }
 
-// GetTransaction retrieves a transaction by ID
+// GetTransaction retrieves a transaction by ID. When Config.TransactionCacheTTL
+// is set and the client has a Cache, transactions in a terminal status are
+// served from the cache until the TTL expires.
 func (ts *TransactionService) GetTransaction(ctx context.Context, transactionID string) (*TransactionResponse, error) {
-	resp, err := ts.client.Get(ctx, fmt.Sprintf("/transactions/%s", transactionID), nil)
+	cache, ttl := ts.client.cache, ts.client.txnTTL
+	if cache != nil && ttl > 0 {
+		if value, ok := cache.Get(transactionCacheKey(transactionID)); ok {
+			if cached, ok := value.(*TransactionResponse); ok {
+				return cached.clone(), nil
+			}
+		}
+	}
+
+	transaction, err := ts.GetTransactionWithExpand(ctx, transactionID, nil)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get transaction: %w", err)
+		return nil, err
+	}
+
+	// Transactions that can still change, including captured ones that may
+	// be refunded, are never cached
+	if cache != nil && ttl > 0 && transaction.Status.IsTerminal() {
+		cache.Set(transactionCacheKey(transactionID), transaction.clone(), ttl)
+	}
+
+	return transaction, nil
+}
+
+// transactionCacheKey returns the cache key for a transaction
+func transactionCacheKey(transactionID string) string {
+	return "transaction:" + transactionID
+}
+
+// clone returns a copy that shares no maps, slices or pointers with t, so
+// cached values can't be changed through a returned one
+func (t *TransactionResponse) clone() *TransactionResponse {
+	clone := *t
+	clone.ProcessedAt = clonePtr(t.ProcessedAt)
+	clone.ExpiresAt = clonePtr(t.ExpiresAt)
+	clone.Metadata = mergeMetadata(t.Metadata, nil)
+	clone.RemainingAmount = clonePtr(t.RemainingAmount)
+	clone.BaseAmount = clonePtr(t.BaseAmount)
+	clone.TipAmount = clonePtr(t.TipAmount)
+	clone.FraudScore = clonePtr(t.FraudScore)
+	clone.LineItems = append([]LineItem(nil), t.LineItems...)
+	clone.Meta = t.Meta.clone()
+	clone.SettlementAmount = clonePtr(t.SettlementAmount)
+	clone.ExchangeRate = clonePtr(t.ExchangeRate)
+	clone.Dispute = t.Dispute.clone()
+
+	clone.Refunds = append([]RefundTransactionResponse(nil), t.Refunds...)
+	for i, refund := range clone.Refunds {
+		clone.Refunds[i].ProcessedAt = clonePtr(refund.ProcessedAt)
+		clone.Refunds[i].Metadata = mergeMetadata(refund.Metadata, nil)
+		clone.Refunds[i].Meta = refund.Meta.clone()
+	}
+	clone.Captures = append([]CaptureResponse(nil), t.Captures...)
+	for i, capture := range clone.Captures {
+		clone.Captures[i].Metadata = mergeMetadata(capture.Metadata, nil)
+		clone.Captures[i].LineItems = append([]LineItem(nil), capture.LineItems...)
+	}
+	return &clone
+}
+
+// GetTransactionWithExpand retrieves a transaction by ID along with the
+// requested related resources (ExpandRefunds, ExpandCaptures, ExpandDispute)
+// in a single round trip
+func (ts *TransactionService) GetTransactionWithExpand(ctx context.Context, transactionID string, expand []string) (*TransactionResponse, error) {
+	if err := ValidateExpand(expand); err != nil {
+		return nil, fmt.Errorf("validation failed: %w", err)
+	}
+
+	var query url.Values
+	if len(expand) > 0 {
+		query = url.Values{}
+		query.Add("expand", strings.Join(expand, ","))
 	}
-	defer resp.Body.Close()
 
-	body, err := io.ReadAll(resp.Body)
+	resp, err := ts.get(ctx, fmt.Sprintf("/transactions/%s", transactionID), query)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read response: %w", err)
+		return nil, fmt.Errorf("failed to get transaction: %w", err)
 	}
 
 	var transaction TransactionResponse
-	if err := json.Unmarshal(body, &transaction); err != nil {
-		return nil, fmt.Errorf("failed to unmarshal response: %w", err)
+	meta, err := ts.decode(resp, &transaction)
+	if err != nil {
+		return nil, err
 	}
+	transaction.Meta = meta
 
 	return &transaction, nil
 }
 
+// MaxConcurrentLookups is the number of transactions GetTransactions
+// fetches at the same time
+const MaxConcurrentLookups = 8
+
+// TransactionLookupError reports that one transaction of a GetTransactions
+// batch could not be fetched
+type TransactionLookupError struct {
+	TransactionID string
+	Err           error
+}
+
+func (e *TransactionLookupError) Error() string {
+	return fmt.Sprintf("transaction %s: %v", e.TransactionID, e.Err)
+}
+
+// Unwrap returns the underlying error
+func (e *TransactionLookupError) Unwrap() error {
+	return e.Err
+}
+
+// GetTransactions fetches several transactions concurrently, at most
+// MaxConcurrentLookups at a time. Duplicate IDs are fetched once.
+//
+// It returns the transactions that were fetched keyed by ID, and a
+// *TransactionLookupError for each ID that failed, in input order. A failed
+// lookup doesn't stop the others. Once ctx is done no further lookups are
+// started, and the remaining IDs fail with the context error.
+func (ts *TransactionService) GetTransactions(ctx context.Context, ids []string) (map[string]*TransactionResponse, []error) {
+	unique := make([]string, 0, len(ids))
+	seen := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			unique = append(unique, id)
+		}
+	}
+
+	transactions := make([]*TransactionResponse, len(unique))
+	errs := make([]error, len(unique))
+	sem := make(chan struct{}, MaxConcurrentLookups)
+	var wg sync.WaitGroup
+	for i, id := range unique {
+		if id == "" {
+			errs[i] = fmt.Errorf("validation failed: %w", validationError("transaction_id", ValidationCodeRequired, "transaction ID cannot be empty"))
+			continue
+		}
+
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			errs[i] = normalizeRequestError(ctx, ctx.Err())
+			continue
+		}
+
+		wg.Add(1)
+		go func(i int, id string) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			transactions[i], errs[i] = ts.GetTransaction(ctx, id)
+		}(i, id)
+	}
+	wg.Wait()
+
+	found := make(map[string]*TransactionResponse, len(unique))
+	var failed []error
+	for i, id := range unique {
+		if errs[i] != nil {
+			failed = append(failed, &TransactionLookupError{TransactionID: id, Err: errs[i]})
+			continue
+		}
+		found[id] = transactions[i]
+	}
+	return found, failed
+}
+
 // CaptureTransactionRequest represents a transaction capture request
 type CaptureTransactionRequest struct {
 	Amount    *float64          `json:"amount,omitempty"`
+	Currency  string            `json:"currency,omitempty"` // Transaction currency; looked up when empty
 	Reference string            `json:"reference,omitempty"`
 	Metadata  map[string]string `json:"metadata,omitempty"`
+
+	// Tip added to the authorized amount, e.g. in restaurants. When set,
+	// Amount is the total and must equal BaseAmount plus TipAdjustment.
+	BaseAmount    *float64 `json:"base_amount,omitempty"`
+	TipAdjustment *float64 `json:"tip_amount,omitempty"`
+
+	// Set only to replace the values given at authorization
+	ShippingAddr        *Address `json:"shipping_address,omitempty"`
+	StatementDescriptor string   `json:"statement_descriptor,omitempty"`
+
+	// Items shipped with this capture. Their amounts must add up to Amount,
+	// or to BaseAmount when a tip is added.
+	LineItems []LineItem `json:"line_items,omitempty"`
+
+	// AuthorizationExpiresAt is the ExpiresAt of the authorization. When it
+	// has passed, the capture fails with ErrAuthorizationExpired without
+	// reaching the gateway. FetchAuthorizationExpiry looks it up when not
+	// given, at the cost of a status request; SkipExpiryCheck turns the
+	// check off, e.g. when the gateway has extended the hold.
+	AuthorizationExpiresAt   *time.Time `json:"-"`
+	FetchAuthorizationExpiry bool       `json:"-"`
+	SkipExpiryCheck          bool       `json:"-"`
+
+	decimalStringAmounts bool // Set from the client config; see MarshalJSON
 }
 
-// CaptureTransaction captures a previously authorized transaction
-func (ts *TransactionService) CaptureTransaction(ctx context.Context, transactionID string, req *CaptureTransactionRequest) (*TransactionResponse, error) {
+// ErrAuthorizationExpired is returned when capturing an authorization whose
+// hold is known to have lapsed
+var ErrAuthorizationExpired = errors.New("authorization expired")
+
+// prepareCaptureRequest returns the copy of a capture request that is sent
+// to the gateway, leaving the caller's request untouched
+func (ts *TransactionService) prepareCaptureRequest(req *CaptureTransactionRequest) *CaptureTransactionRequest {
 	if req == nil {
 		req = &CaptureTransactionRequest{}
 	}
 
-	resp, err := ts.client.Post(ctx, fmt.Sprintf("/transactions/%s/capture", transactionID), req)
-	if err != nil {
-		return nil, fmt.Errorf("failed to capture transaction: %w", err)
+	prepared := *req
+	prepared.Currency = NormalizeCurrency(req.Currency)
+	prepared.decimalStringAmounts = ts.client.decimalStringAmounts
+	prepared.ShippingAddr = req.ShippingAddr.Normalize()
+	prepared.Metadata = mergeMetadata(ts.client.defaultMetadata, req.Metadata)
+	return &prepared
+}
+
+// resolveAmountCurrency checks that a capture or refund amount fits the
+// precision of the transaction currency, and returns the currency. When the
+// currency is not known, the transaction is fetched to find it if the
+// amount has decimals, or is sent as a decimal string, which takes the
+// currency's number of decimal places. Otherwise it stays unknown.
+func (ts *TransactionService) resolveAmountCurrency(ctx context.Context, transactionID string, amount float64, currency string) (string, error) {
+	if currency == "" {
+		if amount == math.Trunc(amount) && !ts.client.decimalStringAmounts {
+			// Whole amounts are valid in every currency
+			return "", nil
+		}
+
+		transaction, err := ts.GetTransaction(ctx, transactionID)
+		if err != nil {
+			return "", err
+		}
+		currency = NormalizeCurrency(transaction.Currency)
+	}
+
+	if err := ValidateAmountPrecision(amount, currency); err != nil {
+		return "", fmt.Errorf("validation failed: %w", err)
+	}
+	return currency, nil
+}
+
+// checkAuthorizationExpiry fails a capture of an authorization that has
+// lapsed, fetching its expiry when requested and not given
+func (ts *TransactionService) checkAuthorizationExpiry(ctx context.Context, transactionID string, req *CaptureTransactionRequest) error {
+	if req.SkipExpiryCheck {
+		return nil
+	}
+
+	expiresAt := req.AuthorizationExpiresAt
+	if expiresAt == nil && req.FetchAuthorizationExpiry {
+		status, err := ts.GetTransactionStatus(ctx, transactionID)
+		if err != nil {
+			return err
+		}
+		expiresAt = status.ExpiresAt
+	}
+
+	authorization := &TransactionResponse{ExpiresAt: expiresAt}
+	if authorization.IsExpiredAt(ts.client.now()) {
+		detail := fmt.Sprintf("authorization of transaction %s expired at %s", transactionID, expiresAt.Format(time.RFC3339))
+		return fmt.Errorf("validation failed: %w", sentinelError("authorization_expires_at", ValidationCodeOutOfRange, ErrAuthorizationExpired, detail))
+	}
+	return nil
+}
+
+// CaptureTransaction captures a previously authorized transaction. Partial
+// capture amounts must fit the precision of the transaction currency; set
+// Currency to avoid looking the transaction up, which DecimalStringAmounts
+// also does for whole amounts. The shipping address and statement
+// descriptor can be updated at capture; when omitted, the values from the
+// authorization are kept. Captures of an authorization known to
+// have expired fail with ErrAuthorizationExpired.
+func (ts *TransactionService) CaptureTransaction(ctx context.Context, transactionID string, req *CaptureTransactionRequest) (*TransactionResponse, error) {
+	req = ts.prepareCaptureRequest(req)
+
+	// Validate the capture request
+	if err := ValidateCaptureRequest(req); err != nil {
+		return nil, fmt.Errorf("validation failed: %w", err)
+	}
+	if err := ts.validateMetadata(req.Metadata); err != nil {
+		return nil, err
+	}
+	if err := ts.checkAuthorizationExpiry(ctx, transactionID, req); err != nil {
+		return nil, err
+	}
+
+	if req.Amount != nil {
+		currency, err := ts.resolveAmountCurrency(ctx, transactionID, *req.Amount, req.Currency)
+		if err != nil {
+			return nil, err
+		}
+		req.Currency = currency
 	}
-	defer resp.Body.Close()
 
-	body, err := io.ReadAll(resp.Body)
+	resp, err := ts.post(ctx, fmt.Sprintf("/transactions/%s/capture", transactionID), req)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read response: %w", err)
+		return nil, fmt.Errorf("failed to capture transaction: %w", err)
 	}
 
 	var transaction TransactionResponse
-	if err := json.Unmarshal(body, &transaction); err != nil {
-		return nil, fmt.Errorf("failed to unmarshal response: %w", err)
+	meta, err := ts.decode(resp, &transaction)
+	if err != nil {
+		return nil, err
 	}
+	transaction.Meta = meta
 
 	return &transaction, nil
 }
 
 // VoidTransactionRequest represents a transaction void request
 type VoidTransactionRequest struct {
-	Reason    string            `json:"reason,omitempty"`
-	Reference string            `json:"reference,omitempty"`
-	Metadata  map[string]string `json:"metadata,omitempty"`
+	ReasonCode VoidReason        `json:"reason_code,omitempty"`
+	Reason     string            `json:"reason,omitempty"` // Free-text note
+	Reference  string            `json:"reference,omitempty"`
+	Metadata   map[string]string `json:"metadata,omitempty"`
 }
 
-// VoidTransaction voids a previously authorized transaction
+// VoidTransaction voids a previously authorized transaction. When the
+// gateway answers with an empty body or a bare status, only the ID and
+// Status of the result are set.
 func (ts *TransactionService) VoidTransaction(ctx context.Context, transactionID string, req *VoidTransactionRequest) (*TransactionResponse, error) {
 	if req == nil {
 		req = &VoidTransactionRequest{}
 	}
+	if err := validateReasonCode(string(req.ReasonCode), req.ReasonCode.IsValid()); err != nil {
+		return nil, fmt.Errorf("validation failed: %w", err)
+	}
+	if err := ts.validateMetadata(req.Metadata); err != nil {
+		return nil, err
+	}
 
-	resp, err := ts.client.Post(ctx, fmt.Sprintf("/transactions/%s/void", transactionID), req)
+	resp, err := ts.post(ctx, fmt.Sprintf("/transactions/%s/void", transactionID), req)
 	if err != nil {
 		return nil, fmt.Errorf("failed to void transaction: %w", err)
 	}
-	defer resp.Body.Close()
 
-	body, err := io.ReadAll(resp.Body)
+	var transaction TransactionResponse
+	meta, status, err := ts.decodeVoid(resp, &transaction)
+	if err != nil {
+		return nil, err
+	}
+	if status != "" {
+		transaction.ID = transactionID
+		transaction.Status = TransactionStatus(status)
+	}
+	transaction.Meta = meta
+
+	return &transaction, nil
+}
+
+// ErrFraudScoreUnavailable is returned by CaptureIfBelowRisk when the
+// transaction carries no fraud score, e.g. because fraud scoring is not
+// enabled for the merchant
+var ErrFraudScoreUnavailable = errors.New("fraud score unavailable")
+
+// CaptureIfBelowRisk captures an authorized transaction if its fraud score
+// is at most maxScore, and voids it with VoidReasonFraud otherwise. A nil
+// amount captures the full authorization. The returned transaction's Status
+// tells which happened.
+//
+// It requires fraud scoring to be enabled for the merchant. Without a score
+// the transaction is left untouched and ErrFraudScoreUnavailable returned.
+func (ts *TransactionService) CaptureIfBelowRisk(ctx context.Context, transactionID string, maxScore float64, amount *float64) (*TransactionResponse, error) {
+	transaction, err := ts.GetTransaction(ctx, transactionID)
+	if err != nil {
+		return nil, err
+	}
+	if transaction.FraudScore == nil {
+		return nil, fmt.Errorf("failed to check fraud score of transaction %s: %w", transactionID, ErrFraudScoreUnavailable)
+	}
+
+	if *transaction.FraudScore > maxScore {
+		return ts.VoidTransaction(ctx, transactionID, &VoidTransactionRequest{
+			ReasonCode: VoidReasonFraud,
+			Reason:     fmt.Sprintf("fraud score %g exceeds %g", *transaction.FraudScore, maxScore),
+		})
+	}
+	return ts.CaptureTransaction(ctx, transactionID, &CaptureTransactionRequest{
+		Amount:   amount,
+		Currency: transaction.Currency,
+	})
+}
+
+// ReversalRequest represents a request to release all or part of an
+// authorization before capture
+type ReversalRequest struct {
+	Amount    *float64          `json:"amount,omitempty"` // nil reverses the full remaining authorization
+	Reason    string            `json:"reason,omitempty"`
+	Reference string            `json:"reference,omitempty"`
+	Metadata  map[string]string `json:"metadata,omitempty"`
+}
+
+// ReverseAuthorization releases all or part of an authorization to reduce
+// the hold on the cardholder's card, e.g. when an order shrinks before
+// capture. The transaction is fetched first to check it was not captured
+// automatically, and for partial reversals that the amount doesn't exceed
+// what is still authorized. The returned transaction reports the remaining
+// authorized amount.
+func (ts *TransactionService) ReverseAuthorization(ctx context.Context, transactionID string, req *ReversalRequest) (*TransactionResponse, error) {
+	if req == nil {
+		req = &ReversalRequest{}
+	}
+	if err := ts.validateMetadata(req.Metadata); err != nil {
+		return nil, err
+	}
+
+	if req.Amount != nil && *req.Amount <= 0 {
+		return nil, fmt.Errorf("validation failed: %w", sentinelError("amount", ValidationCodeOutOfRange, ErrInvalidAmount, "reversal amount must be positive"))
+	}
+
+	authorization, err := ts.GetTransaction(ctx, transactionID)
+	if err != nil {
+		return nil, err
+	}
+	if authorization.CaptureMode == CaptureModeAuto {
+		return nil, fmt.Errorf("validation failed: %w", validationError("capture_mode", ValidationCodeConflict, "an auto-captured transaction cannot be reversed, refund it instead"))
+	}
+
+	if req.Amount != nil {
+		authorized := authorization.Amount
+		if authorization.RemainingAmount != nil {
+			authorized = *authorization.RemainingAmount
+		}
+		if *req.Amount > authorized {
+			return nil, fmt.Errorf("validation failed: %w", sentinelError("amount", ValidationCodeOutOfRange, ErrInvalidAmount, fmt.Sprintf("reversal amount %.2f exceeds authorized amount %.2f", *req.Amount, authorized)))
+		}
+	}
+
+	resp, err := ts.post(ctx, fmt.Sprintf("/transactions/%s/reverse", transactionID), req)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read response: %w", err)
+		return nil, fmt.Errorf("failed to reverse authorization: %w", err)
 	}
 
 	var transaction TransactionResponse
-	if err := json.Unmarshal(body, &transaction); err != nil {
-		return nil, fmt.Errorf("failed to unmarshal response: %w", err)
+	meta, err := ts.decode(resp, &transaction)
+	if err != nil {
+		return nil, err
 	}
+	transaction.Meta = meta
 
 	return &transaction, nil
 }
 
 // RefundTransactionRequest represents a transaction refund request
 type RefundTransactionRequest struct {
-	Amount    float64           `json:"amount"`
-	Reason    string            `json:"reason,omitempty"`
-	Reference string            `json:"reference,omitempty"`
-	Metadata  map[string]string `json:"metadata,omitempty"`
+	Amount     float64           `json:"amount"`
+	Currency   string            `json:"currency,omitempty"` // Transaction currency; looked up when empty
+	ReasonCode RefundReason      `json:"reason_code,omitempty"`
+	Reason     string            `json:"reason,omitempty"` // Free-text note
+	Reference  string            `json:"reference,omitempty"`
+	Metadata   map[string]string `json:"metadata,omitempty"`
+
+	decimalStringAmounts bool // Set from the client config; see MarshalJSON
 }
 
 // RefundTransactionResponse represents a transaction refund response
@@ -194,29 +710,75 @@ type RefundTransactionResponse struct {
 	Metadata          map[string]string `json:"metadata,omitempty"`
 	FailureReason     string            `json:"failure_reason,omitempty"`
 	FailureCode       string            `json:"failure_code,omitempty"`
+	Meta              *ResponseMeta     `json:"-"`
+}
+
+// prepareRefundRequest returns the copy of a refund request that is sent to
+// the gateway, leaving the caller's request untouched
+func (ts *TransactionService) prepareRefundRequest(req *RefundTransactionRequest) *RefundTransactionRequest {
+	prepared := *req
+	prepared.Currency = NormalizeCurrency(req.Currency)
+	prepared.decimalStringAmounts = ts.client.decimalStringAmounts
+	prepared.Metadata = mergeMetadata(ts.client.defaultMetadata, req.Metadata)
+	return &prepared
 }
 
-// RefundTransaction creates a refund for a transaction
+// ErrDuplicateRefund is returned when a refund with the same reference was
+// already issued for a transaction within the dedupe window
+var ErrDuplicateRefund = errors.New("duplicate refund")
+
+// RefundTransaction creates a refund for a transaction.
+//
+// When the request carries a Reference, the client remembers it for the
+// configured dedupe window and refuses a second refund of the same
+// transaction with the same reference, returning ErrDuplicateRefund. This
+// guards against a retry issuing two refunds after a timeout. The reference
+// is released again only when the gateway definitively rejects the refund.
+//
+// The amount must fit the precision of the transaction currency; set
+// Currency to avoid looking the transaction up. With DecimalStringAmounts
+// the currency is looked up for whole amounts too, to format them.
 func (ts *TransactionService) RefundTransaction(ctx context.Context, transactionID string, req *RefundTransactionRequest) (*RefundTransactionResponse, error) {
 	if req == nil {
 		return nil, fmt.Errorf("refund request is required")
 	}
+	req = ts.prepareRefundRequest(req)
+	if err := validateReasonCode(string(req.ReasonCode), req.ReasonCode.IsValid()); err != nil {
+		return nil, fmt.Errorf("validation failed: %w", err)
+	}
+	if err := ts.validateMetadata(req.Metadata); err != nil {
+		return nil, err
+	}
 
-	resp, err := ts.client.Post(ctx, fmt.Sprintf("/transactions/%s/refund", transactionID), req)
+	currency, err := ts.resolveAmountCurrency(ctx, transactionID, req.Amount, req.Currency)
 	if err != nil {
-		return nil, fmt.Errorf("failed to refund transaction: %w", err)
+		return nil, err
 	}
-	defer resp.Body.Close()
+	req.Currency = currency
 
-	body, err := io.ReadAll(resp.Body)
+	dedupe := ts.client.refundDedupe
+	dedupeKey := transactionID + ":" + req.Reference
+	if dedupe != nil && req.Reference != "" {
+		if !dedupe.Reserve(dedupeKey, ts.client.refundDedupeWindow) {
+			return nil, fmt.Errorf("%w: reference %q", ErrDuplicateRefund, req.Reference)
+		}
+	}
+
+	resp, err := ts.post(ctx, fmt.Sprintf("/transactions/%s/refund", transactionID), req)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read response: %w", err)
+		var apiErr *APIError
+		if dedupe != nil && req.Reference != "" && errors.As(err, &apiErr) && apiErr.StatusCode < 500 {
+			dedupe.Release(dedupeKey)
+		}
+		return nil, fmt.Errorf("failed to refund transaction: %w", err)
 	}
 
 	var refund RefundTransactionResponse
-	if err := json.Unmarshal(body, &refund); err != nil {
-		return nil, fmt.Errorf("failed to unmarshal response: %w", err)
+	meta, err := ts.decode(resp, &refund)
+	if err != nil {
+		return nil, err
 	}
+	refund.Meta = meta
 
 	return &refund, nil
 }
@@ -224,7 +786,7 @@ func (ts *TransactionService) RefundTransaction(ctx context.Context, transaction
 // ListTransactionsRequest represents a request to list transactions
 type ListTransactionsRequest struct {
 	MerchantID  string `json:"merchant_id,omitempty"`
-	Status      string `json:"status,omitempty"`
+	Status      string `json:"status,omitempty"` // Single status; prefer Statuses
 	Type        string `json:"type,omitempty"`
 	StartDate   string `json:"start_date,omitempty"`
 	EndDate     string `json:"end_date,omitempty"`
@@ -236,6 +798,40 @@ type ListTransactionsRequest struct {
 	Offset      int    `json:"offset,omitempty"`
 	SortBy      string `json:"sort_by,omitempty"`
 	SortOrder   string `json:"sort_order,omitempty"`
+
+	// Statuses matches transactions in any of the given statuses. It is
+	// combined with Status when both are set.
+	Statuses []TransactionStatus `json:"statuses,omitempty"`
+
+	// StartTime and EndTime are alternatives to StartDate and EndDate, sent
+	// as the date in UTC. A date string set as well takes precedence.
+	StartTime time.Time `json:"-"`
+	EndTime   time.Time `json:"-"`
+}
+
+// statusFilter returns the distinct statuses to filter on, sent as repeated
+// status parameters. The single status is passed through unchecked as it
+// always was; each of the statuses must be known.
+func statusFilter(status string, statuses []TransactionStatus) ([]string, error) {
+	var filter []string
+	seen := make(map[string]bool, len(statuses)+1)
+	add := func(s string) {
+		if !seen[s] {
+			seen[s] = true
+			filter = append(filter, s)
+		}
+	}
+
+	if status != "" {
+		add(status)
+	}
+	for i, s := range statuses {
+		if !s.IsValid() {
+			return nil, validationError(fmt.Sprintf("statuses[%d]", i), ValidationCodeUnsupported, fmt.Sprintf("unknown transaction status %q", s))
+		}
+		add(s.String())
+	}
+	return filter, nil
 }
 
 // ListTransactionsResponse represents a response with multiple transactions
@@ -245,70 +841,113 @@ type ListTransactionsResponse struct {
 	Limit        int                   `json:"limit"`
 	Offset       int                   `json:"offset"`
 	HasMore      bool                  `json:"has_more"`
+	Meta         *ResponseMeta         `json:"-"`
 }
 
 // ListTransactions retrieves a list of transactions with optional filters
 func (ts *TransactionService) ListTransactions(ctx context.Context, req *ListTransactionsRequest) (*ListTransactionsResponse, error) {
+	if req == nil {
+		req = &ListTransactionsRequest{}
+	}
+	statuses, err := statusFilter(req.Status, req.Statuses)
+	if err != nil {
+		return nil, fmt.Errorf("validation failed: %w", err)
+	}
+
 	query := url.Values{}
-	if req != nil {
-		if req.MerchantID != "" {
-			query.Add("merchant_id", req.MerchantID)
-		}
-		if req.Status != "" {
-			query.Add("status", req.Status)
-		}
-		if req.Type != "" {
-			query.Add("type", req.Type)
-		}
-		if req.StartDate != "" {
-			query.Add("start_date", req.StartDate)
-		}
-		if req.EndDate != "" {
-			query.Add("end_date", req.EndDate)
-		}
-		if req.Reference != "" {
-			query.Add("reference", req.Reference)
-		}
-		if req.MinAmount != "" {
-			query.Add("min_amount", req.MinAmount)
-		}
-		if req.MaxAmount != "" {
-			query.Add("max_amount", req.MaxAmount)
-		}
-		if req.Currency != "" {
-			query.Add("currency", req.Currency)
-		}
-		if req.Limit > 0 {
-			query.Add("limit", fmt.Sprintf("%d", req.Limit))
-		}
-		if req.Offset > 0 {
-			query.Add("offset", fmt.Sprintf("%d", req.Offset))
-		}
-		if req.SortBy != "" {
-			query.Add("sort_by", req.SortBy)
-		}
-		if req.SortOrder != "" {
-			query.Add("sort_order", req.SortOrder)
-		}
+	if id := merchantID(ctx, req.MerchantID); id != "" {
+		query.Add("merchant_id", id)
+	}
+	for _, status := range statuses {
+		query.Add("status", status)
+	}
+	if req.Type != "" {
+		query.Add("type", req.Type)
+	}
+	ts.client.addDateRange(query, req.StartDate, req.StartTime, req.EndDate, req.EndTime)
+	if req.Reference != "" {
+		query.Add("reference", req.Reference)
+	}
+	if req.MinAmount != "" {
+		query.Add("min_amount", req.MinAmount)
+	}
+	if req.MaxAmount != "" {
+		query.Add("max_amount", req.MaxAmount)
+	}
+	if req.Currency != "" {
+		query.Add("currency", NormalizeCurrency(req.Currency))
+	}
+	if req.Limit > 0 {
+		query.Add("limit", fmt.Sprintf("%d", req.Limit))
+	}
+	if req.Offset > 0 {
+		query.Add("offset", fmt.Sprintf("%d", req.Offset))
+	}
+	if req.SortBy != "" {
+		query.Add("sort_by", req.SortBy)
+	}
+	if req.SortOrder != "" {
+		query.Add("sort_order", req.SortOrder)
 	}
 
-	resp, err := ts.client.Get(ctx, "/transactions", query)
+	resp, err := ts.get(ctx, "/transactions", query)
 	if err != nil {
 		return nil, fmt.Errorf("failed to list transactions: %w", err)
 	}
-	defer resp.Body.Close()
 
-	body, err := io.ReadAll(resp.Body)
+	var transactions ListTransactionsResponse
+	meta, err := ts.decode(resp, &transactions)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read response: %w", err)
+		return nil, err
 	}
+	transactions.Meta = meta
+	transactions.applyContentRange()
 
-	var transactions ListTransactionsResponse
-	if err := json.Unmarshal(body, &transactions); err != nil {
-		return nil, fmt.Errorf("failed to unmarshal response: %w", err)
+	return &transactions, nil
+}
+
+// applyContentRange fills in the paging fields from the Content-Range of a
+// partial (206) response, which takes precedence over the body
+func (r *ListTransactionsResponse) applyContentRange() {
+	cr := r.Meta.ContentRange
+	if cr == nil {
+		return
+	}
+	r.Offset = cr.Start
+	r.HasMore = cr.HasMore()
+	if cr.Total >= 0 {
+		r.Total = cr.Total
 	}
+}
 
-	return &transactions, nil
+// TransactionIterator iterates over every transaction matching a filter
+type TransactionIterator = Iterator[TransactionResponse]
+
+// IterateTransactions returns an iterator over every transaction matching
+// req, starting at req.Offset and fetching req.Limit transactions per page.
+// Both regular pages and partial (206) responses are followed.
+func (ts *TransactionService) IterateTransactions(req *ListTransactionsRequest) *TransactionIterator {
+	filter := ListTransactionsRequest{}
+	if req != nil {
+		filter = *req
+	}
+
+	return newIterator(filter.Offset, ts.client.maxPages, func(ctx context.Context, offset int) (*page[TransactionResponse], error) {
+		filter.Offset = offset
+		transactions, err := ts.ListTransactions(ctx, &filter)
+		if err != nil {
+			return nil, err
+		}
+		next := offset + len(transactions.Transactions)
+		if cr := transactions.Meta.ContentRange; cr != nil {
+			next = cr.End + 1
+		}
+		return &page[TransactionResponse]{
+			items:      transactions.Transactions,
+			nextOffset: next,
+			hasMore:    transactions.HasMore,
+		}, nil
+	})
 }
 
 // SearchTransactionsRequest represents a search request for transactions
@@ -319,6 +958,11 @@ type SearchTransactionsRequest struct {
 	EndDate     string `json:"end_date,omitempty"`
 	Limit       int    `json:"limit,omitempty"`
 	Offset      int    `json:"offset,omitempty"`
+
+	// StartTime and EndTime are alternatives to StartDate and EndDate, sent
+	// as the date in UTC. A date string set as well takes precedence.
+	StartTime time.Time `json:"-"`
+	EndTime   time.Time `json:"-"`
 }
 
 // SearchTransactions searches for transactions using a query string
@@ -329,15 +973,10 @@ func (ts *TransactionService) SearchTransactions(ctx context.Context, req *Searc
 
 	query := url.Values{}
 	query.Add("q", req.Query)
-	if req.MerchantID != "" {
-		query.Add("merchant_id", req.MerchantID)
-	}
-	if req.StartDate != "" {
-		query.Add("start_date", req.StartDate)
-	}
-	if req.EndDate != "" {
-		query.Add("end_date", req.EndDate)
+	if id := merchantID(ctx, req.MerchantID); id != "" {
+		query.Add("merchant_id", id)
 	}
+	ts.client.addDateRange(query, req.StartDate, req.StartTime, req.EndDate, req.EndTime)
 	if req.Limit > 0 {
 		query.Add("limit", fmt.Sprintf("%d", req.Limit))
 	}
@@ -345,42 +984,87 @@ func (ts *TransactionService) SearchTransactions(ctx context.Context, req *Searc
 		query.Add("offset", fmt.Sprintf("%d", req.Offset))
 	}
 
-	resp, err := ts.client.Get(ctx, "/transactions/search", query)
+	resp, err := ts.get(ctx, "/transactions/search", query)
 	if err != nil {
 		return nil, fmt.Errorf("failed to search transactions: %w", err)
 	}
-	defer resp.Body.Close()
-
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read response: %w", err)
-	}
 
 	var transactions ListTransactionsResponse
-	if err := json.Unmarshal(body, &transactions); err != nil {
-		return nil, fmt.Errorf("failed to unmarshal response: %w", err)
+	meta, err := ts.decode(resp, &transactions)
+	if err != nil {
+		return nil, err
 	}
+	transactions.Meta = meta
 
 	return &transactions, nil
 }
 
-// GetTransactionStatus retrieves the current status of a transaction
-func (ts *TransactionService) GetTransactionStatus(ctx context.Context, transactionID string) (*TransactionResponse, error) {
-	resp, err := ts.client.Get(ctx, fmt.Sprintf("/transactions/%s/status", transactionID), nil)
+// IsExpired reports whether the authorization hold has lapsed, i.e. its
+// ExpiresAt has passed. Transactions without an expiry never expire. It
+// uses the wall clock; use IsExpiredAt with the time of a Config.Clock to
+// agree with the check CaptureTransaction makes.
+func (tr *TransactionResponse) IsExpired() bool {
+	return tr.IsExpiredAt(time.Now())
+}
+
+// IsExpiredAt reports whether the authorization hold had lapsed at now. A
+// hold expires at ExpiresAt itself.
+func (tr *TransactionResponse) IsExpiredAt(now time.Time) bool {
+	return tr.ExpiresAt != nil && !now.Before(*tr.ExpiresAt)
+}
+
+// TransactionStatusResponse is the current state of a transaction, as
+// reported by the lightweight status endpoint
+type TransactionStatusResponse struct {
+	ID          string            `json:"id"`
+	Status      TransactionStatus `json:"status"`
+	ProcessedAt *time.Time        `json:"processed_at,omitempty"`
+	ExpiresAt   *time.Time        `json:"expires_at,omitempty"` // When an uncaptured authorization lapses
+	Meta        *ResponseMeta     `json:"-"`
+}
+
+// GetTransactionStatus retrieves the current status of a transaction. Use
+// GetTransaction for the amounts, card and processor details.
+func (ts *TransactionService) GetTransactionStatus(ctx context.Context, transactionID string) (*TransactionStatusResponse, error) {
+	resp, err := ts.get(ctx, fmt.Sprintf("/transactions/%s/status", transactionID), nil)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get transaction status: %w", err)
 	}
-	defer resp.Body.Close()
 
-	body, err := io.ReadAll(resp.Body)
+	var status TransactionStatusResponse
+	meta, err := ts.decode(resp, &status)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read response: %w", err)
+		return nil, err
 	}
+	status.Meta = meta
 
-	var transaction TransactionResponse
-	if err := json.Unmarshal(body, &transaction); err != nil {
-		return nil, fmt.Errorf("failed to unmarshal response: %w", err)
+	return &status, nil
+}
+
+// TransactionEvent represents an entry in the audit trail of a transaction
+type TransactionEvent struct {
+	Type      string    `json:"type"` // e.g. "authorized", "captured", "partially_refunded", "voided"
+	Amount    float64   `json:"amount"`
+	CreatedAt time.Time `json:"created_at"`
+	Actor     string    `json:"actor"` // API key, user, or system that triggered the event
+}
+
+// GetTransactionEvents retrieves the full event history of a transaction,
+// oldest event first. Use GetTransactionStatus for the current state only.
+func (ts *TransactionService) GetTransactionEvents(ctx context.Context, transactionID string) ([]TransactionEvent, error) {
+	resp, err := ts.get(ctx, fmt.Sprintf("/transactions/%s/events", transactionID), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get transaction events: %w", err)
 	}
 
-	return &transaction, nil
-}
\ No newline at end of file
+	var events []TransactionEvent
+	if _, err := ts.decode(resp, &events); err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(events, func(i, j int) bool {
+		return events[i].CreatedAt.Before(events[j].CreatedAt)
+	})
+
+	return events, nil
+}
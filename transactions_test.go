@@ -1,7 +1,16 @@
 package americanexpress
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 )
 
 func TestTransactionService_AuthorizeTransaction(t *testing.T) {
@@ -101,6 +110,156 @@ func TestTransactionService_AuthorizeTransaction(t *testing.T) {
 			wantErr: true,
 			errMsg:  "capture mode must be 'auto' or 'manual'",
 		},
+		{
+			name: "save card with card details",
+			request: &TransactionRequest{
+				Amount:     100.00,
+				Currency:   "USD",
+				MerchantID: "merchant_123",
+				CardDetails: &CardDetails{
+					Number:      "4111111111111111",
+					ExpiryMonth: 12,
+					ExpiryYear:  2025,
+					CVV:         "123",
+					HolderName:  "John Doe",
+				},
+				SaveCard:   true,
+				CustomerID: "customer_123",
+			},
+			wantErr: false,
+		},
+		{
+			name: "save card with existing token",
+			request: &TransactionRequest{
+				Amount:     100.00,
+				Currency:   "USD",
+				MerchantID: "merchant_123",
+				CardToken:  "token_123",
+				SaveCard:   true,
+				CustomerID: "customer_123",
+			},
+			wantErr: true,
+			errMsg:  "save card requires card details, not an existing card token",
+		},
+		{
+			name: "save card without customer ID",
+			request: &TransactionRequest{
+				Amount:     100.00,
+				Currency:   "USD",
+				MerchantID: "merchant_123",
+				CardDetails: &CardDetails{
+					Number:      "4111111111111111",
+					ExpiryMonth: 12,
+					ExpiryYear:  2025,
+					CVV:         "123",
+					HolderName:  "John Doe",
+				},
+				SaveCard: true,
+			},
+			wantErr: true,
+			errMsg:  "customer ID is required to save a card",
+		},
+		{
+			name: "apple pay wallet payment",
+			request: &TransactionRequest{
+				Amount:     100.00,
+				Currency:   "USD",
+				MerchantID: "merchant_123",
+				WalletPayment: &WalletPayment{
+					Type:    WalletApplePay,
+					Payload: "eyJkYXRhIjoiZW5jcnlwdGVkIn0=",
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "unsupported wallet type",
+			request: &TransactionRequest{
+				Amount:     100.00,
+				Currency:   "USD",
+				MerchantID: "merchant_123",
+				WalletPayment: &WalletPayment{
+					Type:    "samsungpay",
+					Payload: "eyJkYXRhIjoiZW5jcnlwdGVkIn0=",
+				},
+			},
+			wantErr: true,
+			errMsg:  `invalid wallet payment: unsupported wallet type "samsungpay"`,
+		},
+		{
+			name: "empty wallet payload",
+			request: &TransactionRequest{
+				Amount:     100.00,
+				Currency:   "USD",
+				MerchantID: "merchant_123",
+				WalletPayment: &WalletPayment{
+					Type: WalletGooglePay,
+				},
+			},
+			wantErr: true,
+			errMsg:  "invalid wallet payment: wallet payload cannot be empty",
+		},
+		{
+			name: "wallet payment with card token",
+			request: &TransactionRequest{
+				Amount:     100.00,
+				Currency:   "USD",
+				MerchantID: "merchant_123",
+				CardToken:  "token_123",
+				WalletPayment: &WalletPayment{
+					Type:    WalletGooglePay,
+					Payload: "eyJkYXRhIjoiZW5jcnlwdGVkIn0=",
+				},
+			},
+			wantErr: true,
+			errMsg:  "wallet payment cannot be combined with another payment method",
+		},
+		{
+			name: "known MCC",
+			request: &TransactionRequest{
+				Amount:     100.00,
+				Currency:   "USD",
+				MerchantID: "merchant_123",
+				CardToken:  "token_123",
+				MCC:        "5812",
+			},
+			wantErr: false,
+		},
+		{
+			name: "hotel chain MCC",
+			request: &TransactionRequest{
+				Amount:     100.00,
+				Currency:   "USD",
+				MerchantID: "merchant_123",
+				CardToken:  "token_123",
+				MCC:        "3509",
+			},
+			wantErr: false,
+		},
+		{
+			name: "malformed MCC",
+			request: &TransactionRequest{
+				Amount:     100.00,
+				Currency:   "USD",
+				MerchantID: "merchant_123",
+				CardToken:  "token_123",
+				MCC:        "58a2",
+			},
+			wantErr: true,
+			errMsg:  "MCC must be a 4-digit code",
+		},
+		{
+			name: "unknown MCC",
+			request: &TransactionRequest{
+				Amount:     100.00,
+				Currency:   "USD",
+				MerchantID: "merchant_123",
+				CardToken:  "token_123",
+				MCC:        "0001",
+			},
+			wantErr: true,
+			errMsg:  "MCC 0001 is not a known merchant category code",
+		},
 	}
 
 	for _, tt := range tests {
@@ -225,6 +384,156 @@ func TestTransactionService_ListTransactionsRequest(t *testing.T) {
 	}
 }
 
+func TestTransactionService_ListTransactionsTimeRange(t *testing.T) {
+	var query map[string][]string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query = r.URL.Query()
+		fmt.Fprint(w, `{"transactions":[],"disputes":[],"settlements":[]}`)
+	}))
+	defer server.Close()
+
+	logger := &recordingLogger{}
+	sdk := NewSDK(&Config{BaseURL: server.URL, Logger: logger})
+	ctx := context.Background()
+
+	newYork := time.FixedZone("EST", -5*60*60)
+	tokyo := time.FixedZone("JST", 9*60*60)
+	// Late on January 31st in New York is February 1st in UTC, and early on
+	// February 1st in Tokyo is still January 31st
+	start := time.Date(2024, 1, 31, 22, 0, 0, 0, newYork)
+	end := time.Date(2024, 2, 1, 5, 0, 0, 0, tokyo)
+
+	tests := []struct {
+		name      string
+		list      func() error
+		wantStart string
+		wantEnd   string
+	}{
+		{
+			name: "times are converted to UTC dates",
+			list: func() error {
+				_, err := sdk.Transactions.ListTransactions(ctx, &ListTransactionsRequest{StartTime: start, EndTime: end})
+				return err
+			},
+			wantStart: "2024-02-01",
+			wantEnd:   "2024-01-31",
+		},
+		{
+			name: "date strings win over times",
+			list: func() error {
+				_, err := sdk.Transactions.SearchTransactions(ctx, &SearchTransactionsRequest{Query: "acme", StartDate: "2024-01-01", StartTime: start, EndTime: end})
+				return err
+			},
+			wantStart: "2024-01-01",
+			wantEnd:   "2024-01-31",
+		},
+		{
+			name: "disputes",
+			list: func() error {
+				_, err := sdk.Disputes.ListDisputes(ctx, &ListDisputesRequest{StartTime: start, EndDate: "2024-03-01"})
+				return err
+			},
+			wantStart: "2024-02-01",
+			wantEnd:   "2024-03-01",
+		},
+		{
+			name: "settlements",
+			list: func() error {
+				_, err := sdk.Merchant.ListSettlements(ctx, &ListSettlementsRequest{MerchantID: "merchant_123", StartTime: start})
+				return err
+			},
+			wantStart: "2024-02-01",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.list(); err != nil {
+				t.Fatalf("list error = %v", err)
+			}
+			if got := strings.Join(query["start_date"], ","); got != tt.wantStart {
+				t.Errorf("Expected start_date %q, got %q", tt.wantStart, got)
+			}
+			if got := strings.Join(query["end_date"], ","); got != tt.wantEnd {
+				t.Errorf("Expected end_date %q, got %q", tt.wantEnd, got)
+			}
+		})
+	}
+
+	// Only the filter set both ways is warned about
+	if len(logger.messages) != 1 {
+		t.Errorf("Expected one warning for the conflicting filter, got %v", logger.messages)
+	}
+}
+
+func TestTransactionService_ListTransactionsStatuses(t *testing.T) {
+	var gotStatuses []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotStatuses = r.URL.Query()["status"]
+		fmt.Fprint(w, `{"transactions":[]}`)
+	}))
+	defer server.Close()
+
+	sdk := NewSDK(&Config{BaseURL: server.URL})
+	ctx := context.Background()
+
+	tests := []struct {
+		name    string
+		request *ListTransactionsRequest
+		want    []string
+		wantErr string
+	}{
+		{
+			name:    "single status",
+			request: &ListTransactionsRequest{Status: "authorized"},
+			want:    []string{"authorized"},
+		},
+		{
+			name:    "multiple statuses",
+			request: &ListTransactionsRequest{Statuses: []TransactionStatus{TransactionStatusAuthorized, TransactionStatusCaptured}},
+			want:    []string{"authorized", "captured"},
+		},
+		{
+			name: "status and statuses are combined without duplicates",
+			request: &ListTransactionsRequest{
+				Status:   "captured",
+				Statuses: []TransactionStatus{TransactionStatusAuthorized, TransactionStatusCaptured, TransactionStatusAuthorized},
+			},
+			want: []string{"captured", "authorized"},
+		},
+		{
+			name:    "no status",
+			request: &ListTransactionsRequest{},
+			want:    nil,
+		},
+		{
+			name:    "unknown status",
+			request: &ListTransactionsRequest{Statuses: []TransactionStatus{TransactionStatusCaptured, "shipped"}},
+			wantErr: "statuses[1]",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotStatuses = nil
+			_, err := sdk.Transactions.ListTransactions(ctx, tt.request)
+			if tt.wantErr != "" {
+				var vErr *ValidationError
+				if !errors.As(err, &vErr) || vErr.Field != tt.wantErr || vErr.Code != ValidationCodeUnsupported {
+					t.Errorf("Expected unsupported %s error, got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ListTransactions() error = %v", err)
+			}
+			if strings.Join(gotStatuses, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("Expected status params %v, got %v", tt.want, gotStatuses)
+			}
+		})
+	}
+}
+
 func TestTransactionService_SearchTransactionsRequest(t *testing.T) {
 	tests := []struct {
 		name    string
@@ -296,4 +605,1961 @@ func TestSDKIntegration(t *testing.T) {
 	if sdk.Transactions.client != sdk.Client {
 		t.Error("Transactions service should use the same client as SDK")
 	}
-}
\ No newline at end of file
+}
+
+func TestTransactionService_RefundTransactionDedupe(t *testing.T) {
+	status := http.StatusOK
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+		fmt.Fprint(w, `{"id":"refund_123","status":"pending"}`)
+	}))
+	defer server.Close()
+
+	ctx := context.Background()
+	req := &RefundTransactionRequest{Amount: 25.00, Reference: "refund_ref_123"}
+
+	sdk := NewSDK(&Config{BaseURL: server.URL})
+	if _, err := sdk.Transactions.RefundTransaction(ctx, "txn_123", req); err != nil {
+		t.Fatalf("RefundTransaction() error = %v", err)
+	}
+
+	_, err := sdk.Transactions.RefundTransaction(ctx, "txn_123", req)
+	if !errors.Is(err, ErrDuplicateRefund) {
+		t.Errorf("Expected ErrDuplicateRefund, got %v", err)
+	}
+
+	// The same reference on another transaction is a different refund
+	if _, err := sdk.Transactions.RefundTransaction(ctx, "txn_456", req); err != nil {
+		t.Errorf("RefundTransaction() on another transaction error = %v", err)
+	}
+
+	// A refund the gateway rejected may be retried
+	status = http.StatusBadRequest
+	rejected := &RefundTransactionRequest{Amount: 25.00, Reference: "refund_ref_456"}
+	if _, err := sdk.Transactions.RefundTransaction(ctx, "txn_123", rejected); err == nil {
+		t.Fatal("Expected rejected refund to return an error")
+	}
+	status = http.StatusOK
+	if _, err := sdk.Transactions.RefundTransaction(ctx, "txn_123", rejected); err != nil {
+		t.Errorf("Expected retry of rejected refund to succeed, got %v", err)
+	}
+
+	// Deduplication can be disabled
+	sdk = NewSDK(&Config{BaseURL: server.URL, RefundDedupeWindow: -1})
+	for i := 0; i < 2; i++ {
+		if _, err := sdk.Transactions.RefundTransaction(ctx, "txn_123", req); err != nil {
+			t.Errorf("RefundTransaction() with dedupe disabled error = %v", err)
+		}
+	}
+}
+
+func TestTransactionService_ReasonCodes(t *testing.T) {
+	var bodies []map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]interface{}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("Failed to decode request body: %v", err)
+		}
+		bodies = append(bodies, body)
+		fmt.Fprint(w, `{"id":"txn_123","status":"refunded"}`)
+	}))
+	defer server.Close()
+
+	ctx := context.Background()
+	sdk := NewSDK(&Config{BaseURL: server.URL})
+
+	_, err := sdk.Transactions.RefundTransaction(ctx, "txn_123", &RefundTransactionRequest{
+		Amount:     25.00,
+		Currency:   "USD",
+		ReasonCode: RefundReasonProductReturned,
+		Reason:     "Arrived damaged",
+	})
+	if err != nil {
+		t.Fatalf("RefundTransaction() error = %v", err)
+	}
+	_, err = sdk.Transactions.VoidTransaction(ctx, "txn_123", &VoidTransactionRequest{
+		ReasonCode: VoidReasonDuplicate,
+		Reason:     "Submitted twice",
+	})
+	if err != nil {
+		t.Fatalf("VoidTransaction() error = %v", err)
+	}
+
+	want := []struct{ code, reason string }{
+		{"product_returned", "Arrived damaged"},
+		{"duplicate", "Submitted twice"},
+	}
+	if len(bodies) != len(want) {
+		t.Fatalf("Expected %d requests, got %d", len(want), len(bodies))
+	}
+	for i, w := range want {
+		if bodies[i]["reason_code"] != w.code {
+			t.Errorf("Expected reason_code %q, got %v", w.code, bodies[i]["reason_code"])
+		}
+		if bodies[i]["reason"] != w.reason {
+			t.Errorf("Expected reason %q, got %v", w.reason, bodies[i]["reason"])
+		}
+	}
+
+	// Unknown codes are rejected before anything is sent
+	_, err = sdk.Transactions.RefundTransaction(ctx, "txn_123", &RefundTransactionRequest{
+		Amount:     25.00,
+		Currency:   "USD",
+		ReasonCode: "changed_mind",
+	})
+	var vErr *ValidationError
+	if !errors.As(err, &vErr) || vErr.Field != "reason_code" || vErr.Code != ValidationCodeUnsupported {
+		t.Errorf("Expected unsupported reason_code error, got %v", err)
+	}
+	_, err = sdk.Transactions.VoidTransaction(ctx, "txn_123", &VoidTransactionRequest{ReasonCode: "oops"})
+	if !errors.As(err, &vErr) || vErr.Field != "reason_code" {
+		t.Errorf("Expected reason_code error, got %v", err)
+	}
+	if len(bodies) != len(want) {
+		t.Errorf("Expected rejected requests not to be sent, got %d requests", len(bodies))
+	}
+}
+
+func TestTransactionService_CaptureIfBelowRisk(t *testing.T) {
+	tests := []struct {
+		name       string
+		fraudScore string
+		wantPath   string
+		wantStatus TransactionStatus
+		wantErr    error
+	}{
+		{"low risk is captured", `,"fraud_score":12.5`, "/transactions/txn_123/capture", TransactionStatusCaptured, nil},
+		{"threshold is captured", `,"fraud_score":70`, "/transactions/txn_123/capture", TransactionStatusCaptured, nil},
+		{"high risk is voided", `,"fraud_score":88`, "/transactions/txn_123/void", TransactionStatusVoided, nil},
+		{"no score", "", "", "", ErrFraudScoreUnavailable},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var posted string
+			var body map[string]interface{}
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				switch r.Method {
+				case http.MethodGet:
+					fmt.Fprintf(w, `{"id":"txn_123","status":"authorized","amount":100,"currency":"USD"%s}`, tt.fraudScore)
+				case http.MethodPost:
+					posted = r.URL.Path
+					if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+						t.Errorf("Failed to decode request body: %v", err)
+					}
+					status := TransactionStatusCaptured
+					if strings.HasSuffix(r.URL.Path, "/void") {
+						status = TransactionStatusVoided
+					}
+					fmt.Fprintf(w, `{"id":"txn_123","status":"%s"}`, status)
+				}
+			}))
+			defer server.Close()
+
+			sdk := NewSDK(&Config{BaseURL: server.URL})
+			amount := 80.0
+			transaction, err := sdk.Transactions.CaptureIfBelowRisk(context.Background(), "txn_123", 70, &amount)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Errorf("Expected %v, got %v", tt.wantErr, err)
+				}
+				if posted != "" {
+					t.Errorf("Expected no capture or void, got POST %s", posted)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("CaptureIfBelowRisk() error = %v", err)
+			}
+			if posted != tt.wantPath {
+				t.Errorf("Expected POST %s, got %s", tt.wantPath, posted)
+			}
+			if transaction.Status != tt.wantStatus {
+				t.Errorf("Expected status %s, got %s", tt.wantStatus, transaction.Status)
+			}
+			if tt.wantStatus == TransactionStatusVoided && body["reason_code"] != "fraud" {
+				t.Errorf("Expected the void to carry reason_code fraud, got %v", body["reason_code"])
+			}
+			if tt.wantStatus == TransactionStatusCaptured && body["amount"] != 80.0 {
+				t.Errorf("Expected capture amount 80, got %v", body["amount"])
+			}
+		})
+	}
+}
+
+func TestTransactionService_GetTransactionCache(t *testing.T) {
+	requests := map[string]int{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := strings.TrimPrefix(r.URL.Path, "/transactions/")
+		requests[id]++
+		status := map[string]string{"txn_voided": "voided", "txn_authorized": "authorized", "txn_captured": "captured"}[id]
+		fmt.Fprintf(w, `{"id":%q,"status":%q,"metadata":{"order":"1"},"remaining_authorized_amount":10,
+			"line_items":[{"sku":"MUG","quantity":1,"amount":10}],"dispute":{"id":"dsp_1","status":"open"},
+			"captures":[{"id":"cap_1","metadata":{"order":"1"},"line_items":[{"sku":"MUG","quantity":1,"amount":10}]}]}`, id, status)
+	}))
+	defer server.Close()
+
+	ctx := context.Background()
+	clock := newFakeClock()
+	sdk := NewSDK(&Config{
+		BaseURL:             server.URL,
+		Clock:               clock,
+		Cache:               NewMemoryCacheWithClock(clock),
+		TransactionCacheTTL: time.Minute,
+	})
+
+	for i := 0; i < 2; i++ {
+		for _, id := range []string{"txn_voided", "txn_authorized", "txn_captured"} {
+			transaction, err := sdk.Transactions.GetTransaction(ctx, id)
+			if err != nil {
+				t.Fatalf("GetTransaction(%s) error = %v", id, err)
+			}
+			// Changing a returned value must not change the cached one
+			transaction.Metadata["order"] = "changed"
+			*transaction.RemainingAmount = 0
+			transaction.LineItems[0].SKU = "changed"
+			transaction.Dispute.Status = "changed"
+			transaction.Captures[0].Metadata["order"] = "changed"
+			transaction.Captures[0].LineItems[0].SKU = "changed"
+			transaction.Meta.Header.Set("X-Changed", "1")
+		}
+	}
+
+	// Only the voided transaction is final; in-flight and captured ones are
+	// fetched every time
+	if requests["txn_voided"] != 1 || requests["txn_authorized"] != 2 || requests["txn_captured"] != 2 {
+		t.Errorf("Unexpected requests per transaction: %v", requests)
+	}
+
+	cached, err := sdk.Transactions.GetTransaction(ctx, "txn_voided")
+	if err != nil {
+		t.Fatalf("GetTransaction() error = %v", err)
+	}
+	if cached.Metadata["order"] != "1" || *cached.RemainingAmount != 10 || cached.LineItems[0].SKU != "MUG" || cached.Dispute.Status != "open" {
+		t.Errorf("Expected the cached transaction to be unchanged, got %+v", cached)
+	}
+	if cached.Captures[0].Metadata["order"] != "1" || cached.Captures[0].LineItems[0].SKU != "MUG" {
+		t.Errorf("Expected the cached captures to be unchanged, got %+v", cached.Captures)
+	}
+	if cached.Meta.Header.Get("X-Changed") != "" {
+		t.Error("Expected the cached response metadata to be unchanged")
+	}
+
+	clock.Advance(time.Minute)
+	if _, err := sdk.Transactions.GetTransaction(ctx, "txn_voided"); err != nil {
+		t.Fatalf("GetTransaction() error = %v", err)
+	}
+	if requests["txn_voided"] != 2 {
+		t.Errorf("Expected the transaction to be fetched again after the TTL, got %d requests", requests["txn_voided"])
+	}
+
+	// Caching is opt-in even when the client has a cache
+	requests = map[string]int{}
+	uncached := NewSDK(&Config{BaseURL: server.URL, Cache: NewMemoryCache()})
+	for i := 0; i < 2; i++ {
+		if _, err := uncached.Transactions.GetTransaction(ctx, "txn_voided"); err != nil {
+			t.Fatalf("GetTransaction() error = %v", err)
+		}
+	}
+	if requests["txn_voided"] != 2 {
+		t.Errorf("Expected 2 requests without TransactionCacheTTL, got %d", requests["txn_voided"])
+	}
+}
+
+func TestTransactionService_AuthorizeTransactionNormalizesCard(t *testing.T) {
+	var sent TransactionRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewDecoder(r.Body).Decode(&sent); err != nil {
+			t.Errorf("Failed to decode request body: %v", err)
+		}
+		fmt.Fprint(w, `{"id":"txn_123","status":"authorized"}`)
+	}))
+	defer server.Close()
+
+	req := &TransactionRequest{
+		Amount:     100.00,
+		Currency:   "USD",
+		MerchantID: "merchant_123",
+		CardDetails: &CardDetails{
+			Number:      "4111-1111-1111-1111",
+			ExpiryMonth: 12,
+			ExpiryYear:  2025,
+			CVV:         "123",
+			HolderName:  "John Doe",
+		},
+	}
+
+	sdk := NewSDK(&Config{BaseURL: server.URL})
+	if _, err := sdk.Transactions.AuthorizeTransaction(context.Background(), req); err != nil {
+		t.Fatalf("AuthorizeTransaction() error = %v", err)
+	}
+
+	if sent.CardDetails.Number != "4111111111111111" {
+		t.Errorf("Expected normalized card number to be sent, got '%s'", sent.CardDetails.Number)
+	}
+	if sent.CardDetails.HolderName != "JOHN DOE" {
+		t.Errorf("Expected normalized holder name to be sent, got '%s'", sent.CardDetails.HolderName)
+	}
+	if req.CardDetails.Number != "4111-1111-1111-1111" {
+		t.Errorf("Expected caller's card details to be unchanged, got '%s'", req.CardDetails.Number)
+	}
+}
+
+func TestTransactionService_GetTransactionWithExpand(t *testing.T) {
+	var gotExpand string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotExpand = r.URL.Query().Get("expand")
+		if gotExpand == "" {
+			fmt.Fprint(w, `{"id":"txn_123"}`)
+			return
+		}
+		fmt.Fprint(w, `{"id":"txn_123","refunds":[{"id":"refund_1"}],"captures":[{"id":"capture_1","amount":50}]}`)
+	}))
+	defer server.Close()
+
+	sdk := NewSDK(&Config{BaseURL: server.URL})
+	ctx := context.Background()
+
+	transaction, err := sdk.Transactions.GetTransactionWithExpand(ctx, "txn_123", []string{ExpandRefunds, ExpandCaptures})
+	if err != nil {
+		t.Fatalf("GetTransactionWithExpand() error = %v", err)
+	}
+	if gotExpand != "refunds,captures" {
+		t.Errorf("Expected expand query to be 'refunds,captures', got '%s'", gotExpand)
+	}
+	if len(transaction.Refunds) != 1 || len(transaction.Captures) != 1 {
+		t.Errorf("Expected one refund and one capture, got %d and %d", len(transaction.Refunds), len(transaction.Captures))
+	}
+
+	transaction, err = sdk.Transactions.GetTransaction(ctx, "txn_123")
+	if err != nil {
+		t.Fatalf("GetTransaction() error = %v", err)
+	}
+	if transaction.Refunds != nil || transaction.Captures != nil || transaction.Dispute != nil {
+		t.Error("Expected related resources to be nil when not requested")
+	}
+
+	if _, err := sdk.Transactions.GetTransactionWithExpand(ctx, "txn_123", []string{"customer"}); err == nil {
+		t.Error("Expected unsupported expand value to return an error")
+	}
+}
+
+func TestTransactionService_DefaultMetadata(t *testing.T) {
+	var sent struct {
+		Metadata map[string]string `json:"metadata"`
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sent.Metadata = nil
+		if err := json.NewDecoder(r.Body).Decode(&sent); err != nil {
+			t.Errorf("Failed to decode request body: %v", err)
+		}
+		fmt.Fprint(w, `{"id":"txn_123"}`)
+	}))
+	defer server.Close()
+
+	sdk := NewSDK(&Config{
+		BaseURL:         server.URL,
+		DefaultMetadata: map[string]string{"service": "checkout", "env": "prod"},
+	})
+	ctx := context.Background()
+
+	req := &TransactionRequest{
+		Amount:     100.00,
+		Currency:   "USD",
+		MerchantID: "merchant_123",
+		CardToken:  "token_123",
+		Metadata:   map[string]string{"env": "staging"},
+	}
+	if _, err := sdk.Transactions.AuthorizeTransaction(ctx, req); err != nil {
+		t.Fatalf("AuthorizeTransaction() error = %v", err)
+	}
+	if sent.Metadata["service"] != "checkout" || sent.Metadata["env"] != "staging" {
+		t.Errorf("Expected merged metadata with request precedence, got %v", sent.Metadata)
+	}
+	if len(req.Metadata) != 1 {
+		t.Errorf("Expected caller's metadata to be unchanged, got %v", req.Metadata)
+	}
+
+	if _, err := sdk.Transactions.CaptureTransaction(ctx, "txn_123", nil); err != nil {
+		t.Fatalf("CaptureTransaction() error = %v", err)
+	}
+	if sent.Metadata["service"] != "checkout" || sent.Metadata["env"] != "prod" {
+		t.Errorf("Expected default metadata on capture, got %v", sent.Metadata)
+	}
+
+	if _, err := sdk.Transactions.RefundTransaction(ctx, "txn_123", &RefundTransactionRequest{Amount: 10.00}); err != nil {
+		t.Fatalf("RefundTransaction() error = %v", err)
+	}
+	if sent.Metadata["service"] != "checkout" {
+		t.Errorf("Expected default metadata on refund, got %v", sent.Metadata)
+	}
+}
+
+func TestTransactionService_ReverseAuthorization(t *testing.T) {
+	var reversals int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/transactions/txn_123":
+			fmt.Fprint(w, `{"id":"txn_123","amount":100,"remaining_authorized_amount":40}`)
+		case "/transactions/txn_123/reverse":
+			reversals++
+			fmt.Fprint(w, `{"id":"txn_123","amount":100,"remaining_authorized_amount":10}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	sdk := NewSDK(&Config{BaseURL: server.URL})
+	ctx := context.Background()
+
+	tests := []struct {
+		name    string
+		amount  *float64
+		wantErr bool
+	}{
+		{"full reversal", nil, false},
+		{"partial reversal", &[]float64{30.00}[0], false},
+		{"exactly remaining amount", &[]float64{40.00}[0], false},
+		{"exceeds remaining amount", &[]float64{50.00}[0], true},
+		{"zero amount", &[]float64{0}[0], true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			before := reversals
+			transaction, err := sdk.Transactions.ReverseAuthorization(ctx, "txn_123", &ReversalRequest{Amount: tt.amount})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ReverseAuthorization() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				var validationErr *ValidationError
+				if !errors.Is(err, ErrInvalidAmount) || !errors.As(err, &validationErr) || validationErr.Field != "amount" {
+					t.Errorf("Expected an ErrInvalidAmount validation error for amount, got %v", err)
+				}
+				if reversals != before {
+					t.Error("Expected invalid reversal not to reach the gateway")
+				}
+				return
+			}
+			if transaction.RemainingAmount == nil || *transaction.RemainingAmount != 10 {
+				t.Errorf("Expected remaining authorized amount to be 10, got %v", transaction.RemainingAmount)
+			}
+		})
+	}
+}
+
+func TestTransactionService_AmountPrecision(t *testing.T) {
+	var lookups, captures, refunds int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/transactions/txn_jpy":
+			lookups++
+			fmt.Fprint(w, `{"id":"txn_jpy","amount":5000,"currency":"JPY"}`)
+		case "/transactions/txn_jpy/capture", "/transactions/txn_usd/capture":
+			captures++
+			fmt.Fprint(w, `{"id":"txn"}`)
+		case "/transactions/txn_jpy/refund", "/transactions/txn_usd/refund":
+			refunds++
+			fmt.Fprint(w, `{"id":"refund_123"}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	sdk := NewSDK(&Config{BaseURL: server.URL})
+	ctx := context.Background()
+	amount := func(v float64) *float64 { return &v }
+
+	// The currency is looked up when the amount has decimals
+	_, err := sdk.Transactions.CaptureTransaction(ctx, "txn_jpy", &CaptureTransactionRequest{Amount: amount(100.5)})
+	if !errors.Is(err, ErrInvalidAmount) {
+		t.Errorf("Expected ErrInvalidAmount for fractional JPY capture, got %v", err)
+	}
+	if lookups != 1 || captures != 0 {
+		t.Errorf("Expected one lookup and no capture, got %d lookups and %d captures", lookups, captures)
+	}
+
+	// Whole amounts need no lookup
+	if _, err := sdk.Transactions.CaptureTransaction(ctx, "txn_jpy", &CaptureTransactionRequest{Amount: amount(100)}); err != nil {
+		t.Errorf("CaptureTransaction() error = %v", err)
+	}
+	if lookups != 1 || captures != 1 {
+		t.Errorf("Expected no further lookup and one capture, got %d lookups and %d captures", lookups, captures)
+	}
+
+	_, err = sdk.Transactions.RefundTransaction(ctx, "txn_usd", &RefundTransactionRequest{Amount: 10.999, Currency: "USD"})
+	if !errors.Is(err, ErrInvalidAmount) {
+		t.Errorf("Expected ErrInvalidAmount for 3-decimal USD refund, got %v", err)
+	}
+	if _, err := sdk.Transactions.RefundTransaction(ctx, "txn_usd", &RefundTransactionRequest{Amount: 10.99, Currency: "USD"}); err != nil {
+		t.Errorf("RefundTransaction() error = %v", err)
+	}
+	if lookups != 1 || refunds != 1 {
+		t.Errorf("Expected no lookup with an explicit currency and one refund, got %d lookups and %d refunds", lookups, refunds)
+	}
+}
+
+func TestTransactionService_IterateTransactions(t *testing.T) {
+	var offsets []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		offset := r.URL.Query().Get("offset")
+		offsets = append(offsets, offset)
+		switch offset {
+		case "":
+			fmt.Fprint(w, `{"transactions":[{"id":"txn_1"},{"id":"txn_2"}],"total":5,"has_more":true}`)
+		case "2":
+			// Large exports are streamed as partial responses
+			w.Header().Set("Content-Range", "items 2-3/5")
+			w.WriteHeader(http.StatusPartialContent)
+			fmt.Fprint(w, `{"transactions":[{"id":"txn_3"},{"id":"txn_4"}]}`)
+		case "4":
+			w.Header().Set("Content-Range", "items 4-4/5")
+			w.WriteHeader(http.StatusPartialContent)
+			fmt.Fprint(w, `{"transactions":[{"id":"txn_5"}]}`)
+		default:
+			t.Errorf("Unexpected offset %s", offset)
+			fmt.Fprint(w, `{"transactions":[]}`)
+		}
+	}))
+	defer server.Close()
+
+	sdk := NewSDK(&Config{BaseURL: server.URL})
+	ctx := context.Background()
+
+	it := sdk.Transactions.IterateTransactions(&ListTransactionsRequest{Limit: 2})
+	var ids []string
+	for it.Next(ctx) {
+		ids = append(ids, it.Item().ID)
+	}
+	if err := it.Err(); err != nil {
+		t.Fatalf("Iterator error = %v", err)
+	}
+
+	want := []string{"txn_1", "txn_2", "txn_3", "txn_4", "txn_5"}
+	if fmt.Sprint(ids) != fmt.Sprint(want) {
+		t.Errorf("Expected transactions %v, got %v", want, ids)
+	}
+	if len(offsets) != 3 {
+		t.Errorf("Expected 3 page requests, got %v", offsets)
+	}
+
+	page, err := sdk.Transactions.ListTransactions(ctx, &ListTransactionsRequest{Limit: 2, Offset: 2})
+	if err != nil {
+		t.Fatalf("ListTransactions() error = %v", err)
+	}
+	if page.Meta.ContentRange == nil || !page.HasMore || page.Total != 5 || page.Offset != 2 {
+		t.Errorf("Expected partial page at offset 2 of 5 with more to come, got %+v", page)
+	}
+}
+
+func TestIteratorLoopGuards(t *testing.T) {
+	var requests int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		switch r.URL.Path {
+		case "/transactions":
+			// A stuck server that keeps returning the same range
+			w.Header().Set("Content-Range", "items 0-1/*")
+			w.WriteHeader(http.StatusPartialContent)
+			fmt.Fprint(w, `{"transactions":[{"id":"txn_1"},{"id":"txn_2"}],"has_more":true}`)
+		case "/tokens":
+			// A server that always claims more tokens
+			fmt.Fprint(w, `{"tokens":[{"id":"tok_1"}],"has_more":true}`)
+		case "/merchants/merchant_123/settlements":
+			fmt.Fprint(w, `{"settlements":[{"id":"stl_1"}],"has_more":true}`)
+		}
+	}))
+	defer server.Close()
+
+	ctx := context.Background()
+	sdk := NewSDK(&Config{BaseURL: server.URL, MaxPages: 3})
+
+	// count drains an iterator, giving up on one that does not stop
+	count := func(next func(context.Context) bool) int {
+		items := 0
+		for next(ctx) && items <= 100 {
+			items++
+		}
+		return items
+	}
+
+	tests := []struct {
+		name      string
+		iterate   func() (int, error)
+		wantErr   error
+		wantItems int
+		wantPages int
+	}{
+		{
+			name: "stalled transactions",
+			iterate: func() (int, error) {
+				it := sdk.Transactions.IterateTransactions(&ListTransactionsRequest{Limit: 2})
+				return count(it.Next), it.Err()
+			},
+			wantErr:   ErrPaginationStalled,
+			wantItems: 2,
+			wantPages: 2,
+		},
+		{
+			name: "endless tokens",
+			iterate: func() (int, error) {
+				it := sdk.Tokens.IterateTokens(&ListTokensRequest{Limit: 1})
+				return count(it.Next), it.Err()
+			},
+			wantErr:   ErrTooManyPages,
+			wantItems: 3,
+			wantPages: 3,
+		},
+		{
+			name: "endless settlements",
+			iterate: func() (int, error) {
+				it := sdk.Merchant.IterateSettlements(&ListSettlementsRequest{MerchantID: "merchant_123", Limit: 1})
+				return count(it.Next), it.Err()
+			},
+			wantErr:   ErrTooManyPages,
+			wantItems: 3,
+			wantPages: 3,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			requests = 0
+			items, err := tt.iterate()
+
+			var pageErr *PaginationError
+			if !errors.Is(err, tt.wantErr) || !errors.As(err, &pageErr) {
+				t.Fatalf("Expected *PaginationError wrapping %v, got %v", tt.wantErr, err)
+			}
+			if pageErr.Pages != tt.wantPages || requests != tt.wantPages {
+				t.Errorf("Expected %d pages, got %d (%d requests)", tt.wantPages, pageErr.Pages, requests)
+			}
+			if items != tt.wantItems {
+				t.Errorf("Expected %d items, got %d", tt.wantItems, items)
+			}
+		})
+	}
+}
+
+func TestTransactionService_CaptureMode(t *testing.T) {
+	var sentMode string
+	var reversals int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/transactions/authorize":
+			var sent TransactionRequest
+			if err := json.NewDecoder(r.Body).Decode(&sent); err != nil {
+				t.Errorf("Failed to decode request body: %v", err)
+			}
+			sentMode = sent.CaptureMode
+			fmt.Fprint(w, `{"id":"txn_123"}`)
+		case "/transactions/txn_sale":
+			fmt.Fprint(w, `{"id":"txn_sale","amount":100,"capture_mode":"auto"}`)
+		case "/transactions/txn_sale/reverse":
+			reversals++
+			fmt.Fprint(w, `{"id":"txn_sale"}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	sdk := NewSDK(&Config{BaseURL: server.URL})
+	ctx := context.Background()
+
+	tests := []struct {
+		name string
+		mode string
+		want string
+	}{
+		{"empty defaults to manual", "", CaptureModeManual},
+		{"explicit manual", CaptureModeManual, CaptureModeManual},
+		{"explicit auto", CaptureModeAuto, CaptureModeAuto},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := &TransactionRequest{
+				Amount:      100.00,
+				Currency:    "USD",
+				MerchantID:  "merchant_123",
+				CardToken:   "token_123",
+				CaptureMode: tt.mode,
+			}
+			if _, err := sdk.Transactions.AuthorizeTransaction(ctx, req); err != nil {
+				t.Fatalf("AuthorizeTransaction() error = %v", err)
+			}
+			if sentMode != tt.want {
+				t.Errorf("Expected capture mode '%s' on the wire, got '%s'", tt.want, sentMode)
+			}
+			if req.CaptureMode != tt.mode {
+				t.Errorf("Expected caller's capture mode to be unchanged, got '%s'", req.CaptureMode)
+			}
+		})
+	}
+
+	for _, req := range []*ReversalRequest{{Amount: &[]float64{10.00}[0]}, nil} {
+		_, err := sdk.Transactions.ReverseAuthorization(ctx, "txn_sale", req)
+		var validationErr *ValidationError
+		if !errors.As(err, &validationErr) || validationErr.Field != "capture_mode" || validationErr.Code != ValidationCodeConflict {
+			t.Errorf("Expected a capture_mode conflict reversing an auto-captured transaction, got %v", err)
+		}
+	}
+	if reversals != 0 {
+		t.Error("Expected the reversal not to reach the gateway")
+	}
+}
+
+func TestTransactionStatus(t *testing.T) {
+	tests := []struct {
+		status   TransactionStatus
+		terminal bool
+		success  bool
+	}{
+		{TransactionStatusPending, false, false},
+		{TransactionStatusAuthorized, false, true},
+		{TransactionStatusCaptured, false, true},
+		{TransactionStatusSettled, false, true},
+		{TransactionStatusPartiallyRefunded, false, false},
+		{TransactionStatusRefunded, true, false},
+		{TransactionStatusVoided, true, false},
+		{TransactionStatusDeclined, true, false},
+		{"on_hold", false, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.status.String(), func(t *testing.T) {
+			if tt.status.IsTerminal() != tt.terminal {
+				t.Errorf("Expected IsTerminal() to be %v", tt.terminal)
+			}
+			if tt.status.IsSuccess() != tt.success {
+				t.Errorf("Expected IsSuccess() to be %v", tt.success)
+			}
+		})
+	}
+
+	// Known and unknown statuses keep their wire format
+	for _, raw := range []string{`{"status":"captured"}`, `{"status":"on_hold"}`} {
+		var transaction TransactionResponse
+		if err := json.Unmarshal([]byte(raw), &transaction); err != nil {
+			t.Fatalf("Failed to unmarshal %s: %v", raw, err)
+		}
+		encoded, err := json.Marshal(struct {
+			Status TransactionStatus `json:"status"`
+		}{transaction.Status})
+		if err != nil {
+			t.Fatalf("Failed to marshal status: %v", err)
+		}
+		if string(encoded) != raw {
+			t.Errorf("Expected %s after round trip, got %s", raw, encoded)
+		}
+	}
+
+	if !PaymentStatusCaptured.IsSuccess() || PaymentStatusCaptured.IsTerminal() || !PaymentStatusVoided.IsTerminal() {
+		t.Error("Unexpected payment status classification")
+	}
+}
+
+func TestTransactionService_CaptureUpdatesShippingAndDescriptor(t *testing.T) {
+	var sent map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sent = nil
+		if err := json.NewDecoder(r.Body).Decode(&sent); err != nil {
+			t.Errorf("Failed to decode request body: %v", err)
+		}
+		fmt.Fprint(w, `{"id":"txn_123"}`)
+	}))
+	defer server.Close()
+
+	sdk := NewSDK(&Config{BaseURL: server.URL})
+	ctx := context.Background()
+
+	// Omitted fields keep the authorization-time values
+	if _, err := sdk.Transactions.CaptureTransaction(ctx, "txn_123", nil); err != nil {
+		t.Fatalf("CaptureTransaction() error = %v", err)
+	}
+	if _, ok := sent["shipping_address"]; ok {
+		t.Error("Expected shipping_address to be omitted")
+	}
+	if _, ok := sent["statement_descriptor"]; ok {
+		t.Error("Expected statement_descriptor to be omitted")
+	}
+
+	req := &CaptureTransactionRequest{
+		ShippingAddr:        &Address{Line1: "1 Main St", City: "Austin", Country: "US"},
+		StatementDescriptor: "ACME STORE",
+	}
+	if _, err := sdk.Transactions.CaptureTransaction(ctx, "txn_123", req); err != nil {
+		t.Fatalf("CaptureTransaction() error = %v", err)
+	}
+	if sent["statement_descriptor"] != "ACME STORE" {
+		t.Errorf("Expected statement descriptor to be sent, got %v", sent["statement_descriptor"])
+	}
+	if shipping, ok := sent["shipping_address"].(map[string]interface{}); !ok || shipping["city"] != "Austin" {
+		t.Errorf("Expected shipping address to be sent, got %v", sent["shipping_address"])
+	}
+
+	req.StatementDescriptor = "ACME STORE INTERNATIONAL LTD"
+	if _, err := sdk.Transactions.CaptureTransaction(ctx, "txn_123", req); err == nil {
+		t.Error("Expected an overlong statement descriptor to be rejected")
+	}
+}
+
+func TestTransactionService_CaptureWithTip(t *testing.T) {
+	var sent map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sent = nil
+		if err := json.NewDecoder(r.Body).Decode(&sent); err != nil {
+			t.Errorf("Failed to decode request body: %v", err)
+		}
+		fmt.Fprint(w, `{"id":"txn_123","status":"captured","amount":60.1,"base_amount":50,"tip_amount":10.1}`)
+	}))
+	defer server.Close()
+
+	ctx := context.Background()
+	amount, base, tip := 60.1, 50.0, 10.1
+	req := &CaptureTransactionRequest{Amount: &amount, Currency: "USD", BaseAmount: &base, TipAdjustment: &tip}
+
+	sdk := NewSDK(&Config{BaseURL: server.URL})
+	transaction, err := sdk.Transactions.CaptureTransaction(ctx, "txn_123", req)
+	if err != nil {
+		t.Fatalf("CaptureTransaction() error = %v", err)
+	}
+	if sent["base_amount"] != 50.0 || sent["tip_amount"] != 10.1 {
+		t.Errorf("Expected the tip breakdown to be sent, got %v", sent)
+	}
+	if transaction.BaseAmount == nil || *transaction.BaseAmount != 50 || transaction.TipAmount == nil || *transaction.TipAmount != 10.1 {
+		t.Errorf("Expected the tip breakdown on the response, got %+v", transaction)
+	}
+
+	sdk = NewSDK(&Config{BaseURL: server.URL, DecimalStringAmounts: true})
+	if _, err := sdk.Transactions.CaptureTransaction(ctx, "txn_123", req); err != nil {
+		t.Fatalf("CaptureTransaction() error = %v", err)
+	}
+	if sent["amount"] != "60.10" || sent["base_amount"] != "50.00" || sent["tip_amount"] != "10.10" {
+		t.Errorf("Expected the tip breakdown as decimal strings, got %v", sent)
+	}
+
+	tip = 12
+	if _, err := sdk.Transactions.CaptureTransaction(ctx, "txn_123", req); err == nil {
+		t.Error("Expected a total that is not base plus tip to be rejected")
+	}
+}
+
+func TestTransactionBuilder(t *testing.T) {
+	card := &CardDetails{
+		Number:      "4111111111111111",
+		ExpiryMonth: 12,
+		ExpiryYear:  2025,
+		CVV:         "123",
+		HolderName:  "John Doe",
+	}
+
+	tests := []struct {
+		name    string
+		builder *TransactionBuilder
+		wantErr bool
+	}{
+		{
+			name:    "card transaction",
+			builder: NewTransactionBuilder(100.00, "USD", "merchant_123").WithCard(card),
+			wantErr: false,
+		},
+		{
+			name: "token transaction with options",
+			builder: NewTransactionBuilder(100.00, "USD", "merchant_123").
+				WithToken("tok_123").
+				WithBillingAddress(&Address{Line1: "1 Main St", City: "Austin", Country: "US"}).
+				WithMetadata("order_id", "order_123").
+				WithCaptureMode(CaptureModeAuto),
+			wantErr: false,
+		},
+		{
+			name:    "token and card",
+			builder: NewTransactionBuilder(100.00, "USD", "merchant_123").WithCard(card).WithToken("tok_123"),
+			wantErr: true,
+		},
+		{
+			name:    "no payment method",
+			builder: NewTransactionBuilder(100.00, "USD", "merchant_123"),
+			wantErr: true,
+		},
+		{
+			name:    "invalid amount",
+			builder: NewTransactionBuilder(0, "USD", "merchant_123").WithToken("tok_123"),
+			wantErr: true,
+		},
+		{
+			name:    "invalid capture mode",
+			builder: NewTransactionBuilder(100.00, "USD", "merchant_123").WithToken("tok_123").WithCaptureMode("later"),
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := tt.builder.Build()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Build() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && req == nil {
+				t.Error("Expected request to be built")
+			}
+		})
+	}
+
+	builder := NewTransactionBuilder(100.00, "USD", "merchant_123").WithToken("tok_123").WithMetadata("order_id", "order_123")
+	req, err := builder.Build()
+	if err != nil {
+		t.Fatalf("Build() error = %v", err)
+	}
+	if req.CardToken != "tok_123" || req.Metadata["order_id"] != "order_123" {
+		t.Errorf("Expected token and metadata to be set, got %+v", req)
+	}
+
+	// Later changes to the builder must not leak into built requests
+	builder.WithMetadata("order_id", "order_456")
+	if req.Metadata["order_id"] != "order_123" {
+		t.Errorf("Expected built metadata to be unchanged, got %s", req.Metadata["order_id"])
+	}
+}
+
+func TestTransactionService_NormalizesCurrency(t *testing.T) {
+	var sentCurrency, queryCurrency string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			queryCurrency = r.URL.Query().Get("currency")
+			fmt.Fprint(w, `{"transactions":[]}`)
+			return
+		}
+		var body TransactionRequest
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("Failed to decode request body: %v", err)
+		}
+		sentCurrency = body.Currency
+		fmt.Fprint(w, `{"id":"txn_123"}`)
+	}))
+	defer server.Close()
+
+	sdk := NewSDK(&Config{BaseURL: server.URL})
+	ctx := context.Background()
+
+	req := &TransactionRequest{
+		Amount:     100.00,
+		Currency:   "usd",
+		MerchantID: "merchant_123",
+		CardToken:  "tok_123",
+	}
+	if _, err := sdk.Transactions.AuthorizeTransaction(ctx, req); err != nil {
+		t.Fatalf("AuthorizeTransaction() error = %v", err)
+	}
+	if sentCurrency != "USD" {
+		t.Errorf("Expected currency USD to be sent, got %s", sentCurrency)
+	}
+	if req.Currency != "usd" {
+		t.Errorf("Expected caller's request to be untouched, got %s", req.Currency)
+	}
+
+	if _, err := sdk.Transactions.ListTransactions(ctx, &ListTransactionsRequest{Currency: "eur"}); err != nil {
+		t.Fatalf("ListTransactions() error = %v", err)
+	}
+	if queryCurrency != "EUR" {
+		t.Errorf("Expected currency filter EUR, got %s", queryCurrency)
+	}
+}
+
+func TestTransactionService_GetTransactionEvents(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/transactions/txn_123/events" {
+			t.Errorf("Expected path /transactions/txn_123/events, got %s", r.URL.Path)
+		}
+		fmt.Fprint(w, `[
+			{"type":"captured","amount":100,"created_at":"2024-01-01T10:05:00Z","actor":"api_key_1"},
+			{"type":"partially_refunded","amount":25,"created_at":"2024-01-02T09:00:00Z","actor":"user_42"},
+			{"type":"authorized","amount":100,"created_at":"2024-01-01T10:00:00Z","actor":"api_key_1"}
+		]`)
+	}))
+	defer server.Close()
+
+	sdk := NewSDK(&Config{BaseURL: server.URL})
+
+	events, err := sdk.Transactions.GetTransactionEvents(context.Background(), "txn_123")
+	if err != nil {
+		t.Fatalf("GetTransactionEvents() error = %v", err)
+	}
+
+	want := []string{"authorized", "captured", "partially_refunded"}
+	if len(events) != len(want) {
+		t.Fatalf("Expected %d events, got %d", len(want), len(events))
+	}
+	for i, event := range events {
+		if event.Type != want[i] {
+			t.Errorf("Expected event %d to be %s, got %s", i, want[i], event.Type)
+		}
+	}
+	if events[2].Actor != "user_42" || events[2].Amount != 25 {
+		t.Errorf("Expected refund event by user_42 for 25, got %+v", events[2])
+	}
+}
+
+func TestVerificationResults(t *testing.T) {
+	tests := []struct {
+		name      string
+		raw       string
+		cvvCheck  bool
+		cvvMatch  bool
+		avsCheck  bool
+		avsMatch  bool
+		addrMatch bool
+		zipMatch  bool
+	}{
+		{"full match", `{"cvv_result":"Y","avs_result":"Y"}`, true, true, true, true, true, true},
+		{"postal code only", `{"cvv_result":"N","avs_result":"Z"}`, true, false, true, false, false, true},
+		{"address only", `{"cvv_result":"Y","avs_result":"A"}`, true, true, true, false, true, false},
+		{"unavailable", `{"cvv_result":"U","avs_result":"U"}`, false, false, false, false, false, false},
+		{"omitted by gateway", `{}`, false, false, false, false, false, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var transaction TransactionResponse
+			if err := json.Unmarshal([]byte(tt.raw), &transaction); err != nil {
+				t.Fatalf("Failed to unmarshal transaction: %v", err)
+			}
+			var payment PaymentResponse
+			if err := json.Unmarshal([]byte(tt.raw), &payment); err != nil {
+				t.Fatalf("Failed to unmarshal payment: %v", err)
+			}
+			if payment.CVVResult != transaction.CVVResult || payment.AVSResult != transaction.AVSResult {
+				t.Fatalf("Expected payments and transactions to decode the same results, got %+v and %+v", payment, transaction)
+			}
+
+			cvv, avs := payment.CVVResult, payment.AVSResult
+			if cvv.Checked() != tt.cvvCheck || cvv.Matched() != tt.cvvMatch {
+				t.Errorf("Unexpected CVV classification for %q", cvv)
+			}
+			if avs.Checked() != tt.avsCheck || avs.Matched() != tt.avsMatch {
+				t.Errorf("Unexpected AVS classification for %q", avs)
+			}
+			if avs.AddressMatched() != tt.addrMatch || avs.PostalCodeMatched() != tt.zipMatch {
+				t.Errorf("Unexpected AVS address/postal code classification for %q", avs)
+			}
+		})
+	}
+
+	var payment PaymentResponse
+	if err := json.Unmarshal([]byte(`{}`), &payment); err != nil {
+		t.Fatalf("Failed to unmarshal payment: %v", err)
+	}
+	if payment.CVVResult != CVVResultNotChecked || payment.AVSResult != AVSResultNotChecked {
+		t.Errorf("Expected omitted results to be NotChecked, got %q and %q", payment.CVVResult, payment.AVSResult)
+	}
+}
+
+func TestDecimalStringAmounts(t *testing.T) {
+	var body map[string]json.RawMessage
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body = nil
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("Failed to decode request body: %v", err)
+		}
+		fmt.Fprint(w, `{"id":"txn_123"}`)
+	}))
+	defer server.Close()
+
+	ctx := context.Background()
+	tests := []struct {
+		name     string
+		enabled  bool
+		amount   float64
+		currency string
+		want     string
+	}{
+		{"disabled", false, 100.10, "USD", `100.1`},
+		{"two decimals", true, 100.10, "USD", `"100.10"`},
+		{"whole amount", true, 100, "USD", `"100.00"`},
+		{"zero-decimal currency", true, 1000, "JPY", `"1000"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sdk := NewSDK(&Config{BaseURL: server.URL, DecimalStringAmounts: tt.enabled})
+
+			_, err := sdk.Transactions.AuthorizeTransaction(ctx, &TransactionRequest{
+				Amount:     tt.amount,
+				Currency:   tt.currency,
+				MerchantID: "merchant_123",
+				CardToken:  "tok_123",
+			})
+			if err != nil {
+				t.Fatalf("AuthorizeTransaction() error = %v", err)
+			}
+			if string(body["amount"]) != tt.want {
+				t.Errorf("Expected authorize amount %s, got %s", tt.want, body["amount"])
+			}
+
+			_, err = sdk.Payments.CreatePayment(ctx, &PaymentRequest{
+				Amount:     tt.amount,
+				Currency:   tt.currency,
+				MerchantID: "merchant_123",
+				CardToken:  "tok_123",
+			})
+			if err != nil {
+				t.Fatalf("CreatePayment() error = %v", err)
+			}
+			if string(body["amount"]) != tt.want {
+				t.Errorf("Expected payment amount %s, got %s", tt.want, body["amount"])
+			}
+
+			_, err = sdk.Transactions.CaptureTransaction(ctx, "txn_123", &CaptureTransactionRequest{Amount: &tt.amount, Currency: tt.currency})
+			if err != nil {
+				t.Fatalf("CaptureTransaction() error = %v", err)
+			}
+			if string(body["amount"]) != tt.want {
+				t.Errorf("Expected capture amount %s, got %s", tt.want, body["amount"])
+			}
+		})
+	}
+
+	// A capture without an amount still omits it
+	sdk := NewSDK(&Config{BaseURL: server.URL, DecimalStringAmounts: true})
+	if _, err := sdk.Transactions.CaptureTransaction(ctx, "txn_123", nil); err != nil {
+		t.Fatalf("CaptureTransaction() error = %v", err)
+	}
+	if _, ok := body["amount"]; ok {
+		t.Errorf("Expected amount to be omitted, got %s", body["amount"])
+	}
+
+	// Split amounts use the same format
+	fee := 1.5
+	_, err := sdk.Payments.CreatePayment(ctx, &PaymentRequest{
+		Amount:     100,
+		Currency:   "USD",
+		MerchantID: "platform_123",
+		CardToken:  "tok_123",
+		Splits: []PaymentSplit{
+			{MerchantID: "seller_1", Amount: 58.5, Fee: &fee},
+			{MerchantID: "seller_2", Amount: 40},
+		},
+	})
+	if err != nil {
+		t.Fatalf("CreatePayment() with splits error = %v", err)
+	}
+	want := `[{"merchant_id":"seller_1","amount":"58.50","fee":"1.50"},{"merchant_id":"seller_2","amount":"40.00"}]`
+	if string(body["splits"]) != want {
+		t.Errorf("Expected splits %s, got %s", want, body["splits"])
+	}
+}
+
+func TestDecimalStringAmountsTransactionCurrency(t *testing.T) {
+	var lookups int
+	var body map[string]json.RawMessage
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			lookups++
+			fmt.Fprint(w, `{"id":"txn_jpy","amount":5000,"currency":"JPY"}`)
+			return
+		}
+		body = nil
+		json.NewDecoder(r.Body).Decode(&body)
+		fmt.Fprint(w, `{"id":"txn_jpy"}`)
+	}))
+	defer server.Close()
+
+	sdk := NewSDK(&Config{BaseURL: server.URL, DecimalStringAmounts: true})
+	ctx := context.Background()
+
+	// Without a currency, whole amounts are formatted in the currency of
+	// the transaction
+	amount := 1000.0
+	if _, err := sdk.Transactions.CaptureTransaction(ctx, "txn_jpy", &CaptureTransactionRequest{Amount: &amount}); err != nil {
+		t.Fatalf("CaptureTransaction() error = %v", err)
+	}
+	if string(body["amount"]) != `"1000"` || string(body["currency"]) != `"JPY"` {
+		t.Errorf("Expected a JPY capture of \"1000\", got %s %s", body["amount"], body["currency"])
+	}
+
+	if _, err := sdk.Transactions.RefundTransaction(ctx, "txn_jpy", &RefundTransactionRequest{Amount: 500}); err != nil {
+		t.Fatalf("RefundTransaction() error = %v", err)
+	}
+	if string(body["amount"]) != `"500"` || string(body["currency"]) != `"JPY"` {
+		t.Errorf("Expected a JPY refund of \"500\", got %s %s", body["amount"], body["currency"])
+	}
+	if lookups != 2 {
+		t.Errorf("Expected the currency to be looked up for each request, got %d lookups", lookups)
+	}
+}
+
+func TestTransactionResponse_DeclineType(t *testing.T) {
+	tests := []struct {
+		name        string
+		transaction TransactionResponse
+		want        DeclineType
+		retryAfter  time.Duration
+	}{
+		{"approved", TransactionResponse{Status: TransactionStatusAuthorized, ProcessorResponse: "00"}, DeclineNone, 0},
+		{"insufficient funds", TransactionResponse{Status: TransactionStatusDeclined, ProcessorResponse: "51"}, DeclineSoft, 72 * time.Hour},
+		{"issuer unavailable", TransactionResponse{Status: TransactionStatusDeclined, ProcessorResponse: "91"}, DeclineSoft, time.Hour},
+		{"stolen card", TransactionResponse{Status: TransactionStatusDeclined, ProcessorResponse: "43"}, DeclineHard, 0},
+		{"code in failure code", TransactionResponse{Status: TransactionStatusDeclined, FailureCode: "05"}, DeclineSoft, 24 * time.Hour},
+		{"unknown code", TransactionResponse{Status: TransactionStatusDeclined, ProcessorResponse: "N7"}, DeclineHard, 0},
+		{"failed, not declined", TransactionResponse{Status: TransactionStatusFailed, ProcessorResponse: "51"}, DeclineNone, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.transaction.DeclineType(); got != tt.want {
+				t.Errorf("DeclineType() = %v, want %v", got, tt.want)
+			}
+			if got := tt.transaction.RetryAfter(); got != tt.retryAfter {
+				t.Errorf("RetryAfter() = %v, want %v", got, tt.retryAfter)
+			}
+		})
+	}
+}
+
+func TestTransactionService_MetadataLimits(t *testing.T) {
+	var requests int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		fmt.Fprint(w, `{"id":"txn_123"}`)
+	}))
+	defer server.Close()
+
+	ctx := context.Background()
+	newRequest := func(metadata map[string]string) *TransactionRequest {
+		return &TransactionRequest{
+			Amount:     100.00,
+			Currency:   "USD",
+			MerchantID: "merchant_123",
+			CardToken:  "tok_123",
+			Metadata:   metadata,
+		}
+	}
+
+	sdk := NewSDK(&Config{
+		BaseURL:         server.URL,
+		DefaultMetadata: map[string]string{"service": "checkout"},
+		MetadataLimits:  MetadataLimits{MaxKeys: 2, MaxValueLength: 10},
+	})
+
+	// Default metadata counts towards the limits
+	_, err := sdk.Transactions.AuthorizeTransaction(ctx, newRequest(map[string]string{"a": "1", "b": "2"}))
+	var validationErr *ValidationError
+	if !errors.As(err, &validationErr) || validationErr.Field != "metadata" {
+		t.Errorf("Expected too many metadata keys to be rejected, got %v", err)
+	}
+
+	_, err = sdk.Transactions.RefundTransaction(ctx, "txn_123", &RefundTransactionRequest{Amount: 10, Metadata: map[string]string{"reason": "customer request"}})
+	if !errors.As(err, &validationErr) || validationErr.Field != "metadata.reason" {
+		t.Errorf("Expected the long metadata value to be rejected, got %v", err)
+	}
+
+	_, err = sdk.Transactions.VoidTransaction(ctx, "txn_123", &VoidTransactionRequest{Metadata: map[string]string{"reason": "duplicate"}})
+	if err != nil {
+		t.Errorf("VoidTransaction() error = %v", err)
+	}
+	if requests != 1 {
+		t.Errorf("Expected only the valid request to reach the gateway, got %d requests", requests)
+	}
+
+	// Unset limits fall back to the defaults
+	sdk = NewSDK(&Config{BaseURL: server.URL})
+	if _, err := sdk.Transactions.AuthorizeTransaction(ctx, newRequest(map[string]string{"order_id": strings.Repeat("a", 500)})); err != nil {
+		t.Errorf("AuthorizeTransaction() error = %v", err)
+	}
+}
+
+func TestWithMerchant(t *testing.T) {
+	var bodyMerchant, queryMerchant string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			queryMerchant = r.URL.Query().Get("merchant_id")
+			if strings.HasPrefix(r.URL.Path, "/merchants/") {
+				queryMerchant = strings.Split(r.URL.Path, "/")[2]
+				fmt.Fprint(w, `[]`)
+				return
+			}
+			fmt.Fprint(w, `{"transactions":[]}`)
+			return
+		}
+		var body struct {
+			MerchantID string `json:"merchant_id"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("Failed to decode request body: %v", err)
+		}
+		bodyMerchant = body.MerchantID
+		fmt.Fprint(w, `{"id":"txn_123"}`)
+	}))
+	defer server.Close()
+
+	sdk := NewSDK(&Config{BaseURL: server.URL})
+	ctx := WithMerchant(context.Background(), "merchant_ctx")
+
+	if id, ok := MerchantFromContext(ctx); !ok || id != "merchant_ctx" {
+		t.Errorf("Expected merchant_ctx from context, got %q", id)
+	}
+
+	txnReq := &TransactionRequest{Amount: 10, Currency: "USD", CardToken: "tok_123"}
+	if _, err := sdk.Transactions.AuthorizeTransaction(ctx, txnReq); err != nil {
+		t.Fatalf("AuthorizeTransaction() error = %v", err)
+	}
+	if bodyMerchant != "merchant_ctx" {
+		t.Errorf("Expected merchant from context, got %q", bodyMerchant)
+	}
+	if txnReq.MerchantID != "" {
+		t.Error("Expected caller's request to be untouched")
+	}
+
+	// An explicit merchant wins over the context
+	txnReq.MerchantID = "merchant_explicit"
+	if _, err := sdk.Transactions.AuthorizeTransaction(ctx, txnReq); err != nil {
+		t.Fatalf("AuthorizeTransaction() error = %v", err)
+	}
+	if bodyMerchant != "merchant_explicit" {
+		t.Errorf("Expected explicit merchant, got %q", bodyMerchant)
+	}
+
+	if _, err := sdk.Payments.CreatePayment(ctx, &PaymentRequest{Amount: 10, Currency: "USD", CardToken: "tok_123"}); err != nil {
+		t.Fatalf("CreatePayment() error = %v", err)
+	}
+	if bodyMerchant != "merchant_ctx" {
+		t.Errorf("Expected payment merchant from context, got %q", bodyMerchant)
+	}
+
+	if _, err := sdk.Transactions.ListTransactions(ctx, nil); err != nil {
+		t.Fatalf("ListTransactions() error = %v", err)
+	}
+	if queryMerchant != "merchant_ctx" {
+		t.Errorf("Expected list filter merchant from context, got %q", queryMerchant)
+	}
+
+	if _, err := sdk.Transactions.SearchTransactions(ctx, &SearchTransactionsRequest{Query: "john", MerchantID: "merchant_explicit"}); err != nil {
+		t.Fatalf("SearchTransactions() error = %v", err)
+	}
+	if queryMerchant != "merchant_explicit" {
+		t.Errorf("Expected explicit search merchant, got %q", queryMerchant)
+	}
+
+	if _, err := sdk.Merchant.ListSettlements(ctx, nil); err != nil {
+		t.Fatalf("ListSettlements() error = %v", err)
+	}
+	if queryMerchant != "merchant_ctx" {
+		t.Errorf("Expected settlements merchant from context, got %q", queryMerchant)
+	}
+
+	if _, err := sdk.Disputes.ListDisputes(ctx, nil); err != nil {
+		t.Fatalf("ListDisputes() error = %v", err)
+	}
+	if queryMerchant != "merchant_ctx" {
+		t.Errorf("Expected disputes merchant from context, got %q", queryMerchant)
+	}
+	if _, err := sdk.Disputes.ListDisputes(ctx, &ListDisputesRequest{MerchantID: "merchant_explicit"}); err != nil {
+		t.Fatalf("ListDisputes() error = %v", err)
+	}
+	if queryMerchant != "merchant_explicit" {
+		t.Errorf("Expected explicit disputes merchant, got %q", queryMerchant)
+	}
+
+	// Without a merchant anywhere, validation still fails
+	if _, err := sdk.Transactions.AuthorizeTransaction(context.Background(), &TransactionRequest{Amount: 10, Currency: "USD", CardToken: "tok_123"}); err == nil {
+		t.Error("Expected a missing merchant ID to be rejected")
+	}
+}
+
+func TestDeclineError(t *testing.T) {
+	tests := []struct {
+		name       string
+		status     int
+		body       string
+		wantDecl   bool
+		code       string
+		reason     string
+		retryable  bool
+		retryAfter time.Duration
+	}{
+		{
+			name:       "soft decline",
+			status:     http.StatusPaymentRequired,
+			body:       `{"message":"declined","code":"card_declined","processor_code":"51","decline_reason":"insufficient funds"}`,
+			wantDecl:   true,
+			code:       "51",
+			reason:     "insufficient funds",
+			retryable:  true,
+			retryAfter: 72 * time.Hour,
+		},
+		{
+			name:     "hard decline without reason",
+			status:   http.StatusPaymentRequired,
+			body:     `{"message":"card reported stolen","processor_response":"43"}`,
+			wantDecl: true,
+			code:     "43",
+			reason:   "card reported stolen",
+		},
+		{
+			name:   "validation error",
+			status: http.StatusBadRequest,
+			body:   `{"message":"invalid amount"}`,
+		},
+		{
+			name:   "server error",
+			status: http.StatusInternalServerError,
+			body:   `{"message":"internal error"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.status)
+				fmt.Fprint(w, tt.body)
+			}))
+			defer server.Close()
+
+			sdk := NewSDK(&Config{BaseURL: server.URL})
+			_, err := sdk.Transactions.AuthorizeTransaction(context.Background(), &TransactionRequest{
+				Amount:     100.00,
+				Currency:   "USD",
+				MerchantID: "merchant_123",
+				CardToken:  "tok_123",
+			})
+
+			var apiErr *APIError
+			if !errors.As(err, &apiErr) || apiErr.StatusCode != tt.status {
+				t.Errorf("Expected *APIError with status %d, got %v", tt.status, err)
+			}
+
+			var declineErr *DeclineError
+			if errors.As(err, &declineErr) != tt.wantDecl {
+				t.Fatalf("Expected errors.As(*DeclineError) to be %v, got %v", tt.wantDecl, err)
+			}
+			if !tt.wantDecl {
+				return
+			}
+			if declineErr.ProcessorCode != tt.code || declineErr.Reason != tt.reason {
+				t.Errorf("Expected code %s and reason %q, got %s and %q", tt.code, tt.reason, declineErr.ProcessorCode, declineErr.Reason)
+			}
+			if declineErr.Retryable != tt.retryable || declineErr.RetryAfter != tt.retryAfter {
+				t.Errorf("Expected retryable %v after %v, got %v after %v", tt.retryable, tt.retryAfter, declineErr.Retryable, declineErr.RetryAfter)
+			}
+		})
+	}
+}
+
+func TestTransactionService_GetTransactions(t *testing.T) {
+	var mu sync.Mutex
+	var inFlight, maxInFlight int
+	requested := map[string]int{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := strings.TrimPrefix(r.URL.Path, "/transactions/")
+		mu.Lock()
+		requested[id]++
+		inFlight++
+		if inFlight > maxInFlight {
+			maxInFlight = inFlight
+		}
+		mu.Unlock()
+		defer func() {
+			mu.Lock()
+			inFlight--
+			mu.Unlock()
+		}()
+
+		time.Sleep(5 * time.Millisecond)
+		if id == "txn_missing" {
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `{"message":"transaction not found"}`)
+			return
+		}
+		fmt.Fprintf(w, `{"id":%q,"status":"captured"}`, id)
+	}))
+	defer server.Close()
+
+	sdk := NewSDK(&Config{BaseURL: server.URL})
+	ids := []string{"txn_missing", ""}
+	for i := 0; i < 20; i++ {
+		ids = append(ids, fmt.Sprintf("txn_%d", i), fmt.Sprintf("txn_%d", i))
+	}
+
+	transactions, errs := sdk.Transactions.GetTransactions(context.Background(), ids)
+	if len(transactions) != 20 {
+		t.Errorf("Expected 20 transactions, got %d", len(transactions))
+	}
+	if transactions["txn_7"] == nil || transactions["txn_7"].ID != "txn_7" {
+		t.Errorf("Expected txn_7 to be keyed by its ID, got %+v", transactions["txn_7"])
+	}
+	if len(errs) != 2 {
+		t.Fatalf("Expected 2 errors, got %v", errs)
+	}
+
+	var lookupErr *TransactionLookupError
+	var apiErr *APIError
+	if !errors.As(errs[0], &lookupErr) || lookupErr.TransactionID != "txn_missing" || !errors.As(errs[0], &apiErr) || apiErr.StatusCode != http.StatusNotFound {
+		t.Errorf("Expected a 404 lookup error for txn_missing, got %v", errs[0])
+	}
+	var validationErr *ValidationError
+	if !errors.As(errs[1], &validationErr) {
+		t.Errorf("Expected a validation error for the empty ID, got %v", errs[1])
+	}
+
+	if maxInFlight > MaxConcurrentLookups {
+		t.Errorf("Expected at most %d concurrent lookups, got %d", MaxConcurrentLookups, maxInFlight)
+	}
+	for id, count := range requested {
+		if count != 1 {
+			t.Errorf("Expected %s to be fetched once, got %d", id, count)
+		}
+	}
+}
+
+func TestTransactionService_GetTransactionsCanceled(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("Unexpected request to %s", r.URL.Path)
+	}))
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	sdk := NewSDK(&Config{BaseURL: server.URL})
+	transactions, errs := sdk.Transactions.GetTransactions(ctx, []string{"txn_1", "txn_2"})
+	if len(transactions) != 0 || len(errs) != 2 {
+		t.Fatalf("Expected every lookup to fail, got %d transactions and %v", len(transactions), errs)
+	}
+	for _, err := range errs {
+		if !errors.Is(err, ErrContextCanceled) {
+			t.Errorf("Expected ErrContextCanceled, got %v", err)
+		}
+	}
+}
+
+func TestTransactionService_NormalizesAddressCountries(t *testing.T) {
+	var sent TransactionRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewDecoder(r.Body).Decode(&sent); err != nil {
+			t.Errorf("Failed to decode request body: %v", err)
+		}
+		fmt.Fprint(w, `{"id":"txn_123","status":"authorized"}`)
+	}))
+	defer server.Close()
+
+	req := &TransactionRequest{
+		Amount:       100.00,
+		Currency:     "USD",
+		MerchantID:   "merchant_123",
+		CardToken:    "token_123",
+		BillingAddr:  &Address{Line1: "1 Main St", Country: "USA"},
+		ShippingAddr: &Address{Line1: "1 Main St", Country: "united kingdom"},
+	}
+
+	sdk := NewSDK(&Config{BaseURL: server.URL})
+	if _, err := sdk.Transactions.AuthorizeTransaction(context.Background(), req); err != nil {
+		t.Fatalf("AuthorizeTransaction() error = %v", err)
+	}
+	if sent.BillingAddr.Country != "US" || sent.ShippingAddr.Country != "GB" {
+		t.Errorf("Expected countries US and GB to be sent, got %s and %s", sent.BillingAddr.Country, sent.ShippingAddr.Country)
+	}
+	if req.BillingAddr.Country != "USA" {
+		t.Errorf("Expected the caller's address to be unchanged, got %s", req.BillingAddr.Country)
+	}
+
+	req.BillingAddr = &Address{Line1: "1 Main St", Country: "Atlantis"}
+	_, err := sdk.Transactions.AuthorizeTransaction(context.Background(), req)
+	var validationErr *ValidationError
+	if !errors.As(err, &validationErr) || validationErr.Field != "billing_address.country" || !strings.Contains(err.Error(), `"Atlantis"`) {
+		t.Errorf("Expected a validation error naming billing_address.country and the value, got %v", err)
+	}
+}
+
+func TestTransactionService_GetTransactionStatus(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/transactions/txn_123/status" {
+			t.Errorf("Expected path /transactions/txn_123/status, got %s", r.URL.Path)
+		}
+		fmt.Fprint(w, `{"id":"txn_123","status":"authorized","processed_at":"2024-01-15T12:00:00Z","expires_at":"2024-01-22T12:00:00Z"}`)
+	}))
+	defer server.Close()
+
+	sdk := NewSDK(&Config{BaseURL: server.URL})
+	status, err := sdk.Transactions.GetTransactionStatus(context.Background(), "txn_123")
+	if err != nil {
+		t.Fatalf("GetTransactionStatus() error = %v", err)
+	}
+	if status.ID != "txn_123" || status.Status != TransactionStatusAuthorized || status.Meta == nil {
+		t.Errorf("Expected authorized status for txn_123, got %+v", status)
+	}
+	if status.ProcessedAt == nil || !status.ProcessedAt.Equal(time.Date(2024, 1, 15, 12, 0, 0, 0, time.UTC)) {
+		t.Errorf("Expected processed_at to be parsed, got %v", status.ProcessedAt)
+	}
+	if status.ExpiresAt == nil || !status.ExpiresAt.Equal(time.Date(2024, 1, 22, 12, 0, 0, 0, time.UTC)) {
+		t.Errorf("Expected expires_at to be parsed, got %v", status.ExpiresAt)
+	}
+}
+
+func TestTransactionService_AuthorizationExpiry(t *testing.T) {
+	var sent map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sent = nil
+		if err := json.NewDecoder(r.Body).Decode(&sent); err != nil {
+			t.Errorf("Failed to decode request body: %v", err)
+		}
+		fmt.Fprint(w, `{"id":"txn_123","status":"authorized","expires_at":"2024-01-25T12:00:00Z"}`)
+	}))
+	defer server.Close()
+
+	clock := newFakeClock()
+	now := clock.Now()
+	sdk := NewSDK(&Config{BaseURL: server.URL, Clock: clock})
+	ctx := context.Background()
+
+	tests := []struct {
+		name        string
+		expiry      time.Time
+		captureMode string
+		wantErr     bool
+	}{
+		{"ten days", now.Add(10 * 24 * time.Hour), "", false},
+		{"maximum hold", now.Add(MaxAuthorizationHold), CaptureModeManual, false},
+		{"beyond maximum hold", now.Add(MaxAuthorizationHold + time.Minute), "", true},
+		{"in the past", now.Add(-time.Hour), "", true},
+		{"auto capture", now.Add(24 * time.Hour), CaptureModeAuto, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sent = nil
+			expiry := tt.expiry
+			transaction, err := sdk.Transactions.AuthorizeTransaction(ctx, &TransactionRequest{
+				Amount:              100.00,
+				Currency:            "USD",
+				MerchantID:          "merchant_123",
+				CardToken:           "token_123",
+				CaptureMode:         tt.captureMode,
+				AuthorizationExpiry: &expiry,
+			})
+			if tt.wantErr {
+				var validationErr *ValidationError
+				if !errors.As(err, &validationErr) || validationErr.Field != "authorization_expires_at" {
+					t.Errorf("Expected a validation error on authorization_expires_at, got %v", err)
+				}
+				if sent != nil {
+					t.Error("Expected the request not to be sent")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("AuthorizeTransaction() error = %v", err)
+			}
+			if sent["authorization_expires_at"] != expiry.Format(time.RFC3339) {
+				t.Errorf("Expected the expiry to be sent, got %v", sent["authorization_expires_at"])
+			}
+			if transaction.ExpiresAt == nil || !transaction.ExpiresAt.Equal(time.Date(2024, 1, 25, 12, 0, 0, 0, time.UTC)) {
+				t.Errorf("Expected ExpiresAt to be read back, got %v", transaction.ExpiresAt)
+			}
+		})
+	}
+}
+
+func TestTransactionResponse_IsExpired(t *testing.T) {
+	expiresAt := time.Date(2024, 1, 25, 12, 0, 0, 0, time.UTC)
+	transaction := &TransactionResponse{ExpiresAt: &expiresAt}
+
+	if transaction.IsExpiredAt(expiresAt.Add(-time.Second)) {
+		t.Error("Expected the hold not to be expired before ExpiresAt")
+	}
+	if !transaction.IsExpiredAt(expiresAt) {
+		t.Error("Expected the hold to be expired at ExpiresAt")
+	}
+	if !transaction.IsExpired() {
+		t.Error("Expected a hold that lapsed in 2024 to be expired")
+	}
+	if (&TransactionResponse{}).IsExpired() {
+		t.Error("Expected a transaction without an expiry never to expire")
+	}
+}
+
+func TestTransactionService_CaptureExpiredAuthorization(t *testing.T) {
+	expiresAt := newFakeClock().Now().Add(time.Hour)
+
+	var requests []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.Method+" "+r.URL.Path)
+		if r.Method == http.MethodGet {
+			fmt.Fprintf(w, `{"id":"txn_123","status":"authorized","expires_at":%q}`, expiresAt.Format(time.RFC3339))
+			return
+		}
+		fmt.Fprint(w, `{"id":"txn_123","status":"captured"}`)
+	}))
+	defer server.Close()
+
+	ctx := context.Background()
+
+	tests := []struct {
+		name     string
+		advance  time.Duration
+		req      *CaptureTransactionRequest
+		expired  bool
+		requests []string
+	}{
+		{
+			name:     "given expiry not reached",
+			req:      &CaptureTransactionRequest{AuthorizationExpiresAt: &expiresAt},
+			requests: []string{"POST /transactions/txn_123/capture"},
+		},
+		{
+			name:     "one second before expiry",
+			advance:  time.Hour - time.Second,
+			req:      &CaptureTransactionRequest{AuthorizationExpiresAt: &expiresAt},
+			requests: []string{"POST /transactions/txn_123/capture"},
+		},
+		{
+			name:    "just expired",
+			advance: time.Hour,
+			req:     &CaptureTransactionRequest{AuthorizationExpiresAt: &expiresAt},
+			expired: true,
+		},
+		{
+			name:     "check skipped",
+			advance:  time.Hour,
+			req:      &CaptureTransactionRequest{AuthorizationExpiresAt: &expiresAt, SkipExpiryCheck: true},
+			requests: []string{"POST /transactions/txn_123/capture"},
+		},
+		{
+			name:     "unknown expiry",
+			advance:  time.Hour,
+			req:      nil,
+			requests: []string{"POST /transactions/txn_123/capture"},
+		},
+		{
+			name:     "fetched expiry",
+			advance:  time.Hour,
+			req:      &CaptureTransactionRequest{FetchAuthorizationExpiry: true},
+			expired:  true,
+			requests: []string{"GET /transactions/txn_123/status"},
+		},
+		{
+			name:     "fetched expiry not reached",
+			req:      &CaptureTransactionRequest{FetchAuthorizationExpiry: true},
+			requests: []string{"GET /transactions/txn_123/status", "POST /transactions/txn_123/capture"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clock := newFakeClock()
+			clock.Advance(tt.advance)
+			sdk := NewSDK(&Config{BaseURL: server.URL, Clock: clock})
+			requests = nil
+
+			_, err := sdk.Transactions.CaptureTransaction(ctx, "txn_123", tt.req)
+			if tt.expired != errors.Is(err, ErrAuthorizationExpired) {
+				t.Errorf("Expected expired = %v, got error %v", tt.expired, err)
+			}
+			if !tt.expired && err != nil {
+				t.Errorf("CaptureTransaction() error = %v", err)
+			}
+			if fmt.Sprint(requests) != fmt.Sprint(tt.requests) {
+				t.Errorf("Expected requests %v, got %v", tt.requests, requests)
+			}
+		})
+	}
+}
+
+func TestTransactionService_ProcessAsync(t *testing.T) {
+	var mu sync.Mutex
+	var polls int
+	pendingPolls := 2
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		switch r.URL.Path {
+		case "/transactions/authorize":
+			fmt.Fprint(w, `{"id":"txn_123","status":"pending"}`)
+		case "/transactions/txn_123/status":
+			polls++
+			if polls <= pendingPolls {
+				fmt.Fprint(w, `{"id":"txn_123","status":"pending"}`)
+				return
+			}
+			fmt.Fprint(w, `{"id":"txn_123","status":"authorized"}`)
+		case "/transactions/txn_123":
+			fmt.Fprint(w, `{"id":"txn_123","status":"authorized","authorization_code":"A1"}`)
+		}
+	}))
+	defer server.Close()
+
+	sdk := NewSDK(&Config{BaseURL: server.URL, AsyncPollInterval: time.Millisecond})
+	ctx := context.Background()
+	req := &TransactionRequest{MerchantID: "merchant_123", Amount: 50000, Currency: "USD", CardToken: "tok_123"}
+
+	type result struct {
+		transaction *TransactionResponse
+		err         error
+	}
+	results := make(chan result, 2)
+	callback := func(transaction *TransactionResponse, err error) {
+		results <- result{transaction, err}
+	}
+
+	// The transaction is polled until it leaves pending, then fetched
+	handle := sdk.Transactions.ProcessAsync(ctx, req, callback)
+	handle.Wait()
+	got := <-results
+	if got.err != nil {
+		t.Fatalf("ProcessAsync() error = %v", got.err)
+	}
+	if got.transaction.Status != TransactionStatusAuthorized || got.transaction.AuthorizationCode != "A1" {
+		t.Errorf("Unexpected transaction %+v", got.transaction)
+	}
+	if polls != 3 {
+		t.Errorf("Expected 3 status polls, got %d", polls)
+	}
+
+	// Canceling the handle ends polling with context.Canceled
+	mu.Lock()
+	polls, pendingPolls = 0, 1<<30
+	mu.Unlock()
+	handle = sdk.Transactions.ProcessAsync(ctx, req, callback)
+	time.Sleep(10 * time.Millisecond)
+	handle.Cancel()
+	select {
+	case <-handle.Done():
+	case <-time.After(time.Second):
+		t.Fatal("Expected processing to stop after Cancel()")
+	}
+	if got := <-results; !errors.Is(got.err, context.Canceled) {
+		t.Errorf("Expected context.Canceled, got %v", got.err)
+	}
+
+	// Validation errors are reported through the callback too
+	handle = sdk.Transactions.ProcessAsync(ctx, &TransactionRequest{}, callback)
+	handle.Wait()
+	var validationErr *ValidationError
+	if got := <-results; !errors.As(got.err, &validationErr) {
+		t.Errorf("Expected a validation error, got %v", got.err)
+	}
+
+	// The callback fired exactly once per call
+	if len(results) != 0 {
+		t.Errorf("Expected no further callbacks, got %d", len(results))
+	}
+}
+
+func TestTransactionService_SettlementCurrency(t *testing.T) {
+	var body map[string]json.RawMessage
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body = nil
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("Failed to decode request body: %v", err)
+		}
+		fmt.Fprint(w, `{"id":"txn_123","amount":100,"currency":"USD","settlement_amount":92.14,"settlement_currency":"EUR","exchange_rate":0.9214}`)
+	}))
+	defer server.Close()
+
+	sdk := NewSDK(&Config{BaseURL: server.URL, DecimalStringAmounts: true})
+	resp, err := sdk.Transactions.AuthorizeTransaction(context.Background(), &TransactionRequest{
+		Amount:             100,
+		Currency:           "USD",
+		MerchantID:         "merchant_123",
+		CardToken:          "tok_123",
+		SettlementCurrency: "eur",
+		SettlementAmount:   Ptr(92.15),
+		ExchangeRate:       0.9215,
+	})
+	if err != nil {
+		t.Fatalf("AuthorizeTransaction() error = %v", err)
+	}
+
+	if string(body["settlement_currency"]) != `"EUR"` {
+		t.Errorf("Expected settlement currency \"EUR\", got %s", body["settlement_currency"])
+	}
+	if string(body["settlement_amount"]) != `"92.15"` {
+		t.Errorf("Expected settlement amount \"92.15\", got %s", body["settlement_amount"])
+	}
+	if string(body["exchange_rate"]) != `0.9215` {
+		t.Errorf("Expected exchange rate 0.9215, got %s", body["exchange_rate"])
+	}
+
+	// The settled figures are read back as the gateway reports them
+	if resp.SettlementCurrency != "EUR" || resp.SettlementAmount == nil || *resp.SettlementAmount != 92.14 {
+		t.Errorf("Unexpected settlement %q %v", resp.SettlementCurrency, resp.SettlementAmount)
+	}
+	if resp.ExchangeRate == nil || *resp.ExchangeRate != 0.9214 {
+		t.Errorf("Expected exchange rate 0.9214, got %v", resp.ExchangeRate)
+	}
+}
+
+func TestTransactionService_Descriptor(t *testing.T) {
+	var body map[string]json.RawMessage
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body = nil
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("Failed to decode request body: %v", err)
+		}
+		fmt.Fprint(w, `{"id":"txn_123"}`)
+	}))
+	defer server.Close()
+
+	ctx := context.Background()
+	for _, decimalStrings := range []bool{false, true} {
+		sdk := NewSDK(&Config{BaseURL: server.URL, DecimalStringAmounts: decimalStrings})
+		req := &TransactionRequest{
+			Amount:          100,
+			Currency:        "USD",
+			MerchantID:      "merchant_123",
+			CardToken:       "tok_123",
+			DescriptorCity:  " NEW YORK ",
+			DescriptorPhone: "+1 (800) 555-0100",
+		}
+		if _, err := sdk.Transactions.AuthorizeTransaction(ctx, req); err != nil {
+			t.Fatalf("AuthorizeTransaction() error = %v", err)
+		}
+		if want := `{"city":"NEW YORK","phone":"+18005550100"}`; string(body["descriptor"]) != want {
+			t.Errorf("Expected descriptor %s, got %s", want, body["descriptor"])
+		}
+		if _, ok := body["DescriptorCity"]; ok {
+			t.Error("Expected the descriptor fields to be sent in the descriptor object only")
+		}
+
+		// Omitting them sends no descriptor, as before
+		req.DescriptorCity, req.DescriptorPhone = "", ""
+		if _, err := sdk.Transactions.AuthorizeTransaction(ctx, req); err != nil {
+			t.Fatalf("AuthorizeTransaction() error = %v", err)
+		}
+		if _, ok := body["descriptor"]; ok {
+			t.Errorf("Expected no descriptor, got %s", body["descriptor"])
+		}
+	}
+}
+
+func TestTransactionService_CaptureLineItems(t *testing.T) {
+	var body map[string]json.RawMessage
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body = nil
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("Failed to decode request body: %v", err)
+		}
+		fmt.Fprint(w, `{"id":"txn_123","status":"captured","line_items":[{"sku":"SKU-1","quantity":2,"amount":39.98}]}`)
+	}))
+	defer server.Close()
+
+	sdk := NewSDK(&Config{BaseURL: server.URL, DecimalStringAmounts: true})
+	captured, err := sdk.Transactions.CaptureTransaction(context.Background(), "txn_123", &CaptureTransactionRequest{
+		Amount:    Ptr(39.98),
+		Currency:  "USD",
+		LineItems: []LineItem{{SKU: "SKU-1", Quantity: 2, Amount: 39.98}},
+	})
+	if err != nil {
+		t.Fatalf("CaptureTransaction() error = %v", err)
+	}
+
+	if want := `[{"sku":"SKU-1","quantity":2,"amount":"39.98"}]`; string(body["line_items"]) != want {
+		t.Errorf("Expected line items %s, got %s", want, body["line_items"])
+	}
+	if len(captured.LineItems) != 1 || captured.LineItems[0].SKU != "SKU-1" || captured.LineItems[0].Quantity != 2 {
+		t.Errorf("Expected the captured line items to be returned, got %+v", captured.LineItems)
+	}
+}
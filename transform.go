@@ -0,0 +1,51 @@
+package americanexpress
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+)
+
+// ResponseTransformer rewrites a successful response body before it is
+// decoded, e.g. to rename fields for a tenant whose schema differs from the
+// one the SDK expects. resp describes the response; its body has already
+// been read and must not be used. It may be called concurrently.
+type ResponseTransformer func(resp *http.Response, body []byte) ([]byte, error)
+
+// RenameJSONFields returns a ResponseTransformer that renames object keys
+// anywhere in a JSON body, e.g. {"transactionId": "transaction_id"}. Keys
+// are renamed at any depth, metadata included. Bodies that are not JSON are
+// left unchanged.
+func RenameJSONFields(renames map[string]string) ResponseTransformer {
+	return func(resp *http.Response, body []byte) ([]byte, error) {
+		decoder := json.NewDecoder(bytes.NewReader(body))
+		decoder.UseNumber()
+		var v interface{}
+		if err := decoder.Decode(&v); err != nil {
+			return body, nil
+		}
+		return json.Marshal(renameFields(v, renames))
+	}
+}
+
+// renameFields renames the keys of every object in a decoded JSON value
+func renameFields(v interface{}, renames map[string]string) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		renamed := make(map[string]interface{}, len(v))
+		for key, value := range v {
+			if to, ok := renames[key]; ok {
+				key = to
+			}
+			renamed[key] = renameFields(value, renames)
+		}
+		return renamed
+	case []interface{}:
+		for i, value := range v {
+			v[i] = renameFields(value, renames)
+		}
+		return v
+	default:
+		return v
+	}
+}
@@ -0,0 +1,200 @@
+package americanexpress
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// MaxEvidenceFileSize is the largest file, in bytes, that can be uploaded
+// with dispute evidence
+const MaxEvidenceFileSize = 10 << 20
+
+// evidenceContentTypes are the file types accepted as dispute evidence
+var evidenceContentTypes = map[string]bool{
+	"application/pdf": true,
+	"image/jpeg":      true,
+	"image/png":       true,
+	"image/tiff":      true,
+	"text/plain":      true,
+}
+
+// EvidenceFile is a document uploaded along with dispute evidence, e.g. a
+// scanned receipt or a proof of delivery
+type EvidenceFile struct {
+	Type        string    // e.g. "receipt", "proof_of_delivery", "correspondence"
+	Description string    // Optional
+	FileName    string    // Name shown to the card issuer
+	ContentType string    // PDF, JPEG, PNG, TIFF or plain text; detected from FileName when empty
+	Content     io.Reader // Streamed as is. Uploads are only retried when every Content is an io.Seeker.
+}
+
+// contentType returns the MIME type of the file, falling back to the type
+// implied by its extension
+func (f *EvidenceFile) contentType() string {
+	contentType := f.ContentType
+	if contentType == "" {
+		contentType = mime.TypeByExtension(filepath.Ext(f.FileName))
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return ""
+	}
+	return mediaType
+}
+
+// size returns the size of the remaining content when it can be told
+// without reading it
+func (f *EvidenceFile) size() (int64, bool) {
+	switch content := f.Content.(type) {
+	case interface{ Len() int }:
+		return int64(content.Len()), true
+	case *os.File:
+		info, err := content.Stat()
+		if err != nil || !info.Mode().IsRegular() {
+			return 0, false
+		}
+		offset, err := content.Seek(0, io.SeekCurrent)
+		if err != nil {
+			return 0, false
+		}
+		return info.Size() - offset, true
+	default:
+		return 0, false
+	}
+}
+
+// streamingBody is a request body that is streamed rather than marshalled
+// as JSON
+type streamingBody interface {
+	// open returns a reader over the body and its content type. It is called
+	// once per attempt.
+	open() (io.ReadCloser, string, error)
+	// replayable reports whether the body can be opened more than once
+	replayable() bool
+}
+
+// evidenceUpload is a multipart/form-data body carrying dispute evidence
+// and its files. The evidence is sent as JSON in the "evidence" part,
+// listing the files in the order of the "files" parts that follow.
+type evidenceUpload struct {
+	evidence *Evidence
+	files    []EvidenceFile
+	offsets  []int64 // Start of each seekable file, to rewind before a retry
+}
+
+// evidenceFileInfo describes an uploaded file in the evidence part
+type evidenceFileInfo struct {
+	Type        string `json:"type"`
+	Description string `json:"description,omitempty"`
+	FileName    string `json:"file_name"`
+	ContentType string `json:"content_type"`
+}
+
+// newEvidenceUpload prepares a multipart body for evidence and its files
+func newEvidenceUpload(evidence *Evidence, files []EvidenceFile) (*evidenceUpload, error) {
+	upload := &evidenceUpload{evidence: evidence, files: files, offsets: make([]int64, len(files))}
+	for i, file := range files {
+		if seeker, ok := file.Content.(io.Seeker); ok {
+			offset, err := seeker.Seek(0, io.SeekCurrent)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read file %q: %w", file.FileName, err)
+			}
+			upload.offsets[i] = offset
+		}
+	}
+	return upload, nil
+}
+
+// MarshalJSON encodes the evidence part, so that idempotency keys derived
+// from the body tell uploads apart
+func (u *evidenceUpload) MarshalJSON() ([]byte, error) {
+	files := make([]evidenceFileInfo, len(u.files))
+	for i, file := range u.files {
+		files[i] = evidenceFileInfo{
+			Type:        file.Type,
+			Description: file.Description,
+			FileName:    file.FileName,
+			ContentType: file.contentType(),
+		}
+	}
+	return json.Marshal(struct {
+		*Evidence
+		Files []evidenceFileInfo `json:"files"`
+	}{u.evidence, files})
+}
+
+func (u *evidenceUpload) replayable() bool {
+	for _, file := range u.files {
+		if _, ok := file.Content.(io.Seeker); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// open rewinds the files and streams the multipart body through a pipe
+func (u *evidenceUpload) open() (io.ReadCloser, string, error) {
+	for i, file := range u.files {
+		if seeker, ok := file.Content.(io.Seeker); ok {
+			if _, err := seeker.Seek(u.offsets[i], io.SeekStart); err != nil {
+				return nil, "", fmt.Errorf("failed to rewind file %q: %w", file.FileName, err)
+			}
+		}
+	}
+
+	pr, pw := io.Pipe()
+	writer := multipart.NewWriter(pw)
+	go func() {
+		err := u.write(writer)
+		if err == nil {
+			err = writer.Close()
+		}
+		pw.CloseWithError(err)
+	}()
+	return pr, writer.FormDataContentType(), nil
+}
+
+// write writes the evidence part followed by one part per file
+func (u *evidenceUpload) write(writer *multipart.Writer) error {
+	header := textproto.MIMEHeader{}
+	header.Set("Content-Disposition", `form-data; name="evidence"`)
+	header.Set("Content-Type", "application/json")
+	part, err := writer.CreatePart(header)
+	if err != nil {
+		return err
+	}
+	if err := json.NewEncoder(part).Encode(u); err != nil {
+		return fmt.Errorf("failed to marshal evidence: %w", err)
+	}
+
+	for i, file := range u.files {
+		header := textproto.MIMEHeader{}
+		header.Set("Content-Disposition", fmt.Sprintf(`form-data; name="files"; filename="%s"`, escapeQuotes(file.FileName)))
+		header.Set("Content-Type", file.contentType())
+		part, err := writer.CreatePart(header)
+		if err != nil {
+			return err
+		}
+		// Files whose size is unknown up front are checked as they stream
+		n, err := io.Copy(part, io.LimitReader(file.Content, MaxEvidenceFileSize+1))
+		if err != nil {
+			return fmt.Errorf("failed to read file %q: %w", file.FileName, err)
+		}
+		if n > MaxEvidenceFileSize {
+			return evidenceFileTooLarge(i)
+		}
+	}
+	return nil
+}
+
+// escapeQuotes escapes a file name for a Content-Disposition header
+func escapeQuotes(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s)
+}
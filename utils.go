@@ -4,6 +4,8 @@ import (
 	"net/url"
 	"reflect"
 	"strconv"
+	"strings"
+	"time"
 )
 
 // encodeQuery converts a struct to URL query values
@@ -29,7 +31,7 @@ func encodeQuery(v interface{}) (url.Values, error) {
 		fieldType := typ.Field(i)
 		
 		// Get the tag value
-		tag := fieldType.Tag.Get("url")
+		tag, _, _ := strings.Cut(fieldType.Tag.Get("url"), ",")
 		if tag == "" || tag == "-" {
 			continue
 		}
@@ -64,4 +66,99 @@ func encodeQuery(v interface{}) (url.Values, error) {
 	}
 	
 	return values, nil
-}
\ No newline at end of file
+}
+
+// mergeMetadata returns a new map holding the default metadata overlaid with
+// the request metadata, so request keys take precedence. Neither input map
+// is modified. It returns nil when both are empty.
+func mergeMetadata(defaults, metadata map[string]string) map[string]string {
+	if len(defaults) == 0 && len(metadata) == 0 {
+		return metadata
+	}
+
+	merged := make(map[string]string, len(defaults)+len(metadata))
+	for k, v := range defaults {
+		merged[k] = v
+	}
+	for k, v := range metadata {
+		merged[k] = v
+	}
+	return merged
+}
+
+// sanitizeUserAgent keeps only the characters allowed in a User-Agent
+// product list (RFC 9110 tokens, "/", comments and spaces), replacing
+// anything else with "_" and collapsing runs of whitespace
+func sanitizeUserAgent(ua string) string {
+	var b strings.Builder
+	for _, r := range ua {
+		switch {
+		case r == ' ' || r == '\t' || r == '\n' || r == '\r':
+			b.WriteRune(' ')
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+			b.WriteRune(r)
+		case strings.ContainsRune("!#$%&'*+-.^_`|~/();,:", r):
+			b.WriteRune(r)
+		default:
+			b.WriteRune('_')
+		}
+	}
+	return strings.Join(strings.Fields(b.String()), " ")
+}
+
+// Ptr returns a pointer to v. It sets the optional fields of update
+// requests, e.g. Ptr("") to clear a field.
+func Ptr[T any](v T) *T {
+	return &v
+}
+
+// clonePtr returns a pointer to a copy of the value p points to, or nil
+func clonePtr[T any](p *T) *T {
+	if p == nil {
+		return nil
+	}
+	v := *p
+	return &v
+}
+
+// stringValue returns the string s points to, or "" when s is nil
+func stringValue(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
+
+// DateLayout is the layout of the dates in date-range filters
+const DateLayout = "2006-01-02"
+
+// addDateRange adds the start_date and end_date filters of a list request.
+// Each is taken from its date string if set, and otherwise from its time
+// converted to UTC.
+func (c *Client) addDateRange(query url.Values, startDate string, startTime time.Time, endDate string, endTime time.Time) {
+	if date := c.dateFilter("start_date", startDate, startTime); date != "" {
+		query.Add("start_date", date)
+	}
+	if date := c.dateFilter("end_date", endDate, endTime); date != "" {
+		query.Add("end_date", date)
+	}
+}
+
+// dateFilter returns the value of a date filter set as a string, a time or
+// both. The string wins over the time, with a warning as they may disagree.
+func (c *Client) dateFilter(param, date string, t time.Time) string {
+	if date != "" {
+		if !t.IsZero() && c.logger != nil {
+			c.logger.Warn("amex date filter set as both string and time, using the string",
+				"param", param,
+				"date", date,
+				"time", t.UTC().Format(DateLayout),
+			)
+		}
+		return date
+	}
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(DateLayout)
+}
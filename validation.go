@@ -3,8 +3,14 @@ package americanexpress
 import (
 	"errors"
 	"fmt"
+	"math"
+	"net/mail"
+	"net/url"
 	"regexp"
+	"sort"
 	"strings"
+	"time"
+	"unicode/utf8"
 )
 
 var (
@@ -20,37 +26,115 @@ var (
 	ErrInvalidCurrency = errors.New("invalid currency")
 )
 
+// Validation error codes. They are stable, so callers can map them to
+// localized messages together with ValidationError.Field.
+const (
+	ValidationCodeRequired        = "required"
+	ValidationCodeInvalid         = "invalid"
+	ValidationCodeOutOfRange      = "out_of_range"
+	ValidationCodeInvalidLength   = "invalid_length"
+	ValidationCodeUnsupported     = "unsupported"
+	ValidationCodeConflict        = "conflict"
+	ValidationCodeTooManyDecimals = "too_many_decimals"
+)
+
+// ValidationError describes why a request failed validation. Message is the
+// default English description; use Field and Code to show a localized one.
+// Errors for which a sentinel such as ErrInvalidCVV exists unwrap to it.
+type ValidationError struct {
+	// Field is the JSON path of the offending field, e.g. "card_details.cvv".
+	// It is empty when the whole request is invalid.
+	Field   string
+	Code    string
+	Message string
+	Err     error
+}
+
+func (e *ValidationError) Error() string {
+	return e.Message
+}
+
+// Unwrap returns the sentinel error, if any
+func (e *ValidationError) Unwrap() error {
+	return e.Err
+}
+
+// validationError creates a validation error for a field
+func validationError(field, code, message string) error {
+	return &ValidationError{Field: field, Code: code, Message: message}
+}
+
+// sentinelError creates a validation error that unwraps to a sentinel. The
+// message is the sentinel's, followed by detail when given.
+func sentinelError(field, code string, sentinel error, detail string) error {
+	message := sentinel.Error()
+	if detail != "" {
+		message += ": " + detail
+	}
+	return &ValidationError{Field: field, Code: code, Message: message, Err: sentinel}
+}
+
+// nestValidationError reports a validation error of a nested object under
+// the given field, prefixing its message with prefix when one is given
+func nestValidationError(field, prefix string, err error) error {
+	var ve *ValidationError
+	if !errors.As(err, &ve) {
+		if prefix == "" {
+			return err
+		}
+		return fmt.Errorf("%s: %w", prefix, err)
+	}
+
+	nested := *ve
+	if prefix != "" {
+		nested.Message = prefix + ": " + ve.Message
+	}
+	if ve.Field != "" {
+		nested.Field = field + "." + ve.Field
+	} else {
+		nested.Field = field
+	}
+	return &nested
+}
+
 // cardNumberRegex matches basic card number patterns
 var cardNumberRegex = regexp.MustCompile(`^\d{13,19}$`)
 
 // ValidateCardDetails validates card details
 func ValidateCardDetails(card *CardDetails) error {
 	if card == nil {
-		return errors.New("card details cannot be nil")
+		return validationError("card_details", ValidationCodeRequired, "card details cannot be nil")
 	}
 
 	// Remove spaces and validate card number
 	cardNumber := strings.ReplaceAll(card.Number, " ", "")
 	if !cardNumberRegex.MatchString(cardNumber) {
-		return ErrInvalidCardNumber
+		return sentinelError("number", ValidationCodeInvalid, ErrInvalidCardNumber, "")
 	}
 
 	// Validate expiry date
 	if card.ExpiryMonth < 1 || card.ExpiryMonth > 12 {
-		return fmt.Errorf("%w: month must be 1-12", ErrInvalidExpiryDate)
+		return sentinelError("expiry_month", ValidationCodeOutOfRange, ErrInvalidExpiryDate, "month must be 1-12")
 	}
 	if card.ExpiryYear < 2020 || card.ExpiryYear > 2099 {
-		return fmt.Errorf("%w: year must be 2020-2099", ErrInvalidExpiryDate)
+		return sentinelError("expiry_year", ValidationCodeOutOfRange, ErrInvalidExpiryDate, "year must be 2020-2099")
 	}
 
-	// Validate CVV
-	if len(card.CVV) < 3 || len(card.CVV) > 4 {
-		return ErrInvalidCVV
+	// Amex cards have 15 digits and a 4-digit CID; other brands a 3-digit CVV
+	if DetectCardBrand(cardNumber) == CardBrandAmex {
+		if len(cardNumber) != AmexCardNumberLength {
+			return sentinelError("number", ValidationCodeInvalidLength, ErrInvalidCardNumber, fmt.Sprintf("American Express card numbers have %d digits", AmexCardNumberLength))
+		}
+		if len(card.CVV) != AmexCIDLength {
+			return sentinelError("cvv", ValidationCodeInvalidLength, ErrInvalidCVV, fmt.Sprintf("American Express cards have a %d-digit CID", AmexCIDLength))
+		}
+	} else if len(card.CVV) != 3 {
+		return sentinelError("cvv", ValidationCodeInvalidLength, ErrInvalidCVV, "")
 	}
 
 	// Validate holder name
 	if strings.TrimSpace(card.HolderName) == "" {
-		return errors.New("holder name cannot be empty")
+		return validationError("holder_name", ValidationCodeRequired, "holder name cannot be empty")
 	}
 
 	return nil
@@ -59,36 +143,119 @@ func ValidateCardDetails(card *CardDetails) error {
 // ValidatePaymentRequest validates a payment request
 func ValidatePaymentRequest(req *PaymentRequest) error {
 	if req == nil {
-		return errors.New("payment request cannot be nil")
+		return validationError("", ValidationCodeRequired, "payment request cannot be nil")
 	}
 
 	// Validate amount
 	if req.Amount <= 0 {
-		return ErrInvalidAmount
+		return sentinelError("amount", ValidationCodeOutOfRange, ErrInvalidAmount, "")
 	}
 
 	// Validate currency
 	if req.Currency == "" {
-		return ErrInvalidCurrency
+		return sentinelError("currency", ValidationCodeRequired, ErrInvalidCurrency, "")
 	}
 	if len(req.Currency) != 3 {
-		return fmt.Errorf("%w: currency must be 3 characters", ErrInvalidCurrency)
+		return sentinelError("currency", ValidationCodeInvalidLength, ErrInvalidCurrency, "currency must be 3 characters")
 	}
 
 	// Validate merchant ID
 	if strings.TrimSpace(req.MerchantID) == "" {
-		return errors.New("merchant ID cannot be empty")
+		return validationError("merchant_id", ValidationCodeRequired, "merchant ID cannot be empty")
+	}
+
+	// Validate the payment method
+	if err := validatePaymentMethod(req.CardToken, req.CardDetails, req.NetworkToken, nil); err != nil {
+		return err
+	}
+
+	if err := validateAddressCountries(req.BillingAddr, req.ShippingAddr); err != nil {
+		return err
+	}
+
+	// Validate marketplace splits if provided
+	if len(req.Splits) > 0 {
+		if err := validateSplits(req.Splits, req.Amount, req.Currency); err != nil {
+			return err
+		}
+	}
+
+	return validateAmountBreakdown(req.Amount, req.BaseAmount, req.TaxAmount, req.SurchargeAmount, req.Currency)
+}
+
+// ValidateNetworkToken validates a network token
+func ValidateNetworkToken(token *NetworkToken) error {
+	if token == nil {
+		return validationError("", ValidationCodeRequired, "network token cannot be nil")
+	}
+
+	if !cardNumberRegex.MatchString(token.Token) {
+		return validationError("token", ValidationCodeInvalid, "invalid network token number")
+	}
+
+	if strings.TrimSpace(token.Cryptogram) == "" {
+		return validationError("cryptogram", ValidationCodeRequired, "cryptogram is required for network tokens")
+	}
+
+	if token.ExpiryMonth < 1 || token.ExpiryMonth > 12 {
+		return sentinelError("expiry_month", ValidationCodeOutOfRange, ErrInvalidExpiryDate, "month must be 1-12")
+	}
+	if token.ExpiryYear < 2020 || token.ExpiryYear > 2099 {
+		return sentinelError("expiry_year", ValidationCodeOutOfRange, ErrInvalidExpiryDate, "year must be 2020-2099")
+	}
+
+	return nil
+}
+
+// ValidateWalletPayment validates a digital wallet payment
+func ValidateWalletPayment(wallet *WalletPayment) error {
+	if wallet == nil {
+		return validationError("", ValidationCodeRequired, "wallet payment cannot be nil")
+	}
+
+	if wallet.Type != WalletApplePay && wallet.Type != WalletGooglePay {
+		return validationError("type", ValidationCodeUnsupported, fmt.Sprintf("unsupported wallet type %q", wallet.Type))
+	}
+
+	if strings.TrimSpace(wallet.Payload) == "" {
+		return validationError("payload", ValidationCodeRequired, "wallet payload cannot be empty")
+	}
+
+	return nil
+}
+
+// validatePaymentMethod checks that exactly one kind of payment method is
+// provided and that it is valid
+func validatePaymentMethod(cardToken string, card *CardDetails, networkToken *NetworkToken, wallet *WalletPayment) error {
+	if wallet != nil {
+		if cardToken != "" || card != nil || networkToken != nil {
+			return validationError("wallet", ValidationCodeConflict, "wallet payment cannot be combined with another payment method")
+		}
+		if err := ValidateWalletPayment(wallet); err != nil {
+			return nestValidationError("wallet", "invalid wallet payment", err)
+		}
+		return nil
+	}
+
+	if networkToken != nil {
+		if cardToken != "" || card != nil {
+			return validationError("network_token", ValidationCodeConflict, "network token cannot be combined with card token or card details")
+		}
+		if err := ValidateNetworkToken(networkToken); err != nil {
+			return nestValidationError("network_token", "invalid network token", err)
+		}
+		return nil
 	}
 
 	// Validate that either card token or card details are provided
-	if req.CardToken == "" && req.CardDetails == nil {
-		return errors.New("either card token or card details must be provided")
+	if cardToken == "" && card == nil {
+		return validationError("card_token", ValidationCodeRequired, "either card token or card details must be provided")
 	}
 
 	// If card details are provided, validate them
-	if req.CardDetails != nil {
-		if err := ValidateCardDetails(req.CardDetails); err != nil {
-			return fmt.Errorf("invalid card details: %w", err)
+	if card != nil {
+		if err := ValidateCardDetails(card); err != nil {
+			return nestValidationError("card_details", "invalid card details", err)
 		}
 	}
 
@@ -98,78 +265,788 @@ func ValidatePaymentRequest(req *PaymentRequest) error {
 // ValidateTokenRequest validates a token request
 func ValidateTokenRequest(req *TokenRequest) error {
 	if req == nil {
-		return errors.New("token request cannot be nil")
+		return validationError("", ValidationCodeRequired, "token request cannot be nil")
 	}
 
 	if req.CardDetails == nil {
-		return errors.New("card details are required for token creation")
+		return validationError("card_details", ValidationCodeRequired, "card details are required for token creation")
+	}
+
+	if err := ValidateCardDetails(req.CardDetails); err != nil {
+		return nestValidationError("card_details", "", err)
+	}
+
+	if req.BillingAddr != nil {
+		if err := ValidateAddress(req.BillingAddr); err != nil {
+			return nestValidationError("billing_address", "invalid billing address", err)
+		}
 	}
 
-	return ValidateCardDetails(req.CardDetails)
+	return nil
 }
 
-// SupportedCurrencies returns a list of supported currencies
-func SupportedCurrencies() []string {
-	return []string{
-		"USD", "EUR", "GBP", "CAD", "AUD", "JPY", "CHF", "SGD", "HKD", "SEK",
-		"NOK", "DKK", "PLN", "CZK", "HUF", "ILS", "MXN", "BRL", "ARS", "CLP",
+// ValidateEvidence validates dispute evidence
+func ValidateEvidence(evidence *Evidence) error {
+	if evidence == nil {
+		return validationError("", ValidationCodeRequired, "evidence cannot be nil")
+	}
+
+	if strings.TrimSpace(evidence.Rebuttal) == "" {
+		return validationError("rebuttal", ValidationCodeRequired, "rebuttal cannot be empty")
 	}
+
+	for i, doc := range evidence.Documents {
+		if strings.TrimSpace(doc.ID) == "" {
+			return validationError(fmt.Sprintf("documents[%d].id", i), ValidationCodeRequired, fmt.Sprintf("document %d: ID cannot be empty", i))
+		}
+	}
+
+	return nil
 }
 
-// IsSupportedCurrency checks if a currency is supported
-func IsSupportedCurrency(currency string) bool {
-	supported := SupportedCurrencies()
-	for _, c := range supported {
-		if strings.EqualFold(c, currency) {
-			return true
+// ValidateEvidenceFiles validates files uploaded with dispute evidence.
+// Sizes are checked here when they can be told without reading the file,
+// and while uploading otherwise.
+func ValidateEvidenceFiles(files []EvidenceFile) error {
+	for i, file := range files {
+		field := fmt.Sprintf("files[%d]", i)
+		if file.Content == nil {
+			return validationError(field+".content", ValidationCodeRequired, fmt.Sprintf("file %d: content cannot be nil", i))
+		}
+		if strings.TrimSpace(file.FileName) == "" {
+			return validationError(field+".file_name", ValidationCodeRequired, fmt.Sprintf("file %d: file name cannot be empty", i))
+		}
+		if strings.TrimSpace(file.Type) == "" {
+			return validationError(field+".type", ValidationCodeRequired, fmt.Sprintf("file %d: type cannot be empty", i))
+		}
+		if contentType := file.contentType(); !evidenceContentTypes[contentType] {
+			return validationError(field+".content_type", ValidationCodeUnsupported, fmt.Sprintf("file %d: unsupported content type %q", i, contentType))
+		}
+		if size, ok := file.size(); ok && size > MaxEvidenceFileSize {
+			return evidenceFileTooLarge(i)
 		}
 	}
-	return false
+
+	return nil
 }
 
-// ValidateTransactionRequest validates a transaction request
-func ValidateTransactionRequest(req *TransactionRequest) error {
+// evidenceFileTooLarge reports a file exceeding MaxEvidenceFileSize
+func evidenceFileTooLarge(i int) error {
+	return validationError(fmt.Sprintf("files[%d]", i), ValidationCodeOutOfRange, fmt.Sprintf("file %d: file cannot exceed %d bytes", i, MaxEvidenceFileSize))
+}
+
+// ValidateEnrollmentRequest validates a SafeKey enrollment check request
+func ValidateEnrollmentRequest(req *EnrollmentRequest) error {
 	if req == nil {
-		return errors.New("transaction request cannot be nil")
+		return validationError("", ValidationCodeRequired, "enrollment request cannot be nil")
 	}
 
 	// Validate amount
 	if req.Amount <= 0 {
-		return ErrInvalidAmount
+		return sentinelError("amount", ValidationCodeOutOfRange, ErrInvalidAmount, "")
 	}
 
 	// Validate currency
-	if req.Currency == "" {
-		return ErrInvalidCurrency
-	}
 	if len(req.Currency) != 3 {
-		return fmt.Errorf("%w: currency must be 3 characters", ErrInvalidCurrency)
+		return sentinelError("currency", ValidationCodeInvalidLength, ErrInvalidCurrency, "currency must be 3 characters")
 	}
 
 	// Validate merchant ID
 	if strings.TrimSpace(req.MerchantID) == "" {
-		return errors.New("merchant ID cannot be empty")
+		return validationError("merchant_id", ValidationCodeRequired, "merchant ID cannot be empty")
 	}
 
 	// Validate that either card token or card details are provided
 	if req.CardToken == "" && req.CardDetails == nil {
-		return errors.New("either card token or card details must be provided")
+		return validationError("card_token", ValidationCodeRequired, "either card token or card details must be provided")
 	}
 
 	// If card details are provided, validate them
 	if req.CardDetails != nil {
 		if err := ValidateCardDetails(req.CardDetails); err != nil {
-			return fmt.Errorf("invalid card details: %w", err)
+			return nestValidationError("card_details", "invalid card details", err)
+		}
+	}
+
+	if req.Browser == nil && req.Device == nil {
+		return validationError("browser", ValidationCodeRequired, "either browser or device information must be provided")
+	}
+
+	return nil
+}
+
+// ValidateAuthenticationRequest validates a SafeKey authentication request
+func ValidateAuthenticationRequest(req *AuthenticationRequest) error {
+	if req == nil {
+		return validationError("", ValidationCodeRequired, "authentication request cannot be nil")
+	}
+
+	if strings.TrimSpace(req.ThreeDSServerTransID) == "" {
+		return validationError("three_ds_server_trans_id", ValidationCodeRequired, "3DS server transaction ID cannot be empty")
+	}
+
+	return nil
+}
+
+// ValidateMerchantRequest validates a request to create a merchant
+func ValidateMerchantRequest(req *MerchantRequest) error {
+	if req == nil {
+		return validationError("", ValidationCodeRequired, "merchant request cannot be nil")
+	}
+
+	if strings.TrimSpace(req.Name) == "" {
+		return validationError("name", ValidationCodeRequired, "merchant name cannot be empty")
+	}
+
+	return validateMerchantFields(req.Email, req.Website, req.Address)
+}
+
+// ValidateMerchantUpdate validates a request to update a merchant. Only the
+// fields that are set are checked; fields set to an empty string are
+// cleared, except the name, which cannot be.
+func ValidateMerchantUpdate(req *UpdateMerchantRequest) error {
+	if req == nil {
+		return validationError("", ValidationCodeRequired, "merchant request cannot be nil")
+	}
+
+	if req.Name != nil && strings.TrimSpace(*req.Name) == "" {
+		return validationError("name", ValidationCodeRequired, "merchant name cannot be cleared")
+	}
+
+	return validateMerchantFields(stringValue(req.Email), stringValue(req.Website), req.Address)
+}
+
+// validateMerchantFields checks the format of the merchant fields that are
+// not empty
+func validateMerchantFields(email, website string, address *Address) error {
+	if email != "" {
+		if err := validateEmail(email); err != nil {
+			return err
+		}
+	}
+
+	if website != "" {
+		u, err := url.Parse(website)
+		if err != nil || u.Scheme != "https" || u.Host == "" {
+			return validationError("website", ValidationCodeInvalid, "website must be an https URL")
+		}
+	}
+
+	if address != nil && !IsValidCountryCode(address.Country) {
+		return validationError("address.country", ValidationCodeInvalid, "address country must be an ISO 3166-1 alpha-2 code")
+	}
+
+	return nil
+}
+
+// validateEmail checks that an email is a bare address, without a display
+// name
+func validateEmail(email string) error {
+	addr, err := mail.ParseAddress(email)
+	if err != nil || addr.Address != email {
+		return validationError("email", ValidationCodeInvalid, "invalid email address")
+	}
+	return nil
+}
+
+// ValidateCustomerRequest validates a request to create a customer
+func ValidateCustomerRequest(req *CustomerRequest) error {
+	if req == nil {
+		return validationError("", ValidationCodeRequired, "customer request cannot be nil")
+	}
+
+	if req.Email == "" {
+		return validationError("email", ValidationCodeRequired, "customer email cannot be empty")
+	}
+
+	return validateEmail(req.Email)
+}
+
+// ValidateCustomerUpdate validates a request to update a customer. Only the
+// fields that are set are checked; fields set to an empty string are
+// cleared, except the email, which cannot be.
+func ValidateCustomerUpdate(req *UpdateCustomerRequest) error {
+	if req == nil {
+		return validationError("", ValidationCodeRequired, "customer request cannot be nil")
+	}
+
+	if req.Email != nil {
+		if *req.Email == "" {
+			return validationError("email", ValidationCodeRequired, "customer email cannot be cleared")
+		}
+		return validateEmail(*req.Email)
+	}
+
+	return nil
+}
+
+// ValidateSubscriptionRequest validates a request to create a subscription
+func ValidateSubscriptionRequest(req *SubscriptionRequest) error {
+	if req == nil {
+		return validationError("", ValidationCodeRequired, "subscription request cannot be nil")
+	}
+
+	// Validate amount
+	if req.Amount <= 0 {
+		return sentinelError("amount", ValidationCodeOutOfRange, ErrInvalidAmount, "")
+	}
+
+	// Validate currency
+	if len(req.Currency) != 3 {
+		return sentinelError("currency", ValidationCodeInvalidLength, ErrInvalidCurrency, "currency must be 3 characters")
+	}
+
+	// Validate merchant ID
+	if strings.TrimSpace(req.MerchantID) == "" {
+		return validationError("merchant_id", ValidationCodeRequired, "merchant ID cannot be empty")
+	}
+
+	if strings.TrimSpace(req.TokenID) == "" {
+		return validationError("token_id", ValidationCodeRequired, "token ID cannot be empty")
+	}
+
+	if err := validateSubscriptionInterval(req.Interval, req.IntervalCount); err != nil {
+		return err
+	}
+
+	if _, err := time.Parse("2006-01-02", req.StartDate); err != nil {
+		return validationError("start_date", ValidationCodeInvalid, "start date must be in YYYY-MM-DD format")
+	}
+
+	return nil
+}
+
+// ValidateUpdateSubscriptionRequest validates a change to a subscription.
+// Only the fields that are set are checked.
+func ValidateUpdateSubscriptionRequest(req *UpdateSubscriptionRequest) error {
+	if req == nil {
+		return validationError("", ValidationCodeRequired, "subscription request cannot be nil")
+	}
+
+	if req.Amount != nil && *req.Amount <= 0 {
+		return sentinelError("amount", ValidationCodeOutOfRange, ErrInvalidAmount, "")
+	}
+
+	if req.Interval != "" || req.IntervalCount != 0 {
+		interval := req.Interval
+		if interval == "" {
+			// Only the count changes; any valid unit will do for the check
+			interval = IntervalMonth
+		}
+		if err := validateSubscriptionInterval(interval, req.IntervalCount); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// validateSubscriptionInterval checks a subscription's billing cycle
+func validateSubscriptionInterval(interval SubscriptionInterval, count int) error {
+	switch interval {
+	case IntervalDay, IntervalWeek, IntervalMonth, IntervalYear:
+	default:
+		return validationError("interval", ValidationCodeUnsupported, fmt.Sprintf("unsupported subscription interval %q", interval))
+	}
+
+	if count < 0 {
+		return validationError("interval_count", ValidationCodeOutOfRange, "interval count cannot be negative")
+	}
+
+	return nil
+}
+
+// supportedCurrencies is the set of currencies the SDK knows, in upper case
+var supportedCurrencies = newCurrencySet(
+	"USD", "EUR", "GBP", "CAD", "AUD", "JPY", "CHF", "SGD", "HKD", "SEK",
+	"NOK", "DKK", "PLN", "CZK", "HUF", "ILS", "MXN", "BRL", "ARS", "CLP",
+)
+
+// sortedCurrencies lists supportedCurrencies in alphabetical order
+var sortedCurrencies = sortedKeys(supportedCurrencies)
+
+// newCurrencySet builds a set of normalized currency codes, dropping
+// duplicates
+func newCurrencySet(currencies ...string) map[string]struct{} {
+	set := make(map[string]struct{}, len(currencies))
+	for _, currency := range currencies {
+		set[NormalizeCurrency(currency)] = struct{}{}
+	}
+	return set
+}
+
+// sortedKeys returns the keys of a set in alphabetical order
+func sortedKeys(set map[string]struct{}) []string {
+	keys := make([]string, 0, len(set))
+	for key := range set {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// SupportedCurrencies returns a list of supported currencies, sorted
+// alphabetically. It is the static list of every currency the SDK knows;
+// use MerchantService.GetSupportedCurrencies for the ones a merchant is
+// enabled for. The returned slice is a copy and may be modified.
+func SupportedCurrencies() []string {
+	return append([]string(nil), sortedCurrencies...)
+}
+
+// IsSupportedCurrency checks if a currency is supported
+func IsSupportedCurrency(currency string) bool {
+	_, ok := supportedCurrencies[strings.ToUpper(currency)]
+	return ok
+}
+
+// currencyExponents lists the supported currencies whose minor unit is not
+// the usual two decimal places
+var currencyExponents = map[string]int{
+	"JPY": 0,
+	"CLP": 0,
+}
+
+// NormalizeCurrency returns the canonical ISO 4217 form of a currency
+// code, e.g. "usd" becomes "USD"
+func NormalizeCurrency(currency string) string {
+	return strings.ToUpper(strings.TrimSpace(currency))
+}
+
+// CurrencyExponent returns the number of decimal places used by a currency
+func CurrencyExponent(currency string) int {
+	if exponent, ok := currencyExponents[strings.ToUpper(currency)]; ok {
+		return exponent
+	}
+	return 2
+}
+
+// ValidateAmountPrecision checks that an amount has no more decimal places
+// than the currency allows, e.g. 10.999 USD or 10.5 JPY
+func ValidateAmountPrecision(amount float64, currency string) error {
+	exponent := CurrencyExponent(currency)
+	scaled := amount * math.Pow10(exponent)
+	if math.Abs(scaled-math.Round(scaled)) > 1e-6 {
+		return sentinelError("amount", ValidationCodeTooManyDecimals, ErrInvalidAmount, fmt.Sprintf("%s amounts allow at most %d decimal places", strings.ToUpper(currency), exponent))
+	}
+	return nil
+}
+
+// ValidateTransactionRequest validates a transaction request
+func ValidateTransactionRequest(req *TransactionRequest) error {
+	if req == nil {
+		return validationError("", ValidationCodeRequired, "transaction request cannot be nil")
+	}
+
+	// Validate amount
+	if req.Amount <= 0 {
+		return sentinelError("amount", ValidationCodeOutOfRange, ErrInvalidAmount, "")
+	}
+
+	// Validate currency
+	if req.Currency == "" {
+		return sentinelError("currency", ValidationCodeRequired, ErrInvalidCurrency, "")
+	}
+	if len(req.Currency) != 3 {
+		return sentinelError("currency", ValidationCodeInvalidLength, ErrInvalidCurrency, "currency must be 3 characters")
+	}
+
+	// Validate merchant ID
+	if strings.TrimSpace(req.MerchantID) == "" {
+		return validationError("merchant_id", ValidationCodeRequired, "merchant ID cannot be empty")
+	}
+
+	// Validate the payment method
+	if err := validatePaymentMethod(req.CardToken, req.CardDetails, req.NetworkToken, req.WalletPayment); err != nil {
+		return err
+	}
+
+	if err := validateAddressCountries(req.BillingAddr, req.ShippingAddr); err != nil {
+		return err
+	}
+
+	// Saving a card tokenizes the supplied card details for a customer
+	if req.SaveCard {
+		if req.CardDetails == nil || req.CardToken != "" {
+			return validationError("save_card", ValidationCodeConflict, "save card requires card details, not an existing card token")
+		}
+		if strings.TrimSpace(req.CustomerID) == "" {
+			return validationError("customer_id", ValidationCodeRequired, "customer ID is required to save a card")
 		}
 	}
 
-	// Validate capture mode if provided
+	// Validate SafeKey authentication data if provided
+	if req.ThreeDSecure != nil {
+		if req.ThreeDSecure.AuthenticationValue == "" || req.ThreeDSecure.ECI == "" {
+			return validationError("three_d_secure", ValidationCodeRequired, "3DS authentication value and ECI are required")
+		}
+	}
+
+	// Validate capture mode if provided; empty means manual
 	if req.CaptureMode != "" {
-		if req.CaptureMode != "auto" && req.CaptureMode != "manual" {
-			return errors.New("capture mode must be 'auto' or 'manual'")
+		if req.CaptureMode != CaptureModeAuto && req.CaptureMode != CaptureModeManual {
+			return validationError("capture_mode", ValidationCodeUnsupported, "capture mode must be 'auto' or 'manual'")
+		}
+	}
+
+	// A hold expiry only applies to authorizations captured later
+	if req.AuthorizationExpiry != nil && req.CaptureMode == CaptureModeAuto {
+		return validationError("authorization_expires_at", ValidationCodeConflict, "authorization expiry cannot be set when capturing automatically")
+	}
+
+	// Validate the merchant category code override if provided
+	if req.MCC != "" {
+		if err := validateMCC(req.MCC); err != nil {
+			return err
+		}
+	}
+
+	// Validate marketplace splits if provided
+	if len(req.Splits) > 0 {
+		if err := validateSplits(req.Splits, req.Amount, req.Currency); err != nil {
+			return err
 		}
 	}
 
+	if err := validateSettlement(req); err != nil {
+		return err
+	}
+
+	if err := validateAmountBreakdown(req.Amount, req.BaseAmount, req.TaxAmount, req.SurchargeAmount, req.Currency); err != nil {
+		return err
+	}
+
+	return validateDescriptorContact(req.DescriptorCity, req.DescriptorPhone)
+}
+
+// validateSettlement checks that a settlement currency, if any, differs from
+// the presentment currency, and that a settlement amount matches the amount
+// converted at the exchange rate, to the settlement currency's minor unit
+func validateSettlement(req *TransactionRequest) error {
+	if req.SettlementCurrency == "" {
+		if req.SettlementAmount != nil || req.ExchangeRate != 0 {
+			return validationError("settlement_currency", ValidationCodeRequired, "settlement currency is required with a settlement amount or exchange rate")
+		}
+		return nil
+	}
+	if len(req.SettlementCurrency) != 3 {
+		return sentinelError("settlement_currency", ValidationCodeInvalidLength, ErrInvalidCurrency, "settlement currency must be 3 characters")
+	}
+	if strings.EqualFold(req.SettlementCurrency, req.Currency) {
+		return validationError("settlement_currency", ValidationCodeConflict, "settlement currency must differ from the currency")
+	}
+	if req.ExchangeRate < 0 {
+		return validationError("exchange_rate", ValidationCodeOutOfRange, "exchange rate must be positive")
+	}
+	if req.SettlementAmount == nil {
+		return nil
+	}
+
+	if *req.SettlementAmount <= 0 {
+		return sentinelError("settlement_amount", ValidationCodeOutOfRange, ErrInvalidAmount, "settlement amount must be positive")
+	}
+	if ValidateAmountPrecision(*req.SettlementAmount, req.SettlementCurrency) != nil {
+		return sentinelError("settlement_amount", ValidationCodeTooManyDecimals, ErrInvalidAmount, fmt.Sprintf("%s amounts allow at most %d decimal places", strings.ToUpper(req.SettlementCurrency), CurrencyExponent(req.SettlementCurrency)))
+	}
+	if req.ExchangeRate == 0 {
+		return validationError("exchange_rate", ValidationCodeRequired, "exchange rate is required with a settlement amount")
+	}
+
+	tolerance := 0.5*math.Pow10(-CurrencyExponent(req.SettlementCurrency)) + 1e-9
+	if math.Abs(req.Amount*req.ExchangeRate-*req.SettlementAmount) > tolerance {
+		return validationError("settlement_amount", ValidationCodeConflict, "settlement amount must equal the amount converted at the exchange rate")
+	}
+	return nil
+}
+
+// validateAuthorizationExpiry checks that a requested authorization expiry
+// lies after now and within MaxAuthorizationHold of it
+func validateAuthorizationExpiry(expiry, now time.Time) error {
+	if !expiry.After(now) {
+		return validationError("authorization_expires_at", ValidationCodeOutOfRange, "authorization expiry must be in the future")
+	}
+	if expiry.Sub(now) > MaxAuthorizationHold {
+		return validationError("authorization_expires_at", ValidationCodeOutOfRange, fmt.Sprintf("authorization expiry must be within %d days", int(MaxAuthorizationHold.Hours()/24)))
+	}
+	return nil
+}
+
+// validateMCC checks that a merchant category code is a known 4-digit code
+func validateMCC(mcc string) error {
+	if !mccRegex.MatchString(mcc) {
+		return validationError("mcc", ValidationCodeInvalid, "MCC must be a 4-digit code")
+	}
+	if !IsValidMCC(mcc) {
+		return validationError("mcc", ValidationCodeUnsupported, fmt.Sprintf("MCC %s is not a known merchant category code", mcc))
+	}
+	return nil
+}
+
+// ValidateCaptureRequest validates a transaction capture request
+func ValidateCaptureRequest(req *CaptureTransactionRequest) error {
+	if req == nil {
+		return validationError("", ValidationCodeRequired, "capture request cannot be nil")
+	}
+
+	if req.Amount != nil && *req.Amount <= 0 {
+		return sentinelError("amount", ValidationCodeOutOfRange, ErrInvalidAmount, "")
+	}
+
+	if req.TipAdjustment != nil {
+		if err := validateTipAdjustment(req); err != nil {
+			return err
+		}
+	} else if req.BaseAmount != nil {
+		return validationError("tip_amount", ValidationCodeRequired, "tip amount is required with a base amount")
+	}
+
+	if req.ShippingAddr != nil {
+		if err := ValidateAddress(req.ShippingAddr); err != nil {
+			return nestValidationError("shipping_address", "invalid shipping address", err)
+		}
+	}
+
+	if req.StatementDescriptor != "" {
+		if err := validateStatementDescriptor(req.StatementDescriptor); err != nil {
+			return err
+		}
+	}
+
+	// Line items cover the goods, not the tip
+	if len(req.LineItems) > 0 {
+		if req.Amount == nil {
+			return validationError("amount", ValidationCodeRequired, "amount is required with line items")
+		}
+		total := *req.Amount
+		if req.BaseAmount != nil {
+			total = *req.BaseAmount
+		}
+		if err := validateLineItems(req.LineItems, total, req.Currency); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// validateTipAdjustment checks that a capture with a tip has a total amount
+// equal to the base amount plus the tip, within half a minor unit of the
+// currency to allow for floating point error
+func validateTipAdjustment(req *CaptureTransactionRequest) error {
+	if *req.TipAdjustment < 0 {
+		return sentinelError("tip_amount", ValidationCodeOutOfRange, ErrInvalidAmount, "tip amount cannot be negative")
+	}
+	if req.Amount == nil {
+		return validationError("amount", ValidationCodeRequired, "amount is required with a tip adjustment")
+	}
+	if req.BaseAmount == nil {
+		return validationError("base_amount", ValidationCodeRequired, "base amount is required with a tip adjustment")
+	}
+	if *req.BaseAmount <= 0 {
+		return sentinelError("base_amount", ValidationCodeOutOfRange, ErrInvalidAmount, "")
+	}
+
+	tolerance := 0.5 * math.Pow10(-CurrencyExponent(req.Currency))
+	if math.Abs(*req.Amount-(*req.BaseAmount+*req.TipAdjustment)) >= tolerance {
+		return validationError("amount", ValidationCodeConflict, "amount must equal the base amount plus the tip")
+	}
+	return nil
+}
+
+// MetadataLimits caps the size of request metadata. Zero fields use the
+// matching DefaultMetadataLimits value.
+type MetadataLimits struct {
+	MaxKeys        int
+	MaxKeyLength   int // In characters
+	MaxValueLength int // In characters
+}
+
+// DefaultMetadataLimits are the metadata limits enforced by the gateway
+var DefaultMetadataLimits = MetadataLimits{
+	MaxKeys:        50,
+	MaxKeyLength:   40,
+	MaxValueLength: 500,
+}
+
+// withDefaults returns the limits with unset fields filled in
+func (l MetadataLimits) withDefaults() MetadataLimits {
+	if l.MaxKeys == 0 {
+		l.MaxKeys = DefaultMetadataLimits.MaxKeys
+	}
+	if l.MaxKeyLength == 0 {
+		l.MaxKeyLength = DefaultMetadataLimits.MaxKeyLength
+	}
+	if l.MaxValueLength == 0 {
+		l.MaxValueLength = DefaultMetadataLimits.MaxValueLength
+	}
+	return l
+}
+
+// validateMetadata checks metadata against the limits. Errors about a
+// single entry name the offending key in the error field, e.g.
+// "metadata.order_id".
+func validateMetadata(metadata map[string]string, limits MetadataLimits) error {
+	if len(metadata) > limits.MaxKeys {
+		return validationError("metadata", ValidationCodeOutOfRange, fmt.Sprintf("metadata cannot have more than %d keys", limits.MaxKeys))
+	}
+
+	// Check keys in order so the same metadata always reports the same key
+	keys := make([]string, 0, len(metadata))
+	for key := range metadata {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		value := metadata[key]
+		if strings.TrimSpace(key) == "" {
+			return validationError("metadata", ValidationCodeInvalid, "metadata keys cannot be empty")
+		}
+		if utf8.RuneCountInString(key) > limits.MaxKeyLength {
+			return validationError("metadata."+key, ValidationCodeInvalidLength, fmt.Sprintf("metadata key %q exceeds %d characters", key, limits.MaxKeyLength))
+		}
+		if utf8.RuneCountInString(value) > limits.MaxValueLength {
+			return validationError("metadata."+key, ValidationCodeInvalidLength, fmt.Sprintf("metadata value for key %q exceeds %d characters", key, limits.MaxValueLength))
+		}
+	}
+	return nil
+}
+
+// maxCustomerIDLength is the longest customer ID the gateway accepts
+const maxCustomerIDLength = 255
+
+// ValidateCustomerID validates a customer ID used in a request path
+func ValidateCustomerID(customerID string) error {
+	if strings.TrimSpace(customerID) == "" {
+		return validationError("customer_id", ValidationCodeRequired, "customer ID cannot be empty")
+	}
+	if len(customerID) > maxCustomerIDLength {
+		return validationError("customer_id", ValidationCodeInvalidLength, fmt.Sprintf("customer ID cannot exceed %d characters", maxCustomerIDLength))
+	}
+	for _, r := range customerID {
+		if r <= ' ' || r == 0x7f {
+			return validationError("customer_id", ValidationCodeInvalid, "customer ID cannot contain whitespace or control characters")
+		}
+	}
+	return nil
+}
+
+// ValidateAddress validates a billing or shipping address
+func ValidateAddress(addr *Address) error {
+	if addr == nil {
+		return validationError("", ValidationCodeRequired, "address cannot be nil")
+	}
+
+	if strings.TrimSpace(addr.Line1) == "" {
+		return validationError("line1", ValidationCodeRequired, "address line 1 cannot be empty")
+	}
+
+	if strings.TrimSpace(addr.City) == "" {
+		return validationError("city", ValidationCodeRequired, "city cannot be empty")
+	}
+
+	return validateCountryCode(addr.Country)
+}
+
+// validateCountryCode checks that a country is an ISO 3166-1 alpha-2 code.
+// Use NormalizeCountryCode first to accept alpha-3 codes and names.
+func validateCountryCode(country string) error {
+	if strings.TrimSpace(country) == "" {
+		return validationError("country", ValidationCodeRequired, "country cannot be empty")
+	}
+	if !IsValidCountryCode(country) {
+		return validationError("country", ValidationCodeInvalid, fmt.Sprintf("country %q is not an ISO 3166-1 alpha-2 code", country))
+	}
+	return nil
+}
+
+// validateAddressCountries checks the country of the billing and shipping
+// addresses of a transaction or payment. The other address fields are
+// optional there, and so is the country.
+func validateAddressCountries(billing, shipping *Address) error {
+	if billing != nil && billing.Country != "" {
+		if err := validateCountryCode(billing.Country); err != nil {
+			return nestValidationError("billing_address", "invalid billing address", err)
+		}
+	}
+	if shipping != nil && shipping.Country != "" {
+		if err := validateCountryCode(shipping.Country); err != nil {
+			return nestValidationError("shipping_address", "invalid shipping address", err)
+		}
+	}
+	return nil
+}
+
+// maxStatementDescriptorLength is the longest descriptor card networks print
+// on statements
+const maxStatementDescriptorLength = 22
+
+// validateStatementDescriptor checks that a descriptor fits on a card
+// statement and only uses characters the networks accept
+func validateStatementDescriptor(descriptor string) error {
+	if len(descriptor) > maxStatementDescriptorLength {
+		return validationError("statement_descriptor", ValidationCodeInvalidLength,
+			fmt.Sprintf("statement descriptor cannot be longer than %d characters", maxStatementDescriptorLength))
+	}
+	return validateDescriptorChars("statement_descriptor", "statement descriptor", descriptor)
+}
+
+// validateDescriptorChars checks that a descriptor field only uses the
+// printable ASCII characters the networks accept
+func validateDescriptorChars(field, name, value string) error {
+	for _, r := range value {
+		if r < ' ' || r > '~' || strings.ContainsRune(`<>\'"*`, r) {
+			return validationError(field, ValidationCodeInvalid,
+				fmt.Sprintf("%s contains invalid character %q", name, r))
+		}
+	}
+	return nil
+}
+
+// maxDescriptorCityLength is the longest city card networks print next to
+// the descriptor
+const maxDescriptorCityLength = 13
+
+// descriptorPhoneRegex matches an E.164 phone number, with or without the
+// leading plus sign
+var descriptorPhoneRegex = regexp.MustCompile(`^\+?[1-9]\d{6,14}$`)
+
+// validateDescriptorContact checks the city and phone number printed next
+// to the descriptor, if given
+func validateDescriptorContact(city, phone string) error {
+	if len(city) > maxDescriptorCityLength {
+		return validationError("descriptor.city", ValidationCodeInvalidLength,
+			fmt.Sprintf("descriptor city cannot be longer than %d characters", maxDescriptorCityLength))
+	}
+	if err := validateDescriptorChars("descriptor.city", "descriptor city", city); err != nil {
+		return err
+	}
+
+	if phone != "" && !descriptorPhoneRegex.MatchString(phone) {
+		return validationError("descriptor.phone", ValidationCodeInvalid, "descriptor phone must be an E.164 number, e.g. +18005550100")
+	}
+	return nil
+}
+
+// normalizePhone strips the spaces, dashes, dots and parentheses people
+// format phone numbers with, e.g. "+1 (800) 555-0100" becomes "+18005550100"
+func normalizePhone(phone string) string {
+	return strings.Map(func(r rune) rune {
+		if strings.ContainsRune(" -.()", r) {
+			return -1
+		}
+		return r
+	}, strings.TrimSpace(phone))
+}
+
+// ValidateExpand validates the related resources requested for a transaction
+func ValidateExpand(expand []string) error {
+	for _, e := range expand {
+		switch e {
+		case ExpandRefunds, ExpandCaptures, ExpandDispute:
+		default:
+			return validationError("expand", ValidationCodeUnsupported, fmt.Sprintf("unsupported expand value %q", e))
+		}
+	}
 	return nil
 }
 
@@ -1,6 +1,10 @@
 package americanexpress
 
 import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
 	"testing"
 )
 
@@ -87,6 +91,73 @@ func TestValidateCardDetails(t *testing.T) {
 	}
 }
 
+func TestValidateCardDetailsBrandLengths(t *testing.T) {
+	tests := []struct {
+		name    string
+		number  string
+		cvv     string
+		wantErr error
+	}{
+		{"amex with CID", "378282246310005", "1234", nil},
+		{"amex with spaces", "3782 822463 10005", "1234", nil},
+		{"amex with 3-digit CVV", "378282246310005", "123", ErrInvalidCVV},
+		{"amex with 5-digit CID", "378282246310005", "12345", ErrInvalidCVV},
+		{"amex with 14 digits", "37828224631000", "1234", ErrInvalidCardNumber},
+		{"amex with 16 digits", "3782822463100051", "1234", ErrInvalidCardNumber},
+		{"visa with CVV", "4111111111111111", "123", nil},
+		{"visa with 4-digit CVV", "4111111111111111", "1234", ErrInvalidCVV},
+		{"mastercard with 4-digit CVV", "2221000000000009", "1234", ErrInvalidCVV},
+		{"unknown brand with CVV", "9111111111111111", "123", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateCardDetails(&CardDetails{Number: tt.number, ExpiryMonth: 12, ExpiryYear: 2025, CVV: tt.cvv, HolderName: "John Doe"})
+			if tt.wantErr == nil {
+				if err != nil {
+					t.Errorf("ValidateCardDetails() error = %v", err)
+				}
+				return
+			}
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("Expected %v, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestDetectCardBrand(t *testing.T) {
+	tests := []struct {
+		number string
+		want   CardBrand
+		amex   bool
+	}{
+		{"378282246310005", CardBrandAmex, true},
+		{"3400-000000-00009", CardBrandAmex, true},
+		{"3782822463100051", CardBrandAmex, false}, // Too long
+		{"35", CardBrandUnknown, false},
+		{"36000000000008", CardBrandUnknown, false},
+		{"4111111111111111", CardBrandVisa, false},
+		{"5105105105105100", CardBrandMastercard, false},
+		{"2221000000000009", CardBrandMastercard, false},
+		{"2720990000000000", CardBrandMastercard, false},
+		{"2220990000000000", CardBrandUnknown, false},
+		{"2721000000000000", CardBrandUnknown, false},
+		{"6011111111111117", CardBrandDiscover, false},
+		{"6445644564456445", CardBrandDiscover, false},
+		{"", CardBrandUnknown, false},
+	}
+
+	for _, tt := range tests {
+		if got := DetectCardBrand(tt.number); got != tt.want {
+			t.Errorf("DetectCardBrand(%q) = %q, want %q", tt.number, got, tt.want)
+		}
+		if got := IsAmexCard(tt.number); got != tt.amex {
+			t.Errorf("IsAmexCard(%q) = %v, want %v", tt.number, got, tt.amex)
+		}
+	}
+}
+
 func TestValidatePaymentRequest(t *testing.T) {
 	validCard := &CardDetails{
 		Number:      "4111111111111111",
@@ -96,6 +167,14 @@ func TestValidatePaymentRequest(t *testing.T) {
 		HolderName:  "John Doe",
 	}
 
+	validNetworkToken := &NetworkToken{
+		Token:       "4111111111111111",
+		Cryptogram:  "AgAAAAAABk4DWZ4C28yUQAAAAAA=",
+		ECI:         "05",
+		ExpiryMonth: 12,
+		ExpiryYear:  2027,
+	}
+
 	tests := []struct {
 		name    string
 		req     *PaymentRequest
@@ -158,6 +237,52 @@ func TestValidatePaymentRequest(t *testing.T) {
 			},
 			wantErr: true,
 		},
+		{
+			name: "valid request with network token",
+			req: &PaymentRequest{
+				Amount:       100.00,
+				Currency:     "USD",
+				MerchantID:   "merchant_123",
+				NetworkToken: validNetworkToken,
+			},
+			wantErr: false,
+		},
+		{
+			name: "network token without cryptogram",
+			req: &PaymentRequest{
+				Amount:     100.00,
+				Currency:   "USD",
+				MerchantID: "merchant_123",
+				NetworkToken: &NetworkToken{
+					Token:       "4111111111111111",
+					ExpiryMonth: 12,
+					ExpiryYear:  2027,
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "network token with card token",
+			req: &PaymentRequest{
+				Amount:       100.00,
+				Currency:     "USD",
+				MerchantID:   "merchant_123",
+				CardToken:    "token_123",
+				NetworkToken: validNetworkToken,
+			},
+			wantErr: true,
+		},
+		{
+			name: "network token with card details",
+			req: &PaymentRequest{
+				Amount:       100.00,
+				Currency:     "USD",
+				MerchantID:   "merchant_123",
+				CardDetails:  validCard,
+				NetworkToken: validNetworkToken,
+			},
+			wantErr: true,
+		},
 	}
 
 	for _, tt := range tests {
@@ -193,6 +318,40 @@ func TestIsSupportedCurrency(t *testing.T) {
 	}
 }
 
+func TestSupportedCurrencies(t *testing.T) {
+	currencies := SupportedCurrencies()
+	if !sort.StringsAreSorted(currencies) {
+		t.Errorf("Expected sorted currencies, got %v", currencies)
+	}
+	seen := make(map[string]bool)
+	for _, currency := range currencies {
+		if seen[currency] {
+			t.Errorf("Expected no duplicates, got %s twice", currency)
+		}
+		seen[currency] = true
+		if !IsSupportedCurrency(currency) {
+			t.Errorf("Expected %s to be supported", currency)
+		}
+	}
+
+	// Changing the returned slice must not change the supported currencies
+	currencies[0] = "XYZ"
+	if IsSupportedCurrency("XYZ") || SupportedCurrencies()[0] == "XYZ" {
+		t.Error("Expected the supported currencies to be unchanged")
+	}
+
+	set := newCurrencySet("usd", "USD", " eur ")
+	if len(set) != 2 {
+		t.Errorf("Expected 2 currencies, got %v", sortedKeys(set))
+	}
+}
+
+func BenchmarkIsSupportedCurrency(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		IsSupportedCurrency("clp")
+	}
+}
+
 func TestFormatAmount(t *testing.T) {
 	tests := []struct {
 		name   string
@@ -214,3 +373,851 @@ func TestFormatAmount(t *testing.T) {
 	}
 }
 
+func TestValidateEvidence(t *testing.T) {
+	tests := []struct {
+		name     string
+		evidence *Evidence
+		wantErr  bool
+	}{
+		{
+			name: "valid evidence",
+			evidence: &Evidence{
+				Rebuttal: "Goods were delivered to the cardholder",
+				Documents: []EvidenceDocument{
+					{ID: "doc_123", Type: "proof_of_delivery"},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name:     "nil evidence",
+			evidence: nil,
+			wantErr:  true,
+		},
+		{
+			name:     "empty rebuttal",
+			evidence: &Evidence{Rebuttal: "  "},
+			wantErr:  true,
+		},
+		{
+			name: "document without ID",
+			evidence: &Evidence{
+				Rebuttal:  "Goods were delivered to the cardholder",
+				Documents: []EvidenceDocument{{Type: "receipt"}},
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateEvidence(tt.evidence)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateEvidence() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateEvidenceFiles(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    EvidenceFile
+		wantErr bool
+	}{
+		{"valid PDF", EvidenceFile{Type: "receipt", FileName: "receipt.pdf", Content: strings.NewReader("%PDF-1.7")}, false},
+		{"content type from extension", EvidenceFile{Type: "receipt", FileName: "receipt.PNG", Content: strings.NewReader("png")}, false},
+		{"explicit content type", EvidenceFile{Type: "receipt", FileName: "receipt", ContentType: "image/jpeg; q=1", Content: strings.NewReader("jpg")}, false},
+		{"missing content", EvidenceFile{Type: "receipt", FileName: "receipt.pdf"}, true},
+		{"missing file name", EvidenceFile{Type: "receipt", Content: strings.NewReader("pdf")}, true},
+		{"missing type", EvidenceFile{FileName: "receipt.pdf", Content: strings.NewReader("pdf")}, true},
+		{"unsupported content type", EvidenceFile{Type: "receipt", FileName: "receipt.exe", Content: strings.NewReader("MZ")}, true},
+		{"too large", EvidenceFile{Type: "receipt", FileName: "receipt.pdf", Content: strings.NewReader(strings.Repeat("a", MaxEvidenceFileSize+1))}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateEvidenceFiles([]EvidenceFile{tt.file})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateEvidenceFiles() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestIsTestCard(t *testing.T) {
+	tests := []struct {
+		number   string
+		scenario string
+		ok       bool
+	}{
+		{"378282246310005", TestCardApproved, true},
+		{"3700 000000 00002", TestCardDeclined, true},
+		{"3400-000000-00009", TestCardInsufficientFunds, true},
+		{"371111111111114", TestCardLostOrStolen, true},
+		{"4111111111111111", "", false},
+		{"", "", false},
+	}
+
+	for _, tt := range tests {
+		scenario, ok := IsTestCard(tt.number)
+		if scenario != tt.scenario || ok != tt.ok {
+			t.Errorf("IsTestCard(%q) = %q, %v, want %q, %v", tt.number, scenario, ok, tt.scenario, tt.ok)
+		}
+	}
+
+	scenarios := TestCardScenarios()
+	if len(scenarios) != len(testCards) {
+		t.Errorf("Expected %d test cards, got %d", len(testCards), len(scenarios))
+	}
+	scenarios["378282246310005"] = TestCardDeclined
+	if scenario, _ := IsTestCard("378282246310005"); scenario != TestCardApproved {
+		t.Errorf("Expected changes to the returned map not to affect IsTestCard, got %q", scenario)
+	}
+
+	for number := range testCards {
+		if err := ValidateCardDetails(&CardDetails{Number: number, ExpiryMonth: 12, ExpiryYear: 2025, CVV: "1234", HolderName: "John Doe"}); err != nil {
+			t.Errorf("Expected test card %s to be valid, got %v", number, err)
+		}
+	}
+}
+
+func TestCardDetailsNormalize(t *testing.T) {
+	tests := []struct {
+		name       string
+		card       *CardDetails
+		wantNumber string
+		wantHolder string
+	}{
+		{
+			name:       "spaces",
+			card:       &CardDetails{Number: "3782 822463 10005", HolderName: "John Doe"},
+			wantNumber: "378282246310005",
+			wantHolder: "JOHN DOE",
+		},
+		{
+			name:       "dashes",
+			card:       &CardDetails{Number: "4111-1111-1111-1111", HolderName: "  jane   smith "},
+			wantNumber: "4111111111111111",
+			wantHolder: "JANE SMITH",
+		},
+		{
+			name:       "unicode name",
+			card:       &CardDetails{Number: "4111111111111111", HolderName: "José Müller-Øberg"},
+			wantNumber: "4111111111111111",
+			wantHolder: "JOSÉ MÜLLER-ØBERG",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			original := *tt.card
+			got := tt.card.Normalize()
+
+			if got.Number != tt.wantNumber {
+				t.Errorf("Normalize() number = %v, want %v", got.Number, tt.wantNumber)
+			}
+			if got.HolderName != tt.wantHolder {
+				t.Errorf("Normalize() holder name = %v, want %v", got.HolderName, tt.wantHolder)
+			}
+			if *tt.card != original {
+				t.Errorf("Normalize() modified the original card details: %+v", *tt.card)
+			}
+		})
+	}
+
+	var card *CardDetails
+	if card.Normalize() != nil {
+		t.Error("Expected Normalize() on nil card details to return nil")
+	}
+}
+
+func TestValidateEnrollmentRequest(t *testing.T) {
+	tests := []struct {
+		name    string
+		req     *EnrollmentRequest
+		wantErr bool
+	}{
+		{
+			name: "valid request with browser info",
+			req: &EnrollmentRequest{
+				Amount:     100.00,
+				Currency:   "USD",
+				MerchantID: "merchant_123",
+				CardToken:  "token_123",
+				Browser:    &BrowserInfo{UserAgent: "Mozilla/5.0", Language: "en-US"},
+			},
+			wantErr: false,
+		},
+		{
+			name:    "nil request",
+			req:     nil,
+			wantErr: true,
+		},
+		{
+			name: "missing payment method",
+			req: &EnrollmentRequest{
+				Amount:     100.00,
+				Currency:   "USD",
+				MerchantID: "merchant_123",
+				Device:     &DeviceInfo{Channel: "app"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "missing browser and device info",
+			req: &EnrollmentRequest{
+				Amount:     100.00,
+				Currency:   "USD",
+				MerchantID: "merchant_123",
+				CardToken:  "token_123",
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateEnrollmentRequest(tt.req)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateEnrollmentRequest() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestMergeMetadata(t *testing.T) {
+	defaults := map[string]string{"service": "checkout", "env": "prod"}
+
+	tests := []struct {
+		name     string
+		defaults map[string]string
+		metadata map[string]string
+		want     map[string]string
+	}{
+		{"both nil", nil, nil, nil},
+		{"defaults only", defaults, nil, map[string]string{"service": "checkout", "env": "prod"}},
+		{"request only", nil, map[string]string{"order": "123"}, map[string]string{"order": "123"}},
+		{
+			name:     "request keys take precedence",
+			defaults: defaults,
+			metadata: map[string]string{"env": "staging", "order": "123"},
+			want:     map[string]string{"service": "checkout", "env": "staging", "order": "123"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := mergeMetadata(tt.defaults, tt.metadata)
+			if len(got) != len(tt.want) || (got == nil) != (tt.want == nil) {
+				t.Fatalf("mergeMetadata() = %v, want %v", got, tt.want)
+			}
+			for k, v := range tt.want {
+				if got[k] != v {
+					t.Errorf("mergeMetadata()[%q] = %q, want %q", k, got[k], v)
+				}
+			}
+		})
+	}
+
+	if defaults["env"] != "prod" {
+		t.Error("Expected mergeMetadata() not to modify the defaults")
+	}
+}
+
+func TestValidateAmountPrecision(t *testing.T) {
+	tests := []struct {
+		name     string
+		amount   float64
+		currency string
+		wantErr  bool
+	}{
+		{"USD two decimals", 10.99, "USD", false},
+		{"USD one decimal", 10.1, "USD", false},
+		{"USD whole amount", 10, "USD", false},
+		{"USD three decimals", 10.999, "USD", true},
+		{"JPY whole amount", 1000, "JPY", false},
+		{"JPY with decimals", 1000.5, "jpy", true},
+		{"CLP with decimals", 500.25, "CLP", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateAmountPrecision(tt.amount, tt.currency)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateAmountPrecision() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, ErrInvalidAmount) {
+				t.Errorf("Expected ErrInvalidAmount, got %v", err)
+			}
+		})
+	}
+}
+
+func TestValidationError(t *testing.T) {
+	card := &CardDetails{
+		Number:      "4111111111111111",
+		ExpiryMonth: 12,
+		ExpiryYear:  2025,
+		CVV:         "12",
+		HolderName:  "John Doe",
+	}
+
+	tests := []struct {
+		name     string
+		err      error
+		field    string
+		code     string
+		message  string
+		sentinel error
+	}{
+		{
+			name:     "card field",
+			err:      ValidateCardDetails(card),
+			field:    "cvv",
+			code:     ValidationCodeInvalidLength,
+			message:  "invalid CVV",
+			sentinel: ErrInvalidCVV,
+		},
+		{
+			name:     "nested card field",
+			err:      ValidatePaymentRequest(&PaymentRequest{Amount: 10, Currency: "USD", MerchantID: "merchant_123", CardDetails: card}),
+			field:    "card_details.cvv",
+			code:     ValidationCodeInvalidLength,
+			message:  "invalid card details: invalid CVV",
+			sentinel: ErrInvalidCVV,
+		},
+		{
+			name:     "sentinel with detail",
+			err:      ValidateTransactionRequest(&TransactionRequest{Amount: 10, Currency: "US", MerchantID: "merchant_123", CardToken: "token_123"}),
+			field:    "currency",
+			code:     ValidationCodeInvalidLength,
+			message:  "invalid currency: currency must be 3 characters",
+			sentinel: ErrInvalidCurrency,
+		},
+		{
+			name:    "without sentinel",
+			err:     ValidateEvidence(&Evidence{Rebuttal: "Delivered", Documents: []EvidenceDocument{{Type: "receipt"}}}),
+			field:   "documents[0].id",
+			code:    ValidationCodeRequired,
+			message: "document 0: ID cannot be empty",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var ve *ValidationError
+			if !errors.As(tt.err, &ve) {
+				t.Fatalf("Expected a *ValidationError, got %v", tt.err)
+			}
+			if ve.Field != tt.field || ve.Code != tt.code {
+				t.Errorf("Expected field '%s' and code '%s', got '%s' and '%s'", tt.field, tt.code, ve.Field, ve.Code)
+			}
+			if tt.err.Error() != tt.message {
+				t.Errorf("Expected message '%s', got '%s'", tt.message, tt.err.Error())
+			}
+			if tt.sentinel != nil && !errors.Is(tt.err, tt.sentinel) {
+				t.Errorf("Expected error to match %v", tt.sentinel)
+			}
+		})
+	}
+}
+
+func TestValidateMerchantRequest(t *testing.T) {
+	tests := []struct {
+		name    string
+		req     *MerchantRequest
+		wantErr bool
+	}{
+		{
+			name: "valid request",
+			req: &MerchantRequest{
+				Name:    "Acme Store",
+				Email:   "billing@acme.example",
+				Website: "https://acme.example",
+				Address: &Address{Line1: "1 Main St", City: "Austin", Country: "US"},
+			},
+			wantErr: false,
+		},
+		{"nil request", nil, true},
+		{"missing name", &MerchantRequest{Email: "billing@acme.example"}, true},
+		{"invalid email", &MerchantRequest{Name: "Acme", Email: "not-an-email"}, true},
+		{"email with display name", &MerchantRequest{Name: "Acme", Email: "Acme <billing@acme.example>"}, true},
+		{"http website", &MerchantRequest{Name: "Acme", Website: "http://acme.example"}, true},
+		{"website without host", &MerchantRequest{Name: "Acme", Website: "https://"}, true},
+		{"unknown country", &MerchantRequest{Name: "Acme", Address: &Address{Country: "XX"}}, true},
+		{"alpha-3 country", &MerchantRequest{Name: "Acme", Address: &Address{Country: "USA"}}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateMerchantRequest(tt.req)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateMerchantRequest() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	// Updates only check the fields that are set
+	if err := ValidateMerchantUpdate(&UpdateMerchantRequest{Website: Ptr("https://acme.example")}); err != nil {
+		t.Errorf("ValidateMerchantUpdate() error = %v", err)
+	}
+	if err := ValidateMerchantUpdate(&UpdateMerchantRequest{Website: Ptr("ftp://acme.example")}); err == nil {
+		t.Error("Expected ValidateMerchantUpdate() to reject a non-https website")
+	}
+	if err := ValidateMerchantUpdate(&UpdateMerchantRequest{Website: Ptr(""), Email: Ptr("")}); err != nil {
+		t.Errorf("Expected ValidateMerchantUpdate() to allow clearing fields, got %v", err)
+	}
+	if err := ValidateMerchantUpdate(&UpdateMerchantRequest{Name: Ptr("")}); err == nil {
+		t.Error("Expected ValidateMerchantUpdate() to reject clearing the name")
+	}
+}
+
+func TestValidateCustomerRequest(t *testing.T) {
+	tests := []struct {
+		name    string
+		req     *CustomerRequest
+		wantErr bool
+	}{
+		{"valid request", &CustomerRequest{Email: "jane@example.com", Name: "Jane Doe", DefaultPaymentMethod: "tok_123"}, false},
+		{"nil request", nil, true},
+		{"missing email", &CustomerRequest{Name: "Jane Doe"}, true},
+		{"invalid email", &CustomerRequest{Email: "jane@"}, true},
+		{"email with display name", &CustomerRequest{Email: "Jane <jane@example.com>"}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateCustomerRequest(tt.req)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateCustomerRequest() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	// Updates only check the fields that are set
+	if err := ValidateCustomerUpdate(&UpdateCustomerRequest{Name: Ptr("Jane Smith")}); err != nil {
+		t.Errorf("ValidateCustomerUpdate() error = %v", err)
+	}
+	if err := ValidateCustomerUpdate(&UpdateCustomerRequest{Name: Ptr(""), DefaultPaymentMethod: Ptr("")}); err != nil {
+		t.Errorf("Expected ValidateCustomerUpdate() to allow clearing fields, got %v", err)
+	}
+	if err := ValidateCustomerUpdate(&UpdateCustomerRequest{Email: Ptr("")}); err == nil {
+		t.Error("Expected ValidateCustomerUpdate() to reject clearing the email")
+	}
+	err := ValidateCustomerUpdate(&UpdateCustomerRequest{Email: Ptr("not-an-email")})
+	var validationErr *ValidationError
+	if !errors.As(err, &validationErr) || validationErr.Field != "email" {
+		t.Errorf("Expected an email error from ValidateCustomerUpdate(), got %v", err)
+	}
+}
+
+func TestValidateSplits(t *testing.T) {
+	fee := func(f float64) *float64 { return &f }
+	tests := []struct {
+		name      string
+		splits    []PaymentSplit
+		wantField string
+		wantCode  string
+	}{
+		{"amounts add up", []PaymentSplit{{MerchantID: "seller_1", Amount: 60}, {MerchantID: "seller_2", Amount: 40}}, "", ""},
+		{"amounts plus fees add up", []PaymentSplit{{MerchantID: "seller_1", Amount: 57, Fee: fee(3)}, {MerchantID: "seller_2", Amount: 38, Fee: fee(2)}}, "", ""},
+		{"missing destination", []PaymentSplit{{Amount: 100}}, "splits[0].merchant_id", ValidationCodeRequired},
+		{"zero amount", []PaymentSplit{{MerchantID: "seller_1", Amount: 100}, {MerchantID: "seller_2"}}, "splits[1].amount", ValidationCodeOutOfRange},
+		{"negative fee", []PaymentSplit{{MerchantID: "seller_1", Amount: 101, Fee: fee(-1)}}, "splits[0].fee", ValidationCodeOutOfRange},
+		{"too many decimals", []PaymentSplit{{MerchantID: "seller_1", Amount: 99.995, Fee: fee(0.005)}}, "splits[0].amount", ValidationCodeTooManyDecimals},
+		{"short of the total", []PaymentSplit{{MerchantID: "seller_1", Amount: 60}, {MerchantID: "seller_2", Amount: 30}}, "splits", ValidationCodeConflict},
+		{"over the total", []PaymentSplit{{MerchantID: "seller_1", Amount: 95, Fee: fee(10)}}, "splits", ValidationCodeConflict},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := &TransactionRequest{
+				Amount:     100,
+				Currency:   "USD",
+				MerchantID: "platform_123",
+				CardToken:  "tok_123",
+				Splits:     tt.splits,
+			}
+			err := ValidateTransactionRequest(req)
+			if tt.wantField == "" {
+				if err != nil {
+					t.Errorf("ValidateTransactionRequest() error = %v", err)
+				}
+				return
+			}
+			var validationErr *ValidationError
+			if !errors.As(err, &validationErr) || validationErr.Field != tt.wantField || validationErr.Code != tt.wantCode {
+				t.Errorf("Expected %s error on %s, got %v", tt.wantCode, tt.wantField, err)
+			}
+		})
+	}
+
+	// Payments are checked the same way
+	err := ValidatePaymentRequest(&PaymentRequest{
+		Amount:     100,
+		Currency:   "USD",
+		MerchantID: "platform_123",
+		CardToken:  "tok_123",
+		Splits:     []PaymentSplit{{MerchantID: "seller_1", Amount: 90}},
+	})
+	if err == nil {
+		t.Error("Expected ValidatePaymentRequest() to reject splits short of the total")
+	}
+}
+
+func TestValidateDescriptorContact(t *testing.T) {
+	tests := []struct {
+		name      string
+		city      string
+		phone     string
+		wantField string
+	}{
+		{"omitted", "", "", ""},
+		{"city and phone", "NEW YORK", "+18005550100", ""},
+		{"phone without plus", "", "18005550100", ""},
+		{"longest city", "SAN FRANCISCO", "", ""},
+		{"city too long", "SAN FRANCISCOS", "", "descriptor.city"},
+		{"city with invalid character", "NEW*YORK", "", "descriptor.city"},
+		{"phone with letters", "", "+1800FLOWERS", "descriptor.phone"},
+		{"phone too short", "", "+123456", "descriptor.phone"},
+		{"phone too long", "", "+1234567890123456", "descriptor.phone"},
+		{"phone with leading zero", "", "+08005550100", "descriptor.phone"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateTransactionRequest(&TransactionRequest{
+				Amount:          100,
+				Currency:        "USD",
+				MerchantID:      "merchant_123",
+				CardToken:       "tok_123",
+				DescriptorCity:  tt.city,
+				DescriptorPhone: tt.phone,
+			})
+			if tt.wantField == "" {
+				if err != nil {
+					t.Errorf("ValidateTransactionRequest() error = %v", err)
+				}
+				return
+			}
+			var validationErr *ValidationError
+			if !errors.As(err, &validationErr) || validationErr.Field != tt.wantField {
+				t.Errorf("Expected an error on %s, got %v", tt.wantField, err)
+			}
+		})
+	}
+}
+
+func TestValidateAmountBreakdown(t *testing.T) {
+	tests := []struct {
+		name      string
+		amount    float64
+		base      *float64
+		tax       *float64
+		surcharge *float64
+		wantField string
+		wantCode  string
+	}{
+		{"no breakdown", 100, nil, nil, nil, "", ""},
+		{"base plus tax and surcharge", 111, Ptr(100.0), Ptr(8.0), Ptr(3.0), "", ""},
+		{"floating point sum", 0.3, Ptr(0.1), Ptr(0.2), nil, "", ""},
+		{"zero surcharge", 108, Ptr(100.0), Ptr(8.0), Ptr(0.0), "", ""},
+		{"tax without base", 108, nil, Ptr(8.0), nil, "", ""},
+		{"base only", 100, Ptr(100.0), nil, nil, "", ""},
+		{"sum short of the amount", 111.01, Ptr(100.0), Ptr(8.0), Ptr(3.0), "amount", ValidationCodeConflict},
+		{"sum over the amount", 110, Ptr(100.0), Ptr(8.0), Ptr(3.0), "amount", ValidationCodeConflict},
+		{"tax and surcharge leave no base", 11, nil, Ptr(8.0), Ptr(3.0), "amount", ValidationCodeConflict},
+		{"negative tax", 92, Ptr(100.0), Ptr(-8.0), nil, "tax_amount", ValidationCodeOutOfRange},
+		{"negative surcharge", 97, Ptr(100.0), nil, Ptr(-3.0), "surcharge_amount", ValidationCodeOutOfRange},
+		{"surcharge with too many decimals", 103.005, Ptr(100.0), nil, Ptr(3.005), "surcharge_amount", ValidationCodeTooManyDecimals},
+		{"zero base", 8, Ptr(0.0), Ptr(8.0), nil, "base_amount", ValidationCodeOutOfRange},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			errs := []error{
+				ValidateTransactionRequest(&TransactionRequest{Amount: tt.amount, Currency: "USD", MerchantID: "merchant_123", CardToken: "tok_123", BaseAmount: tt.base, TaxAmount: tt.tax, SurchargeAmount: tt.surcharge}),
+				ValidatePaymentRequest(&PaymentRequest{Amount: tt.amount, Currency: "USD", MerchantID: "merchant_123", CardToken: "tok_123", BaseAmount: tt.base, TaxAmount: tt.tax, SurchargeAmount: tt.surcharge}),
+			}
+			for _, err := range errs {
+				if tt.wantField == "" {
+					if err != nil {
+						t.Errorf("Expected a valid breakdown, got %v", err)
+					}
+					continue
+				}
+				var validationErr *ValidationError
+				if !errors.As(err, &validationErr) || validationErr.Field != tt.wantField || validationErr.Code != tt.wantCode {
+					t.Errorf("Expected %s error on %s, got %v", tt.wantCode, tt.wantField, err)
+				}
+			}
+		})
+	}
+}
+
+func TestValidateSettlement(t *testing.T) {
+	tests := []struct {
+		name      string
+		currency  string
+		amount    *float64
+		rate      float64
+		wantField string
+		wantCode  string
+	}{
+		{"no settlement", "", nil, 0, "", ""},
+		{"gateway rate", "EUR", nil, 0, "", ""},
+		{"merchant rate", "EUR", Ptr(92.15), 0.9215, "", ""},
+		{"rounded conversion", "EUR", Ptr(92.15), 0.92153, "", ""},
+		{"zero-decimal currency", "JPY", Ptr(15012.0), 150.123, "", ""},
+		{"amount without currency", "", Ptr(92.15), 0.9215, "settlement_currency", ValidationCodeRequired},
+		{"rate without currency", "", nil, 0.9215, "settlement_currency", ValidationCodeRequired},
+		{"short currency", "EU", nil, 0, "settlement_currency", ValidationCodeInvalidLength},
+		{"same currency", "usd", nil, 0, "settlement_currency", ValidationCodeConflict},
+		{"negative rate", "EUR", nil, -1, "exchange_rate", ValidationCodeOutOfRange},
+		{"amount without rate", "EUR", Ptr(92.15), 0, "exchange_rate", ValidationCodeRequired},
+		{"zero amount", "EUR", Ptr(0.0), 0.9215, "settlement_amount", ValidationCodeOutOfRange},
+		{"too many decimals", "JPY", Ptr(15012.3), 150.123, "settlement_amount", ValidationCodeTooManyDecimals},
+		{"inconsistent amount", "EUR", Ptr(92.17), 0.9215, "settlement_amount", ValidationCodeConflict},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateTransactionRequest(&TransactionRequest{
+				Amount:             100,
+				Currency:           "USD",
+				MerchantID:         "merchant_123",
+				CardToken:          "tok_123",
+				SettlementCurrency: tt.currency,
+				SettlementAmount:   tt.amount,
+				ExchangeRate:       tt.rate,
+			})
+			if tt.wantField == "" {
+				if err != nil {
+					t.Errorf("ValidateTransactionRequest() error = %v", err)
+				}
+				return
+			}
+			var validationErr *ValidationError
+			if !errors.As(err, &validationErr) || validationErr.Field != tt.wantField || validationErr.Code != tt.wantCode {
+				t.Errorf("Expected %s error on %s, got %v", tt.wantCode, tt.wantField, err)
+			}
+		})
+	}
+}
+
+func TestValidateCaptureRequest(t *testing.T) {
+	shipping := &Address{Line1: "1 Main St", City: "Austin", State: "TX", PostalCode: "78701", Country: "US"}
+
+	tests := []struct {
+		name    string
+		req     *CaptureTransactionRequest
+		wantErr bool
+	}{
+		{"empty request", &CaptureTransactionRequest{}, false},
+		{"shipping and descriptor", &CaptureTransactionRequest{ShippingAddr: shipping, StatementDescriptor: "ACME STORE #123"}, false},
+		{"nil request", nil, true},
+		{"negative amount", &CaptureTransactionRequest{Amount: &[]float64{-1}[0]}, true},
+		{"shipping without city", &CaptureTransactionRequest{ShippingAddr: &Address{Line1: "1 Main St", Country: "US"}}, true},
+		{"shipping with alpha-3 country", &CaptureTransactionRequest{ShippingAddr: &Address{Line1: "1 Main St", City: "Austin", Country: "USA"}}, true},
+		{"descriptor too long", &CaptureTransactionRequest{StatementDescriptor: "ACME STORE INTERNATIONAL"}, true},
+		{"descriptor with quote", &CaptureTransactionRequest{StatementDescriptor: `ACME "STORE"`}, true},
+		{"descriptor with non-ASCII", &CaptureTransactionRequest{StatementDescriptor: "CAFÉ"}, true},
+		{"tip", &CaptureTransactionRequest{Amount: &[]float64{60.10}[0], BaseAmount: &[]float64{50}[0], TipAdjustment: &[]float64{10.10}[0]}, false},
+		{"zero tip", &CaptureTransactionRequest{Amount: &[]float64{50}[0], BaseAmount: &[]float64{50}[0], TipAdjustment: &[]float64{0}[0]}, false},
+		{"tip not adding up", &CaptureTransactionRequest{Amount: &[]float64{60.11}[0], BaseAmount: &[]float64{50}[0], TipAdjustment: &[]float64{10.10}[0]}, true},
+		{"tip without amount", &CaptureTransactionRequest{BaseAmount: &[]float64{50}[0], TipAdjustment: &[]float64{10}[0]}, true},
+		{"tip without base amount", &CaptureTransactionRequest{Amount: &[]float64{60}[0], TipAdjustment: &[]float64{10}[0]}, true},
+		{"negative tip", &CaptureTransactionRequest{Amount: &[]float64{40}[0], BaseAmount: &[]float64{50}[0], TipAdjustment: &[]float64{-10}[0]}, true},
+		{"base amount without tip", &CaptureTransactionRequest{Amount: &[]float64{50}[0], BaseAmount: &[]float64{50}[0]}, true},
+		{"line items", &CaptureTransactionRequest{Amount: Ptr(59.97), LineItems: []LineItem{{SKU: "SKU-1", Quantity: 2, Amount: 39.98}, {SKU: "SKU-2", Quantity: 1, Amount: 19.99}}}, false},
+		{"line items with a tip", &CaptureTransactionRequest{Amount: Ptr(60.0), BaseAmount: Ptr(50.0), TipAdjustment: Ptr(10.0), LineItems: []LineItem{{SKU: "SKU-1", Quantity: 1, Amount: 50}}}, false},
+		{"line items without amount", &CaptureTransactionRequest{LineItems: []LineItem{{SKU: "SKU-1", Quantity: 1, Amount: 50}}}, true},
+		{"line items not adding up", &CaptureTransactionRequest{Amount: Ptr(60.0), LineItems: []LineItem{{SKU: "SKU-1", Quantity: 1, Amount: 50}}}, true},
+		{"line item without SKU", &CaptureTransactionRequest{Amount: Ptr(50.0), LineItems: []LineItem{{Quantity: 1, Amount: 50}}}, true},
+		{"line item without quantity", &CaptureTransactionRequest{Amount: Ptr(50.0), LineItems: []LineItem{{SKU: "SKU-1", Amount: 50}}}, true},
+		{"line item with zero amount", &CaptureTransactionRequest{Amount: Ptr(50.0), LineItems: []LineItem{{SKU: "SKU-1", Quantity: 1, Amount: 50}, {SKU: "SKU-2", Quantity: 1}}}, true},
+		{"line item with too many decimals", &CaptureTransactionRequest{Amount: Ptr(50.0), Currency: "JPY", LineItems: []LineItem{{SKU: "SKU-1", Quantity: 1, Amount: 49.5}, {SKU: "SKU-2", Quantity: 1, Amount: 0.5}}}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateCaptureRequest(tt.req)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateCaptureRequest() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestNormalizeCurrency(t *testing.T) {
+	tests := []struct {
+		currency string
+		want     string
+	}{
+		{"USD", "USD"},
+		{"usd", "USD"},
+		{"Eur", "EUR"},
+		{" gbp ", "GBP"},
+		{"", ""},
+	}
+
+	for _, tt := range tests {
+		if got := NormalizeCurrency(tt.currency); got != tt.want {
+			t.Errorf("NormalizeCurrency(%q) = %q, want %q", tt.currency, got, tt.want)
+		}
+	}
+}
+
+func TestValidateMetadata(t *testing.T) {
+	many := make(map[string]string)
+	for i := 0; i < 51; i++ {
+		many[fmt.Sprintf("key_%d", i)] = "value"
+	}
+
+	tests := []struct {
+		name     string
+		metadata map[string]string
+		wantCode string
+		field    string
+	}{
+		{"nil", nil, "", ""},
+		{"within limits", map[string]string{"order_id": "order_123", "note": strings.Repeat("a", 500)}, "", ""},
+		{"too many keys", many, ValidationCodeOutOfRange, "metadata"},
+		{"key too long", map[string]string{strings.Repeat("k", 41): "value"}, ValidationCodeInvalidLength, "metadata." + strings.Repeat("k", 41)},
+		{"value too long", map[string]string{"note": strings.Repeat("a", 501)}, ValidationCodeInvalidLength, "metadata.note"},
+		{"multi-byte value within limit", map[string]string{"note": strings.Repeat("é", 500)}, "", ""},
+		{"empty key", map[string]string{"": "value"}, ValidationCodeInvalid, "metadata"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateMetadata(tt.metadata, DefaultMetadataLimits)
+			if tt.wantCode == "" {
+				if err != nil {
+					t.Errorf("Expected no error, got %v", err)
+				}
+				return
+			}
+
+			var validationErr *ValidationError
+			if !errors.As(err, &validationErr) {
+				t.Fatalf("Expected *ValidationError, got %v", err)
+			}
+			if validationErr.Code != tt.wantCode || validationErr.Field != tt.field {
+				t.Errorf("Expected %s on %s, got %s on %s", tt.wantCode, tt.field, validationErr.Code, validationErr.Field)
+			}
+		})
+	}
+}
+
+func TestValidateTokenRequestBillingAddress(t *testing.T) {
+	card := &CardDetails{Number: "4111111111111111", ExpiryMonth: 12, ExpiryYear: 2025, CVV: "123", HolderName: "John Doe"}
+
+	tests := []struct {
+		name    string
+		addr    *Address
+		field   string
+		wantErr bool
+	}{
+		{"no address", nil, "", false},
+		{"valid address", &Address{Line1: "1 Main St", City: "Austin", PostalCode: "78701", Country: "US"}, "", false},
+		{"missing line1", &Address{City: "Austin", Country: "US"}, "billing_address.line1", true},
+		{"invalid country", &Address{Line1: "1 Main St", City: "Austin", Country: "XX"}, "billing_address.country", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateTokenRequest(&TokenRequest{CardDetails: card, BillingAddr: tt.addr})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ValidateTokenRequest() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			var validationErr *ValidationError
+			if tt.wantErr && (!errors.As(err, &validationErr) || validationErr.Field != tt.field) {
+				t.Errorf("Expected a *ValidationError on %s, got %v", tt.field, err)
+			}
+		})
+	}
+}
+
+func TestIsValidMCC(t *testing.T) {
+	tests := []struct {
+		code string
+		want bool
+	}{
+		{"5812", true},
+		{"0742", true},
+		{"3000", true},
+		{"3999", true},
+		{"9950", true},
+		{"3303", false},
+		{"0001", false},
+		{"581", false},
+		{"58120", false},
+		{"58a2", false},
+		{"", false},
+	}
+
+	for _, tt := range tests {
+		if got := IsValidMCC(tt.code); got != tt.want {
+			t.Errorf("IsValidMCC(%q) = %v, want %v", tt.code, got, tt.want)
+		}
+	}
+}
+
+func TestNormalizeCountryCode(t *testing.T) {
+	tests := []struct {
+		country string
+		want    string
+	}{
+		{"US", "US"},
+		{" us ", "US"},
+		{"USA", "US"},
+		{"gbr", "GB"},
+		{"United States", "US"},
+		{"united  states of america", "US"},
+		{"UK", "GB"},
+		{"South Korea", "KR"},
+		{"Côte d'Ivoire", "CI"},
+		{"Narnia", "Narnia"},
+		{"XXX", "XXX"},
+		{"", ""},
+	}
+
+	for _, tt := range tests {
+		if got := NormalizeCountryCode(tt.country); got != tt.want {
+			t.Errorf("NormalizeCountryCode(%q) = %q, want %q", tt.country, got, tt.want)
+		}
+	}
+}
+
+func TestValidateAddressCountries(t *testing.T) {
+	tests := []struct {
+		name    string
+		req     *PaymentRequest
+		field   string
+		wantErr bool
+	}{
+		{"no addresses", &PaymentRequest{}, "", false},
+		{"alpha-2 countries", &PaymentRequest{BillingAddr: &Address{Country: "US"}, ShippingAddr: &Address{Country: "CA"}}, "", false},
+		{"address without country", &PaymentRequest{BillingAddr: &Address{PostalCode: "78701"}}, "", false},
+		{"alpha-3 billing country", &PaymentRequest{BillingAddr: &Address{Country: "USA"}}, "billing_address.country", true},
+		{"country name on shipping", &PaymentRequest{ShippingAddr: &Address{Country: "United States"}}, "shipping_address.country", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := *tt.req
+			req.Amount, req.Currency, req.MerchantID, req.CardToken = 10, "USD", "merchant_123", "token_123"
+
+			err := ValidatePaymentRequest(&req)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ValidatePaymentRequest() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			var validationErr *ValidationError
+			if tt.wantErr && (!errors.As(err, &validationErr) || validationErr.Field != tt.field) {
+				t.Errorf("Expected a *ValidationError on %s, got %v", tt.field, err)
+			}
+		})
+	}
+}
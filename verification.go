@@ -0,0 +1,74 @@
+package americanexpress
+
+// CVVResult is the outcome of the card security code (CID) check. Codes the
+// SDK does not know yet decode unchanged and are treated as unverified.
+type CVVResult string
+
+// CVV results
+const (
+	CVVResultMatch       CVVResult = "Y"
+	CVVResultNoMatch     CVVResult = "N"
+	CVVResultUnavailable CVVResult = "U" // The issuer could not check the code
+	CVVResultNotChecked  CVVResult = ""  // No check was requested or the gateway omitted the result
+)
+
+// String returns the wire value of the result
+func (r CVVResult) String() string {
+	return string(r)
+}
+
+// Checked reports whether the issuer verified the security code, whatever
+// the outcome
+func (r CVVResult) Checked() bool {
+	return r == CVVResultMatch || r == CVVResultNoMatch
+}
+
+// Matched reports whether the security code matched
+func (r CVVResult) Matched() bool {
+	return r == CVVResultMatch
+}
+
+// AVSResult is the outcome of the address verification check. Codes the SDK
+// does not know yet decode unchanged and are treated as unverified.
+type AVSResult string
+
+// AVS results
+const (
+	AVSResultMatch          AVSResult = "Y" // Street address and postal code match
+	AVSResultAddressOnly    AVSResult = "A" // Street address matches, postal code doesn't
+	AVSResultPostalCodeOnly AVSResult = "Z" // Postal code matches, street address doesn't
+	AVSResultNoMatch        AVSResult = "N"
+	AVSResultUnavailable    AVSResult = "U" // The issuer could not check the address
+	AVSResultNotChecked     AVSResult = ""  // No check was requested or the gateway omitted the result
+)
+
+// String returns the wire value of the result
+func (r AVSResult) String() string {
+	return string(r)
+}
+
+// Checked reports whether the issuer verified the address, whatever the
+// outcome
+func (r AVSResult) Checked() bool {
+	switch r {
+	case AVSResultMatch, AVSResultAddressOnly, AVSResultPostalCodeOnly, AVSResultNoMatch:
+		return true
+	default:
+		return false
+	}
+}
+
+// Matched reports whether both the street address and postal code matched
+func (r AVSResult) Matched() bool {
+	return r == AVSResultMatch
+}
+
+// AddressMatched reports whether the street address matched
+func (r AVSResult) AddressMatched() bool {
+	return r == AVSResultMatch || r == AVSResultAddressOnly
+}
+
+// PostalCodeMatched reports whether the postal code matched
+func (r AVSResult) PostalCodeMatched() bool {
+	return r == AVSResultMatch || r == AVSResultPostalCodeOnly
+}